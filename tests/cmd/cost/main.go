@@ -0,0 +1,92 @@
+// Command cost prints the estimated monthly and per-test-hour cost of the plan fixture of every test with a budget,
+// and fails when one is over its budget. With -plan, it prints the cost of each resource of any plan instead.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/cost
+//	go run ./cmd/cost -test TestRunCompleteExample
+//	go run ./cmd/cost -plan plan.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/cost"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func main() {
+	moduleDir := flag.String("module-dir", "..", "root directory of the module")
+	pricesPath := flag.String("prices", "", "price table (default tests/cost-prices.yaml of the module)")
+	budgetsPath := flag.String("budgets", "", "budgets (default tests/cost-budgets.yaml of the module)")
+	test := flag.String("test", "", "only estimate the fixture of this test")
+	planPath := flag.String("plan", "", "plan JSON produced by `terraform show -json`, priced resource by resource")
+	flag.Parse()
+
+	if *pricesPath == "" {
+		*pricesPath = filepath.Join(*moduleDir, cost.PricesPath)
+	}
+	if *budgetsPath == "" {
+		*budgetsPath = filepath.Join(*moduleDir, cost.BudgetsPath)
+	}
+	prices, err := cost.LoadPrices(*pricesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *planPath != "" {
+		plan, err := tfplan.LoadPlan(*planPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Print(prices.EstimatePlan(plan).String())
+		return
+	}
+
+	budgets, err := cost.LoadBudgets(*budgetsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	tests := budgets.Tests()
+	if *test != "" {
+		if _, ok := budgets.Budgets[*test]; !ok {
+			fmt.Fprintf(os.Stderr, "no budget for %s in %s\n", *test, *budgetsPath)
+			os.Exit(2)
+		}
+		tests = []string{*test}
+	}
+
+	var over []string
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TEST\tFIXTURE\tMONTHLY\tPER TEST HOUR\tBUDGET")
+	for _, name := range tests {
+		budget := budgets.Budgets[name]
+		estimate, err := prices.EstimateFixture(*moduleDir, budget.Fixture)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		limit := fmt.Sprintf("%.2f", budget.Monthly)
+		if budget.Hourly > 0 {
+			limit += fmt.Sprintf(", %.4f per hour", budget.Hourly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.4f\t%s\n", name, budget.Fixture, estimate.Monthly(), estimate.Hourly(), limit)
+		for _, problem := range budget.Over(estimate) {
+			over = append(over, name+": "+problem)
+		}
+	}
+	_ = w.Flush()
+
+	if len(over) > 0 {
+		fmt.Printf("\n%d test(s) over budget:\n  %s\n", len(over), strings.Join(over, "\n  "))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,45 @@
+// Command failures classifies the Terraform errors of a saved Schematics job log or local apply output, printing the
+// failing resource, error code, request ID and class (quota, transient, iam, validation, module_bug) of each.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/failures -log job.log
+//	ibmcloud schematics logs --id <workspace> | go run ./cmd/failures
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/failures"
+)
+
+func main() {
+	rulesPath := flag.String("rules", "failure-classes.yaml", "failure classification rules")
+	logPath := flag.String("log", "", "log to classify (default standard input)")
+	flag.Parse()
+
+	rules, err := failures.LoadRules(*rulesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var data []byte
+	if *logPath == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*logPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	report := rules.Classify(string(data))
+	fmt.Print(report.String())
+	if len(report.Failures) > 0 {
+		os.Exit(1)
+	}
+}
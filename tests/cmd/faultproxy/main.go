@@ -0,0 +1,68 @@
+// Command faultproxy serves a scenario of fault-scenarios.yaml in front of the VPC API of a region, to apply any
+// configuration through it by hand. It prints the endpoint to give the provider, and the counts of the faults when
+// interrupted.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/faultproxy -scenario throttling -region us-south
+//	IBMCLOUD_IS_NG_API_ENDPOINT=<printed endpoint> terraform apply
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/faultproxy"
+)
+
+func main() {
+	scenariosPath := flag.String("scenarios", "fault-scenarios.yaml", "fault injection scenarios")
+	name := flag.String("scenario", "", "scenario to inject")
+	region := flag.String("region", "us-south", "region of the VPC API")
+	target := flag.String("target", "", "API to forward to (default the VPC API of -region)")
+	useTLS := flag.Bool("tls", false, "serve TLS with a self-signed certificate, written to -cert")
+	certPath := flag.String("cert", "faultproxy.pem", "file the certificate of the proxy is written to with -tls")
+	flag.Parse()
+
+	scenarios, err := faultproxy.LoadScenarios(*scenariosPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	scenario, ok := scenarios.Scenarios[*name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown scenario %q, one of %s\n", *name, strings.Join(scenarios.Names(), ", "))
+		os.Exit(2)
+	}
+	if *target == "" {
+		*target = fmt.Sprintf("https://%s.iaas.cloud.ibm.com", *region)
+	}
+	server, err := faultproxy.NewServer(*target, scenario.Faults, *useTLS)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defer server.Close()
+	if *useTLS {
+		if err := server.CertFile(*certPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("Certificate of the proxy written to %s\n", *certPath)
+	}
+	fmt.Printf("Injecting %s into %s: %s\n", *name, *target, scenario.Description)
+	fmt.Printf("IBMCLOUD_IS_NG_API_ENDPOINT=%s/v1\n", server.URL)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	fmt.Print("\n", server.Proxy.String())
+	if problems := server.Proxy.Verify(); len(problems) > 0 {
+		fmt.Printf("\n%s\n", strings.Join(problems, "\n"))
+		server.Close()
+		os.Exit(1)
+	}
+}
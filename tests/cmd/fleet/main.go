@@ -0,0 +1,76 @@
+// Command fleet generates the tfvars JSON file of every tier of a fleet spec, one call of the module per tier, with
+// the security group rules of the flows between tiers. The inputs are checked against the variables of the module
+// and the naming rules before they are written, nothing is written if one is rejected.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/fleet -spec fleet.yaml -out generated
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fleet"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "fleet spec YAML file")
+	outDir := flag.String("out", ".", "directory the <tier>.tfvars.json files are written to")
+	moduleDir := flag.String("module-dir", "..", "directory of the module the inputs are checked against")
+	flag.Parse()
+	if *specPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	spec, err := fleet.LoadSpec(*specPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	files, err := tfconfig.ParseDir(*moduleDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	variables, err := tfconfig.Variables(files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	vars := spec.Generate()
+	report := fleet.Check(vars, variables)
+	fmt.Print(report.String())
+	if report.Failed() {
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var tiers []string
+	for tier := range vars {
+		tiers = append(tiers, tier)
+	}
+	sort.Strings(tiers)
+	for _, tier := range tiers {
+		data, err := vars[tier].JSON()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		path := filepath.Join(*outDir, tier+".tfvars.json")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Println("wrote", path)
+	}
+}
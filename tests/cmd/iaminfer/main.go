@@ -0,0 +1,85 @@
+// Command iaminfer infers the IAM roles needed to deploy each catalog flavor from its plan, and reports the roles
+// missing from (or excessive in) the flavor's iam_permissions in ibm_catalog.json.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/iaminfer
+//	go run ./cmd/iaminfer -flavor fully-configurable -plan fully-configurable-plan.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/catalog"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/iaminfer"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func main() {
+	moduleDir := flag.String("module-dir", "..", "root directory of the module")
+	catalogPath := flag.String("catalog", "", "path to ibm_catalog.json (defaults to the one of the module)")
+	mapPath := flag.String("map", "", "path to the IAM permission map (defaults to "+iaminfer.MapPath+")")
+	flavorName := flag.String("flavor", "", "only check this flavor")
+	planPath := flag.String("plan", "", "plan JSON of the flavor (defaults to its plan fixture), requires -flavor")
+	strict := flag.Bool("strict", false, "also fail on excessive roles")
+	flag.Parse()
+
+	if *planPath != "" && *flavorName == "" {
+		fmt.Fprintln(os.Stderr, "-plan requires -flavor")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *catalogPath == "" {
+		*catalogPath = filepath.Join(*moduleDir, "ibm_catalog.json")
+	}
+	if *mapPath == "" {
+		*mapPath = filepath.Join(*moduleDir, iaminfer.MapPath)
+	}
+
+	m, err := iaminfer.LoadMap(*mapPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	manifest, err := catalog.LoadManifest(*catalogPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	checked := 0
+	failed := false
+	for _, product := range manifest.Products {
+		for _, flavor := range product.Flavors {
+			if *flavorName != "" && flavor.Name != *flavorName {
+				continue
+			}
+			checked++
+			path := *planPath
+			if path == "" {
+				path = fixtures.PlanPath(*moduleDir, iaminfer.PlanName(flavor))
+			}
+			plan, err := tfplan.LoadPlan(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			report := m.Compare(plan, flavor)
+			fmt.Print(report.String())
+			if len(report.Missing) > 0 || len(report.Unmapped) > 0 || (*strict && len(report.Excessive) > 0) {
+				failed = true
+			}
+		}
+	}
+	if checked == 0 {
+		fmt.Fprintf(os.Stderr, "no flavor %s in %s\n", *flavorName, *catalogPath)
+		os.Exit(2)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
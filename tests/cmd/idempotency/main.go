@@ -0,0 +1,46 @@
+// Command idempotency classifies the changes of a second plan (the re-plan after an apply, which the consistency
+// tests expect to be empty) by resource, attribute and known-issue signature, and fails only on changes without a
+// known signature.
+//
+// Usage (from the tests directory, after applying an example and planning it again):
+//
+//	terraform plan -out second.tfplan && terraform show -json second.tfplan > second-plan.json
+//	go run ./cmd/idempotency -plan second-plan.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/idempotency"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func main() {
+	planPath := flag.String("plan", "", "path to the second plan JSON produced by `terraform show -json`")
+	signaturesPath := flag.String("signatures", "idempotency-signatures.yaml", "path to the known-issue signatures")
+	flag.Parse()
+
+	if *planPath == "" {
+		fmt.Fprintln(os.Stderr, "-plan is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	signatures, err := idempotency.LoadSignatures(*signaturesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	plan, err := tfplan.LoadPlan(*planPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	report := idempotency.Classify(plan, signatures)
+	fmt.Print(report.String())
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
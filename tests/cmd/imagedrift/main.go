@@ -0,0 +1,56 @@
+// Command imagedrift reports VSIs that are not running the configured image and prints a rolling
+// replacement plan for them.
+//
+// Usage (from the tests directory):
+//
+//	terraform show -json > state.json
+//	go run ./cmd/imagedrift -state state.json -image-id r006-xxxx -plan -batch-size 2
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/imagedrift"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func main() {
+	statePath := flag.String("state", "", "path to the state JSON produced by `terraform show -json`")
+	imageID := flag.String("image-id", "", "the configured image_id")
+	catalogVersionCRN := flag.String("catalog-version-crn", "", "the configured catalog_offering.version_crn, for catalog images")
+	printPlan := flag.Bool("plan", false, "print a rolling replacement plan for the stale instances")
+	batchSize := flag.Int("batch-size", 1, "maximum number of instances replaced per batch")
+	minHealthy := flag.Int("min-healthy", 1, "number of healthy members each load balancer pool must keep")
+	failOnDrift := flag.Bool("fail-on-drift", false, "exit with status 1 if any instance is stale")
+	flag.Parse()
+
+	if *statePath == "" {
+		fmt.Fprintln(os.Stderr, "-state is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	state, err := tfplan.LoadState(*statePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	report, err := imagedrift.Analyze(state, imagedrift.Expected{ImageID: *imageID, CatalogVersionCRN: *catalogVersionCRN})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	report.WriteReport(os.Stdout)
+
+	if *printPlan {
+		fmt.Println()
+		imagedrift.WritePlan(os.Stdout, report.RolloutPlan(imagedrift.Options{BatchSize: *batchSize, MinHealthyPerPool: *minHealthy}))
+	}
+
+	if *failOnDrift && len(report.Stale()) > 0 {
+		os.Exit(1)
+	}
+}
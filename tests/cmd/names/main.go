@@ -0,0 +1,92 @@
+// Command names enumerates the names the module, or the fully-configurable solution, generates from a tfvars JSON
+// file, and fails when one is longer than 63 characters or does not match `^[a-z]([-a-z0-9]*[a-z0-9])?$`. With
+// -plan, it checks the names of a plan instead, and also fails when several resources (of several module calls) use
+// the same name in a VPC, subnet or region.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/names -inputs module.tfvars.json
+//	go run ./cmd/names -plan fixtures/plans/multi-profile-one-vpc.json
+//	go run ./cmd/names -solution fully-configurable -inputs catalog.tfvars.json
+//	go run ./cmd/names -solution fully-configurable -prefix landing-zone-production -all
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/names"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func main() {
+	inputsPath := flag.String("inputs", "", "tfvars JSON file with the inputs, defaults of the solution if empty")
+	solution := flag.String("solution", "module", "module or fully-configurable")
+	prefix := flag.String("prefix", "", "prefix overriding the one of the inputs")
+	all := flag.Bool("all", false, "print every generated name, not only the invalid ones")
+	planPath := flag.String("plan", "", "plan JSON produced by `terraform show -json`, checked instead of the inputs")
+	flag.Parse()
+
+	if *planPath != "" {
+		plan, err := tfplan.LoadPlan(*planPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		collisions := names.Collisions(plan)
+		report := names.Check(collisions.Names)
+		fmt.Print(report.String())
+		fmt.Print(collisions.String())
+		if report.Failed() || collisions.Failed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	var generated []names.Name
+	switch *solution {
+	case "module":
+		if *inputsPath == "" {
+			fmt.Fprintln(os.Stderr, "-inputs is required for the module")
+			flag.Usage()
+			os.Exit(2)
+		}
+		inputs, err := names.LoadInputs(*inputsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if *prefix != "" {
+			inputs.Prefix = *prefix
+		}
+		generated = inputs.Enumerate()
+	case "fully-configurable":
+		inputs := names.NewFullyConfigurable("")
+		if *inputsPath != "" {
+			var err error
+			if inputs, err = names.LoadFullyConfigurable(*inputsPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+		if *prefix != "" {
+			inputs.Prefix = prefix
+		}
+		generated = inputs.Enumerate()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown solution %q\n", *solution)
+		os.Exit(2)
+	}
+
+	if *all {
+		for _, name := range generated {
+			fmt.Printf("%s\t%d\t%s\n", name.Address, len(name.Value), name.Value)
+		}
+	}
+	report := names.Check(generated)
+	fmt.Print(report.String())
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
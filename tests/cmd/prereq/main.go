@@ -0,0 +1,124 @@
+// Command prereq provisions the prerequisites of a solution (tests/existing-resources) and adds their outputs to
+// the catalog validation values of the solution, or destroys them. The pre-validation and post-validation scripts of
+// tests/scripts run it in the catalog pipeline, with go run or the binary of PREREQ_BIN.
+//
+// Usage (from the tests directory, with VALIDATION_APIKEY or TF_VAR_ibmcloud_api_key set):
+//
+//	go run ./cmd/prereq provision -solution solutions/fully-configurable
+//	go run ./cmd/prereq teardown
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/prereq"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s provision|teardown [flags]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	// $VALIDATION_APIKEY is the API key of the catalog runtime
+	if os.Getenv("TF_VAR_ibmcloud_api_key") == "" && os.Getenv("VALIDATION_APIKEY") != "" {
+		if err := os.Setenv("TF_VAR_ibmcloud_api_key", os.Getenv("VALIDATION_APIKEY")); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "provision":
+		err = provision(os.Args[2:])
+	case "teardown":
+		err = teardown(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func provision(args []string) error {
+	flags := flag.NewFlagSet("provision", flag.ExitOnError)
+	moduleDir := flags.String("module-dir", "..", "root directory of the module")
+	solution := flags.String("solution", "solutions/fully-configurable", "solution whose catalog validation values are written")
+	region := flags.String("region", "us-south", "region of the prerequisites")
+	prefix := flags.String("prefix", "", "prefix of the prerequisites (defaults to vsi- and 4 random characters)")
+	createVPC := flags.Bool("create-vpc", true, "create the resource group and VPC, not only look up the image")
+	_ = flags.Parse(args)
+
+	if *prefix == "" {
+		var err error
+		if *prefix, err = prereq.RandomPrefix("vsi"); err != nil {
+			return err
+		}
+	}
+	tf := &prereq.CLI{
+		Dir:    filepath.Join(*moduleDir, prereq.Dir),
+		Config: prereq.Config{Prefix: *prefix, Region: *region, CreateVPC: *createVPC},
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	fmt.Println("Provisioning the prerequisite resource group and VPC ..")
+	outputs, err := prereq.Provision(context.Background(), tf)
+	if err != nil {
+		return err
+	}
+
+	valuesPath := filepath.Join(*moduleDir, *solution, prereq.ValuesFile)
+	values, err := os.ReadFile(valuesPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		// outside of the catalog pipeline, render the template the way the pipeline does
+		template, err := os.ReadFile(valuesPath + prereq.TemplateSuffix)
+		if err != nil {
+			return fmt.Errorf("error reading catalog validation values template: %w", err)
+		}
+		values, err = prereq.RenderTemplate(template, map[string]interface{}{
+			"VALIDATION_APIKEY": os.Getenv("TF_VAR_ibmcloud_api_key"),
+			"TAGS":              []string{},
+			"PREFIX":            *prefix,
+		})
+		if err != nil {
+			return fmt.Errorf("error rendering %s: %w", valuesPath+prereq.TemplateSuffix, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("error reading catalog validation values: %w", err)
+	}
+	values, err = prereq.MergeValues(values, outputs.SolutionInputs())
+	if err != nil {
+		return fmt.Errorf("%s: %w", valuesPath, err)
+	}
+	if err := os.WriteFile(valuesPath, values, 0o600); err != nil {
+		return fmt.Errorf("error writing catalog validation values: %w", err)
+	}
+	fmt.Printf("Added the prerequisite inputs to %s\n", valuesPath)
+	return nil
+}
+
+func teardown(args []string) error {
+	flags := flag.NewFlagSet("teardown", flag.ExitOnError)
+	moduleDir := flags.String("module-dir", "..", "root directory of the module")
+	_ = flags.Parse(args)
+
+	dir := filepath.Join(*moduleDir, prereq.Dir)
+	config, err := prereq.LoadConfig(filepath.Join(dir, prereq.VarFile))
+	if err != nil {
+		return err
+	}
+	fmt.Println("Destroying the prerequisite resource group and VPC ..")
+	return prereq.Teardown(context.Background(), &prereq.CLI{Dir: dir, Config: config, Stdout: os.Stdout, Stderr: os.Stderr})
+}
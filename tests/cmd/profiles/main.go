@@ -0,0 +1,86 @@
+// Command profiles checks the instance profile, boot volume and data volumes of a tfvars JSON file, or of every
+// instance of a plan, against the profile catalog, and prints the fix of each combination the API would reject. With
+// -refresh, it rewrites the catalog with the profiles of a region instead.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/profiles -inputs module.tfvars.json
+//	go run ./cmd/profiles -plan fixtures/plans/complete.json
+//	IBMCLOUD_API_KEY=... go run ./cmd/profiles -refresh -region us-south
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/profiles"
+)
+
+func main() {
+	catalogPath := flag.String("catalog", "machine-profiles.yaml", "profile catalog")
+	inputsPath := flag.String("inputs", "", "tfvars JSON file with the inputs of the module")
+	planPath := flag.String("plan", "", "plan JSON produced by `terraform show -json`, checked instead of the inputs")
+	refresh := flag.Bool("refresh", false, "rewrite the catalog with the profiles of -region, with IBMCLOUD_API_KEY")
+	region := flag.String("region", "us-south", "region the profiles are listed from")
+	flag.Parse()
+
+	catalog, err := profiles.LoadCatalog(*catalogPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var report *profiles.Report
+	switch {
+	case *refresh:
+		apiKey := os.Getenv("IBMCLOUD_API_KEY")
+		if apiKey == "" {
+			fmt.Fprintln(os.Stderr, "IBMCLOUD_API_KEY is required to refresh the catalog")
+			os.Exit(2)
+		}
+		client, err := profiles.NewClient(apiKey, *region)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		refreshed, err := profiles.Refresh(context.Background(), client, catalog)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		data, err := os.ReadFile(*catalogPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err := os.WriteFile(*catalogPath, refreshed.Render(profiles.Header(data)), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("%s: %d instance and %d volume profiles of %s\n", *catalogPath, len(refreshed.InstanceProfiles), len(refreshed.VolumeProfiles), *region)
+		return
+	case *planPath != "":
+		if report, err = catalog.CheckPlanFile(*planPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	case *inputsPath != "":
+		inputs, err := profiles.LoadInputs(*inputsPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		report = catalog.Check(*inputsPath, inputs)
+	default:
+		fmt.Fprintln(os.Stderr, "one of -inputs, -plan or -refresh is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	fmt.Print(report.String())
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
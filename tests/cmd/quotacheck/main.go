@@ -0,0 +1,74 @@
+// Command quotacheck estimates the quota the examples and solutions of a test run need from their plan fixtures,
+// and prints the batches that fit in the usage of a region, read from the VPC and resource controller APIs, and the
+// limits of quota-limits.yaml, and the tests that never fit.
+//
+// Usage (from the tests directory, with TF_VAR_ibmcloud_api_key set):
+//
+//	go run ./cmd/quotacheck -region us-south
+//	go run ./cmd/quotacheck -region eu-de complete multi-profile-one-vpc
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/quota"
+)
+
+func main() {
+	region := flag.String("region", "us-south", "region whose usage the tests are scheduled in")
+	moduleDir := flag.String("module-dir", "..", "root directory of the module")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [fixture...]\n\nFixtures are plan fixture names, defaults to all of them\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	apiKey := os.Getenv("TF_VAR_ibmcloud_api_key")
+	if apiKey == "" {
+		fmt.Fprintln(os.Stderr, "TF_VAR_ibmcloud_api_key is not set")
+		os.Exit(2)
+	}
+	limits, err := quota.LoadLimits(filepath.Join(*moduleDir, quota.LimitsPath))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	provider, err := quota.NewAPIProvider(apiKey, limits)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	usage, err := provider.Usage(context.Background(), *region)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	names := flag.Args()
+	if len(names) == 0 {
+		if names, err = fixtures.PlanNames(*moduleDir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	var tests []quota.Test
+	for _, name := range names {
+		demand, err := quota.LoadDemand(fixtures.PlanPath(*moduleDir, name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("%s: %s\n", name, demand)
+		tests = append(tests, quota.Test{Name: name, Demand: demand})
+	}
+	schedule := quota.Plan(tests, usage)
+	fmt.Print(schedule.String())
+	if len(schedule.Skipped) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+// Command retry prints the rule of retry-policy.yaml that retries the error of a saved Terraform output or Schematics
+// job log, with the backoff before each retry, to check a new rule against the error it was added for.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/retry -log apply.log
+//	ibmcloud schematics logs --id <workspace> | go run ./cmd/retry
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/retry"
+)
+
+func main() {
+	policyPath := flag.String("policy", "retry-policy.yaml", "retry policy")
+	logPath := flag.String("log", "", "output of the failed run (default standard input)")
+	flag.Parse()
+
+	policy, err := retry.LoadPolicy(*policyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var data []byte
+	if *logPath == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*logPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	rule := policy.Match(string(data), nil)
+	if rule == nil {
+		fmt.Println("No rule retries this error, it fails the run at once.")
+		os.Exit(1)
+	}
+	fmt.Printf("Rule %s: %s\n", rule.Name, rule.Description)
+	for retry := 1; retry <= policy.MaxRetriesOf(rule); retry++ {
+		fmt.Printf("  retry %d after %s\n", retry, policy.Backoff(rule, retry))
+	}
+}
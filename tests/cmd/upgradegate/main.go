@@ -0,0 +1,62 @@
+// Command upgradegate fails when an upgrade plan destroys or replaces data-bearing resources that are not
+// documented in the upgrade allow-list.
+//
+// Usage (from the tests directory, with the plan of the upgrade from the previous release):
+//
+//	terraform show -json upgrade.tfplan > upgrade-plan.json
+//	go run ./cmd/upgradegate -plan upgrade-plan.json -from v5.3.0
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/upgradegate"
+)
+
+func main() {
+	planPath := flag.String("plan", "", "path to the upgrade plan JSON produced by `terraform show -json`")
+	fromVersion := flag.String("from", "", "release the upgrade starts from (all allow-list entries apply if empty)")
+	allowListPath := flag.String("allowlist", "upgrade-safety-allowlist.yaml", "path to the upgrade allow-list")
+	moduleDir := flag.String("module-dir", "..", "directory of the module holding moved_config.tf")
+	flag.Parse()
+
+	if *planPath == "" {
+		fmt.Fprintln(os.Stderr, "-plan is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	allowList, err := upgradegate.LoadAllowList(*allowListPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	moved, err := tfconfig.MovedBlocks(*moduleDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := allowList.Validate(moved); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	plan, err := tfplan.LoadPlan(*planPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	report, err := upgradegate.Check(plan, allowList, *fromVersion)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Print(report.String())
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,73 @@
+// Command vardiff compares the input variables of the module, the fscloud profile and the solutions with a previous
+// revision and fails when the changes require a bigger release than allowed.
+//
+// Usage (from the tests directory):
+//
+//	go run ./cmd/vardiff -ref v5.3.0
+//	go run ./cmd/vardiff -ref v5.3.0 -max-bump major solutions/fully-configurable
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/vardiff"
+)
+
+func main() {
+	ref := flag.String("ref", "", "git ref to compare with (defaults to the most recent tag)")
+	moduleDir := flag.String("module-dir", "..", "root directory of the module")
+	maxBump := flag.String("max-bump", "minor", "highest release level allowed: major, minor or patch")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [dir...]\n\nDirectories are relative to the module root, defaults to %v\n\n", os.Args[0], vardiff.Dirs)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	allowed, err := vardiff.ParseLevel(*maxBump)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *ref == "" {
+		*ref = fixtures.PreviousReleaseRef(*moduleDir)
+		if *ref == "" {
+			fmt.Fprintln(os.Stderr, "no tag found, -ref is required")
+			os.Exit(2)
+		}
+	}
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = vardiff.Dirs
+	}
+
+	failed := false
+	for _, dir := range dirs {
+		old, found, err := vardiff.LoadDirAtRef(*moduleDir, *ref, dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if !found {
+			fmt.Printf("%s: does not exist at %s, skipped\n", dir, *ref)
+			continue
+		}
+		new, err := vardiff.LoadDir(filepath.Join(*moduleDir, dir))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		report := vardiff.Compare(old, new)
+		fmt.Printf("%s (since %s): %s", dir, *ref, report.String())
+		if report.Bump() > allowed {
+			failed = true
+		}
+	}
+	if failed {
+		fmt.Printf("The changes require a bigger release than %s.\n", allowed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,165 @@
+package catalog
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// AddonTest is the addon configuration of an addon test (cloudinfo.NewAddonConfigTerraform and the
+// cloudinfo.AddonConfig dependencies it overrides)
+type AddonTest struct {
+	OfferingName   string
+	OfferingFlavor string
+	Dependencies   []AddonRef
+}
+
+// AddonRef is a dependency set in the addon configuration of a test
+type AddonRef struct {
+	OfferingName   string
+	OfferingFlavor string
+	// Disabled is true when the test turns the dependency off (Enabled: core.BoolPtr(false))
+	Disabled bool
+	// Pos is the file:line of the dependency, for reports
+	Pos string
+}
+
+// ParseAddonTest extracts the addon configuration built in a test function of a Go file
+func ParseAddonTest(path string, function string) (*AddonTest, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	var body *ast.BlockStmt
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == function {
+			body = fn.Body
+		}
+	}
+	if body == nil {
+		return nil, fmt.Errorf("%s: function %s not found", path, function)
+	}
+
+	test := &AddonTest{}
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.CallExpr:
+			if isSelector(n.Fun, "cloudinfo", "NewAddonConfigTerraform") && len(n.Args) >= 3 {
+				test.OfferingName = stringLiteral(n.Args[1])
+				test.OfferingFlavor = stringLiteral(n.Args[2])
+			}
+		case *ast.CompositeLit:
+			if isSelector(n.Type, "cloudinfo", "AddonConfig") {
+				test.Dependencies = append(test.Dependencies, addonRef(fset, n))
+			}
+			// []cloudinfo.AddonConfig{{...}, {...}} elides the type of the elements
+			if array, ok := n.Type.(*ast.ArrayType); ok && isSelector(array.Elt, "cloudinfo", "AddonConfig") {
+				for _, element := range n.Elts {
+					if literal, ok := element.(*ast.CompositeLit); ok && literal.Type == nil {
+						test.Dependencies = append(test.Dependencies, addonRef(fset, literal))
+					}
+				}
+			}
+		}
+		return true
+	})
+	if test.OfferingName == "" {
+		return nil, fmt.Errorf("%s: %s does not call cloudinfo.NewAddonConfigTerraform with literal offering name and flavor", path, function)
+	}
+	return test, nil
+}
+
+func addonRef(fset *token.FileSet, literal *ast.CompositeLit) AddonRef {
+	ref := AddonRef{Pos: fset.Position(literal.Pos()).String()}
+	for _, element := range literal.Elts {
+		field, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := field.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "OfferingName":
+			ref.OfferingName = stringLiteral(field.Value)
+		case "OfferingFlavor":
+			ref.OfferingFlavor = stringLiteral(field.Value)
+		case "Enabled":
+			call, ok := field.Value.(*ast.CallExpr)
+			if ok && isSelector(call.Fun, "core", "BoolPtr") && len(call.Args) == 1 {
+				value, ok := call.Args[0].(*ast.Ident)
+				ref.Disabled = ok && value.Name == "false"
+			}
+		}
+	}
+	return ref
+}
+
+func isSelector(expr ast.Expr, pkg string, name string) bool {
+	selector, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := selector.X.(*ast.Ident)
+	return ok && ident.Name == pkg && selector.Sel.Name == name
+}
+
+func stringLiteral(expr ast.Expr) string {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return ""
+	}
+	value, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+// CheckDependencies checks that the offering and flavor of an addon test exist in the manifest, that the test sets or
+// explicitly disables every dependency the flavor declares, so a dependency added to the catalog is not deployed with
+// defaults the test never chose, and that the dependencies it sets are declared with their flavor
+func CheckDependencies(manifest *Manifest, test *AddonTest) []Finding {
+	flavor := manifest.Flavor(test.OfferingName, test.OfferingFlavor)
+	if flavor == nil {
+		return []Finding{{Flavor: test.OfferingFlavor, Key: test.OfferingName, Problem: "the addon test deploys a product flavor that is not in the catalog"}}
+	}
+	var findings []Finding
+	for _, dependency := range flavor.Dependencies {
+		set := false
+		for _, ref := range test.Dependencies {
+			set = set || ref.OfferingName == dependency.Name
+		}
+		if !set {
+			findings = append(findings, Finding{Flavor: flavor.Name, Key: dependency.Name, Problem: "declared dependency that the addon test neither sets nor disables"})
+		}
+	}
+	for _, ref := range test.Dependencies {
+		var declared *Dependency
+		for i := range flavor.Dependencies {
+			if flavor.Dependencies[i].Name == ref.OfferingName {
+				declared = &flavor.Dependencies[i]
+			}
+		}
+		switch {
+		case declared == nil:
+			findings = append(findings, Finding{Flavor: flavor.Name, Key: ref.OfferingName, Problem: fmt.Sprintf("configured by the addon test (%s) but not a declared dependency", ref.Pos)})
+		case !ref.Disabled && len(declared.Flavors) > 0 && !contains(declared.Flavors, ref.OfferingFlavor):
+			findings = append(findings, Finding{Flavor: flavor.Name, Key: ref.OfferingName, Problem: fmt.Sprintf("the addon test (%s) uses flavor %s, the dependency declares %v", ref.Pos, ref.OfferingFlavor, declared.Flavors)})
+		}
+	}
+	return findings
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+// Package catalog cross-checks the IBM Cloud catalog manifest (ibm_catalog.json) with the solutions it publishes:
+// every `configuration` entry of a flavor must be a variable of the flavor's working directory with a compatible
+// type, default, required flag and sensitivity, and every variable must be declared in the catalog.
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// platformInputs are set by the catalog and projects themselves, they do not need to be required in the configuration
+var platformInputs = map[string]bool{
+	"ibmcloud_api_key": true,
+}
+
+// Manifest is the part of ibm_catalog.json the checks need
+type Manifest struct {
+	Products []Product `json:"products"`
+}
+
+// Product is a catalog offering
+type Product struct {
+	Name    string   `json:"name"`
+	Flavors []Flavor `json:"flavors"`
+}
+
+// Flavor is a variation of an offering, deployed from WorkingDirectory
+type Flavor struct {
+	Name             string          `json:"name"`
+	WorkingDirectory string          `json:"working_directory"`
+	Configuration    []Input         `json:"configuration"`
+	IAMPermissions   []IAMPermission `json:"iam_permissions"`
+	Dependencies     []Dependency    `json:"dependencies"`
+}
+
+// Input is an entry of the `configuration` of a flavor
+type Input struct {
+	Key          string          `json:"key"`
+	Type         string          `json:"type"`
+	DefaultValue json.RawMessage `json:"default_value"`
+	Required     bool            `json:"required"`
+	// Virtual inputs are catalog only, they are not variables of the solution
+	Virtual bool `json:"virtual"`
+	Hidden  bool `json:"hidden"`
+}
+
+// IAMPermission is an entry of the `iam_permissions` of a flavor
+type IAMPermission struct {
+	ServiceName string   `json:"service_name"`
+	RoleCRNs    []string `json:"role_crns"`
+	Notes       string   `json:"notes"`
+}
+
+// Dependency is an offering deployed together with the flavor (an addon)
+type Dependency struct {
+	Name     string   `json:"name"`
+	ID       string   `json:"id"`
+	Version  string   `json:"version"`
+	Flavors  []string `json:"flavors"`
+	Optional bool     `json:"optional"`
+}
+
+// Finding is an inconsistency between a flavor of the catalog and its solution
+type Finding struct {
+	Flavor  string
+	Key     string
+	Problem string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Flavor, f.Key, f.Problem)
+}
+
+// LoadManifest reads ibm_catalog.json
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading catalog manifest %s: %w", path, err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("error parsing catalog manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Flavor returns the flavor of a product, nil if it does not exist
+func (m *Manifest) Flavor(product string, flavor string) *Flavor {
+	for i := range m.Products {
+		if m.Products[i].Name != product {
+			continue
+		}
+		for j := range m.Products[i].Flavors {
+			if m.Products[i].Flavors[j].Name == flavor {
+				return &m.Products[i].Flavors[j]
+			}
+		}
+	}
+	return nil
+}
+
+// CheckInputs compares the configuration of every flavor of the manifest with the variables of its working
+// directory (relative to root)
+func CheckInputs(manifest *Manifest, root string) ([]Finding, error) {
+	var findings []Finding
+	for _, product := range manifest.Products {
+		for _, flavor := range product.Flavors {
+			files, err := tfconfig.ParseDir(filepath.Join(root, flavor.WorkingDirectory))
+			if err != nil {
+				return nil, fmt.Errorf("flavor %s: %w", flavor.Name, err)
+			}
+			variables, err := tfconfig.Variables(files)
+			if err != nil {
+				return nil, fmt.Errorf("flavor %s: %w", flavor.Name, err)
+			}
+			findings = append(findings, CheckFlavor(flavor, variables)...)
+		}
+	}
+	return findings, nil
+}
+
+// CheckFlavor compares the configuration of a flavor with the variables of its solution
+func CheckFlavor(flavor Flavor, variables []tfconfig.Variable) []Finding {
+	var findings []Finding
+	report := func(key string, format string, args ...interface{}) {
+		findings = append(findings, Finding{Flavor: flavor.Name, Key: key, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	byName := map[string]tfconfig.Variable{}
+	for _, variable := range variables {
+		byName[variable.Name] = variable
+	}
+	seen := map[string]bool{}
+	for _, input := range flavor.Configuration {
+		if seen[input.Key] {
+			report(input.Key, "declared more than once in the configuration")
+			continue
+		}
+		seen[input.Key] = true
+
+		variable, ok := byName[input.Key]
+		if !ok {
+			if !input.Virtual {
+				report(input.Key, "orphaned, not a variable of %s (set \"virtual\": true for catalog only inputs)", flavor.WorkingDirectory)
+			}
+			continue
+		}
+		if input.Virtual {
+			report(input.Key, "marked virtual but is a variable of %s", flavor.WorkingDirectory)
+		}
+		if input.Type != "" && !compatibleType(input.Type, variable) {
+			report(input.Key, "catalog type %s does not match the variable type %s", input.Type, typeName(variable.Type))
+		}
+		if input.Type == "password" && !variable.Sensitive {
+			report(input.Key, "catalog type password but the variable is not sensitive")
+		}
+		if input.Type != "" && !secureTypes[input.Type] && variable.Sensitive {
+			report(input.Key, "the variable is sensitive but the catalog type %s shows its value, use password", input.Type)
+		}
+		if !variable.HasDefault && !input.Required && input.DefaultValue == nil && !platformInputs[input.Key] {
+			report(input.Key, "the variable has no default, the catalog must mark it required or give a default_value")
+		}
+		if input.DefaultValue != nil && variable.HasDefault {
+			if same, known := sameDefault(input.DefaultValue, variable); known && !same {
+				report(input.Key, "catalog default %s does not match the variable default %s", string(input.DefaultValue), variable.DefaultText)
+			}
+		}
+	}
+	for _, variable := range variables {
+		if !seen[variable.Name] {
+			report(variable.Name, "undeclared, the variable is missing from the catalog configuration")
+		}
+	}
+	return findings
+}
+
+// secureTypes are the catalog input types whose value the catalog hides
+var secureTypes = map[string]bool{
+	"password":               true,
+	"multiline_secure_value": true,
+}
+
+// compatibleType maps catalog input types to Terraform types
+func compatibleType(catalogType string, variable tfconfig.Variable) bool {
+	t := variable.Type
+	if t.Equals(cty.DynamicPseudoType) {
+		return true
+	}
+	switch catalogType {
+	case "string", "multiline_secure_value":
+		return t.Equals(cty.String)
+	case "password":
+		return t.Equals(cty.String)
+	case "boolean":
+		return t.Equals(cty.Bool)
+	case "number", "int", "float":
+		return t.Equals(cty.Number)
+	case "array":
+		return t.IsListType() || t.IsSetType() || t.IsTupleType()
+	case "object", "map":
+		return t.IsObjectType() || t.IsMapType()
+	default:
+		return false
+	}
+}
+
+func typeName(t cty.Type) string {
+	if t.Equals(cty.DynamicPseudoType) {
+		return "any"
+	}
+	return t.FriendlyNameForConstraint()
+}
+
+// sameDefault compares a catalog default with the default of the variable. known is false when the variable default
+// can not be evaluated offline (it references functions or other variables).
+func sameDefault(catalogDefault json.RawMessage, variable tfconfig.Variable) (same bool, known bool) {
+	if variable.Default == cty.NilVal || !variable.Default.IsWhollyKnown() {
+		return false, false
+	}
+	if variable.Default.IsNull() {
+		return string(catalogDefault) == "null" || string(catalogDefault) == `"__NULL__"`, true
+	}
+	var catalogValue interface{}
+	if err := json.Unmarshal(catalogDefault, &catalogValue); err != nil {
+		return false, true
+	}
+	// convert the variable default to its JSON form so lists, maps and numbers compare like the catalog ones
+	data, err := ctyjson.Marshal(variable.Default, variable.Default.Type())
+	if err != nil {
+		return false, false
+	}
+	var variableValue interface{}
+	if err := json.Unmarshal(data, &variableValue); err != nil {
+		return false, false
+	}
+	return reflect.DeepEqual(catalogValue, variableValue), true
+}
+
+// DependencyNames returns the names of the dependencies of a flavor, sorted
+func (f *Flavor) DependencyNames() []string {
+	names := make([]string, 0, len(f.Dependencies))
+	for _, dependency := range f.Dependencies {
+		names = append(names, dependency.Name)
+	}
+	sort.Strings(names)
+	return names
+}
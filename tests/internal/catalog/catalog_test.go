@@ -0,0 +1,77 @@
+package catalog
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+func findingStrings(findings []Finding) []string {
+	var list []string
+	for _, finding := range findings {
+		list = append(list, finding.String())
+	}
+	return list
+}
+
+func TestCheckInputs(t *testing.T) {
+	manifest, err := LoadManifest("testdata/ibm_catalog.json")
+	require.NoError(t, err)
+
+	findings, err := CheckInputs(manifest, "testdata")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"fully-configurable: image_id: the variable has no default, the catalog must mark it required or give a default_value",
+		`fully-configurable: machine_type: catalog default "bx2-2x8" does not match the variable default "cx2-2x4"`,
+		"fully-configurable: vsi_per_subnet: catalog type string does not match the variable type number",
+		"fully-configurable: kms_api_key: catalog type password but the variable is not sensitive",
+		"fully-configurable: logging_api_key: the variable is sensitive but the catalog type string shows its value, use password",
+		"fully-configurable: resource_tags: declared more than once in the configuration",
+		`fully-configurable: existing_vpc_crn: orphaned, not a variable of solution (set "virtual": true for catalog only inputs)`,
+		"fully-configurable: user_data: undeclared, the variable is missing from the catalog configuration",
+	}, findingStrings(findings))
+}
+
+func TestCheckDependencies(t *testing.T) {
+	manifest, err := LoadManifest("testdata/ibm_catalog.json")
+	require.NoError(t, err)
+
+	test, err := ParseAddonTest("testdata/addon_test.go", "TestAddonDefaultConfiguration")
+	require.NoError(t, err)
+	assert.Equal(t, "deploy-arch-ibm-slz-vsi", test.OfferingName)
+	assert.Len(t, test.Dependencies, 4)
+	assert.True(t, test.Dependencies[3].Disabled)
+	assert.Equal(t, []string{
+		"fully-configurable: deploy-arch-ibm-activity-tracker: declared dependency that the addon test neither sets nor disables",
+		"fully-configurable: deploy-arch-ibm-cloud-monitoring: the addon test (testdata/addon_test.go:15:3) uses flavor security-enforced, the dependency declares [fully-configurable]",
+		"fully-configurable: deploy-arch-ibm-secrets-manager: configured by the addon test (testdata/addon_test.go:19:3) but not a declared dependency",
+	}, findingStrings(CheckDependencies(manifest, test)))
+
+	test, err = ParseAddonTest("testdata/addon_test.go", "TestAddonOtherFlavor")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"security-enforced: deploy-arch-ibm-slz-vsi: the addon test deploys a product flavor that is not in the catalog",
+	}, findingStrings(CheckDependencies(manifest, test)))
+
+	_, err = ParseAddonTest("testdata/addon_test.go", "TestMissing")
+	assert.Error(t, err)
+}
+
+// TestCatalogManifest checks ibm_catalog.json against the variables of the solutions and the addon test
+func TestCatalogManifest(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	manifest, err := LoadManifest(filepath.Join(root, "ibm_catalog.json"))
+	require.NoError(t, err)
+
+	findings, err := CheckInputs(manifest, root)
+	require.NoError(t, err)
+	assert.Empty(t, findingStrings(findings))
+
+	test, err := ParseAddonTest(filepath.Join(root, "tests", "pr_test.go"), "TestAddonDefaultConfiguration")
+	require.NoError(t, err)
+	assert.Empty(t, findingStrings(CheckDependencies(manifest, test)))
+}
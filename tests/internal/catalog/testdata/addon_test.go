@@ -0,0 +1,32 @@
+package test
+
+func TestAddonDefaultConfiguration(t *testing.T) {
+	options.AddonConfig = cloudinfo.NewAddonConfigTerraform(
+		options.Prefix,
+		"deploy-arch-ibm-slz-vsi",
+		"fully-configurable",
+		map[string]interface{}{},
+	)
+	options.AddonConfig.Dependencies = []cloudinfo.AddonConfig{
+		{
+			OfferingName:   "deploy-arch-ibm-kms",
+			OfferingFlavor: "fully-configurable",
+		},
+		{
+			OfferingName:   "deploy-arch-ibm-cloud-monitoring",
+			OfferingFlavor: "security-enforced",
+		},
+		{
+			OfferingName:   "deploy-arch-ibm-secrets-manager",
+			OfferingFlavor: "fully-configurable",
+		},
+		{
+			OfferingName: "deploy-arch-ibm-cloud-logs",
+			Enabled:      core.BoolPtr(false),
+		},
+	}
+}
+
+func TestAddonOtherFlavor(t *testing.T) {
+	options.AddonConfig = cloudinfo.NewAddonConfigTerraform(options.Prefix, "deploy-arch-ibm-slz-vsi", "security-enforced", nil)
+}
@@ -0,0 +1,48 @@
+variable "ibmcloud_api_key" {
+  type      = string
+  sensitive = true
+}
+
+variable "prefix" {
+  type = string
+}
+
+variable "image_id" {
+  type = string
+}
+
+variable "machine_type" {
+  type    = string
+  default = "cx2-2x4"
+}
+
+variable "vsi_per_subnet" {
+  type    = number
+  default = 1
+}
+
+variable "enable_floating_ip" {
+  type    = bool
+  default = false
+}
+
+variable "kms_api_key" {
+  type    = string
+  default = null
+}
+
+variable "logging_api_key" {
+  type      = string
+  default   = null
+  sensitive = true
+}
+
+variable "resource_tags" {
+  type    = list(string)
+  default = []
+}
+
+variable "user_data" {
+  type    = string
+  default = null
+}
@@ -0,0 +1,94 @@
+package cost
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"gopkg.in/yaml.v3"
+)
+
+// Budget is the maximum cost of the resources a test applies
+type Budget struct {
+	// Fixture is the plan fixture of tests/fixtures/plans the test applies
+	Fixture string  `yaml:"fixture"`
+	Monthly float64 `yaml:"monthly"`
+	// Hourly is the maximum cost per hour the test keeps the resources, 0 for no limit
+	Hourly float64 `yaml:"hourly"`
+}
+
+// Budgets is the content of the budgets file, keyed by test name
+type Budgets struct {
+	Budgets map[string]Budget `yaml:"budgets"`
+}
+
+// LoadBudgets reads the budgets file
+func LoadBudgets(path string) (*Budgets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading budgets %s: %w", path, err)
+	}
+	budgets := &Budgets{}
+	if err := yaml.Unmarshal(data, budgets); err != nil {
+		return nil, fmt.Errorf("error parsing budgets %s: %w", path, err)
+	}
+	if err := budgets.Validate(); err != nil {
+		return nil, fmt.Errorf("budgets %s: %w", path, err)
+	}
+	return budgets, nil
+}
+
+// Validate checks every budget has a fixture and a monthly limit
+func (b *Budgets) Validate() error {
+	var problems []string
+	for _, test := range b.Tests() {
+		budget := b.Budgets[test]
+		if budget.Fixture == "" {
+			problems = append(problems, test+": no fixture")
+		}
+		if budget.Monthly <= 0 {
+			problems = append(problems, test+": monthly must be positive")
+		}
+		if budget.Hourly < 0 {
+			problems = append(problems, test+": hourly can not be negative")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid budgets:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Tests returns the names of the tests with a budget, sorted
+func (b *Budgets) Tests() []string {
+	tests := make([]string, 0, len(b.Budgets))
+	for test := range b.Budgets {
+		tests = append(tests, test)
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// Over returns why an estimate exceeds the budget, nil when it does not
+func (b Budget) Over(estimate *Estimate) []string {
+	var over []string
+	if monthly := estimate.Monthly(); monthly > b.Monthly {
+		over = append(over, fmt.Sprintf("$%.2f per month above the budget of $%.2f", monthly, b.Monthly))
+	}
+	if hourly := estimate.Hourly(); b.Hourly > 0 && hourly > b.Hourly {
+		over = append(over, fmt.Sprintf("$%.4f per test hour above the budget of $%.4f", hourly, b.Hourly))
+	}
+	return over
+}
+
+// EstimateFixture prices the plan fixture of a budget, root being the root of the module
+func (p *Prices) EstimateFixture(root string, fixture string) (*Estimate, error) {
+	plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, fixture))
+	if err != nil {
+		return nil, err
+	}
+	return p.EstimatePlan(plan), nil
+}
@@ -0,0 +1,330 @@
+// Package cost estimates the monthly and hourly cost of the resources a plan creates or keeps (instances and their
+// boot volumes, data volumes, floating IPs, load balancers and snapshots) from a checked-in price table
+// (tests/cost-prices.yaml), and checks the estimate of the plan fixture of a test against its budget
+// (tests/cost-budgets.yaml) before the test applies it.
+package cost
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"gopkg.in/yaml.v3"
+)
+
+// PricesPath and BudgetsPath are the paths of the price table and of the budgets, relative to the root of the module
+const (
+	PricesPath  = "tests/cost-prices.yaml"
+	BudgetsPath = "tests/cost-budgets.yaml"
+)
+
+// Load balancer kinds of the price table
+const (
+	Application = "application"
+	Network     = "network"
+)
+
+// profileSize reads the vCPUs and memory of an instance profile from its name (`bx2-4x16`, `gx3-16x80x1l4`)
+var profileSize = regexp.MustCompile(`^[a-z0-9]+-(\d+)x(\d+)`)
+
+// Prices is the content of the price table
+type Prices struct {
+	HoursPerMonth       float64                 `yaml:"hours_per_month"`
+	InstanceProfiles    map[string]float64      `yaml:"instance_profiles"`
+	InstanceDefault     InstanceDefault         `yaml:"instance_default"`
+	VolumeProfiles      map[string]VolumePrices `yaml:"volume_profiles"`
+	DefaultBootVolumeGB int                     `yaml:"default_boot_volume_gb"`
+	FloatingIP          float64                 `yaml:"floating_ip"`
+	LoadBalancers       map[string]float64      `yaml:"load_balancers"`
+	SnapshotGB          float64                 `yaml:"snapshot_gb"`
+}
+
+// InstanceDefault prices an instance profile missing from the table
+type InstanceDefault struct {
+	VCPU     float64 `yaml:"vcpu"`
+	MemoryGB float64 `yaml:"memory_gb"`
+}
+
+// VolumePrices are the monthly prices of a volume profile
+type VolumePrices struct {
+	GB   float64 `yaml:"gb"`
+	IOPS float64 `yaml:"iops"`
+}
+
+// LoadPrices reads the price table
+func LoadPrices(path string) (*Prices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading price table %s: %w", path, err)
+	}
+	prices := &Prices{}
+	if err := yaml.Unmarshal(data, prices); err != nil {
+		return nil, fmt.Errorf("error parsing price table %s: %w", path, err)
+	}
+	if err := prices.Validate(); err != nil {
+		return nil, fmt.Errorf("price table %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// Validate checks the table has the prices the estimate needs
+func (p *Prices) Validate() error {
+	var problems []string
+	if p.HoursPerMonth <= 0 {
+		problems = append(problems, "hours_per_month must be positive")
+	}
+	if p.InstanceDefault.VCPU <= 0 || p.InstanceDefault.MemoryGB <= 0 {
+		problems = append(problems, "instance_default needs a vcpu and a memory_gb price")
+	}
+	if _, ok := p.VolumeProfiles["general-purpose"]; !ok {
+		problems = append(problems, "no price for the general-purpose volume profile of the boot volumes")
+	}
+	if p.DefaultBootVolumeGB <= 0 {
+		problems = append(problems, "default_boot_volume_gb must be positive")
+	}
+	for _, kind := range []string{Application, Network} {
+		if _, ok := p.LoadBalancers[kind]; !ok {
+			problems = append(problems, "no price for the "+kind+" load balancers")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid price table:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Line is the cost of a resource, or of the boot volume of an instance
+type Line struct {
+	Address string
+	Type    string
+	// Detail is what is priced (`cx2-2x4`, `10iops-tier 100 GB`)
+	Detail  string
+	Monthly float64
+	// Estimated is true when a price or a size is not the one of the resource: an instance profile missing from the
+	// table, a volume profile without price, a size only known after the apply
+	Estimated bool
+}
+
+// Estimate is the cost of the resources of a plan
+type Estimate struct {
+	Lines         []Line
+	HoursPerMonth float64
+}
+
+// Monthly returns the cost of the resources for a month
+func (e *Estimate) Monthly() float64 {
+	total := 0.0
+	for _, line := range e.Lines {
+		total += line.Monthly
+	}
+	return total
+}
+
+// Hourly returns the cost of the resources for an hour, what a test costs for each hour it keeps them
+func (e *Estimate) Hourly() float64 {
+	return e.Monthly() / e.HoursPerMonth
+}
+
+// EstimatePlan prices the resources a plan creates or keeps. Resource types the table does not price (networks,
+// keys, policies, ...) are free or negligible and left out.
+func (p *Prices) EstimatePlan(plan *tfjson.Plan) *Estimate {
+	estimate := &Estimate{HoursPerMonth: p.HoursPerMonth}
+	// the capacities of the volumes by ID, and by the address of their configuration for the volumes created by the
+	// plan, whose ID is unknown
+	volumeCapacities := map[string]int{}
+	var changes []*tfjson.ResourceChange
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil || change.Change.Actions.Delete() && !change.Change.Actions.Replace() {
+			continue
+		}
+		changes = append(changes, change)
+		if change.Type == "ibm_is_volume" {
+			capacity := int(tfplan.Number(change.Change.After, "capacity"))
+			if id := tfplan.String(change.Change.After, "id"); id != "" {
+				volumeCapacities[id] = capacity
+			}
+			volumeCapacities[configAddress(change)+instanceKey(change.Index)] = capacity
+		}
+	}
+
+	for _, change := range changes {
+		after := change.Change.After
+		switch change.Type {
+		case "ibm_is_instance":
+			profile := tfplan.String(after, "profile")
+			line := Line{Address: change.Address, Type: change.Type, Detail: profile}
+			line.Monthly, line.Estimated = p.instanceHourly(profile)
+			line.Monthly *= p.HoursPerMonth
+			estimate.Lines = append(estimate.Lines, line)
+			boot := p.volume(change.Address+".boot_volume", "ibm_is_volume", tfplan.String(after, "boot_volume", 0, "profile"),
+				number(after, "boot_volume", 0, "size"), number(after, "boot_volume", 0, "iops"))
+			estimate.Lines = append(estimate.Lines, boot)
+		case "ibm_is_volume":
+			estimate.Lines = append(estimate.Lines, p.volume(change.Address, change.Type, tfplan.String(after, "profile"), number(after, "capacity"), number(after, "iops")))
+		case "ibm_is_floating_ip":
+			estimate.Lines = append(estimate.Lines, Line{Address: change.Address, Type: change.Type, Detail: "floating IP", Monthly: p.FloatingIP})
+		case "ibm_is_lb":
+			kind := Application
+			if tfplan.String(after, "profile") == "network-fixed" {
+				kind = Network
+			}
+			estimate.Lines = append(estimate.Lines, Line{Address: change.Address, Type: change.Type, Detail: kind, Monthly: p.LoadBalancers[kind] * p.HoursPerMonth})
+		case "ibm_is_snapshot":
+			size, known := volumeCapacities[tfplan.String(after, "source_volume")]
+			if !known {
+				size, known = sourceVolumeCapacity(plan, change, volumeCapacities)
+			}
+			if !known {
+				size = p.DefaultBootVolumeGB
+			}
+			estimate.Lines = append(estimate.Lines, Line{Address: change.Address, Type: change.Type, Detail: fmt.Sprintf("%d GB", size), Monthly: float64(size) * p.SnapshotGB, Estimated: !known})
+		}
+	}
+	sort.SliceStable(estimate.Lines, func(i, j int) bool { return estimate.Lines[i].Address < estimate.Lines[j].Address })
+	return estimate
+}
+
+// sourceVolumeCapacity returns the capacity of the source volume of a snapshot when the plan creates both, following
+// the reference of the source_volume expression of the configuration to a volume of the same module: the volume of
+// the same key for a snapshot of a count or for_each, else the volume of the reference
+func sourceVolumeCapacity(plan *tfjson.Plan, snapshot *tfjson.ResourceChange, capacities map[string]int) (int, bool) {
+	config := configResource(plan, snapshot)
+	if config == nil || config.Expressions["source_volume"] == nil {
+		return 0, false
+	}
+	prefix := strings.TrimSuffix(configAddress(snapshot), snapshot.Type+"."+snapshot.Name)
+	for _, reference := range config.Expressions["source_volume"].References {
+		if !strings.HasPrefix(reference, "ibm_is_volume.") {
+			continue
+		}
+		volume := prefix + strings.Join(strings.SplitN(reference, ".", 3)[:2], ".")
+		if capacity, ok := capacities[volume+instanceKey(snapshot.Index)]; ok {
+			return capacity, true
+		}
+		if capacity, ok := capacities[volume]; ok {
+			return capacity, true
+		}
+	}
+	return 0, false
+}
+
+// configAddress returns the address of the configuration of a resource, without the instance keys of its module calls
+// and its own
+func configAddress(change *tfjson.ResourceChange) string {
+	address := change.Type + "." + change.Name
+	if change.ModuleAddress != "" {
+		address = tfplan.ModuleCallPath(change.ModuleAddress) + "." + address
+	}
+	return address
+}
+
+// instanceKey renders the count or for_each key of a resource as in its address, empty without one
+func instanceKey(index interface{}) string {
+	switch key := index.(type) {
+	case string:
+		return fmt.Sprintf("[%q]", key)
+	case float64:
+		return fmt.Sprintf("[%d]", int(key))
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("[%v]", key)
+	}
+}
+
+// configResource returns the configuration of the resource of a change, nil if the plan has none
+func configResource(plan *tfjson.Plan, change *tfjson.ResourceChange) *tfjson.ConfigResource {
+	if plan.Config == nil || plan.Config.RootModule == nil {
+		return nil
+	}
+	module := plan.Config.RootModule
+	for _, name := range strings.Split(tfplan.ModuleCallPath(change.ModuleAddress), ".") {
+		if name == "" || name == "module" {
+			continue
+		}
+		call := module.ModuleCalls[name]
+		if call == nil || call.Module == nil {
+			return nil
+		}
+		module = call.Module
+	}
+	for _, resource := range module.Resources {
+		if resource.Mode == tfjson.ManagedResourceMode && resource.Type == change.Type && resource.Name == change.Name {
+			return resource
+		}
+	}
+	return nil
+}
+
+// instanceHourly returns the hourly price of an instance profile, from its size when it is missing from the table
+func (p *Prices) instanceHourly(profile string) (float64, bool) {
+	if price, ok := p.InstanceProfiles[profile]; ok {
+		return price, false
+	}
+	match := profileSize.FindStringSubmatch(profile)
+	if match == nil {
+		return 0, true
+	}
+	vcpu, _ := strconv.Atoi(match[1])
+	memory, _ := strconv.Atoi(match[2])
+	return float64(vcpu)*p.InstanceDefault.VCPU + float64(memory)*p.InstanceDefault.MemoryGB, true
+}
+
+// volume prices a volume, of the general-purpose profile when it has none and of the default boot volume size when
+// its capacity is unknown
+func (p *Prices) volume(address string, resourceType string, profile string, capacity *int, iops *int) Line {
+	line := Line{Address: address, Type: resourceType}
+	if profile == "" {
+		profile = "general-purpose"
+	}
+	size := p.DefaultBootVolumeGB
+	if capacity != nil {
+		size = *capacity
+	} else {
+		line.Estimated = true
+	}
+	prices, ok := p.VolumeProfiles[profile]
+	if !ok {
+		prices = p.VolumeProfiles["general-purpose"]
+		line.Estimated = true
+	}
+	line.Detail = fmt.Sprintf("%s %d GB", profile, size)
+	line.Monthly = float64(size) * prices.GB
+	if iops != nil && prices.IOPS > 0 {
+		line.Detail += fmt.Sprintf(" %d IOPS", *iops)
+		line.Monthly += float64(*iops) * prices.IOPS
+	}
+	return line
+}
+
+// number returns a planned number, nil if it is null or unknown
+func number(value interface{}, path ...interface{}) *int {
+	if tfplan.Lookup(value, path...) == nil {
+		return nil
+	}
+	n := int(tfplan.Number(value, path...))
+	return &n
+}
+
+// String renders the estimate as a table, one line per priced resource, followed by the totals
+func (e *Estimate) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tPRICED\tMONTHLY")
+	for _, line := range e.Lines {
+		detail := line.Detail
+		if line.Estimated {
+			detail += " (estimated)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\n", line.Address, detail, line.Monthly)
+	}
+	_ = w.Flush()
+	fmt.Fprintf(&sb, "Estimated cost: $%.2f per month, $%.4f per test hour.\n", e.Monthly(), e.Hourly())
+	return sb.String()
+}
@@ -0,0 +1,121 @@
+package cost
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// prices are rounder than the ones of the repository, and do not change with them
+var prices = &Prices{
+	HoursPerMonth:       730,
+	InstanceProfiles:    map[string]float64{"bx2-2x8": 0.1},
+	InstanceDefault:     InstanceDefault{VCPU: 0.03, MemoryGB: 0.005},
+	VolumeProfiles:      map[string]VolumePrices{"custom": {GB: 0.08, IOPS: 0.07}, "general-purpose": {GB: 0.1}, "sdp": {GB: 0.08, IOPS: 0.0005}},
+	DefaultBootVolumeGB: 100,
+	FloatingIP:          5,
+	LoadBalancers:       map[string]float64{Application: 0.03, Network: 0.02},
+	SnapshotGB:          0.04,
+}
+
+func TestRepoPrices(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	_, err = LoadPrices(filepath.Join(root, PricesPath))
+	require.NoError(t, err)
+}
+
+// TestRepoBudgets checks every budget has a plan fixture, and the fixture is within the budget
+func TestRepoBudgets(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	repoPrices, err := LoadPrices(filepath.Join(root, PricesPath))
+	require.NoError(t, err)
+	budgets, err := LoadBudgets(filepath.Join(root, BudgetsPath))
+	require.NoError(t, err)
+	require.NotEmpty(t, budgets.Tests())
+	for _, test := range budgets.Tests() {
+		budget := budgets.Budgets[test]
+		estimate, err := repoPrices.EstimateFixture(root, budget.Fixture)
+		require.NoError(t, err, test)
+		assert.Empty(t, budget.Over(estimate), "%s:\n%s", test, estimate)
+	}
+}
+
+func TestEstimatePlan(t *testing.T) {
+	plan, err := tfplan.LoadPlan(filepath.Join("testdata", "plan.json"))
+	require.NoError(t, err)
+	estimate := prices.EstimatePlan(plan)
+
+	type priced struct {
+		Address   string
+		Detail    string
+		Monthly   float64
+		Estimated bool
+	}
+	var lines []priced
+	for _, line := range estimate.Lines {
+		lines = append(lines, priced{line.Address, line.Detail, line.Monthly, line.Estimated})
+	}
+	expected := []priced{
+		{"ibm_is_floating_ip.fip", "floating IP", 5, false},
+		// missing from the table, 16 vCPUs and 80 GB
+		{"ibm_is_instance.gpu", "gx3-16x80x1l4", (16*0.03 + 80*0.005) * 730, true},
+		{"ibm_is_instance.gpu.boot_volume", "sdp 250 GB 3000 IOPS", 250*0.08 + 3000*0.0005, false},
+		{"ibm_is_instance.web", "bx2-2x8", 0.1 * 730, false},
+		// the size of the image, only known after the apply
+		{"ibm_is_instance.web.boot_volume", "general-purpose 100 GB", 100 * 0.1, true},
+		{"ibm_is_lb.nlb", "network", 0.02 * 730, false},
+		{"ibm_is_snapshot.boot", "100 GB", 100 * 0.04, true},
+		// sized after its source volume
+		{"ibm_is_snapshot.data", "200 GB", 200 * 0.04, false},
+		// its source volume created by the plan, found through the configuration, of the same key
+		{`ibm_is_snapshot.keyed["b"]`, "40 GB", 40 * 0.04, false},
+		{"ibm_is_snapshot.new", "300 GB", 300 * 0.04, false},
+		{"ibm_is_volume.data", "custom 200 GB 1000 IOPS", 200*0.08 + 1000*0.07, false},
+		{`ibm_is_volume.keyed["a"]`, "general-purpose 20 GB", 20 * 0.1, false},
+		{`ibm_is_volume.keyed["b"]`, "general-purpose 40 GB", 40 * 0.1, false},
+		// replaced, and priced as general-purpose
+		{"ibm_is_volume.logs", "5iops-tier 50 GB", 50 * 0.1, true},
+		{"ibm_is_volume.new", "general-purpose 300 GB", 300 * 0.1, false},
+	}
+	require.Len(t, lines, len(expected))
+	total := 0.0
+	for i := range expected {
+		assert.Equal(t, expected[i].Address, lines[i].Address)
+		assert.Equal(t, expected[i].Detail, lines[i].Detail, expected[i].Address)
+		assert.InDelta(t, expected[i].Monthly, lines[i].Monthly, 1e-9, expected[i].Address)
+		assert.Equal(t, expected[i].Estimated, lines[i].Estimated, expected[i].Address)
+		total += expected[i].Monthly
+	}
+	assert.InDelta(t, total, estimate.Monthly(), 1e-9)
+	assert.InDelta(t, total/730, estimate.Hourly(), 1e-9)
+	assert.Contains(t, estimate.String(), "ibm_is_instance.gpu              gx3-16x80x1l4 (estimated)           642.40")
+	assert.Contains(t, estimate.String(), "Estimated cost: $919.10 per month, $1.2590 per test hour.")
+}
+
+func TestBudgetOver(t *testing.T) {
+	estimate := &Estimate{HoursPerMonth: 730, Lines: []Line{{Monthly: 365}}}
+	assert.Empty(t, Budget{Fixture: "basic", Monthly: 400}.Over(estimate))
+	assert.Equal(t, []string{"$365.00 per month above the budget of $300.00"}, Budget{Fixture: "basic", Monthly: 300}.Over(estimate))
+	assert.Equal(t, []string{
+		"$365.00 per month above the budget of $300.00",
+		"$0.5000 per test hour above the budget of $0.4000",
+	}, Budget{Fixture: "basic", Monthly: 300, Hourly: 0.4}.Over(estimate))
+}
+
+func TestValidate(t *testing.T) {
+	err := (&Prices{HoursPerMonth: 730, InstanceDefault: InstanceDefault{VCPU: 0.03}, LoadBalancers: map[string]float64{Application: 0.03}}).Validate()
+	assert.EqualError(t, err, "invalid price table:\n"+
+		"  instance_default needs a vcpu and a memory_gb price\n"+
+		"  no price for the general-purpose volume profile of the boot volumes\n"+
+		"  default_boot_volume_gb must be positive\n"+
+		"  no price for the network load balancers")
+
+	err = (&Budgets{Budgets: map[string]Budget{"TestB": {Monthly: 10, Hourly: -1}, "TestA": {Fixture: "basic"}}}).Validate()
+	assert.EqualError(t, err, "invalid budgets:\n  TestA: monthly must be positive\n  TestB: no fixture\n  TestB: hourly can not be negative")
+}
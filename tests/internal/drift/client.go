@@ -0,0 +1,83 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// VPCVersion is the API version the VPC client requests
+const VPCVersion = "2025-01-01"
+
+// VPCClient performs the mutations with the VPC REST API of a region
+type VPCClient struct {
+	service *core.BaseService
+}
+
+// NewVPCClient returns a VPC client of a region authenticated with an API key
+func NewVPCClient(apiKey string, region string) (*VPCClient, error) {
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           fmt.Sprintf("https://%s.iaas.cloud.ibm.com/v1", region),
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &VPCClient{service: service}, nil
+}
+
+// SetServiceURL changes the endpoint, for tests or private endpoints
+func (c *VPCClient) SetServiceURL(url string) error {
+	return c.service.SetServiceURL(url)
+}
+
+// Service returns the underlying service, to install a recording or replaying transport
+func (c *VPCClient) Service() *core.BaseService {
+	return c.service
+}
+
+func (c *VPCClient) request(ctx context.Context, method string, path string, pathParams map[string]string, patch map[string]interface{}) error {
+	builder := core.NewRequestBuilder(method).WithContext(ctx)
+	if _, err := builder.ResolveRequestURL(c.service.GetServiceURL(), path, pathParams); err != nil {
+		return err
+	}
+	builder.AddHeader("Accept", "application/json")
+	builder.AddQuery("version", VPCVersion)
+	builder.AddQuery("generation", "2")
+	if patch != nil {
+		if _, err := builder.SetBodyContentJSON(patch); err != nil {
+			return err
+		}
+		builder.AddHeader("Content-Type", "application/merge-patch+json")
+	}
+	request, err := builder.Build()
+	if err != nil {
+		return err
+	}
+	var result map[string]interface{}
+	if _, err := c.service.Request(request, &result); err != nil {
+		return fmt.Errorf("%s %s: %w", method, request.URL.Path, err)
+	}
+	return nil
+}
+
+// DetachVolume deletes a volume attachment of an instance
+func (c *VPCClient) DetachVolume(ctx context.Context, instanceID string, attachmentID string) error {
+	return c.request(ctx, core.DELETE, "/instances/{instance_id}/volume_attachments/{id}", map[string]string{"instance_id": instanceID, "id": attachmentID}, nil)
+}
+
+// UpdateSecurityGroupRule patches a rule of a security group
+func (c *VPCClient) UpdateSecurityGroupRule(ctx context.Context, groupID string, ruleID string, patch map[string]interface{}) error {
+	return c.request(ctx, core.PATCH, "/security_groups/{security_group_id}/rules/{id}", map[string]string{"security_group_id": groupID, "id": ruleID}, patch)
+}
+
+// DeleteFloatingIP deletes a floating IP
+func (c *VPCClient) DeleteFloatingIP(ctx context.Context, id string) error {
+	return c.request(ctx, core.DELETE, "/floating_ips/{id}", map[string]string{"id": id}, nil)
+}
+
+// ResizeVolume changes the capacity of a volume
+func (c *VPCClient) ResizeVolume(ctx context.Context, volumeID string, capacity int) error {
+	return c.request(ctx, core.PATCH, "/volumes/{id}", map[string]string{"id": volumeID}, map[string]interface{}{"capacity": capacity})
+}
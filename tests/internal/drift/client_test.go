@@ -0,0 +1,42 @@
+package drift
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fakevpc"
+)
+
+func TestVPCClient(t *testing.T) {
+	server := fakevpc.NewServer("us-south")
+	defer server.Close()
+	instance := server.Seed(fakevpc.Instances, fakevpc.Resource{"name": "drift-vpc-subnet-a-0"})
+	attachment := server.SeedNested(fakevpc.VolumeAttachments, instance.ID(), fakevpc.Resource{"name": "drift-data-attachment"})
+	group := server.Seed(fakevpc.SecurityGroups, fakevpc.Resource{"name": "drift-sg"})
+	rule := server.SeedNested(fakevpc.SecurityGroupRules, group.ID(), fakevpc.Resource{"direction": "inbound", "remote": map[string]interface{}{"cidr_block": "10.0.0.0/8"}})
+	floatingIP := server.Seed(fakevpc.FloatingIPs, fakevpc.Resource{"name": "drift-fip"})
+	boot := server.Seed(fakevpc.Volumes, fakevpc.Resource{"name": "drift-boot", "capacity": 100})
+
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	client := &VPCClient{service: service}
+
+	ctx := context.Background()
+	require.NoError(t, client.DetachVolume(ctx, instance.ID(), attachment.ID()))
+	assert.Empty(t, server.List(fakevpc.VolumeAttachments))
+	require.NoError(t, client.UpdateSecurityGroupRule(ctx, group.ID(), rule.ID(), map[string]interface{}{"remote": map[string]interface{}{"cidr_block": DefaultRemote}}))
+	assert.Equal(t, map[string]interface{}{"cidr_block": DefaultRemote}, server.List(fakevpc.SecurityGroupRules)[0]["remote"])
+	require.NoError(t, client.DeleteFloatingIP(ctx, floatingIP.ID()))
+	assert.Nil(t, server.Get(fakevpc.FloatingIPs, floatingIP.ID()))
+	require.NoError(t, client.ResizeVolume(ctx, boot.ID(), 250))
+	assert.Equal(t, float64(250), server.Get(fakevpc.Volumes, boot.ID())["capacity"])
+
+	for _, request := range server.Requests() {
+		assert.Equal(t, "generation=2&version="+VPCVersion, request.Query)
+	}
+	err = client.DeleteFloatingIP(ctx, floatingIP.ID())
+	assert.ErrorContains(t, err, "DELETE /v1/floating_ips/"+floatingIP.ID())
+}
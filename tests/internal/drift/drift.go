@@ -0,0 +1,316 @@
+// Package drift simulates out-of-band changes to an applied example (an operator detaching a volume, editing a
+// security group rule, removing a floating IP or resizing a boot volume in the console) and checks how the module
+// reacts: after each mutation the example is planned again, and the plan must either repair the drift (the apply
+// restores the resource and the next plan is empty for it) or deliberately ignore it, like the
+// `ignore_changes = [image, user_data]` of the instances.
+//
+// The mutations are read from a YAML file (tests/drift-mutations.yaml) and performed through a Client, so they can
+// be unit tested with a fake; Terraform runs the plans and applies.
+package drift
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"gopkg.in/yaml.v3"
+)
+
+// MutationsPath is the path of the mutation table, relative to the root of the module
+const MutationsPath = "tests/drift-mutations.yaml"
+
+// Kinds of mutations
+const (
+	DetachVolume          = "detach-volume"
+	EditSecurityGroupRule = "edit-security-group-rule"
+	RemoveFloatingIP      = "remove-floating-ip"
+	ResizeBootVolume      = "resize-boot-volume"
+)
+
+// Expectations of a mutation
+const (
+	Repair = "repair"
+	Ignore = "ignore"
+)
+
+// DefaultRemote is the remote CIDR an edited security group rule gets when the mutation does not set one
+// (TEST-NET-1, never a real source of traffic)
+const DefaultRemote = "192.0.2.0/24"
+
+// kindTypes is the resource type each kind of mutation targets
+var kindTypes = map[string]string{
+	DetachVolume:          "ibm_is_instance",
+	EditSecurityGroupRule: "ibm_is_security_group_rule",
+	RemoveFloatingIP:      "ibm_is_floating_ip",
+	ResizeBootVolume:      "ibm_is_instance",
+}
+
+// Mutations is the content of the mutation table
+type Mutations struct {
+	Mutations []Mutation `yaml:"mutations"`
+}
+
+// Mutation is an out-of-band change to a resource of the state
+type Mutation struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+	// Address of the targeted resource, `*` matches any sequence of characters; the first match in address order is
+	// mutated, and the mutation fails when nothing matches
+	Address string `yaml:"address"`
+	// Expect is repair or ignore
+	Expect string `yaml:"expect"`
+	// Capacity is the new size in gigabytes of a resized boot volume
+	Capacity int `yaml:"capacity,omitempty"`
+	// Remote is the new remote CIDR of an edited security group rule, DefaultRemote if empty
+	Remote string `yaml:"remote,omitempty"`
+}
+
+// Client performs the mutations with the IBM Cloud VPC API
+type Client interface {
+	DetachVolume(ctx context.Context, instanceID string, attachmentID string) error
+	UpdateSecurityGroupRule(ctx context.Context, groupID string, ruleID string, patch map[string]interface{}) error
+	DeleteFloatingIP(ctx context.Context, id string) error
+	ResizeVolume(ctx context.Context, volumeID string, capacity int) error
+}
+
+// Terraform plans and applies the example the mutations are performed on
+type Terraform interface {
+	State(ctx context.Context) (*tfjson.State, error)
+	Plan(ctx context.Context) (*tfjson.Plan, error)
+	Apply(ctx context.Context) error
+}
+
+// Status is the outcome of a mutation
+type Status string
+
+const (
+	Repaired Status = "repaired"
+	Ignored  Status = "ignored"
+	Failed   Status = "FAILED"
+)
+
+// Result is the outcome of a mutation
+type Result struct {
+	Mutation Mutation
+	Address  string
+	Status   Status
+	Detail   string
+}
+
+// Report is the outcome of every mutation
+type Report struct {
+	Results []Result
+}
+
+// LoadMutations reads and validates the mutation table
+func LoadMutations(path string) (*Mutations, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading drift mutations %s: %w", path, err)
+	}
+	mutations := &Mutations{}
+	if err := yaml.Unmarshal(data, mutations); err != nil {
+		return nil, fmt.Errorf("error parsing drift mutations %s: %w", path, err)
+	}
+	if err := mutations.Validate(); err != nil {
+		return nil, fmt.Errorf("drift mutations %s: %w", path, err)
+	}
+	return mutations, nil
+}
+
+// Validate checks every mutation has a name, a known kind, an address and an expectation
+func (m *Mutations) Validate() error {
+	var problems []string
+	for i, mutation := range m.Mutations {
+		name := mutation.Name
+		if name == "" {
+			name = fmt.Sprintf("mutation %d", i+1)
+			problems = append(problems, fmt.Sprintf("%s: no name", name))
+		}
+		if _, ok := kindTypes[mutation.Kind]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: unknown kind %q", name, mutation.Kind))
+		}
+		if mutation.Address == "" {
+			problems = append(problems, fmt.Sprintf("%s: no address", name))
+		}
+		if mutation.Expect != Repair && mutation.Expect != Ignore {
+			problems = append(problems, fmt.Sprintf("%s: expect must be %s or %s", name, Repair, Ignore))
+		}
+		if mutation.Kind == ResizeBootVolume && mutation.Capacity <= 0 {
+			problems = append(problems, fmt.Sprintf("%s: no capacity", name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid mutations:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Run performs the mutations one after the other on the applied example, planning it again after each one. A
+// repaired drift is applied before the next mutation. Errors of Terraform stop the run, errors of the client only
+// fail their mutation.
+func Run(ctx context.Context, tf Terraform, client Client, mutations []Mutation) (*Report, error) {
+	report := &Report{}
+	for _, mutation := range mutations {
+		result, err := run(ctx, tf, client, mutation)
+		if err != nil {
+			return report, fmt.Errorf("mutation %s: %w", mutation.Name, err)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func run(ctx context.Context, tf Terraform, client Client, mutation Mutation) (Result, error) {
+	result := Result{Mutation: mutation}
+	state, err := tf.State(ctx)
+	if err != nil {
+		return result, err
+	}
+	target := find(state, mutation.Address)
+	if target == nil {
+		// a mutation of a resource the example does not create would never run
+		result.Status, result.Detail = Failed, "no resource of the state matches "+mutation.Address
+		return result, nil
+	}
+	result.Address = target.Address
+	if target.Type != kindTypes[mutation.Kind] {
+		result.Status, result.Detail = Failed, fmt.Sprintf("%s needs a %s, not a %s", mutation.Kind, kindTypes[mutation.Kind], target.Type)
+		return result, nil
+	}
+	if err := perform(ctx, client, mutation, target); err != nil {
+		result.Status, result.Detail = Failed, err.Error()
+		return result, nil
+	}
+
+	plan, err := tf.Plan(ctx)
+	if err != nil {
+		return result, err
+	}
+	action := plannedAction(plan, target.Address)
+	if mutation.Expect == Ignore {
+		if action != "" {
+			result.Status, result.Detail = Failed, fmt.Sprintf("the plan would %s the resource", action)
+		} else {
+			result.Status = Ignored
+		}
+		return result, nil
+	}
+	if action == "" {
+		result.Status, result.Detail = Failed, "the plan does not repair the drift"
+		return result, nil
+	}
+	if err := tf.Apply(ctx); err != nil {
+		return result, err
+	}
+	plan, err = tf.Plan(ctx)
+	if err != nil {
+		return result, err
+	}
+	if again := plannedAction(plan, target.Address); again != "" {
+		result.Status, result.Detail = Failed, fmt.Sprintf("the plan would still %s the resource after the repair", again)
+		return result, nil
+	}
+	result.Status, result.Detail = Repaired, action
+	return result, nil
+}
+
+// find returns the first managed resource of the state, in address order, matching the address pattern
+func find(state *tfjson.State, address string) *tfjson.StateResource {
+	pattern := addressPattern(address)
+	for _, resource := range tfplan.StateResources(state) {
+		if resource.Mode == tfjson.ManagedResourceMode && pattern.MatchString(resource.Address) {
+			return resource
+		}
+	}
+	return nil
+}
+
+// addressPattern turns an address, where `*` matches anything, into an anchored regular expression
+func addressPattern(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
+
+// perform runs the mutation on the target with the client
+func perform(ctx context.Context, client Client, mutation Mutation, target *tfjson.StateResource) error {
+	values := target.AttributeValues
+	switch mutation.Kind {
+	case DetachVolume:
+		boot := tfplan.String(values, "boot_volume", 0, "volume_id")
+		for _, attachment := range tfplan.List(values, "volume_attachments") {
+			if tfplan.String(attachment, "volume_id") != boot {
+				return client.DetachVolume(ctx, tfplan.String(values, "id"), tfplan.String(attachment, "id"))
+			}
+		}
+		return fmt.Errorf("%s has no data volume attached", target.Address)
+	case ResizeBootVolume:
+		boot := tfplan.String(values, "boot_volume", 0, "volume_id")
+		if boot == "" {
+			return fmt.Errorf("%s has no boot volume", target.Address)
+		}
+		return client.ResizeVolume(ctx, boot, mutation.Capacity)
+	case EditSecurityGroupRule:
+		remote := mutation.Remote
+		if remote == "" {
+			remote = DefaultRemote
+		}
+		patch := map[string]interface{}{"remote": map[string]interface{}{"cidr_block": remote}}
+		return client.UpdateSecurityGroupRule(ctx, tfplan.String(values, "group"), tfplan.String(values, "rule_id"), patch)
+	case RemoveFloatingIP:
+		return client.DeleteFloatingIP(ctx, tfplan.String(values, "id"))
+	default:
+		return fmt.Errorf("unknown kind %q", mutation.Kind)
+	}
+}
+
+// plannedAction returns the action the plan takes on a resource, empty for none
+func plannedAction(plan *tfjson.Plan, address string) string {
+	for _, change := range plan.ResourceChanges {
+		if change.Address != address || change.Change == nil {
+			continue
+		}
+		switch actions := change.Change.Actions; {
+		case actions.Replace():
+			return "replace"
+		case actions.Create():
+			return "create"
+		case actions.Update():
+			return "update"
+		case actions.Delete():
+			return "delete"
+		}
+	}
+	return ""
+}
+
+// Failed returns true if a mutation was not repaired or ignored as expected
+func (r *Report) Failed() bool {
+	for _, result := range r.Results {
+		if result.Status == Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the results as a table, one line per mutation
+func (r *Report) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MUTATION\tADDRESS\tEXPECT\tRESULT\tDETAIL")
+	for _, result := range r.Results {
+		address := result.Address
+		if address == "" {
+			address = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Mutation.Name, address, result.Mutation.Expect, result.Status, result.Detail)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
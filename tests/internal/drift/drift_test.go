@@ -0,0 +1,203 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// fakeCloud is both the client and Terraform: a mutation drifts the resource holding the mutated ID, the plan
+// changes the drifted resources the module does not ignore, and the apply repairs the ones it can
+type fakeCloud struct {
+	t     *testing.T
+	state *tfjson.State
+	// ignored are the addresses whose drift the plan does not see, unrepairable the ones the apply does not fix
+	ignored      map[string]bool
+	unrepairable map[string]bool
+	failing      error
+	drifted      map[string]bool
+	calls        []string
+	applies      int
+}
+
+func newFakeCloud(t *testing.T) *fakeCloud {
+	state, err := tfplan.LoadState("testdata/state.json")
+	require.NoError(t, err)
+	return &fakeCloud{t: t, state: state, ignored: map[string]bool{}, unrepairable: map[string]bool{}, drifted: map[string]bool{}}
+}
+
+// drift marks the resource whose attribute has the value as drifted
+func (f *fakeCloud) drift(call string, attribute string, value string) error {
+	f.calls = append(f.calls, call)
+	if f.failing != nil {
+		return f.failing
+	}
+	for _, resource := range tfplan.StateResources(f.state) {
+		if tfplan.String(resource.AttributeValues, attribute) == value {
+			f.drifted[resource.Address] = true
+			return nil
+		}
+		for _, attachment := range tfplan.List(resource.AttributeValues, "volume_attachments") {
+			if tfplan.String(attachment, "id") == value {
+				f.drifted[resource.Address] = true
+				return nil
+			}
+		}
+		if tfplan.String(resource.AttributeValues, "boot_volume", 0, "volume_id") == value {
+			f.drifted[resource.Address] = true
+			return nil
+		}
+	}
+	f.t.Errorf("%s: no resource with %s %s", call, attribute, value)
+	return nil
+}
+
+func (f *fakeCloud) DetachVolume(ctx context.Context, instanceID string, attachmentID string) error {
+	return f.drift("detach "+instanceID+" "+attachmentID, "volume_attachments", attachmentID)
+}
+
+func (f *fakeCloud) UpdateSecurityGroupRule(ctx context.Context, groupID string, ruleID string, patch map[string]interface{}) error {
+	assert.Equal(f.t, DefaultRemote, tfplan.String(patch, "remote", "cidr_block"))
+	return f.drift("update rule "+groupID+" "+ruleID, "rule_id", ruleID)
+}
+
+func (f *fakeCloud) DeleteFloatingIP(ctx context.Context, id string) error {
+	return f.drift("delete floating ip "+id, "id", id)
+}
+
+func (f *fakeCloud) ResizeVolume(ctx context.Context, volumeID string, capacity int) error {
+	return f.drift("resize "+volumeID, "boot_volume", volumeID)
+}
+
+func (f *fakeCloud) State(ctx context.Context) (*tfjson.State, error) {
+	return f.state, nil
+}
+
+func (f *fakeCloud) Plan(ctx context.Context) (*tfjson.Plan, error) {
+	plan := &tfjson.Plan{}
+	for address := range f.drifted {
+		if f.ignored[address] {
+			continue
+		}
+		plan.ResourceChanges = append(plan.ResourceChanges, &tfjson.ResourceChange{
+			Address: address,
+			Mode:    tfjson.ManagedResourceMode,
+			Change:  &tfjson.Change{Actions: tfjson.Actions{tfjson.ActionUpdate}},
+		})
+	}
+	return plan, nil
+}
+
+func (f *fakeCloud) Apply(ctx context.Context) error {
+	f.applies++
+	for address := range f.drifted {
+		if !f.ignored[address] && !f.unrepairable[address] {
+			delete(f.drifted, address)
+		}
+	}
+	return nil
+}
+
+func statuses(report *Report) []string {
+	var list []string
+	for _, result := range report.Results {
+		list = append(list, result.Mutation.Name+": "+string(result.Status)+" "+result.Detail)
+	}
+	return list
+}
+
+func TestRun(t *testing.T) {
+	mutations, err := LoadMutations("testdata/mutations.yaml")
+	require.NoError(t, err)
+	cloud := newFakeCloud(t)
+	cloud.ignored[`module.slz_vsi.ibm_is_instance.vsi["drift-vpc-subnet-b-0"]`] = true
+
+	report, err := Run(context.Background(), cloud, cloud, mutations.Mutations)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"detach data volume: repaired update",
+		"resize boot volume: ignored ",
+		"edit ssh rule: repaired update",
+		"remove floating ip: repaired update",
+	}, statuses(report))
+	assert.False(t, report.Failed())
+	assert.Equal(t, []string{
+		"detach 0717_instance-a 0717-attachment-data-a",
+		"resize r006-boot-b",
+		"update rule r006-sg r006-rule",
+		"delete floating ip r006-fip-a",
+	}, cloud.calls)
+	assert.Equal(t, 3, cloud.applies)
+	assert.Regexp(t, `resize boot volume\s+module\.slz_vsi\.ibm_is_instance\.vsi\["drift-vpc-subnet-b-0"\]\s+ignore\s+ignored`, report.String())
+}
+
+func TestRunFailures(t *testing.T) {
+	instanceA := `module.slz_vsi.ibm_is_instance.vsi["drift-vpc-subnet-a-0"]`
+	instanceB := `module.slz_vsi.ibm_is_instance.vsi["drift-vpc-subnet-b-0"]`
+	cloud := newFakeCloud(t)
+	cloud.ignored[instanceA] = true
+	cloud.unrepairable[`module.slz_vsi.ibm_is_floating_ip.vsi_fip["drift-vpc-subnet-a-0"]`] = true
+
+	report, err := Run(context.Background(), cloud, cloud, []Mutation{
+		{Name: "not repaired", Kind: DetachVolume, Address: instanceA, Expect: Repair},
+		{Name: "not ignored", Kind: ResizeBootVolume, Address: instanceB, Capacity: 250, Expect: Ignore},
+		{Name: "still drifted", Kind: RemoveFloatingIP, Address: "module.slz_vsi.ibm_is_floating_ip.*", Expect: Repair},
+		{Name: "no data volume", Kind: DetachVolume, Address: instanceB, Expect: Repair},
+		{Name: "wrong type", Kind: RemoveFloatingIP, Address: instanceB, Expect: Repair},
+		{Name: "no match", Kind: EditSecurityGroupRule, Address: "module.slz_vsi_dh[0].ibm_is_security_group_rule.security_group_rules[*]", Expect: Repair},
+	})
+	require.NoError(t, err)
+	assert.True(t, report.Failed())
+	assert.Equal(t, []string{
+		"not repaired: FAILED the plan does not repair the drift",
+		"not ignored: FAILED the plan would update the resource",
+		"still drifted: FAILED the plan would still update the resource after the repair",
+		`no data volume: FAILED module.slz_vsi.ibm_is_instance.vsi["drift-vpc-subnet-b-0"] has no data volume attached`,
+		"wrong type: FAILED remove-floating-ip needs a ibm_is_floating_ip, not a ibm_is_instance",
+		"no match: FAILED no resource of the state matches module.slz_vsi_dh[0].ibm_is_security_group_rule.security_group_rules[*]",
+	}, statuses(report))
+
+	cloud = newFakeCloud(t)
+	cloud.failing = errors.New("403 Forbidden")
+	report, err = Run(context.Background(), cloud, cloud, []Mutation{{Name: "forbidden", Kind: RemoveFloatingIP, Address: "module.slz_vsi.ibm_is_floating_ip.*", Expect: Repair}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"forbidden: FAILED 403 Forbidden"}, statuses(report))
+}
+
+func TestValidate(t *testing.T) {
+	mutations := &Mutations{Mutations: []Mutation{
+		{Name: "ok", Kind: RemoveFloatingIP, Address: "module.slz_vsi.ibm_is_floating_ip.*", Expect: Repair},
+		{Kind: "reboot", Expect: "restore"},
+		{Name: "resize", Kind: ResizeBootVolume, Address: "module.slz_vsi.ibm_is_instance.*", Expect: Ignore},
+	}}
+	err := mutations.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mutation 2: no name")
+	assert.Contains(t, err.Error(), `mutation 2: unknown kind "reboot"`)
+	assert.Contains(t, err.Error(), "mutation 2: no address")
+	assert.Contains(t, err.Error(), "mutation 2: expect must be repair or ignore")
+	assert.Contains(t, err.Error(), "resize: no capacity")
+
+	path := filepath.Join(t.TempDir(), "mutations.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("mutations:\n  - name: x\n"), 0o600))
+	_, err = LoadMutations(path)
+	assert.ErrorContains(t, err, `x: unknown kind ""`)
+}
+
+// TestRepoMutations checks the mutation table of the module is valid
+func TestRepoMutations(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	mutations, err := LoadMutations(filepath.Join(root, MutationsPath))
+	require.NoError(t, err)
+	assert.NotEmpty(t, mutations.Mutations)
+}
@@ -0,0 +1,298 @@
+// Package failures extracts the Terraform error blocks of a Schematics job log or of the output of a local apply, reads
+// the failing resource, the provider error code and the request ID of each, and classifies the failure as quota,
+// transient, IAM, validation or module bug with the rules of tests/failure-classes.yaml, so a failed test starts its
+// message with what failed and why instead of the end of a long log.
+package failures
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClassesPath is the path of the rules, relative to the root of the module
+const ClassesPath = "tests/failure-classes.yaml"
+
+// Classes of failures
+const (
+	Quota      = "quota"
+	Transient  = "transient"
+	IAM        = "iam"
+	Validation = "validation"
+	ModuleBug  = "module_bug"
+)
+
+var classes = []string{Quota, Transient, IAM, Validation, ModuleBug}
+
+// Rule classifies the error blocks with one of its codes or statuses, or matching one of its patterns
+type Rule struct {
+	Name     string   `yaml:"name"`
+	Class    string   `yaml:"class"`
+	Codes    []string `yaml:"codes"`
+	Statuses []int    `yaml:"statuses"`
+	Patterns []string `yaml:"patterns"`
+	patterns []*regexp.Regexp
+}
+
+// Rules is the content of the rules file
+type Rules struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// LoadRules reads the rules file
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading failure classes %s: %w", path, err)
+	}
+	rules := &Rules{}
+	if err := yaml.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("error parsing failure classes %s: %w", path, err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("failure classes %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// Validate checks the rules have a known class and compiles their patterns
+func (r *Rules) Validate() error {
+	var problems []string
+	names := map[string]bool{}
+	for i, rule := range r.Rules {
+		if rule.Name == "" {
+			problems = append(problems, fmt.Sprintf("rule %d: no name", i))
+		} else if names[rule.Name] {
+			problems = append(problems, "rule "+rule.Name+": duplicate name")
+		}
+		names[rule.Name] = true
+		if !slices.Contains(classes, rule.Class) {
+			problems = append(problems, fmt.Sprintf("rule %s: unknown class %q, one of %s", rule.Name, rule.Class, strings.Join(classes, ", ")))
+		}
+		if len(rule.Codes)+len(rule.Statuses)+len(rule.Patterns) == 0 {
+			problems = append(problems, "rule "+rule.Name+": no codes, statuses or patterns")
+		}
+		rule.patterns = nil
+		for _, pattern := range rule.Patterns {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("rule %s: %v", rule.Name, err))
+				continue
+			}
+			rule.patterns = append(rule.patterns, compiled)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid failure classes:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// matches returns whether the rule classifies a failure
+func (r *Rule) matches(failure *Failure) bool {
+	if failure.Code != "" && slices.Contains(r.Codes, failure.Code) {
+		return true
+	}
+	for _, status := range r.Statuses {
+		if status == failure.Status {
+			return true
+		}
+	}
+	for _, pattern := range r.patterns {
+		if pattern.MatchString(failure.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Failure is an error block of a log
+type Failure struct {
+	// Summary is the first line of the block, without `Error: `
+	Summary string
+	// Address is the address of the failing resource (`module.slz_vsi.ibm_is_instance.vsi["..."]`), empty for an
+	// error outside of a resource
+	Address string
+	// Location is the file and line of the configuration the error is reported on
+	Location string
+	// Code is the error code of the API, or the IAM error code
+	Code string
+	// Status is the HTTP status of the API response, 0 when there is none
+	Status int
+	// RequestID is the ID of the failed API request, to quote to IBM Cloud support
+	RequestID string
+	Class     string
+	// Rule is the name of the rule that classified the failure, empty for the module bugs no rule matches
+	Rule string
+	// Text is the error block, without the prefixes of the log lines
+	Text string
+}
+
+var (
+	ansi = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// linePrefix is the timestamp of a Schematics log line (`2025/01/15 10:22:33 Terraform apply | `) or of a
+	// terratest log line (`TestRunCompleteExample 2025-01-15T10:22:33Z logger.go:66: `)
+	linePrefix = regexp.MustCompile(`^\s*(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\s+Terraform \w+ \|)?|\S+ \d{4}-\d{2}-\d{2}T[\d:.]+Z?[+\d:]* \S+\.go:\d+:)\s?`)
+	// blockEnd is the end of the output of a Terraform command in a Schematics log
+	blockEnd = regexp.MustCompile(`(?i)^(Terraform (PLAN|APPLY|DESTROY) error|Command finished|-{3,}\s*$)`)
+
+	address   = regexp.MustCompile(`(?m)^\s*with ((?:module\.[\w-]+(?:\[[^\]]+\])?\.)*[\w-]+\.[\w-]+(?:\[[^\]]+\])?),\s*$`)
+	location  = regexp.MustCompile(`(?m)^\s*on (\S+) line (\d+)`)
+	apiCode   = regexp.MustCompile(`"code":\s*"([^"]+)"`)
+	iamCode   = regexp.MustCompile(`\b(BX[A-Z]{3}\d{4}E)\b`)
+	status    = regexp.MustCompile(`"StatusCode":\s*(\d{3})|(?i)\bstatus(?: code)?:? (\d{3})\b`)
+	requestID = regexp.MustCompile(`(?i)"X-Request-Id":\s*\[\s*"([^"]+)"|"trace":\s*"([^"]+)"|\b(?:request|transaction|correlation)[ _-]?id[=:]\s*['"]?([\w-]{8,})`)
+)
+
+// Parse returns the error blocks of a log, in their order, once each: Schematics repeats the errors of a job
+func Parse(log string) []*Failure {
+	var failures []*Failure
+	var block []string
+	seen := map[string]bool{}
+	flush := func() {
+		if len(block) == 0 {
+			return
+		}
+		text := strings.TrimRight(strings.Join(block, "\n"), "\n ")
+		block = nil
+		if seen[text] {
+			return
+		}
+		seen[text] = true
+		failures = append(failures, parseBlock(text))
+	}
+	for _, line := range strings.Split(ansi.ReplaceAllString(log, ""), "\n") {
+		line = strings.TrimRight(linePrefix.ReplaceAllString(strings.TrimRight(line, "\r"), ""), " ")
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case strings.HasPrefix(trimmed, "╷"):
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "╵"):
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "│"):
+			line = strings.TrimPrefix(strings.TrimPrefix(trimmed, "│"), " ")
+			trimmed = strings.TrimLeft(line, " ")
+		}
+		if strings.HasPrefix(trimmed, "Error: ") {
+			flush()
+			block = []string{trimmed}
+			continue
+		}
+		if block == nil {
+			continue
+		}
+		if blockEnd.MatchString(trimmed) {
+			flush()
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+	return failures
+}
+
+func parseBlock(text string) *Failure {
+	failure := &Failure{Text: text}
+	failure.Summary, _, _ = strings.Cut(strings.TrimPrefix(text, "Error: "), "\n")
+	if match := address.FindStringSubmatch(text); match != nil {
+		failure.Address = match[1]
+	}
+	if match := location.FindStringSubmatch(text); match != nil {
+		failure.Location = match[1] + ":" + match[2]
+	}
+	if match := apiCode.FindStringSubmatch(text); match != nil {
+		failure.Code = match[1]
+	} else if match := iamCode.FindStringSubmatch(text); match != nil {
+		failure.Code = match[1]
+	}
+	if match := status.FindStringSubmatch(text); match != nil {
+		failure.Status, _ = strconv.Atoi(match[1] + match[2])
+	}
+	if match := requestID.FindStringSubmatch(text); match != nil {
+		failure.RequestID = match[1] + match[2] + match[3]
+	}
+	return failure
+}
+
+// Classify parses a log and classifies its failures
+func (r *Rules) Classify(log string) *Report {
+	report := &Report{Failures: Parse(log)}
+	for _, failure := range report.Failures {
+		failure.Class = ModuleBug
+		for _, rule := range r.Rules {
+			if rule.matches(failure) {
+				failure.Class = rule.Class
+				failure.Rule = rule.Name
+				break
+			}
+		}
+	}
+	return report
+}
+
+// Report is the classified failures of a log
+type Report struct {
+	Failures []*Failure
+}
+
+// Counts returns the number of failures of each class
+func (r *Report) Counts() map[string]int {
+	counts := map[string]int{}
+	for _, failure := range r.Failures {
+		counts[failure.Class]++
+	}
+	return counts
+}
+
+// String renders the failures as a table, led by their number per class
+func (r *Report) String() string {
+	if len(r.Failures) == 0 {
+		return "No Terraform error found in the output.\n"
+	}
+	counts := r.Counts()
+	var perClass []string
+	for _, class := range classes {
+		if counts[class] > 0 {
+			perClass = append(perClass, fmt.Sprintf("%d %s", counts[class], class))
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d Terraform failure(s): %s\n", len(r.Failures), strings.Join(perClass, ", "))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLASS\tRESOURCE\tCODE\tREQUEST ID\tERROR")
+	for _, failure := range r.Failures {
+		resource := failure.Address
+		if resource == "" {
+			resource = failure.Location
+		}
+		code := failure.Code
+		if failure.Status != 0 {
+			code = strings.TrimSpace(fmt.Sprintf("%s %d", code, failure.Status))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", failure.Class, dash(resource), dash(code), dash(failure.RequestID), shorten(failure.Summary, 100))
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func shorten(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
@@ -0,0 +1,105 @@
+package failures
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/logs")
+
+func repoRules(t *testing.T) *Rules {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	rules, err := LoadRules(filepath.Join(root, ClassesPath))
+	require.NoError(t, err)
+	return rules
+}
+
+// classified is a failure without its text, as in the golden files
+type classified struct {
+	Class     string `json:"class"`
+	Rule      string `json:"rule,omitempty"`
+	Address   string `json:"address,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Summary   string `json:"summary"`
+}
+
+// TestCorpus classifies the saved logs of testdata/logs and compares the failures with the <log>.json next to each,
+// run with -update to rewrite them
+func TestCorpus(t *testing.T) {
+	rules := repoRules(t)
+	logs, err := filepath.Glob(filepath.Join("testdata", "logs", "*.log"))
+	require.NoError(t, err)
+	require.NotEmpty(t, logs)
+	for _, path := range logs {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		var failures []classified
+		for _, failure := range rules.Classify(string(data)).Failures {
+			failures = append(failures, classified{failure.Class, failure.Rule, failure.Address, failure.Location, failure.Code, failure.Status, failure.RequestID, failure.Summary})
+		}
+		actual, err := json.MarshalIndent(failures, "", "  ")
+		require.NoError(t, err)
+		golden := strings.TrimSuffix(path, ".log") + ".json"
+		if *update {
+			require.NoError(t, os.WriteFile(golden, append(actual, '\n'), 0o644))
+			continue
+		}
+		expected, err := os.ReadFile(golden)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual)+"\n", "%s differs, run the test with -update if the change is expected", golden)
+	}
+}
+
+func TestParse(t *testing.T) {
+	// the output of an older Terraform, without the frame around the blocks
+	failures := Parse("module.vsi.ibm_is_instance.vsi[\"a\"]: Creating...\n" +
+		"\x1b[31mError: \x1b[0m\x1b[1mError while creating instance\x1b[0m\n" +
+		"\n" +
+		"  with module.vsi.ibm_is_instance.vsi[\"a\"],\n" +
+		"  on main.tf line 10, in resource \"ibm_is_instance\" \"vsi\":\n" +
+		"request_id=0a1b2c3d-4e5f\n")
+	require.Len(t, failures, 1)
+	assert.Equal(t, "Error while creating instance", failures[0].Summary)
+	assert.Equal(t, `module.vsi.ibm_is_instance.vsi["a"]`, failures[0].Address)
+	assert.Equal(t, "main.tf:10", failures[0].Location)
+	assert.Equal(t, "0a1b2c3d-4e5f", failures[0].RequestID)
+
+	assert.Empty(t, Parse("Apply complete! Resources: 12 added, 0 changed, 0 destroyed.\n"))
+}
+
+func TestReport(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "logs", "local-transient.log"))
+	require.NoError(t, err)
+	report := repoRules(t).Classify(string(data))
+	assert.Equal(t, map[string]int{Transient: 2}, report.Counts())
+	assert.Equal(t, "2 Terraform failure(s): 2 transient\n"+
+		"CLASS      RESOURCE                                                  CODE                              REQUEST ID                            ERROR\n"+
+		`transient  module.slz_vsi.ibm_is_lb_pool_member.alb_pool_members[1]  load_balancer_update_pending 409  5c8e2b71-0f4a-4d93-a6e1-7b3d9c0f2a58  CreateLoadBalancerPoolMemberWithContext failed: The load balancer with ID 'r006-4a9c0e1b-7d23-4f6...`+"\n"+
+		`transient  module.slz_vsi.ibm_is_lb.lb["example-nlb"]                -                                 -                                     timeout while waiting for state to become 'done, ' (last state: 'pending', timeout: 45m0s)`+"\n",
+		report.String())
+	assert.Equal(t, "No Terraform error found in the output.\n", repoRules(t).Classify("exit status 1").String())
+}
+
+func TestValidate(t *testing.T) {
+	rules := &Rules{Rules: []*Rule{
+		{Name: "a", Class: "flaky", Patterns: []string{"("}},
+		{Name: "a", Class: Quota},
+	}}
+	assert.EqualError(t, rules.Validate(), "invalid failure classes:\n"+
+		"  rule a: unknown class \"flaky\", one of quota, transient, iam, validation, module_bug\n"+
+		"  rule a: error parsing regexp: missing closing ): `(`\n"+
+		"  rule a: duplicate name\n"+
+		"  rule a: no codes, statuses or patterns")
+}
@@ -0,0 +1,514 @@
+// Package fakevpc is an in-memory fake of the IBM Cloud VPC REST API, for testing the Go tooling of this module
+// (cleanup, snapshot lookups, import, quota checks) without an account. It serves the collections the module
+// touches (instances and their volume attachments, virtual network interfaces, subnets and their reserved IPs,
+// volumes, snapshots, snapshot consistency groups, floating IPs, security groups and their rules, load balancers and
+// images) under `/v1`, with the query
+// parameters, pagination, status codes and error bodies of the real API, so vpc-go-sdk clients work against it:
+//
+//	server := fakevpc.NewServer("us-south")
+//	defer server.Close()
+//	service, _ := vpcv1.NewVpcV1(&vpcv1.VpcV1Options{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+//
+// Resources are plain JSON objects. The server fills in the fields the API generates (id, crn, href, created_at,
+// status and lifecycle_state), and Inject makes requests fail or slow down.
+package fakevpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collections served by the fake
+const (
+	Instances                 = "instances"
+	VolumeAttachments         = "volume_attachments"
+	VirtualNetworkInterfaces  = "virtual_network_interfaces"
+	Subnets                   = "subnets"
+	ReservedIPs               = "reserved_ips"
+	Volumes                   = "volumes"
+	Snapshots                 = "snapshots"
+	SnapshotConsistencyGroups = "snapshot_consistency_groups"
+	FloatingIPs               = "floating_ips"
+	SecurityGroups            = "security_groups"
+	SecurityGroupRules        = "rules"
+	LoadBalancers             = "load_balancers"
+	Images                    = "images"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 100
+	accountID    = "a/0123456789abcdef0123456789abcdef"
+	createdAt    = "2025-01-01T00:00:00Z"
+)
+
+// collection describes how the fake fills in a resource of a collection
+type collection struct {
+	// crnType is the resource type of the CRN, empty for resources without a CRN
+	crnType string
+	// defaults are the status fields of a new resource
+	defaults map[string]interface{}
+}
+
+var collections = map[string]collection{
+	Instances:                 {crnType: "instance", defaults: map[string]interface{}{"status": "running", "lifecycle_state": "stable"}},
+	VolumeAttachments:         {defaults: map[string]interface{}{"status": "attached"}},
+	VirtualNetworkInterfaces:  {crnType: "virtual-network-interface", defaults: map[string]interface{}{"lifecycle_state": "stable"}},
+	Subnets:                   {crnType: "subnet", defaults: map[string]interface{}{"status": "available"}},
+	ReservedIPs:               {defaults: map[string]interface{}{"lifecycle_state": "stable", "owner": "user"}},
+	Volumes:                   {crnType: "volume", defaults: map[string]interface{}{"status": "available"}},
+	Snapshots:                 {crnType: "snapshot", defaults: map[string]interface{}{"lifecycle_state": "stable"}},
+	SnapshotConsistencyGroups: {crnType: "snapshot-consistency-group", defaults: map[string]interface{}{"lifecycle_state": "stable"}},
+	FloatingIPs:               {crnType: "floating-ip", defaults: map[string]interface{}{"status": "available"}},
+	SecurityGroups:            {crnType: "security-group"},
+	SecurityGroupRules:        {},
+	LoadBalancers:             {crnType: "load-balancer", defaults: map[string]interface{}{"provisioning_status": "active", "operating_status": "online"}},
+	Images:                    {crnType: "image", defaults: map[string]interface{}{"status": "available"}},
+}
+
+// nested are the collections served under a resource of another collection (`/v1/subnets/{id}/reserved_ips`), with
+// the collection of their parent
+var nested = map[string]string{
+	VolumeAttachments:  Instances,
+	ReservedIPs:        Subnets,
+	SecurityGroupRules: SecurityGroups,
+}
+
+// Resource is a resource of the fake, as the API returns it
+type Resource map[string]interface{}
+
+// ID returns the id of the resource
+func (r Resource) ID() string {
+	id, _ := r["id"].(string)
+	return id
+}
+
+// Fault makes the requests it matches fail, or wait before being served
+type Fault struct {
+	// Method matches any method when empty
+	Method string
+	// Path is a prefix of the request path (`/v1/instances`), empty matches every request
+	Path string
+	// Status and Code are the status and error code of the failure, no failure when Status is 0
+	Status int
+	Code   string
+	// Delay is waited before failing or serving the request
+	Delay time.Duration
+	// Times is the number of requests the fault applies to, 0 for every request
+	Times int
+}
+
+// Request is a request the fake served, for assertions
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+}
+
+// Server is the fake VPC API. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+	Region string
+
+	mu        sync.Mutex
+	next      int
+	resources map[string][]Resource
+	// parents are the parent ids of the resources of nested collections, by id
+	parents  map[string]string
+	faults   []*Fault
+	requests []Request
+}
+
+// NewServer starts a fake in a region, stop it with Close
+func NewServer(region string) *Server {
+	s := &Server{Region: region, resources: map[string][]Resource{}, parents: map[string]string{}}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Inject adds a fault. Faults are matched in the order they were added.
+func (s *Server) Inject(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &fault)
+}
+
+// Requests returns the requests served so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Seed adds a resource to a collection, filling in the generated fields it does not have, and returns it. Resources
+// of nested collections are seeded with SeedNested.
+func (s *Server) Seed(name string, resource Resource) Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(name, "", resource)
+}
+
+// SeedNested adds a resource to a nested collection (a reserved IP of a subnet, a volume attachment of an instance,
+// a rule of a security group)
+func (s *Server) SeedNested(name string, parentID string, resource Resource) Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(name, parentID, resource)
+}
+
+// Get returns a resource of a collection, nil if it does not exist
+func (s *Server) Get(name string, id string) Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.find(name, id); i >= 0 {
+		return s.resources[name][i]
+	}
+	return nil
+}
+
+// List returns the resources of a collection, in creation order
+func (s *Server) List(name string) []Resource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Resource(nil), s.resources[name]...)
+}
+
+func (s *Server) create(name string, parent string, resource Resource) Resource {
+	created := Resource{}
+	for key, value := range collections[name].defaults {
+		created[key] = value
+	}
+	for key, value := range resource {
+		created[key] = value
+	}
+	if created.ID() == "" {
+		s.next++
+		created["id"] = fmt.Sprintf("r006-%08x-0000-4000-8000-%012x", s.next, s.next)
+	}
+	id := created.ID()
+	if parent != "" {
+		created["href"] = fmt.Sprintf("%s/v1/%s/%s/%s/%s", s.URL, nested[name], parent, name, id)
+		s.parents[id] = parent
+	} else {
+		created["href"] = fmt.Sprintf("%s/v1/%s/%s", s.URL, name, id)
+	}
+	if crnType := collections[name].crnType; crnType != "" {
+		if _, ok := created["crn"]; !ok {
+			created["crn"] = fmt.Sprintf("crn:v1:bluemix:public:is:%s:%s::%s:%s", s.Region, accountID, crnType, id)
+		}
+	}
+	if _, ok := created["created_at"]; !ok {
+		created["created_at"] = createdAt
+	}
+	s.resources[name] = append(s.resources[name], created)
+	return created
+}
+
+func (s *Server) find(name string, id string) int {
+	for i, resource := range s.resources[name] {
+		if resource.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// fault returns the first fault matching the request, and counts it
+func (s *Server) fault(r *http.Request) *Fault {
+	for _, fault := range s.faults {
+		if fault.Method != "" && fault.Method != r.Method || !strings.HasPrefix(r.URL.Path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.removeFault(fault)
+			}
+		}
+		return fault
+	}
+	return nil
+}
+
+func (s *Server) removeFault(fault *Fault) {
+	for i, f := range s.faults {
+		if f == fault {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// ServeHTTP serves the VPC API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery})
+	fault := s.fault(r)
+	s.mu.Unlock()
+	if fault != nil {
+		time.Sleep(fault.Delay)
+		if fault.Status != 0 {
+			code := fault.Code
+			if code == "" {
+				code = "injected_fault"
+			}
+			writeError(w, fault.Status, code, "injected fault")
+			return
+		}
+	}
+
+	if r.URL.Query().Get("version") == "" {
+		writeError(w, http.StatusBadRequest, "missing_version", "The version query parameter is required")
+		return
+	}
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/v1"), "/"), "/")
+	name, parent, id := "", "", ""
+	switch {
+	case len(segments) == 3 && nested[segments[2]] == segments[0]:
+		name, parent = segments[2], segments[1]
+	case len(segments) == 4 && nested[segments[2]] == segments[0]:
+		name, parent, id = segments[2], segments[1], segments[3]
+	case len(segments) == 1:
+		name = segments[0]
+	case len(segments) == 2:
+		name, id = segments[0], segments[1]
+	}
+	if _, ok := collections[name]; !ok || nested[name] != "" && parent == "" {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("No route for %s %s", r.Method, r.URL.Path))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if parent != "" && s.find(nested[name], parent) < 0 {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("%s %s not found", nested[name], parent))
+		return
+	}
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		s.list(w, r, name, parent)
+	case id == "" && r.Method == http.MethodPost:
+		s.post(w, r, name, parent)
+	case id != "" && r.Method == http.MethodGet:
+		if resource := s.lookup(w, name, parent, id); resource != nil {
+			writeJSON(w, http.StatusOK, resource)
+		}
+	case id != "" && r.Method == http.MethodPatch:
+		s.patch(w, r, name, parent, id)
+	case id != "" && r.Method == http.MethodDelete:
+		s.delete(w, name, parent, id)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", fmt.Sprintf("%s is not allowed on %s", r.Method, r.URL.Path))
+	}
+}
+
+// lookup returns a resource, or writes a not found error
+func (s *Server) lookup(w http.ResponseWriter, name string, parent string, id string) Resource {
+	i := s.find(name, id)
+	if i < 0 || parent != "" && s.parents[id] != parent {
+		writeError(w, http.StatusNotFound, "not_found", fmt.Sprintf("%s %s not found", name, id))
+		return nil
+	}
+	return s.resources[name][i]
+}
+
+// list serves a page of a collection. Query parameters other than version, generation, start and limit filter on
+// the field they name (`name`, `resource_group.id`, `source_volume.id`).
+func (s *Server) list(w http.ResponseWriter, r *http.Request, name string, parent string) {
+	query := r.URL.Query()
+	limit := defaultLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxLimit {
+			writeError(w, http.StatusBadRequest, "validation_failed", fmt.Sprintf("limit must be between 1 and %d", maxLimit))
+			return
+		}
+		limit = n
+	}
+	start := 0
+	if value := query.Get("start"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "validation_failed", "invalid start")
+			return
+		}
+		start = n
+	}
+
+	var matching []Resource
+	for _, resource := range s.resources[name] {
+		if parent != "" && s.parents[resource.ID()] != parent || !matches(resource, query) {
+			continue
+		}
+		matching = append(matching, resource)
+	}
+	page := []Resource{}
+	if start < len(matching) {
+		page = matching[start:min(start+limit, len(matching))]
+	}
+
+	base := s.URL + r.URL.Path
+	body := map[string]interface{}{
+		name:          page,
+		"limit":       limit,
+		"total_count": len(matching),
+		"first":       map[string]string{"href": fmt.Sprintf("%s?limit=%d", base, limit)},
+	}
+	if start+limit < len(matching) {
+		body["next"] = map[string]string{"href": fmt.Sprintf("%s?limit=%d&start=%d", base, limit, start+limit)}
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// matches returns true if the resource has the value of every filter of the query
+func matches(resource Resource, query map[string][]string) bool {
+	for key, values := range query {
+		switch key {
+		case "version", "generation", "start", "limit":
+			continue
+		}
+		if field(resource, key) != values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// field returns a string field of the resource by dotted path, empty if there is none
+func field(resource Resource, path string) string {
+	var value interface{} = map[string]interface{}(resource)
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+func (s *Server) post(w http.ResponseWriter, r *http.Request, name string, parent string) {
+	resource := Resource{}
+	if !readJSON(w, r, &resource) {
+		return
+	}
+	delete(resource, "id")
+	if resourceName, ok := resource["name"].(string); ok && resourceName != "" {
+		for _, existing := range s.resources[name] {
+			if field(existing, "name") == resourceName && s.parents[existing.ID()] == parent {
+				writeError(w, http.StatusBadRequest, "validation_failed", fmt.Sprintf("The name %s is already used by %s", resourceName, existing.ID()))
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusCreated, s.create(name, parent, resource))
+}
+
+// patch merges the body into the resource (JSON merge patch); the generated fields can not be changed
+func (s *Server) patch(w http.ResponseWriter, r *http.Request, name string, parent string, id string) {
+	resource := s.lookup(w, name, parent, id)
+	if resource == nil {
+		return
+	}
+	patch := Resource{}
+	if !readJSON(w, r, &patch) {
+		return
+	}
+	for key, value := range patch {
+		switch key {
+		case "id", "crn", "href", "created_at":
+			continue
+		}
+		if value == nil {
+			delete(resource, key)
+			continue
+		}
+		resource[key] = value
+	}
+	writeJSON(w, http.StatusOK, resource)
+}
+
+// delete removes a resource. As in the API, a snapshot consistency group can not be deleted while it has snapshots
+// unless delete_snapshots_on_delete is set, in which case they are deleted with it.
+func (s *Server) delete(w http.ResponseWriter, name string, parent string, id string) {
+	resource := s.lookup(w, name, parent, id)
+	if resource == nil {
+		return
+	}
+	if name == SnapshotConsistencyGroups {
+		var members []string
+		for _, snapshot := range s.resources[Snapshots] {
+			if field(snapshot, "snapshot_consistency_group.id") == id {
+				members = append(members, snapshot.ID())
+			}
+		}
+		if len(members) > 0 {
+			if deleteSnapshots, _ := resource["delete_snapshots_on_delete"].(bool); !deleteSnapshots {
+				writeError(w, http.StatusConflict, "snapshot_consistency_group_in_use", fmt.Sprintf("Snapshot consistency group %s has snapshots %s", id, strings.Join(members, ", ")))
+				return
+			}
+			for _, member := range members {
+				s.remove(Snapshots, member)
+			}
+		}
+	}
+	s.remove(name, id)
+	delete(s.parents, id)
+	// the nested resources go with their parent
+	for child, parentCollection := range nested {
+		if parentCollection != name {
+			continue
+		}
+		var orphans []string
+		for _, resource := range s.resources[child] {
+			if s.parents[resource.ID()] == id {
+				orphans = append(orphans, resource.ID())
+			}
+		}
+		for _, orphan := range orphans {
+			s.remove(child, orphan)
+			delete(s.parents, orphan)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) remove(name string, id string) {
+	if i := s.find(name, id); i >= 0 {
+		s.resources[name] = append(s.resources[name][:i], s.resources[name][i+1:]...)
+	}
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	data, err := io.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, value)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "validation_failed", fmt.Sprintf("Invalid request body: %s", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error in the format of the VPC API
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+		"trace":  "fakevpc",
+	})
+}
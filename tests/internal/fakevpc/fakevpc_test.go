@@ -0,0 +1,427 @@
+package fakevpc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/IBM/vpc-go-sdk/vpcv1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// client sends requests the way vpc-go-sdk does: version and generation query parameters, JSON bodies
+type client struct {
+	service *core.BaseService
+}
+
+func newClient(t *testing.T, server *Server) *client {
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	return &client{service: service}
+}
+
+func (c *client) do(method string, path string, query map[string]string, body interface{}, result interface{}) (*core.DetailedResponse, error) {
+	builder := core.NewRequestBuilder(method).WithContext(context.Background())
+	if _, err := builder.ResolveRequestURL(c.service.GetServiceURL(), path, nil); err != nil {
+		return nil, err
+	}
+	builder.AddQuery("version", "2025-01-01")
+	builder.AddQuery("generation", "2")
+	for name, value := range query {
+		builder.AddQuery(name, value)
+	}
+	if body != nil {
+		if _, err := builder.SetBodyContentJSON(body); err != nil {
+			return nil, err
+		}
+	}
+	request, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	return c.service.Request(request, result)
+}
+
+// errorCode returns the code of the first error of a VPC API error response
+func errorCode(response *core.DetailedResponse) string {
+	result, _ := response.Result.(map[string]interface{})
+	errors, _ := result["errors"].([]interface{})
+	if len(errors) == 0 {
+		return ""
+	}
+	code, _ := errors[0].(map[string]interface{})["code"].(string)
+	return code
+}
+
+func TestCRUD(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	c := newClient(t, server)
+
+	var volume map[string]interface{}
+	response, err := c.do(core.POST, "/volumes", nil, map[string]interface{}{"name": "vsi-data", "capacity": 100, "profile": map[string]string{"name": "general-purpose"}}, &volume)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	id := volume["id"].(string)
+	assert.Equal(t, "available", volume["status"])
+	assert.Equal(t, "crn:v1:bluemix:public:is:us-south:a/0123456789abcdef0123456789abcdef::volume:"+id, volume["crn"])
+	assert.Equal(t, server.URL+"/v1/volumes/"+id, volume["href"])
+
+	_, err = c.do(core.POST, "/volumes", nil, map[string]interface{}{"name": "vsi-data"}, &volume)
+	assert.ErrorContains(t, err, "already used")
+
+	_, err = c.do(core.PATCH, "/volumes/"+id, nil, map[string]interface{}{"capacity": 200, "id": "changed"}, &volume)
+	require.NoError(t, err)
+	assert.Equal(t, float64(200), volume["capacity"])
+	assert.Equal(t, id, volume["id"])
+
+	response, err = c.do(core.DELETE, "/volumes/"+id, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Nil(t, server.Get(Volumes, id))
+
+	response, err = c.do(core.GET, "/volumes/"+id, nil, nil, &volume)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, "not_found", errorCode(response))
+}
+
+func TestListFiltersAndPages(t *testing.T) {
+	server := NewServer("au-syd")
+	defer server.Close()
+	c := newClient(t, server)
+
+	boot := server.Seed(Volumes, Resource{"name": "boot"})
+	for _, name := range []string{"snap-1", "snap-2", "snap-3"} {
+		server.Seed(Snapshots, Resource{"name": name, "source_volume": map[string]interface{}{"id": boot.ID()}})
+	}
+	server.Seed(Snapshots, Resource{"name": "other", "source_volume": map[string]interface{}{"id": "r006-other"}})
+
+	var names []string
+	query := map[string]string{"source_volume.id": boot.ID(), "limit": "2"}
+	for {
+		var page struct {
+			Snapshots  []map[string]interface{} `json:"snapshots"`
+			TotalCount int                      `json:"total_count"`
+			Next       *struct {
+				Href string `json:"href"`
+			} `json:"next"`
+		}
+		_, err := c.do(core.GET, "/snapshots", query, nil, &page)
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.TotalCount)
+		for _, snapshot := range page.Snapshots {
+			names = append(names, snapshot["name"].(string))
+		}
+		if page.Next == nil {
+			break
+		}
+		// vpc-go-sdk pagers read the start token from the next href
+		start, err := core.GetQueryParam(&page.Next.Href, "start")
+		require.NoError(t, err)
+		query["start"] = *start
+	}
+	assert.Equal(t, []string{"snap-1", "snap-2", "snap-3"}, names)
+}
+
+func TestReservedIPs(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	c := newClient(t, server)
+
+	subnet := server.Seed(Subnets, Resource{"name": "subnet-a"})
+	other := server.Seed(Subnets, Resource{"name": "subnet-b"})
+	server.SeedNested(ReservedIPs, other.ID(), Resource{"name": "vsi-ip"})
+
+	var reservedIP map[string]interface{}
+	_, err := c.do(core.POST, "/subnets/"+subnet.ID()+"/reserved_ips", nil, map[string]interface{}{"name": "vsi-ip", "address": "10.10.10.4"}, &reservedIP)
+	require.NoError(t, err, "names are unique per subnet")
+	assert.Equal(t, server.URL+"/v1/subnets/"+subnet.ID()+"/reserved_ips/"+reservedIP["id"].(string), reservedIP["href"])
+	assert.Nil(t, reservedIP["crn"])
+
+	var list struct {
+		ReservedIPs []map[string]interface{} `json:"reserved_ips"`
+	}
+	_, err = c.do(core.GET, "/subnets/"+subnet.ID()+"/reserved_ips", nil, nil, &list)
+	require.NoError(t, err)
+	assert.Len(t, list.ReservedIPs, 1)
+
+	response, err := c.do(core.GET, "/subnets/"+other.ID()+"/reserved_ips/"+reservedIP["id"].(string), nil, nil, &reservedIP)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	_, err = c.do(core.DELETE, "/subnets/"+subnet.ID(), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, server.List(ReservedIPs), 1)
+	response, err = c.do(core.GET, "/subnets/"+subnet.ID()+"/reserved_ips", nil, nil, &list)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestNestedCollections(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	c := newClient(t, server)
+
+	instance := server.Seed(Instances, Resource{"name": "vsi-1"})
+	attachment := server.SeedNested(VolumeAttachments, instance.ID(), Resource{"name": "vsi-1-data"})
+	group := server.Seed(SecurityGroups, Resource{"name": "vsi-sg"})
+	var rule map[string]interface{}
+	_, err := c.do(core.POST, "/security_groups/"+group.ID()+"/rules", nil, map[string]interface{}{"direction": "inbound"}, &rule)
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/v1/security_groups/"+group.ID()+"/rules/"+rule["id"].(string), rule["href"])
+
+	_, err = c.do(core.PATCH, "/security_groups/"+group.ID()+"/rules/"+rule["id"].(string), nil, map[string]interface{}{"direction": "outbound"}, &rule)
+	require.NoError(t, err)
+	assert.Equal(t, "outbound", server.List(SecurityGroupRules)[0]["direction"])
+
+	response, err := c.do(core.GET, "/instances/"+instance.ID()+"/volume_attachments", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	_, err = c.do(core.DELETE, "/instances/"+instance.ID()+"/volume_attachments/"+attachment.ID(), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, server.List(VolumeAttachments))
+
+	_, err = c.do(core.DELETE, "/security_groups/"+group.ID(), nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, server.List(SecurityGroupRules), "the rules go with their group")
+}
+
+func TestConsistencyGroupDelete(t *testing.T) {
+	server := NewServer("au-syd")
+	defer server.Close()
+	c := newClient(t, server)
+
+	kept := server.Seed(SnapshotConsistencyGroups, Resource{"name": "kept"})
+	server.Seed(Snapshots, Resource{"name": "kept-boot", "snapshot_consistency_group": map[string]interface{}{"id": kept.ID()}})
+	deleted := server.Seed(SnapshotConsistencyGroups, Resource{"name": "deleted", "delete_snapshots_on_delete": true})
+	server.Seed(Snapshots, Resource{"name": "deleted-boot", "snapshot_consistency_group": map[string]interface{}{"id": deleted.ID()}})
+
+	response, err := c.do(core.DELETE, "/snapshot_consistency_groups/"+kept.ID(), nil, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+
+	_, err = c.do(core.DELETE, "/snapshot_consistency_groups/"+deleted.ID(), nil, nil, nil)
+	require.NoError(t, err)
+	snapshots := server.List(Snapshots)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, "kept-boot", snapshots[0]["name"])
+}
+
+func TestValidation(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/instances")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "version is required")
+
+	c := newClient(t, server)
+	for path, status := range map[string]int{"/vpcs": http.StatusNotFound, "/reserved_ips": http.StatusNotFound, "/instances/a/b/c": http.StatusNotFound} {
+		response, err := c.do(core.GET, path, nil, nil, nil)
+		require.Error(t, err)
+		assert.Equal(t, status, response.StatusCode, path)
+	}
+	response, err := c.do(core.GET, "/images", map[string]string{"limit": "500"}, nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	response, err = c.do(core.PUT, "/images/x", nil, map[string]string{}, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func TestFaults(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	c := newClient(t, server)
+	instance := server.Seed(Instances, Resource{"name": "vsi-001"})
+
+	server.Inject(Fault{Method: core.GET, Path: "/v1/instances", Status: http.StatusServiceUnavailable, Times: 2})
+	server.Inject(Fault{Path: "/v1/floating_ips", Status: http.StatusTooManyRequests, Code: "rate_limit_exceeded"})
+	server.Inject(Fault{Path: "/v1/load_balancers", Delay: 20 * time.Millisecond})
+
+	var result map[string]interface{}
+	for i := 0; i < 2; i++ {
+		response, err := c.do(core.GET, "/instances/"+instance.ID(), nil, nil, &result)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	}
+	_, err := c.do(core.GET, "/instances/"+instance.ID(), nil, nil, &result)
+	require.NoError(t, err, "the fault applies twice")
+	assert.Equal(t, "running", result["status"])
+
+	response, err := c.do(core.GET, "/floating_ips", nil, nil, &result)
+	require.Error(t, err)
+	assert.Equal(t, "rate_limit_exceeded", errorCode(response))
+
+	started := time.Now()
+	_, err = c.do(core.GET, "/load_balancers", nil, nil, &result)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(started), 20*time.Millisecond)
+
+	requests := server.Requests()
+	require.Len(t, requests, 5)
+	assert.Equal(t, Request{Method: core.GET, Path: "/v1/load_balancers", Query: "generation=2&version=2025-01-01"}, requests[4])
+}
+
+// newVPC returns a vpc-go-sdk client of the fake
+func newVPC(t *testing.T, server *Server) *vpcv1.VpcV1 {
+	vpc, err := vpcv1.NewVpcV1(&vpcv1.VpcV1Options{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	return vpc
+}
+
+func TestSDKVolumes(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	vpc := newVPC(t, server)
+
+	volume, response, err := vpc.CreateVolume(vpc.NewCreateVolumeOptions(&vpcv1.VolumePrototypeVolumeByCapacity{
+		Name:     core.StringPtr("vsi-data"),
+		Profile:  &vpcv1.VolumeProfileIdentityByName{Name: core.StringPtr("general-purpose")},
+		Zone:     &vpcv1.ZoneIdentityByName{Name: core.StringPtr("us-south-1")},
+		Capacity: core.Int64Ptr(100),
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode)
+	assert.Equal(t, "available", *volume.Status)
+	assert.Equal(t, "general-purpose", *volume.Profile.Name)
+	assert.Equal(t, "crn:v1:bluemix:public:is:us-south:a/0123456789abcdef0123456789abcdef::volume:"+*volume.ID, *volume.CRN)
+
+	patch, err := (&vpcv1.VolumePatch{Capacity: core.Int64Ptr(200)}).AsPatch()
+	require.NoError(t, err)
+	volume, _, err = vpc.UpdateVolume(vpc.NewUpdateVolumeOptions(*volume.ID, patch))
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), *volume.Capacity)
+
+	volumes, _, err := vpc.ListVolumes(vpc.NewListVolumesOptions())
+	require.NoError(t, err)
+	require.Len(t, volumes.Volumes, 1)
+	assert.Equal(t, "vsi-data", *volumes.Volumes[0].Name)
+
+	_, err = vpc.DeleteVolume(vpc.NewDeleteVolumeOptions(*volume.ID))
+	require.NoError(t, err)
+	_, response, err = vpc.GetVolume(vpc.NewGetVolumeOptions(*volume.ID))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestSDKInstances(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	vpc := newVPC(t, server)
+	subnet := server.Seed(Subnets, Resource{"name": "subnet-a"})
+
+	for _, name := range []string{"vsi-1", "vsi-2"} {
+		instance, _, err := vpc.CreateInstance(vpc.NewCreateInstanceOptions(&vpcv1.InstancePrototypeInstanceByImage{
+			Name:    core.StringPtr(name),
+			Image:   &vpcv1.ImageIdentityByID{ID: core.StringPtr("r006-image")},
+			Profile: &vpcv1.InstanceProfileIdentityByName{Name: core.StringPtr("cx2-2x4")},
+			VPC:     &vpcv1.VPCIdentityByID{ID: core.StringPtr("r006-vpc")},
+			Zone:    &vpcv1.ZoneIdentityByName{Name: core.StringPtr("us-south-1")},
+			PrimaryNetworkInterface: &vpcv1.NetworkInterfacePrototype{
+				Subnet: &vpcv1.SubnetIdentityByID{ID: core.StringPtr(subnet.ID())},
+			},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, "running", *instance.Status)
+		assert.Equal(t, "cx2-2x4", *instance.Profile.Name)
+	}
+	server.Seed(Instances, Resource{"name": "other-vpc", "vpc": map[string]interface{}{"id": "r006-other"}})
+
+	instances, _, err := vpc.ListInstances(&vpcv1.ListInstancesOptions{VPCID: core.StringPtr("r006-vpc")})
+	require.NoError(t, err)
+	require.Len(t, instances.Instances, 2)
+	assert.Equal(t, int64(2), *instances.TotalCount)
+
+	id := *instances.Instances[0].ID
+	instance, _, err := vpc.GetInstance(vpc.NewGetInstanceOptions(id))
+	require.NoError(t, err)
+	assert.Equal(t, "vsi-1", *instance.Name)
+	assert.Equal(t, subnet.ID(), *instance.PrimaryNetworkInterface.Subnet.ID)
+
+	_, err = vpc.DeleteInstance(vpc.NewDeleteInstanceOptions(id))
+	require.NoError(t, err)
+	assert.Nil(t, server.Get(Instances, id))
+}
+
+func TestSDKSnapshots(t *testing.T) {
+	server := NewServer("au-syd")
+	defer server.Close()
+	vpc := newVPC(t, server)
+	boot := server.Seed(Volumes, Resource{"name": "boot"})
+	server.Seed(Snapshots, Resource{"name": "other", "source_volume": map[string]interface{}{"id": "r026-other"}})
+
+	for _, name := range []string{"snap-1", "snap-2", "snap-3"} {
+		options := vpc.NewCreateSnapshotOptions(&vpcv1.VolumeIdentityByID{ID: core.StringPtr(boot.ID())})
+		options.Name = core.StringPtr(name)
+		snapshot, _, err := vpc.CreateSnapshot(options)
+		require.NoError(t, err)
+		assert.Equal(t, "stable", *snapshot.LifecycleState)
+	}
+
+	// the pages of the snapshots of the volume, read with the start token of the next href
+	var names []string
+	options := &vpcv1.ListSnapshotsOptions{SourceVolumeID: core.StringPtr(boot.ID()), Limit: core.Int64Ptr(2)}
+	for {
+		page, _, err := vpc.ListSnapshots(options)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), *page.TotalCount)
+		for _, snapshot := range page.Snapshots {
+			names = append(names, *snapshot.Name)
+		}
+		start, err := page.GetNextStart()
+		require.NoError(t, err)
+		if start == nil {
+			break
+		}
+		options.Start = start
+	}
+	assert.Equal(t, []string{"snap-1", "snap-2", "snap-3"}, names)
+
+	snapshots := server.List(Snapshots)
+	_, err := vpc.DeleteSnapshot(vpc.NewDeleteSnapshotOptions(snapshots[0].ID()))
+	require.NoError(t, err)
+	_, response, err := vpc.GetSnapshot(vpc.NewGetSnapshotOptions(snapshots[0].ID()))
+	require.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+}
+
+func TestSDKFloatingIPs(t *testing.T) {
+	server := NewServer("us-south")
+	defer server.Close()
+	vpc := newVPC(t, server)
+
+	floatingIP, _, err := vpc.CreateFloatingIP(vpc.NewCreateFloatingIPOptions(&vpcv1.FloatingIPPrototypeFloatingIPByZone{
+		Name: core.StringPtr("vsi-fip"),
+		Zone: &vpcv1.ZoneIdentityByName{Name: core.StringPtr("us-south-1")},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "available", *floatingIP.Status)
+	assert.Equal(t, "us-south-1", *floatingIP.Zone.Name)
+
+	patch, err := (&vpcv1.FloatingIPPatch{Name: core.StringPtr("vsi-fip-renamed")}).AsPatch()
+	require.NoError(t, err)
+	floatingIP, _, err = vpc.UpdateFloatingIP(vpc.NewUpdateFloatingIPOptions(*floatingIP.ID, patch))
+	require.NoError(t, err)
+	assert.Equal(t, "vsi-fip-renamed", *floatingIP.Name)
+
+	// the VPC API error of a fault reaches the SDK caller
+	server.Inject(Fault{Path: "/v1/floating_ips", Status: http.StatusTooManyRequests, Code: "rate_limit_exceeded", Times: 1})
+	_, response, err := vpc.ListFloatingIps(vpc.NewListFloatingIpsOptions())
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.Equal(t, "rate_limit_exceeded", errorCode(response))
+
+	floatingIPs, _, err := vpc.ListFloatingIps(vpc.NewListFloatingIpsOptions())
+	require.NoError(t, err)
+	require.Len(t, floatingIPs.FloatingIps, 1)
+
+	_, err = vpc.DeleteFloatingIP(vpc.NewDeleteFloatingIPOptions(*floatingIP.ID))
+	require.NoError(t, err)
+	assert.Empty(t, server.List(FloatingIPs))
+}
@@ -0,0 +1,408 @@
+// Package faultproxy is an HTTP(S) reverse proxy between the IBM Cloud provider and the VPC API of a region that
+// injects latency, throttling (429), server errors (5xx) and connection resets into the requests of the endpoints
+// listed by a scenario of tests/fault-scenarios.yaml. It checks that the module and the harness survive a slow or
+// failing API: Terraform retries the failed requests, the apply stays within the `max_apply` of the scenario (the 45
+// minute timeouts of the load balancers), and the cleanup still destroys everything. The tests of the package proxy
+// internal/fakevpc instead.
+//
+//	server, _ := faultproxy.NewServer("https://us-south.iaas.cloud.ibm.com", scenario.Faults, false)
+//	defer server.Close()
+//	// IBMCLOUD_IS_NG_API_ENDPOINT=server.URL + "/v1" in the environment of Terraform
+//
+// Faults are matched in order, the first one matching the method and the path of a request applies to it.
+package faultproxy
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ScenariosPath is the path of the scenarios, relative to the root of the module
+const ScenariosPath = "tests/fault-scenarios.yaml"
+
+// Fault slows down or fails the requests it matches
+type Fault struct {
+	Name string `yaml:"name"`
+	// Method matches any method when empty
+	Method string `yaml:"method"`
+	// Path is a regular expression matched against the request path (`^/v1/load_balancers/[^/]+$`)
+	Path string `yaml:"path"`
+	// Latency is waited before failing or forwarding the request
+	Latency time.Duration `yaml:"latency"`
+	// Status and Code are the status and the error code of the failure, no failure when Status is 0
+	Status int    `yaml:"status"`
+	Code   string `yaml:"code"`
+	// RetryAfter is sent in the Retry-After header of a failure when set
+	RetryAfter time.Duration `yaml:"retry_after"`
+	// Reset closes the connection of the request without a response
+	Reset bool `yaml:"reset"`
+	// Every injects the fault into every n-th matching request only, every one when 0 or 1
+	Every int `yaml:"every"`
+	// Times is the number of requests the fault is injected into, 0 for no limit
+	Times int `yaml:"times"`
+	path  *regexp.Regexp
+}
+
+// Persistent returns whether the fault fails every request it matches, which no retry gets through
+func (f *Fault) Persistent() bool {
+	return (f.Status != 0 || f.Reset) && f.Every <= 1 && f.Times == 0
+}
+
+// action describes what the fault does to a request
+func (f *Fault) action() string {
+	var actions []string
+	if f.Latency > 0 {
+		actions = append(actions, "latency "+f.Latency.String())
+	}
+	if f.Status != 0 {
+		actions = append(actions, strconv.Itoa(f.Status))
+	}
+	if f.Reset {
+		actions = append(actions, "reset")
+	}
+	return strings.Join(actions, " + ")
+}
+
+// Scenario is a set of faults injected while an example is applied and destroyed
+type Scenario struct {
+	Description string `yaml:"description"`
+	// Example is the directory of the example applied through the proxy, relative to the root of the module
+	Example string `yaml:"example"`
+	// ApplyFails is set when the faults are expected to make the apply fail, only the cleanup has to succeed
+	ApplyFails bool `yaml:"apply_fails"`
+	// MaxApply is the longest the apply may take through the faults, no limit when 0
+	MaxApply time.Duration `yaml:"max_apply"`
+	Faults   []*Fault      `yaml:"faults"`
+}
+
+// Scenarios is the content of the scenarios file
+type Scenarios struct {
+	Scenarios map[string]*Scenario `yaml:"scenarios"`
+}
+
+// LoadScenarios reads the scenarios file
+func LoadScenarios(path string) (*Scenarios, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fault scenarios %s: %w", path, err)
+	}
+	scenarios := &Scenarios{}
+	if err := yaml.Unmarshal(data, scenarios); err != nil {
+		return nil, fmt.Errorf("error parsing fault scenarios %s: %w", path, err)
+	}
+	if err := scenarios.Validate(); err != nil {
+		return nil, fmt.Errorf("fault scenarios %s: %w", path, err)
+	}
+	return scenarios, nil
+}
+
+// Names returns the names of the scenarios, sorted
+func (s *Scenarios) Names() []string {
+	var names []string
+	for name := range s.Scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the scenarios and compiles the paths of their faults
+func (s *Scenarios) Validate() error {
+	var problems []string
+	for _, name := range s.Names() {
+		scenario := s.Scenarios[name]
+		if scenario.Example == "" {
+			problems = append(problems, "scenario "+name+": no example")
+		}
+		if len(scenario.Faults) == 0 {
+			problems = append(problems, "scenario "+name+": no faults")
+		}
+		if scenario.MaxApply < 0 {
+			problems = append(problems, "scenario "+name+": max_apply can not be negative")
+		}
+		if scenario.MaxApply != 0 && scenario.ApplyFails {
+			problems = append(problems, "scenario "+name+": max_apply and apply_fails are exclusive")
+		}
+		for _, problem := range validateFaults(scenario.Faults) {
+			problems = append(problems, "scenario "+name+": "+problem)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid fault scenarios:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func validateFaults(faults []*Fault) []string {
+	var problems []string
+	names := map[string]bool{}
+	for i, fault := range faults {
+		if fault.Name == "" {
+			problems = append(problems, fmt.Sprintf("fault %d: no name", i))
+		} else if names[fault.Name] {
+			problems = append(problems, "fault "+fault.Name+": duplicate name")
+		}
+		names[fault.Name] = true
+		path, err := regexp.Compile(fault.Path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("fault %s: %v", fault.Name, err))
+		}
+		fault.path = path
+		switch {
+		case fault.Status != 0 && fault.Reset:
+			problems = append(problems, "fault "+fault.Name+": status and reset are exclusive")
+		case fault.Status == 0 && !fault.Reset && fault.Latency <= 0:
+			problems = append(problems, "fault "+fault.Name+": no status, reset or latency")
+		case fault.Status != 0 && (fault.Status < 400 || fault.Status > 599):
+			problems = append(problems, fmt.Sprintf("fault %s: status %d is not an error", fault.Name, fault.Status))
+		}
+		if fault.RetryAfter != 0 && fault.Status == 0 {
+			problems = append(problems, "fault "+fault.Name+": retry_after without a status")
+		}
+		if fault.Latency < 0 || fault.RetryAfter < 0 || fault.Every < 0 || fault.Times < 0 {
+			problems = append(problems, "fault "+fault.Name+": latency, retry_after, every and times can not be negative")
+		}
+	}
+	return problems
+}
+
+// Stats counts the requests a fault matched and the ones it was injected into
+type Stats struct {
+	Fault    string
+	Matched  int
+	Injected int
+}
+
+// Request is a request the proxy served
+type Request struct {
+	Method string
+	Path   string
+	// Fault is the name of the fault injected into the request, empty for a request forwarded as is
+	Fault string
+}
+
+// Proxy forwards the requests to its target, injecting the faults. It is safe for concurrent use.
+type Proxy struct {
+	faults  []*Fault
+	forward *httputil.ReverseProxy
+
+	mu       sync.Mutex
+	stats    map[*Fault]*Stats
+	requests []Request
+}
+
+// New returns a proxy to the API at target (`https://us-south.iaas.cloud.ibm.com`) injecting the faults
+func New(target string, faults []*Fault) (*Proxy, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid target %q, expected a URL like https://us-south.iaas.cloud.ibm.com", target)
+	}
+	if problems := validateFaults(faults); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid faults:\n  %s", strings.Join(problems, "\n  "))
+	}
+	p := &Proxy{faults: faults, stats: map[*Fault]*Stats{}}
+	for _, fault := range faults {
+		p.stats[fault] = &Stats{Fault: fault.Name}
+	}
+	p.forward = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(targetURL)
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			writeError(w, http.StatusBadGateway, "proxy_error", fmt.Sprintf("error forwarding %s %s: %v", r.Method, r.URL.Path, err))
+		},
+	}
+	return p, nil
+}
+
+// match returns the first fault matching the request and whether to inject it, and records the request
+func (p *Proxy) match(r *http.Request) (*Fault, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	request := Request{Method: r.Method, Path: r.URL.Path}
+	defer func() { p.requests = append(p.requests, request) }()
+	for _, fault := range p.faults {
+		if fault.Method != "" && fault.Method != r.Method || !fault.path.MatchString(r.URL.Path) {
+			continue
+		}
+		stats := p.stats[fault]
+		stats.Matched++
+		inject := (fault.Every <= 1 || stats.Matched%fault.Every == 0) && (fault.Times == 0 || stats.Injected < fault.Times)
+		if inject {
+			stats.Injected++
+			request.Fault = fault.Name
+		}
+		return fault, inject
+	}
+	return nil, false
+}
+
+// ServeHTTP injects the fault matching the request, and forwards the request unless the fault fails it
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fault, inject := p.match(r)
+	if !inject {
+		p.forward.ServeHTTP(w, r)
+		return
+	}
+	if fault.Latency > 0 {
+		timer := time.NewTimer(fault.Latency)
+		select {
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+	switch {
+	case fault.Reset:
+		reset(w)
+	case fault.Status != 0:
+		if fault.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int((fault.RetryAfter+time.Second-1)/time.Second)))
+		}
+		code := fault.Code
+		if code == "" {
+			code = "injected_fault"
+		}
+		writeError(w, fault.Status, code, fmt.Sprintf("fault %s injected by faultproxy", fault.Name))
+	default:
+		p.forward.ServeHTTP(w, r)
+	}
+}
+
+// reset closes the connection of a request without a response, with a TCP reset when the connection allows it
+func reset(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// Stats returns the counts of the faults, in their order
+func (p *Proxy) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var stats []Stats
+	for _, fault := range p.faults {
+		stats = append(stats, *p.stats[fault])
+	}
+	return stats
+}
+
+// Requests returns the requests served so far
+func (p *Proxy) Requests() []Request {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Request(nil), p.requests...)
+}
+
+// Verify returns the faults that did not exercise the client as expected: the ones no request matched, and the
+// failures no matching request followed, which the client did not retry
+func (p *Proxy) Verify() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var problems []string
+	for _, fault := range p.faults {
+		stats := p.stats[fault]
+		switch {
+		case stats.Matched == 0:
+			problems = append(problems, fmt.Sprintf("fault %s matched no request, the scenario does not exercise it", fault.Name))
+		case fault.Status != 0 || fault.Reset:
+			if !fault.Persistent() && stats.Matched == stats.Injected {
+				problems = append(problems, fmt.Sprintf("the %d request(s) failed by fault %s were not retried", stats.Injected, fault.Name))
+			}
+		}
+	}
+	return problems
+}
+
+// String renders the counts of the faults as a table
+func (p *Proxy) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FAULT\tENDPOINT\tACTION\tMATCHED\tINJECTED")
+	for i, stats := range p.Stats() {
+		fault := p.faults[i]
+		method := fault.Method
+		if method == "" {
+			method = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s %s\t%s\t%d\t%d\n", fault.Name, method, fault.Path, fault.action(), stats.Matched, stats.Injected)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+// Server serves a proxy on a local port
+type Server struct {
+	*httptest.Server
+	Proxy *Proxy
+}
+
+// NewServer starts a proxy to target on a local port, over TLS with a self-signed certificate when useTLS is set
+// (see CertFile), stop it with Close
+func NewServer(target string, faults []*Fault, useTLS bool) (*Server, error) {
+	proxy, err := New(target, faults)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{Server: httptest.NewUnstartedServer(proxy), Proxy: proxy}
+	if useTLS {
+		server.StartTLS()
+	} else {
+		server.Start()
+	}
+	return server, nil
+}
+
+// CertFile writes the certificate of a TLS server in PEM, for the clients to trust (`SSL_CERT_FILE` of Terraform,
+// along with the certificates of the other IBM Cloud endpoints the provider calls)
+func (s *Server) CertFile(path string) error {
+	certificate := s.Certificate()
+	if certificate == nil {
+		return fmt.Errorf("the proxy at %s does not serve TLS", s.URL)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing the certificate of the proxy %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeError writes an error in the format of the VPC API
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": message}},
+		"trace":  "faultproxy",
+	})
+}
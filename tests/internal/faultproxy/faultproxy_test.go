@@ -0,0 +1,199 @@
+package faultproxy
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fakevpc"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+func TestRepoScenarios(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	scenarios, err := LoadScenarios(filepath.Join(root, ScenariosPath))
+	require.NoError(t, err)
+	require.NotEmpty(t, scenarios.Scenarios)
+	for _, name := range scenarios.Names() {
+		assert.DirExists(t, filepath.Join(root, scenarios.Scenarios[name].Example), "example of scenario %s", name)
+	}
+}
+
+// client returns a client of the VPC API behind the proxy, retrying as the provider does with a short backoff
+func client(t *testing.T, server *Server, retries int) *core.BaseService {
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	if server.Certificate() != nil {
+		service.SetHTTPClient(server.Client())
+	}
+	if retries > 0 {
+		service.EnableRetries(retries, 10*time.Millisecond)
+	}
+	return service
+}
+
+func request(t *testing.T, service *core.BaseService, method string, path string, body map[string]interface{}) (map[string]interface{}, *core.DetailedResponse, error) {
+	builder := core.NewRequestBuilder(method)
+	_, err := builder.ResolveRequestURL(service.GetServiceURL(), path, nil)
+	require.NoError(t, err)
+	builder.AddHeader("Accept", "application/json")
+	builder.AddQuery("version", "2025-01-01")
+	if body != nil {
+		_, err := builder.SetBodyContentJSON(body)
+		require.NoError(t, err)
+	}
+	req, err := builder.Build()
+	require.NoError(t, err)
+	var result map[string]interface{}
+	response, err := service.Request(req, &result)
+	return result, response, err
+}
+
+func TestProxy(t *testing.T) {
+	api := fakevpc.NewServer("us-south")
+	defer api.Close()
+	volume := api.Seed(fakevpc.Volumes, fakevpc.Resource{"name": "slz-vsi-block-1"})
+	faults := []*Fault{
+		{Name: "create-unavailable", Method: http.MethodPost, Path: "^/v1/volumes$", Status: 503, Code: "service_unavailable", Times: 2},
+		{Name: "read-reset", Method: http.MethodGet, Path: "^/v1/volumes/[^/]+$", Reset: true, Times: 1},
+		{Name: "list-throttled", Method: http.MethodGet, Path: "^/v1/volumes$", Status: 429, Every: 2, Times: 1},
+		{Name: "slow-subnets", Path: "^/v1/subnets", Latency: 50 * time.Millisecond},
+	}
+	server, err := NewServer(api.URL, faults, false)
+	require.NoError(t, err)
+	defer server.Close()
+	service := client(t, server, 3)
+
+	created, _, err := request(t, service, core.POST, "/volumes", map[string]interface{}{"name": "slz-vsi-block-2"})
+	require.NoError(t, err)
+	assert.Equal(t, "slz-vsi-block-2", created["name"])
+	assert.Len(t, api.List(fakevpc.Volumes), 2, "the failed creations did not reach the API")
+
+	read, _, err := request(t, service, core.GET, "/volumes/"+volume.ID(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "slz-vsi-block-1", read["name"])
+
+	for i := 0; i < 2; i++ {
+		_, _, err = request(t, service, core.GET, "/volumes", nil)
+		require.NoError(t, err)
+	}
+
+	started := time.Now()
+	_, _, err = request(t, service, core.GET, "/subnets", nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(started), 50*time.Millisecond)
+
+	assert.Equal(t, []Stats{
+		{Fault: "create-unavailable", Matched: 3, Injected: 2},
+		{Fault: "read-reset", Matched: 2, Injected: 1},
+		{Fault: "list-throttled", Matched: 3, Injected: 1},
+		{Fault: "slow-subnets", Matched: 1, Injected: 1},
+	}, server.Proxy.Stats())
+	assert.Empty(t, server.Proxy.Verify())
+	assert.Equal(t, Request{Method: http.MethodPost, Path: "/v1/volumes", Fault: "create-unavailable"}, server.Proxy.Requests()[0])
+	assert.Equal(t, Request{Method: http.MethodPost, Path: "/v1/volumes"}, server.Proxy.Requests()[2])
+	assert.Equal(t, "FAULT               ENDPOINT                 ACTION        MATCHED  INJECTED\n"+
+		"create-unavailable  POST ^/v1/volumes$       503           3        2\n"+
+		"read-reset          GET ^/v1/volumes/[^/]+$  reset         2        1\n"+
+		"list-throttled      GET ^/v1/volumes$        429           3        1\n"+
+		"slow-subnets        * ^/v1/subnets           latency 50ms  1        1\n",
+		server.Proxy.String())
+}
+
+func TestFailure(t *testing.T) {
+	api := fakevpc.NewServer("us-south")
+	defer api.Close()
+	faults := []*Fault{
+		{Name: "throttled", Path: "^/v1/instances$", Status: 429, Code: "too_many_requests", RetryAfter: 1500 * time.Millisecond, Times: 1},
+		{Name: "outage", Method: http.MethodPost, Path: "^/v1/load_balancers$", Status: 503},
+		{Name: "unused", Path: "^/v1/images$", Reset: true},
+	}
+	server, err := NewServer(api.URL, faults, false)
+	require.NoError(t, err)
+	defer server.Close()
+
+	// without retries, the error of the fault reaches the client
+	_, response, err := request(t, client(t, server, 0), core.GET, "/instances", nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, response.StatusCode)
+	assert.Equal(t, "2", response.Headers.Get("Retry-After"))
+	assert.Contains(t, err.Error(), "fault throttled injected by faultproxy")
+
+	// a persistent fault fails every retry
+	_, response, err = request(t, client(t, server, 2), core.POST, "/load_balancers", map[string]interface{}{"name": "slz-vsi-alb"})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Empty(t, api.List(fakevpc.LoadBalancers))
+
+	assert.Equal(t, []string{
+		"the 1 request(s) failed by fault throttled were not retried",
+		"fault unused matched no request, the scenario does not exercise it",
+	}, server.Proxy.Verify())
+}
+
+func TestTLS(t *testing.T) {
+	api := fakevpc.NewServer("us-south")
+	defer api.Close()
+	server, err := NewServer(api.URL, []*Fault{{Name: "reset", Path: "^/v1/images$", Reset: true, Times: 1}}, true)
+	require.NoError(t, err)
+	defer server.Close()
+
+	_, _, err = request(t, client(t, server, 2), core.GET, "/images", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Stats{{Fault: "reset", Matched: 2, Injected: 1}}, server.Proxy.Stats())
+
+	path := filepath.Join(t.TempDir(), "proxy.pem")
+	require.NoError(t, server.CertFile(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, server.Certificate().Raw, certificate.Raw)
+
+	plain, err := NewServer(api.URL, nil, false)
+	require.NoError(t, err)
+	defer plain.Close()
+	assert.Error(t, plain.CertFile(path))
+}
+
+func TestValidate(t *testing.T) {
+	scenarios := &Scenarios{Scenarios: map[string]*Scenario{
+		"empty": {},
+		"invalid": {Example: "examples/complete", Faults: []*Fault{
+			{Name: "a", Path: "(", Status: 503, Reset: true},
+			{Name: "a", Path: "^/v1/volumes$"},
+			{Name: "b", Status: 200, RetryAfter: -time.Second},
+			{Path: "^/v1/subnets$", Reset: true, RetryAfter: time.Second, Times: -1},
+		}},
+		"timed": {Example: "examples/complete", ApplyFails: true, MaxApply: -time.Minute, Faults: []*Fault{
+			{Name: "slow", Path: "^/v1/load_balancers$", Latency: time.Minute},
+		}},
+	}}
+	assert.EqualError(t, scenarios.Validate(), "invalid fault scenarios:\n"+
+		"  scenario empty: no example\n"+
+		"  scenario empty: no faults\n"+
+		"  scenario invalid: fault a: error parsing regexp: missing closing ): `(`\n"+
+		"  scenario invalid: fault a: status and reset are exclusive\n"+
+		"  scenario invalid: fault a: duplicate name\n"+
+		"  scenario invalid: fault a: no status, reset or latency\n"+
+		"  scenario invalid: fault b: status 200 is not an error\n"+
+		"  scenario invalid: fault b: latency, retry_after, every and times can not be negative\n"+
+		"  scenario invalid: fault 3: no name\n"+
+		"  scenario invalid: fault : retry_after without a status\n"+
+		"  scenario invalid: fault : latency, retry_after, every and times can not be negative\n"+
+		"  scenario timed: max_apply can not be negative\n"+
+		"  scenario timed: max_apply and apply_fails are exclusive")
+
+	_, err := New("us-south.iaas.cloud.ibm.com", nil)
+	assert.EqualError(t, err, `invalid target "us-south.iaas.cloud.ibm.com", expected a URL like https://us-south.iaas.cloud.ibm.com`)
+}
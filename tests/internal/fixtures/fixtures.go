@@ -0,0 +1,151 @@
+// Package fixtures locates the module source and the checked-in plan fixtures (tests/fixtures/plans) so the
+// offline checks can run from any package directory of the tests module.
+package fixtures
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// PlansDir is the directory of the plan fixtures, relative to the root of the module
+const PlansDir = "tests/fixtures/plans"
+
+// RepoRoot walks up from the working directory to the root of the module (the directory holding moved_config.tf)
+func RepoRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "moved_config.tf")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("could not find the module root (moved_config.tf) above the working directory")
+		}
+		dir = parent
+	}
+}
+
+// PlanNames returns the names of the plan fixtures, which are the names of the example or solution they come from
+func PlanNames(root string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, PlansDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// PlanPath returns the path of a plan fixture
+func PlanPath(root string, name string) string {
+	return filepath.Join(root, PlansDir, name+".json")
+}
+
+// ConfigDir returns the example or solution directory a plan fixture was produced from
+func ConfigDir(root string, name string) (string, error) {
+	for _, parent := range []string{"examples", "solutions"} {
+		dir := filepath.Join(root, parent, name)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("no example or solution named %s", name)
+}
+
+// PreviousReleaseRef returns the git ref of the previous release: the PREVIOUS_RELEASE_REF environment
+// variable if set, otherwise the most recent tag reachable from HEAD. It returns "" if there is none.
+func PreviousReleaseRef(root string) string {
+	if ref, ok := os.LookupEnv("PREVIOUS_RELEASE_REF"); ok && ref != "" {
+		return ref
+	}
+	out, err := exec.Command("git", "-C", root, "describe", "--tags", "--abbrev=0", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// UpgradeBaseRef returns the git ref the upgrade tests of the test wrapper upgrade from: the UPGRADE_BASE_REF
+// environment variable if set, otherwise the default branch of origin the wrapper checks out (origin/main)
+func UpgradeBaseRef(root string) string {
+	if ref, ok := os.LookupEnv("UPGRADE_BASE_REF"); ok && ref != "" {
+		return ref
+	}
+	out, err := exec.Command("git", "-C", root, "rev-parse", "--abbrev-ref", "origin/HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) == "" {
+		return "origin/main"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ReleaseVersion returns the release a git ref contains: the ref itself when it is a version, otherwise the most
+// recent tag reachable from it. It returns "" if there is none.
+func ReleaseVersion(root string, ref string) string {
+	if _, err := version.NewVersion(ref); err == nil {
+		return ref
+	}
+	out, err := exec.Command("git", "-C", root, "describe", "--tags", "--abbrev=0", ref).Output()
+	if err != nil {
+		return ""
+	}
+	tag := strings.TrimSpace(string(out))
+	if _, err := version.NewVersion(tag); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// ReadAtRef returns the content of a file (path relative to the module root) as it was at a git ref.
+// The returned bool is false if the file does not exist at that ref.
+func ReadAtRef(root string, ref string, path string) ([]byte, bool, error) {
+	if err := exec.Command("git", "-C", root, "cat-file", "-e", ref+":"+filepath.ToSlash(path)).Run(); err != nil {
+		return nil, false, nil
+	}
+	out, err := exec.Command("git", "-C", root, "show", ref+":"+filepath.ToSlash(path)).Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading %s at %s: %w", path, ref, err)
+	}
+	return out, true, nil
+}
+
+// ListAtRef returns the paths (relative to the module root) of the files of a directory at a git ref, not recursive.
+// The returned bool is false if the directory does not exist at that ref.
+func ListAtRef(root string, ref string, dir string) ([]string, bool, error) {
+	prefix := filepath.ToSlash(filepath.Clean(dir)) + "/"
+	if prefix == "./" {
+		prefix = ""
+	}
+	if prefix != "" {
+		if err := exec.Command("git", "-C", root, "cat-file", "-e", ref+":"+strings.TrimSuffix(prefix, "/")).Run(); err != nil {
+			return nil, false, nil
+		}
+	}
+	args := []string{"-C", root, "ls-tree", "--name-only", ref}
+	if prefix != "" {
+		args = append(args, prefix)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, false, fmt.Errorf("error listing %s at %s: %w", dir, ref, err)
+	}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			paths = append(paths, line)
+		}
+	}
+	sort.Strings(paths)
+	return paths, true, nil
+}
@@ -0,0 +1,136 @@
+package fleet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/names"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Problem is an input of a tier the module would reject
+type Problem struct {
+	Tier     string
+	Variable string
+	Detail   string
+}
+
+// Report is the outcome of the check of the inputs of every tier
+type Report struct {
+	Tiers    []string
+	Problems []Problem
+}
+
+// Check compares the inputs of every tier with the variables of the module: each one must be a variable, convert to
+// its type once the defaults of its optional attributes are applied, and every variable without a default must be
+// set. The names the module generates from the inputs must also follow the rules of internal/names.
+func Check(vars map[string]*TierVars, variables []tfconfig.Variable) *Report {
+	byName := map[string]tfconfig.Variable{}
+	for _, variable := range variables {
+		byName[variable.Name] = variable
+	}
+	report := &Report{}
+	for tier := range vars {
+		report.Tiers = append(report.Tiers, tier)
+	}
+	sort.Strings(report.Tiers)
+	for _, tier := range report.Tiers {
+		problem := func(variable string, format string, args ...any) {
+			report.Problems = append(report.Problems, Problem{Tier: tier, Variable: variable, Detail: fmt.Sprintf(format, args...)})
+		}
+		data, err := vars[tier].JSON()
+		if err != nil {
+			problem("", "error rendering the inputs: %s", err)
+			continue
+		}
+		var inputs map[string]json.RawMessage
+		if err := json.Unmarshal(data, &inputs); err != nil {
+			problem("", "error reading the inputs: %s", err)
+			continue
+		}
+		for _, name := range sortedNames(inputs) {
+			variable, ok := byName[name]
+			if !ok {
+				problem(name, "not a variable of the module")
+				continue
+			}
+			if err := conform(inputs[name], variable.Type, variable.TypeDefaults); err != nil {
+				problem(name, "%s", err)
+			}
+		}
+		for _, variable := range variables {
+			if _, ok := inputs[variable.Name]; !ok && !variable.HasDefault {
+				problem(variable.Name, "required by the module but not generated")
+			}
+		}
+
+		generated := &names.Inputs{}
+		if err := json.Unmarshal(data, generated); err != nil {
+			problem("", "error reading the inputs: %s", err)
+			continue
+		}
+		for _, invalid := range names.Check(generated.Enumerate()).Problems {
+			problem("(names)", "%s %q: %s", invalid.Name.Address, invalid.Name.Value, invalid.Detail)
+		}
+	}
+	return report
+}
+
+// conform converts a JSON value to the type of a variable, the way Terraform reads a tfvars JSON file
+func conform(data []byte, ty cty.Type, defaults *typeexpr.Defaults) error {
+	implied, err := ctyjson.ImpliedType(data)
+	if err != nil {
+		return err
+	}
+	value, err := ctyjson.Unmarshal(data, implied)
+	if err != nil {
+		return err
+	}
+	if value.IsNull() {
+		return nil
+	}
+	if defaults != nil {
+		value = defaults.Apply(value)
+	}
+	if _, err := convert.Convert(value, ty); err != nil {
+		return fmt.Errorf("does not convert to %s: %s", typeexpr.TypeString(ty), err)
+	}
+	return nil
+}
+
+func sortedNames(m map[string]json.RawMessage) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Failed returns true if the module would reject an input
+func (r *Report) Failed() bool {
+	return len(r.Problems) > 0
+}
+
+// String renders the problems as a table, one line per problem
+func (r *Report) String() string {
+	if !r.Failed() {
+		return fmt.Sprintf("The inputs of the %d tier(s) conform to the module variables.\n", len(r.Tiers))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d problem(s) in the inputs of the %d tier(s):\n", len(r.Problems), len(r.Tiers))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIER\tVARIABLE\tPROBLEM")
+	for _, problem := range r.Problems {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", problem.Tier, problem.Variable, problem.Detail)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
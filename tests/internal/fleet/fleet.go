@@ -0,0 +1,294 @@
+// Package fleet generates the tfvars of multi-tier deployments, one call of the module per tier the way
+// examples/multi-profile-one-vpc does, from a concise YAML fleet spec: tiers, instances per zone, disks, load
+// balancers and the flows allowed between tiers. The generated `block_storage_volumes`, `load_balancers`,
+// `security_group.rules` and `custom_vsi_volume_names` are checked against the types of the module variables and
+// the naming rules of internal/names before they are written.
+package fleet
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults of the load balancer pools, as in the examples
+const (
+	DefaultAlgorithm       = "round_robin"
+	DefaultConnectionLimit = 100
+	DefaultHealthDelay     = 60
+	DefaultHealthRetries   = 5
+	DefaultHealthTimeout   = 30
+	NetworkFixed           = "network-fixed"
+)
+
+var tierName = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+// Spec is the content of a fleet spec
+type Spec struct {
+	Prefix          string   `yaml:"prefix"`
+	ResourceGroupID string   `yaml:"resource_group_id"`
+	VPCID           string   `yaml:"vpc_id"`
+	ImageID         string   `yaml:"image_id"`
+	SSHKeyIDs       []string `yaml:"ssh_key_ids"`
+	Tags            []string `yaml:"tags"`
+	Subnets         []Subnet `yaml:"subnets"`
+	Tiers           []Tier   `yaml:"tiers"`
+	Flows           []Flow   `yaml:"flows"`
+}
+
+// Subnet is a subnet of the VPC the tiers are placed in. The CIDR is the source or destination of the security
+// group rules of the flows.
+type Subnet struct {
+	Name string `yaml:"name"`
+	ID   string `yaml:"id"`
+	Zone string `yaml:"zone"`
+	CIDR string `yaml:"cidr"`
+}
+
+// Tier is a call of the module
+type Tier struct {
+	Name        string `yaml:"name"`
+	MachineType string `yaml:"machine_type"`
+	// PerZone is the number of instances in each subnet of the tier
+	PerZone       int            `yaml:"per_zone"`
+	Subnets       []string       `yaml:"subnets"`
+	Disks         []Disk         `yaml:"disks"`
+	LoadBalancers []LoadBalancer `yaml:"load_balancers"`
+	// Ingress and Egress are rules with addresses outside of the fleet
+	Ingress []Rule `yaml:"ingress"`
+	Egress  []Rule `yaml:"egress"`
+}
+
+// Disk is a data volume of every instance of a tier
+type Disk struct {
+	Name     string `yaml:"name"`
+	Profile  string `yaml:"profile"`
+	Capacity int    `yaml:"capacity"`
+	IOPS     int    `yaml:"iops,omitempty"`
+}
+
+// LoadBalancer is a load balancer in front of the instances of a tier
+type LoadBalancer struct {
+	Name string `yaml:"name"`
+	// Type is public or private
+	Type string `yaml:"type"`
+	// Profile is empty for an application load balancer, network-fixed for a network load balancer
+	Profile    string `yaml:"profile,omitempty"`
+	Protocol   string `yaml:"protocol"`
+	Port       int    `yaml:"port"`
+	MemberPort int    `yaml:"member_port"`
+	// Sources are the CIDRs allowed to reach the listener
+	Sources []string `yaml:"sources"`
+}
+
+// Rule allows traffic between a tier and a CIDR outside of the fleet
+type Rule struct {
+	CIDR     string `yaml:"cidr"`
+	Protocol string `yaml:"protocol,omitempty"`
+	Port     int    `yaml:"port"`
+}
+
+// Flow allows traffic from the instances of a tier to the ones of another tier
+type Flow struct {
+	From     string `yaml:"from"`
+	To       string `yaml:"to"`
+	Protocol string `yaml:"protocol,omitempty"`
+	Port     int    `yaml:"port"`
+}
+
+// LoadSpec reads and validates a fleet spec
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading fleet spec %s: %w", path, err)
+	}
+	spec := &Spec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("error parsing fleet spec %s: %w", path, err)
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("fleet spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Validate checks the references between tiers, subnets and flows, and the values the module validates at plan
+func (s *Spec) Validate() error {
+	var problems []string
+	if !tierName.MatchString(s.Prefix) {
+		problems = append(problems, fmt.Sprintf("prefix %q must match %s", s.Prefix, tierName))
+	}
+	for field, value := range map[string]string{"resource_group_id": s.ResourceGroupID, "vpc_id": s.VPCID, "image_id": s.ImageID} {
+		if value == "" {
+			problems = append(problems, "no "+field)
+		}
+	}
+	if len(s.SSHKeyIDs) == 0 {
+		problems = append(problems, "no ssh_key_ids")
+	}
+	subnets := map[string]bool{}
+	for _, subnet := range s.Subnets {
+		if subnets[subnet.Name] {
+			problems = append(problems, fmt.Sprintf("subnet %s: duplicate name", subnet.Name))
+		}
+		subnets[subnet.Name] = true
+		if subnet.ID == "" || subnet.Zone == "" {
+			problems = append(problems, fmt.Sprintf("subnet %s: no id or zone", subnet.Name))
+		}
+		if _, _, err := net.ParseCIDR(subnet.CIDR); err != nil {
+			problems = append(problems, fmt.Sprintf("subnet %s: invalid cidr %q", subnet.Name, subnet.CIDR))
+		}
+	}
+	tiers := map[string]bool{}
+	for i, tier := range s.Tiers {
+		name := tier.Name
+		if name == "" {
+			name = fmt.Sprintf("tier %d", i+1)
+		} else {
+			name = "tier " + name
+		}
+		if !tierName.MatchString(tier.Name) {
+			problems = append(problems, fmt.Sprintf("%s: name must match %s", name, tierName))
+		}
+		if tiers[tier.Name] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate name", name))
+		}
+		tiers[tier.Name] = true
+		if tier.MachineType == "" {
+			problems = append(problems, fmt.Sprintf("%s: no machine_type", name))
+		}
+		if tier.PerZone < 1 {
+			problems = append(problems, fmt.Sprintf("%s: per_zone must be at least 1", name))
+		}
+		if len(tier.Subnets) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no subnets", name))
+		}
+		zones := map[string]bool{}
+		for _, subnet := range tier.Subnets {
+			if !subnets[subnet] {
+				problems = append(problems, fmt.Sprintf("%s: unknown subnet %s", name, subnet))
+				continue
+			}
+			// the instances are counted, and named, per zone
+			zone := zoneOf(s.Subnets, subnet)
+			if zones[zone] {
+				problems = append(problems, fmt.Sprintf("%s: several subnets in zone %s", name, zone))
+			}
+			zones[zone] = true
+		}
+		disks := map[string]bool{}
+		for _, disk := range tier.Disks {
+			if disks[disk.Name] || disk.Name == "" {
+				problems = append(problems, fmt.Sprintf("%s: disk names must be unique and not empty", name))
+			}
+			disks[disk.Name] = true
+			if disk.Profile == "" {
+				problems = append(problems, fmt.Sprintf("%s: disk %s has no profile", name, disk.Name))
+			}
+		}
+		lbs := map[string]bool{}
+		for _, lb := range tier.LoadBalancers {
+			problems = append(problems, lb.validate(name, lbs)...)
+		}
+		for _, rule := range append(append([]Rule{}, tier.Ingress...), tier.Egress...) {
+			if _, _, err := net.ParseCIDR(rule.CIDR); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid cidr %q", name, rule.CIDR))
+			}
+			problems = append(problems, validatePort(name, rule.Protocol, rule.Port)...)
+		}
+	}
+	for _, flow := range s.Flows {
+		name := fmt.Sprintf("flow %s to %s", flow.From, flow.To)
+		if !tiers[flow.From] || !tiers[flow.To] {
+			problems = append(problems, fmt.Sprintf("%s: unknown tier", name))
+		}
+		if flow.From == flow.To {
+			problems = append(problems, fmt.Sprintf("%s: a tier does not need a flow to itself", name))
+		}
+		problems = append(problems, validatePort(name, flow.Protocol, flow.Port)...)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid fleet spec:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (lb LoadBalancer) validate(tier string, seen map[string]bool) []string {
+	var problems []string
+	name := fmt.Sprintf("%s: load balancer %s", tier, lb.Name)
+	if !tierName.MatchString(lb.Name) {
+		problems = append(problems, fmt.Sprintf("%s: name must match %s", name, tierName))
+	}
+	if seen[lb.Name] {
+		problems = append(problems, fmt.Sprintf("%s: duplicate name", name))
+	}
+	seen[lb.Name] = true
+	if lb.Type != "public" && lb.Type != "private" {
+		problems = append(problems, fmt.Sprintf("%s: type must be public or private", name))
+	}
+	if lb.Profile != "" && lb.Profile != NetworkFixed {
+		problems = append(problems, fmt.Sprintf("%s: profile must be empty or %s", name, NetworkFixed))
+	}
+	if lb.Protocol != "http" && lb.Protocol != "https" && lb.Protocol != "tcp" {
+		problems = append(problems, fmt.Sprintf("%s: protocol must be http, https or tcp", name))
+	}
+	if lb.Profile == NetworkFixed && lb.Protocol != "tcp" {
+		problems = append(problems, fmt.Sprintf("%s: a network load balancer only supports tcp", name))
+	}
+	if lb.Port < 1 || lb.Port > 65535 || lb.MemberPort < 1 || lb.MemberPort > 65535 {
+		problems = append(problems, fmt.Sprintf("%s: port and member_port must be between 1 and 65535", name))
+	}
+	for _, source := range lb.Sources {
+		if _, _, err := net.ParseCIDR(source); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid source %q", name, source))
+		}
+	}
+	return problems
+}
+
+func validatePort(name string, protocol string, port int) []string {
+	var problems []string
+	if protocol != "" && protocol != "tcp" && protocol != "udp" {
+		problems = append(problems, fmt.Sprintf("%s: protocol must be tcp or udp", name))
+	}
+	if port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("%s: port must be between 1 and 65535", name))
+	}
+	return problems
+}
+
+// Tier returns the tier of a name
+func (s *Spec) Tier(name string) *Tier {
+	for i := range s.Tiers {
+		if s.Tiers[i].Name == name {
+			return &s.Tiers[i]
+		}
+	}
+	return nil
+}
+
+func zoneOf(subnets []Subnet, name string) string {
+	for _, subnet := range subnets {
+		if subnet.Name == name {
+			return subnet.Zone
+		}
+	}
+	return ""
+}
+
+// subnets returns the subnets of a tier, in the order of the tier
+func (s *Spec) subnets(tier *Tier) []Subnet {
+	var subnets []Subnet
+	for _, name := range tier.Subnets {
+		for _, subnet := range s.Subnets {
+			if subnet.Name == name {
+				subnets = append(subnets, subnet)
+			}
+		}
+	}
+	return subnets
+}
@@ -0,0 +1,162 @@
+package fleet
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/golden")
+
+func moduleVariables(t *testing.T) []tfconfig.Variable {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	files, err := tfconfig.ParseDir(root)
+	require.NoError(t, err)
+	variables, err := tfconfig.Variables(files)
+	require.NoError(t, err)
+	return variables
+}
+
+// TestGolden compares the inputs generated from testdata/fleet.yaml with testdata/golden/<tier>.tfvars.json, run
+// with -update to rewrite them
+func TestGolden(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fleet.yaml"))
+	require.NoError(t, err)
+	vars := spec.Generate()
+	require.Len(t, vars, 3)
+	for tier, inputs := range vars {
+		data, err := inputs.JSON()
+		require.NoError(t, err)
+		path := filepath.Join("testdata", "golden", tier+".tfvars.json")
+		if *update {
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+			continue
+		}
+		golden, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, string(golden), string(data), "%s differs, run the test with -update if the change is expected", path)
+	}
+}
+
+// TestRepoVariables checks the generated inputs against the variables of the module
+func TestRepoVariables(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fleet.yaml"))
+	require.NoError(t, err)
+	report := Check(spec.Generate(), moduleVariables(t))
+	assert.False(t, report.Failed(), report.String())
+	assert.Equal(t, "The inputs of the 3 tier(s) conform to the module variables.\n", report.String())
+}
+
+func TestGenerate(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fleet.yaml"))
+	require.NoError(t, err)
+	vars := spec.Generate()
+
+	rules := map[string]SecurityGroupRule{}
+	for _, rule := range vars["db"].SecurityGroup.Rules {
+		rules[rule.Name] = rule
+	}
+	// the database accepts the app tier from both of its subnets, and nothing else
+	assert.Equal(t, SecurityGroupRule{Name: "from-app-5432-1", Direction: "inbound", Source: "10.20.10.0/24", Protocol: "tcp", PortMin: 5432, PortMax: 5432}, rules["from-app-5432-1"])
+	assert.Equal(t, "udp", rules["from-app-8125-0"].Protocol)
+	assert.Len(t, rules, 4)
+
+	app := vars["app"]
+	var outbound []string
+	for _, rule := range app.SecurityGroup.Rules {
+		if rule.Direction == "outbound" {
+			outbound = append(outbound, rule.Name)
+		}
+	}
+	assert.Equal(t, []string{"to-db-5432-0", "to-db-8125-0"}, outbound)
+	require.Len(t, app.LoadBalancers, 1)
+	assert.Equal(t, NetworkFixed, *app.LoadBalancers[0].Profile)
+	assert.Nil(t, app.LoadBalancers[0].ConnectionLimit)
+	assert.Nil(t, app.LoadBalancers[0].SecurityGroup)
+	assert.Equal(t, map[string][]string{"shop-app-2-001": {"shop-app-2-001-logs"}}, app.CustomVSIVolumeNames["vpc-subnet-b"])
+
+	web := vars["web"]
+	require.NotNil(t, web.LoadBalancers[0].SecurityGroup)
+	assert.Equal(t, "shop-web-web-sg", web.LoadBalancers[0].SecurityGroup.Name)
+	assert.Equal(t, "8080", web.LoadBalancers[0].PoolMemberPort)
+	assert.Len(t, web.CustomVSIVolumeNames["vpc-subnet-c"], 2)
+	assert.Equal(t, []string{"env:test", "tier:web"}, web.ResourceTags)
+}
+
+func TestValidate(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fleet.yaml"))
+	require.NoError(t, err)
+	spec.Prefix = "Shop"
+	spec.Subnets[2].Zone = "us-south-2"
+	spec.Tiers[0].LoadBalancers[0].Profile = NetworkFixed
+	spec.Tiers[1].Disks = append(spec.Tiers[1].Disks, Disk{Name: "logs", Profile: "general-purpose"})
+	spec.Tiers[2].Subnets = append(spec.Tiers[2].Subnets, "vpc-subnet-d")
+	spec.Flows = append(spec.Flows, Flow{From: "db", To: "cache", Port: 6379}, Flow{From: "web", To: "web", Port: 0})
+
+	err = spec.Validate()
+	require.Error(t, err)
+	problems := strings.Split(err.Error(), "\n  ")
+	assert.Equal(t, "invalid fleet spec:", problems[0])
+	assert.Equal(t, []string{
+		`prefix "Shop" must match ^[a-z]([-a-z0-9]*[a-z0-9])?$`,
+		"tier web: several subnets in zone us-south-2",
+		"tier web: load balancer web: a network load balancer only supports tcp",
+		"tier app: disk names must be unique and not empty",
+		"tier db: unknown subnet vpc-subnet-d",
+		"flow db to cache: unknown tier",
+		"flow web to web: a tier does not need a flow to itself",
+		"flow web to web: port must be between 1 and 65535",
+	}, problems[1:])
+}
+
+func TestCheck(t *testing.T) {
+	spec, err := LoadSpec(filepath.Join("testdata", "fleet.yaml"))
+	require.NoError(t, err)
+	spec.Prefix = "shop-" + strings.Repeat("x", 50)
+	vars := map[string]*TierVars{"db": spec.Generate()["db"]}
+
+	// the module without machine_type, with a required `tier` variable, and with rules requiring a remote
+	file, err := tfconfig.ParseSource("changed.tf", []byte(`
+variable "tier" {
+  type = string
+}
+variable "security_group" {
+  type = object({
+    name  = string
+    rules = list(object({ name = string, direction = string, source = string, remote = string }))
+  })
+  default = null
+}
+`))
+	require.NoError(t, err)
+	changed, err := tfconfig.Variables([]*tfconfig.File{file})
+	require.NoError(t, err)
+	variables := changed
+	for _, variable := range moduleVariables(t) {
+		if variable.Name != "machine_type" && variable.Name != "security_group" {
+			variables = append(variables, variable)
+		}
+	}
+
+	report := Check(vars, variables)
+	require.True(t, report.Failed())
+	details := map[string]string{}
+	for _, problem := range report.Problems {
+		assert.Equal(t, "db", problem.Tier)
+		details[problem.Variable] += problem.Detail + "\n"
+	}
+	assert.Equal(t, "not a variable of the module\n", details["machine_type"])
+	assert.Equal(t, "required by the module but not generated\n", details["tier"])
+	assert.Contains(t, details["security_group"], "does not convert to object(")
+	// the prefix makes the names of the data volumes longer than 63 characters
+	assert.Contains(t, details["(names)"], `ibm_is_volume.volume["vpc-subnet-a-0-data"]`)
+	assert.Contains(t, report.String(), "TIER  VARIABLE")
+}
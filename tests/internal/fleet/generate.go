@@ -0,0 +1,234 @@
+package fleet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SecurityGroupRule is an element of the rules of the security_group input, or of the one of a load balancer
+type SecurityGroupRule struct {
+	Name      string `json:"name"`
+	Direction string `json:"direction"`
+	Source    string `json:"source"`
+	Protocol  string `json:"protocol"`
+	PortMin   int    `json:"port_min"`
+	PortMax   int    `json:"port_max"`
+}
+
+// SecurityGroup is the security_group input, or the one of a load balancer
+type SecurityGroup struct {
+	Name  string              `json:"name"`
+	Rules []SecurityGroupRule `json:"rules"`
+}
+
+// Volume is an element of the block_storage_volumes input
+type Volume struct {
+	Name     string `json:"name"`
+	Profile  string `json:"profile"`
+	Capacity *int   `json:"capacity,omitempty"`
+	IOPS     *int   `json:"iops,omitempty"`
+}
+
+// LoadBalancerInput is an element of the load_balancers input
+type LoadBalancerInput struct {
+	Name             string         `json:"name"`
+	Type             string         `json:"type"`
+	ListenerPort     int            `json:"listener_port"`
+	ListenerProtocol string         `json:"listener_protocol"`
+	ConnectionLimit  *int           `json:"connection_limit,omitempty"`
+	Algorithm        string         `json:"algorithm"`
+	Protocol         string         `json:"protocol"`
+	HealthDelay      int            `json:"health_delay"`
+	HealthRetries    int            `json:"health_retries"`
+	HealthTimeout    int            `json:"health_timeout"`
+	HealthType       string         `json:"health_type"`
+	PoolMemberPort   string         `json:"pool_member_port"`
+	Profile          *string        `json:"profile,omitempty"`
+	SecurityGroup    *SecurityGroup `json:"security_group,omitempty"`
+}
+
+// SubnetInput is an element of the subnets input
+type SubnetInput struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Zone string `json:"zone"`
+	CIDR string `json:"cidr"`
+}
+
+// TierVars are the inputs of the module call of a tier, written as <tier>.tfvars.json
+type TierVars struct {
+	ResourceGroupID      string                         `json:"resource_group_id"`
+	Prefix               string                         `json:"prefix"`
+	VPCID                string                         `json:"vpc_id"`
+	Subnets              []SubnetInput                  `json:"subnets"`
+	ImageID              string                         `json:"image_id"`
+	SSHKeyIDs            []string                       `json:"ssh_key_ids"`
+	MachineType          string                         `json:"machine_type"`
+	VSIPerSubnet         int                            `json:"vsi_per_subnet"`
+	UserData             *string                        `json:"user_data"`
+	ResourceTags         []string                       `json:"resource_tags"`
+	CreateSecurityGroup  bool                           `json:"create_security_group"`
+	SecurityGroup        SecurityGroup                  `json:"security_group"`
+	BlockStorageVolumes  []Volume                       `json:"block_storage_volumes"`
+	LoadBalancers        []LoadBalancerInput            `json:"load_balancers"`
+	CustomVSIVolumeNames map[string]map[string][]string `json:"custom_vsi_volume_names"`
+}
+
+// Generate returns the inputs of the module call of every tier, in the order of the spec
+func (s *Spec) Generate() map[string]*TierVars {
+	vars := map[string]*TierVars{}
+	for i := range s.Tiers {
+		vars[s.Tiers[i].Name] = s.generate(&s.Tiers[i])
+	}
+	return vars
+}
+
+// JSON renders the inputs as indented JSON, the way they are written and compared with the golden files
+func (v *TierVars) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (s *Spec) generate(tier *Tier) *TierVars {
+	prefix := s.Prefix + "-" + tier.Name
+	subnets := s.subnets(tier)
+	vars := &TierVars{
+		ResourceGroupID:      s.ResourceGroupID,
+		Prefix:               prefix,
+		VPCID:                s.VPCID,
+		ImageID:              s.ImageID,
+		SSHKeyIDs:            s.SSHKeyIDs,
+		MachineType:          tier.MachineType,
+		VSIPerSubnet:         tier.PerZone,
+		ResourceTags:         append(append([]string{}, s.Tags...), "tier:"+tier.Name),
+		CreateSecurityGroup:  true,
+		SecurityGroup:        SecurityGroup{Name: prefix + "-sg", Rules: s.rules(tier)},
+		BlockStorageVolumes:  []Volume{},
+		LoadBalancers:        []LoadBalancerInput{},
+		CustomVSIVolumeNames: map[string]map[string][]string{},
+	}
+	for _, subnet := range subnets {
+		vars.Subnets = append(vars.Subnets, SubnetInput(subnet))
+	}
+	for _, disk := range tier.Disks {
+		vars.BlockStorageVolumes = append(vars.BlockStorageVolumes, Volume{Name: disk.Name, Profile: disk.Profile, Capacity: optional(disk.Capacity), IOPS: optional(disk.IOPS)})
+	}
+	for _, lb := range tier.LoadBalancers {
+		vars.LoadBalancers = append(vars.LoadBalancers, loadBalancer(prefix, lb, subnets))
+	}
+	// readable names instead of the ones built from the end of the subnet ID, keyed with a padded count so the
+	// module, which takes them in key order, gives them to the instances in the same order
+	for _, subnet := range subnets {
+		instances := map[string][]string{}
+		for count := 1; count <= tier.PerZone; count++ {
+			instance := fmt.Sprintf("%s-%s-%03d", prefix, zoneNumber(subnet.Zone), count)
+			volumes := []string{}
+			for _, disk := range tier.Disks {
+				volumes = append(volumes, instance+"-"+disk.Name)
+			}
+			instances[instance] = volumes
+		}
+		vars.CustomVSIVolumeNames[subnet.Name] = instances
+	}
+	return vars
+}
+
+// rules returns the rules of the security group of a tier: the flows from and to the other tiers, from and to the
+// CIDRs of the tier, and from its load balancers, which are in the subnets of the tier
+func (s *Spec) rules(tier *Tier) []SecurityGroupRule {
+	var rules []SecurityGroupRule
+	add := func(name string, direction string, source string, protocol string, port int) {
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		rules = append(rules, SecurityGroupRule{Name: name, Direction: direction, Source: source, Protocol: protocol, PortMin: port, PortMax: port})
+	}
+	for _, flow := range s.Flows {
+		switch tier.Name {
+		case flow.To:
+			for i, subnet := range s.subnets(s.Tier(flow.From)) {
+				add(fmt.Sprintf("from-%s-%d-%d", flow.From, flow.Port, i), "inbound", subnet.CIDR, flow.Protocol, flow.Port)
+			}
+		case flow.From:
+			for i, subnet := range s.subnets(s.Tier(flow.To)) {
+				add(fmt.Sprintf("to-%s-%d-%d", flow.To, flow.Port, i), "outbound", subnet.CIDR, flow.Protocol, flow.Port)
+			}
+		}
+	}
+	for i, rule := range tier.Ingress {
+		add(fmt.Sprintf("ingress-%d-%d", rule.Port, i), "inbound", rule.CIDR, rule.Protocol, rule.Port)
+	}
+	for i, rule := range tier.Egress {
+		add(fmt.Sprintf("egress-%d-%d", rule.Port, i), "outbound", rule.CIDR, rule.Protocol, rule.Port)
+	}
+	ports := map[int]bool{}
+	for _, lb := range tier.LoadBalancers {
+		ports[lb.MemberPort] = true
+	}
+	for _, port := range sortedKeys(ports) {
+		for i, subnet := range s.subnets(tier) {
+			add(fmt.Sprintf("lb-%d-%d", port, i), "inbound", subnet.CIDR, "tcp", port)
+		}
+	}
+	return rules
+}
+
+func loadBalancer(prefix string, lb LoadBalancer, subnets []Subnet) LoadBalancerInput {
+	input := LoadBalancerInput{
+		Name:             lb.Name,
+		Type:             lb.Type,
+		ListenerPort:     lb.Port,
+		ListenerProtocol: lb.Protocol,
+		Algorithm:        DefaultAlgorithm,
+		Protocol:         lb.Protocol,
+		HealthDelay:      DefaultHealthDelay,
+		HealthRetries:    DefaultHealthRetries,
+		HealthTimeout:    DefaultHealthTimeout,
+		HealthType:       lb.Protocol,
+		PoolMemberPort:   fmt.Sprint(lb.MemberPort),
+	}
+	if lb.Profile == NetworkFixed {
+		profile := NetworkFixed
+		input.Profile = &profile
+		// a network load balancer does not limit the connections of its listener
+	} else {
+		input.ConnectionLimit = optional(DefaultConnectionLimit)
+	}
+	if len(lb.Sources) > 0 {
+		sg := &SecurityGroup{Name: fmt.Sprintf("%s-%s-sg", prefix, lb.Name)}
+		for i, source := range lb.Sources {
+			sg.Rules = append(sg.Rules, SecurityGroupRule{Name: fmt.Sprintf("source-%d", i), Direction: "inbound", Source: source, Protocol: "tcp", PortMin: lb.Port, PortMax: lb.Port})
+		}
+		for i, subnet := range subnets {
+			sg.Rules = append(sg.Rules, SecurityGroupRule{Name: fmt.Sprintf("member-%d", i), Direction: "outbound", Source: subnet.CIDR, Protocol: "tcp", PortMin: lb.MemberPort, PortMax: lb.MemberPort})
+		}
+		input.SecurityGroup = sg
+	}
+	return input
+}
+
+// zoneNumber returns the number ending the name of a zone (`us-south-2` is 2)
+func zoneNumber(zone string) string {
+	return zone[strings.LastIndex(zone, "-")+1:]
+}
+
+func optional(value int) *int {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
+func sortedKeys(m map[int]bool) []int {
+	var keys []int
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
@@ -0,0 +1,363 @@
+// Package httpreplay records the HTTP requests the test helpers send to IBM Cloud APIs to a cassette file, with API
+// keys, tokens and passwords redacted, and replays them deterministically, so the helpers can be debugged and
+// tested without network or an account. The transport is installed into the go-sdk-core BaseService clients (and
+// their IAM authenticator) with Install.
+//
+// The mode comes from HTTP_CASSETTE_MODE: `record` sends the requests and saves them, `replay` answers from the
+// cassette and fails on a request it has not recorded, anything else passes the requests through.
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// Mode is what the transport does with requests
+type Mode string
+
+const (
+	Passthrough Mode = "passthrough"
+	Record      Mode = "record"
+	Replay      Mode = "replay"
+)
+
+// ModeEnv is the environment variable holding the mode
+const ModeEnv = "HTTP_CASSETTE_MODE"
+
+// Redacted replaces secrets in cassettes
+const Redacted = "REDACTED"
+
+// secretHeaders are never recorded in clear
+var secretHeaders = map[string]bool{
+	"Authorization":        true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Auth-Refresh-Token": true,
+	"X-Auth-Token":         true,
+	"Refresh-Token":        true,
+	"X-Api-Key":            true,
+}
+
+// secretFields are redacted in JSON bodies, form bodies and query strings, compared case-insensitively
+var secretFields = regexp.MustCompile(`(?i)^(apikey|api_key|ibmcloud_api_key|access_token|refresh_token|delegated_refresh_token|password|passphrase|private_key|client_secret|token)$`)
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request, redacted
+type Request struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response, redacted
+type Response struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// Cassette is the content of a cassette file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Transport records or replays requests. It is safe for concurrent use.
+type Transport struct {
+	Mode Mode
+	Path string
+	// Next sends the requests in passthrough and record modes, http.DefaultTransport if nil
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	// used are the interactions already replayed, so identical requests get the responses in recorded order
+	used map[int]bool
+}
+
+// New returns a transport for a cassette. In replay mode the cassette is loaded.
+func New(mode Mode, path string) (*Transport, error) {
+	t := &Transport{Mode: mode, Path: path, used: map[int]bool{}}
+	if mode == Replay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cassette %s: %w", path, err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("error parsing cassette %s: %w", path, err)
+		}
+	}
+	return t, nil
+}
+
+// FromEnv returns a transport for a cassette in the mode of HTTP_CASSETTE_MODE
+func FromEnv(path string) (*Transport, error) {
+	mode := Mode(strings.ToLower(os.Getenv(ModeEnv)))
+	if mode != Record && mode != Replay {
+		mode = Passthrough
+	}
+	return New(mode, path)
+}
+
+// Client returns an HTTP client using the transport
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+// Install makes a go-sdk-core service, and its IAM authenticator if it has one, send their requests through the
+// transport
+func (t *Transport) Install(service *core.BaseService) {
+	service.SetHTTPClient(t.Client())
+	if service.Options != nil {
+		if iam, ok := service.Options.Authenticator.(*core.IamAuthenticator); ok {
+			iam.Client = t.Client()
+		}
+	}
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip records, replays or passes the request through
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Mode != Record && t.Mode != Replay {
+		return t.next().RoundTrip(req)
+	}
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorded := redactRequest(req, body)
+
+	if t.Mode == Replay {
+		interaction, err := t.match(recorded)
+		if err != nil {
+			return nil, err
+		}
+		return interaction.Response.toHTTP(req), nil
+	}
+
+	resp, err := t.next().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: redactHeaders(resp.Header),
+			Body:    redactBody(resp.Header.Get("Content-Type"), respBody),
+		},
+	})
+	return resp, nil
+}
+
+// match returns the first unused recorded interaction for the request
+func (t *Transport) match(request Request) (Interaction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, interaction := range t.cassette.Interactions {
+		if t.used[i] || interaction.Request.Method != request.Method || interaction.Request.URL != request.URL {
+			continue
+		}
+		if !sameBody(interaction.Request.Body, request.Body) {
+			continue
+		}
+		t.used[i] = true
+		return interaction, nil
+	}
+	return Interaction{}, fmt.Errorf("no recorded interaction left in %s for %s %s", t.Path, request.Method, request.URL)
+}
+
+// Save writes the recorded interactions to the cassette, in record mode
+func (t *Transport) Save() error {
+	if t.Mode != Record {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.Path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.Path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing cassette %s: %w", t.Path, err)
+	}
+	return nil
+}
+
+// readBody reads a body and replaces it with a copy, so it can still be sent or returned
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	_ = (*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func redactRequest(req *http.Request, body []byte) Request {
+	u := *req.URL
+	u.RawQuery = redactQuery(u.Query()).Encode()
+	return Request{
+		Method:  req.Method,
+		URL:     u.String(),
+		Headers: redactHeaders(req.Header),
+		Body:    redactBody(req.Header.Get("Content-Type"), body),
+	}
+}
+
+func redactHeaders(headers http.Header) http.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	redacted := http.Header{}
+	for name, values := range headers {
+		if secretHeaders[http.CanonicalHeaderKey(name)] {
+			redacted[name] = []string{Redacted}
+			continue
+		}
+		redacted[name] = values
+	}
+	return redacted
+}
+
+func redactQuery(values url.Values) url.Values {
+	for name := range values {
+		if secretFields.MatchString(name) {
+			values[name] = []string{Redacted}
+		}
+	}
+	return values
+}
+
+// redactBody redacts the secret fields of JSON and form bodies, other bodies are kept as they are
+func redactBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(string(body))
+		if err == nil {
+			return redactQuery(values).Encode()
+		}
+	}
+	var value interface{}
+	if json.Unmarshal(body, &value) == nil {
+		data, err := json.Marshal(redactJSON(value))
+		if err == nil {
+			return string(data)
+		}
+	}
+	return string(body)
+}
+
+// replayedTokenExpiration is the expiration written over the one of recorded IAM token responses (2100-01-01), so
+// the IAM authenticator keeps using the replayed token instead of requesting a new one
+const replayedTokenExpiration = 4102444800
+
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if _, isToken := v["access_token"]; isToken {
+			if _, ok := v["expiration"]; ok {
+				v["expiration"] = replayedTokenExpiration
+			}
+		}
+		for name, field := range v {
+			if secretFields.MatchString(name) {
+				v[name] = Redacted
+				continue
+			}
+			v[name] = redactJSON(field)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// sameBody compares bodies, JSON bodies regardless of key order and formatting
+func sameBody(a string, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+func (r Response) toHTTP(req *http.Request) *http.Response {
+	headers := http.Header{}
+	for name, values := range r.Headers {
+		headers[name] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        headers,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// Unused returns the recorded requests that were not replayed, a test replaying a whole cassette expects none
+func (t *Transport) Unused() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var unused []string
+	for i, interaction := range t.cassette.Interactions {
+		if !t.used[i] {
+			unused = append(unused, interaction.Request.Method+" "+interaction.Request.URL)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
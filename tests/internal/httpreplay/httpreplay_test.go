@@ -0,0 +1,140 @@
+package httpreplay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const apiKey = "s3cr3t-api-key"
+
+// fakeCloud answers the IAM token request and a JSON API with a counter, so replayed responses are distinguishable
+func fakeCloud(t *testing.T) *httptest.Server {
+	calls := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/identity/token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, apiKey, r.Form.Get("apikey"))
+			_, _ = w.Write([]byte(`{"access_token": "eyJhbGciOi.real.token", "refresh_token": "real-refresh", "token_type": "Bearer", "expires_in": 3600, "expiration": ` + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + `}`))
+		case "/v1/snapshots":
+			assert.Equal(t, "Bearer eyJhbGciOi.real.token", r.Header.Get("Authorization"))
+			calls++
+			_, _ = w.Write([]byte(`{"total_count": ` + strconv.Itoa(calls) + `, "password": "hunter2"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// listSnapshots is a helper under test, using a go-sdk-core service
+func listSnapshots(t *testing.T, service *core.BaseService) (int, error) {
+	builder := core.NewRequestBuilder(core.GET).WithContext(context.Background())
+	_, err := builder.ResolveRequestURL(service.GetServiceURL(), "/v1/snapshots", nil)
+	require.NoError(t, err)
+	builder.AddQuery("version", "2025-01-01")
+	builder.AddQuery("apikey", apiKey)
+	request, err := builder.Build()
+	require.NoError(t, err)
+	var result map[string]interface{}
+	_, err = service.Request(request, &result)
+	if err != nil {
+		return 0, err
+	}
+	return int(result["total_count"].(float64)), nil
+}
+
+func newService(t *testing.T, url string) *core.BaseService {
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           url,
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey, URL: url},
+	})
+	require.NoError(t, err)
+	return service
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fakeCloud(t)
+	cassette := filepath.Join(t.TempDir(), "snapshots.json")
+
+	recorder, err := New(Record, cassette)
+	require.NoError(t, err)
+	service := newService(t, server.URL)
+	recorder.Install(service)
+	for _, expected := range []int{1, 2} {
+		count, err := listSnapshots(t, service)
+		require.NoError(t, err)
+		assert.Equal(t, expected, count)
+	}
+	require.NoError(t, recorder.Save())
+	server.Close()
+
+	data, err := os.ReadFile(cassette)
+	require.NoError(t, err)
+	for _, secret := range []string{apiKey, "eyJhbGciOi.real.token", "real-refresh", "hunter2"} {
+		assert.NotContains(t, string(data), secret)
+	}
+	var recorded Cassette
+	require.NoError(t, json.Unmarshal(data, &recorded))
+	require.Len(t, recorded.Interactions, 3)
+	assert.Equal(t, "apikey=REDACTED&grant_type=urn%3Aibm%3Aparams%3Aoauth%3Agrant-type%3Aapikey&response_type=cloud_iam", recorded.Interactions[0].Request.Body)
+	assert.Contains(t, recorded.Interactions[0].Response.Body, `"expiration":4102444800`)
+	assert.Equal(t, []string{Redacted}, recorded.Interactions[1].Request.Headers["Authorization"])
+
+	// the server is gone: the same calls are answered from the cassette, in recorded order
+	player, err := New(Replay, cassette)
+	require.NoError(t, err)
+	service = newService(t, server.URL)
+	player.Install(service)
+	for _, expected := range []int{1, 2} {
+		count, err := listSnapshots(t, service)
+		require.NoError(t, err)
+		assert.Equal(t, expected, count)
+	}
+	assert.Empty(t, player.Unused())
+
+	_, err = listSnapshots(t, service)
+	assert.ErrorContains(t, err, "no recorded interaction left")
+}
+
+func TestRedactBody(t *testing.T) {
+	assert.Equal(t, `{"inputs":{"ibmcloud_api_key":"REDACTED","prefix":"vsi"},"list":[{"Password":"REDACTED"}]}`,
+		redactBody("application/json", []byte(`{"inputs": {"ibmcloud_api_key": "key", "prefix": "vsi"}, "list": [{"Password": "p"}]}`)))
+	assert.Equal(t, "apikey=REDACTED&grant_type=x", redactBody("application/x-www-form-urlencoded", []byte("grant_type=x&apikey=key")))
+	assert.Equal(t, "plain text", redactBody("text/plain", []byte("plain text")))
+	assert.True(t, sameBody(`{"a": 1, "b": [2]}`, `{"b":[2],"a":1}`))
+	assert.False(t, sameBody(`{"a": 1}`, `{"a": 2}`))
+}
+
+func TestPassthroughAndEnv(t *testing.T) {
+	server := fakeCloud(t)
+	defer server.Close()
+
+	t.Setenv(ModeEnv, "")
+	transport, err := FromEnv(filepath.Join(t.TempDir(), "unused.json"))
+	require.NoError(t, err)
+	assert.Equal(t, Passthrough, transport.Mode)
+	resp, err := transport.Client().Get(server.URL + "/missing")
+	require.NoError(t, err)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.NoError(t, transport.Save())
+
+	t.Setenv(ModeEnv, "REPLAY")
+	_, err = FromEnv(filepath.Join(t.TempDir(), "missing.json"))
+	assert.True(t, err != nil && strings.Contains(err.Error(), "error reading cassette"))
+}
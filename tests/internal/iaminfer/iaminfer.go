@@ -0,0 +1,349 @@
+// Package iaminfer infers the IAM roles a deployer needs from the resources of a plan, using the checked-in mapping
+// table (tests/iam-permission-map.yaml), and compares them with the `iam_permissions` a catalog flavor declares in
+// ibm_catalog.json. Missing roles otherwise only surface when a customer's deployment fails.
+package iaminfer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/catalog"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"gopkg.in/yaml.v3"
+)
+
+// MapPath is the path of the mapping table, relative to the root of the module
+const MapPath = "tests/iam-permission-map.yaml"
+
+// AllIAMServices is the catalog service name that grants a role on every IAM enabled service
+const AllIAMServices = "All Identity and Access enabled services"
+
+// accountManagementServices are not covered by AllIAMServices
+var accountManagementServices = map[string]bool{
+	"Resource group only":             true,
+	"All Account Management services": true,
+	"iam-identity":                    true,
+	"iam-groups":                      true,
+	"iam-access-management":           true,
+}
+
+// platformRoles and serviceRoles are ordered, a role covers every role before it
+var platformRoles = []string{"Viewer", "Operator", "Editor", "Administrator"}
+var serviceRoles = []string{"Reader", "Writer", "Manager"}
+
+// Map is the content of the mapping table
+type Map struct {
+	Always    []Grant           `yaml:"always"`
+	Aliases   map[string]string `yaml:"aliases"`
+	Resources []ResourceRule    `yaml:"resources"`
+}
+
+// Grant is a role on a service
+type Grant struct {
+	Service string   `yaml:"service"`
+	Roles   []string `yaml:"roles"`
+}
+
+// ResourceRule gives the roles needed to create the resource types matching Type
+type ResourceRule struct {
+	Type        string   `yaml:"type"`
+	Service     string   `yaml:"service"`
+	ServiceFrom []string `yaml:"service_from"`
+	Roles       []string `yaml:"roles"`
+}
+
+// Requirement is a role on a service needed by some resources of the plan
+type Requirement struct {
+	Service   string
+	Role      string
+	Resources []string
+}
+
+func (r Requirement) String() string {
+	return fmt.Sprintf("%s %s", r.Service, r.Role)
+}
+
+// Excess is a declared role that no resource of the plan needs, or that is higher than needed
+type Excess struct {
+	Service string
+	Role    string
+	Detail  string
+}
+
+// Report is the comparison of the inferred requirements with the declared permissions of a flavor
+type Report struct {
+	Flavor       string
+	Requirements []Requirement
+	Missing      []Requirement
+	Excessive    []Excess
+	// Unmapped are resource types of the plan that have no entry in the mapping table
+	Unmapped []string
+}
+
+// LoadMap reads the mapping table
+func LoadMap(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading IAM permission map %s: %w", path, err)
+	}
+	m := &Map{}
+	if err := yaml.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("error parsing IAM permission map %s: %w", path, err)
+	}
+	for _, rule := range m.Resources {
+		if rule.Service != "" && len(rule.ServiceFrom) > 0 {
+			return nil, fmt.Errorf("IAM permission map %s: %s sets both service and service_from", path, rule.Type)
+		}
+		if len(rule.Roles) > 0 && rule.Service == "" && len(rule.ServiceFrom) == 0 {
+			return nil, fmt.Errorf("IAM permission map %s: %s has roles but no service", path, rule.Type)
+		}
+	}
+	return m, nil
+}
+
+// PlanName returns the name of the plan fixture of a flavor (fixtures/plans/<name>.json), the last element of its
+// working directory
+func PlanName(flavor catalog.Flavor) string {
+	return filepath.Base(flavor.WorkingDirectory)
+}
+
+// rule returns the most specific rule matching a resource type
+func (m *Map) rule(resourceType string) *ResourceRule {
+	var best *ResourceRule
+	for i := range m.Resources {
+		rule := &m.Resources[i]
+		if typePattern(rule.Type).MatchString(resourceType) && (best == nil || len(rule.Type) > len(best.Type)) {
+			best = rule
+		}
+	}
+	return best
+}
+
+func typePattern(pattern string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+// service resolves an alias
+func (m *Map) service(name string) string {
+	if alias, ok := m.Aliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// Infer returns the roles needed to create the managed resources of a plan, and the resource types the mapping
+// table does not cover
+func (m *Map) Infer(plan *tfjson.Plan) ([]Requirement, []string) {
+	needed := map[string]*Requirement{}
+	add := func(service string, role string, resource string) {
+		key := service + "\x00" + role
+		if needed[key] == nil {
+			needed[key] = &Requirement{Service: service, Role: role}
+		}
+		if resource != "" {
+			needed[key].Resources = append(needed[key].Resources, resource)
+		}
+	}
+	for _, grant := range m.Always {
+		for _, role := range grant.Roles {
+			add(m.service(grant.Service), role, "")
+		}
+	}
+
+	unmapped := map[string]bool{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil {
+			continue
+		}
+		if !change.Change.Actions.Create() && !change.Change.Actions.Update() && !change.Change.Actions.Replace() {
+			continue
+		}
+		rule := m.rule(change.Type)
+		if rule == nil {
+			unmapped[change.Type] = true
+			continue
+		}
+		service := rule.Service
+		if len(rule.ServiceFrom) > 0 {
+			service = serviceFrom(change.Change.After, rule.ServiceFrom)
+			if service == "" {
+				service = AllIAMServices
+			}
+		}
+		for _, role := range rule.Roles {
+			add(m.service(service), role, change.Address)
+		}
+	}
+
+	requirements := make([]Requirement, 0, len(needed))
+	for _, requirement := range needed {
+		sort.Strings(requirement.Resources)
+		requirements = append(requirements, *requirement)
+	}
+	sort.Slice(requirements, func(i, j int) bool {
+		if requirements[i].Service != requirements[j].Service {
+			return requirements[i].Service < requirements[j].Service
+		}
+		return requirements[i].Role < requirements[j].Role
+	})
+	types := make([]string, 0, len(unmapped))
+	for resourceType := range unmapped {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	return requirements, types
+}
+
+// serviceFrom reads the first attribute path that is set. `a.b` reads attribute b of the first element of the list
+// a, or the value of the element named b of a list of name/value pairs (like resource_attributes).
+func serviceFrom(after interface{}, paths []string) string {
+	for _, path := range paths {
+		parts := strings.SplitN(path, ".", 2)
+		if len(parts) == 1 {
+			if value := tfplan.String(after, parts[0]); value != "" {
+				return value
+			}
+			continue
+		}
+		for _, element := range tfplan.List(after, parts[0]) {
+			if tfplan.String(element, "name") == parts[1] {
+				if value := tfplan.String(element, "value"); value != "" {
+					return value
+				}
+			}
+		}
+		if value := tfplan.String(after, parts[0], 0, parts[1]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// declaredRole is a role of the iam_permissions of a flavor
+type declaredRole struct {
+	service string
+	role    string
+	kind    string
+}
+
+// parseRoleCRN turns `crn:v1:bluemix:public:iam::::role:Editor` into ("role", "Editor")
+func parseRoleCRN(crn string) (kind string, role string) {
+	parts := strings.Split(crn, ":")
+	if len(parts) < 2 {
+		return "", crn
+	}
+	return parts[len(parts)-2], parts[len(parts)-1]
+}
+
+// roleKind returns "role" for platform roles and "serviceRole" for the others
+func roleKind(role string) string {
+	for _, platformRole := range platformRoles {
+		if role == platformRole {
+			return "role"
+		}
+	}
+	return "serviceRole"
+}
+
+// rank returns the position of a role in its hierarchy, -1 for custom roles
+func rank(role string) int {
+	for _, roles := range [][]string{platformRoles, serviceRoles} {
+		for i, r := range roles {
+			if r == role {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// covers returns true if the declared role grants the required one
+func (m *Map) covers(declared declaredRole, required Requirement) bool {
+	service := m.service(declared.service)
+	if service != required.Service && !(service == AllIAMServices && !accountManagementServices[required.Service]) {
+		return false
+	}
+	if declared.kind != roleKind(required.Role) {
+		return false
+	}
+	if declared.role == required.Role {
+		return true
+	}
+	return rank(declared.role) >= 0 && rank(required.Role) >= 0 && rank(declared.role) >= rank(required.Role)
+}
+
+// Compare infers the requirements of a plan and compares them with the declared permissions of a flavor
+func (m *Map) Compare(plan *tfjson.Plan, flavor catalog.Flavor) *Report {
+	report := &Report{Flavor: flavor.Name}
+	report.Requirements, report.Unmapped = m.Infer(plan)
+
+	var declared []declaredRole
+	for _, permission := range flavor.IAMPermissions {
+		for _, crn := range permission.RoleCRNs {
+			kind, role := parseRoleCRN(crn)
+			declared = append(declared, declaredRole{service: permission.ServiceName, role: role, kind: kind})
+		}
+	}
+
+	for _, requirement := range report.Requirements {
+		covered := false
+		for _, d := range declared {
+			if m.covers(d, requirement) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			report.Missing = append(report.Missing, requirement)
+		}
+	}
+
+	for _, d := range declared {
+		highest := -1
+		needed := false
+		for _, requirement := range report.Requirements {
+			if m.covers(d, requirement) {
+				needed = true
+				if rank(requirement.Role) > highest {
+					highest = rank(requirement.Role)
+				}
+			}
+		}
+		switch {
+		case !needed:
+			report.Excessive = append(report.Excessive, Excess{Service: d.service, Role: d.role, Detail: "not needed by the planned resources"})
+		case m.service(d.service) != AllIAMServices && rank(d.role) > highest && highest >= 0:
+			lower := platformRoles
+			if d.kind != "role" {
+				lower = serviceRoles
+			}
+			report.Excessive = append(report.Excessive, Excess{Service: d.service, Role: d.role, Detail: fmt.Sprintf("higher than needed, %s is enough", lower[highest])})
+		}
+	}
+	return report
+}
+
+// String renders the missing and excessive roles
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d role(s) inferred from the plan\n", r.Flavor, len(r.Requirements))
+	for _, resourceType := range r.Unmapped {
+		fmt.Fprintf(&sb, "  unmapped resource type %s, add it to the IAM permission map\n", resourceType)
+	}
+	for _, missing := range r.Missing {
+		fmt.Fprintf(&sb, "  missing %s", missing)
+		if len(missing.Resources) > 0 {
+			fmt.Fprintf(&sb, " (needed by %s)", strings.Join(missing.Resources, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	for _, excess := range r.Excessive {
+		fmt.Fprintf(&sb, "  excessive %s %s: %s\n", excess.Service, excess.Role, excess.Detail)
+	}
+	return sb.String()
+}
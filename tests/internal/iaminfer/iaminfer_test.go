@@ -0,0 +1,128 @@
+package iaminfer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/catalog"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func requirementStrings(requirements []Requirement) []string {
+	var list []string
+	for _, requirement := range requirements {
+		list = append(list, requirement.String())
+	}
+	return list
+}
+
+func permission(service string, roles ...string) catalog.IAMPermission {
+	var crns []string
+	for _, role := range roles {
+		crns = append(crns, "crn:v1:bluemix:public:iam::::"+roleKind(role)+":"+role)
+	}
+	return catalog.IAMPermission{ServiceName: service, RoleCRNs: crns}
+}
+
+func TestInfer(t *testing.T) {
+	m, err := LoadMap("testdata/map.yaml")
+	require.NoError(t, err)
+	plan, err := tfplan.LoadPlan("testdata/plan.json")
+	require.NoError(t, err)
+
+	requirements, unmapped := m.Infer(plan)
+	assert.Equal(t, []string{
+		"Resource group only Viewer",
+		"hs-crypto Administrator",
+		"is Editor",
+		"is Operator",
+		"kms Manager",
+		"secrets-manager Viewer",
+	}, requirementStrings(requirements))
+	assert.Equal(t, []string{"ibm_dns_zone"}, unmapped)
+	assert.Equal(t, []string{"ibm_is_instance.vsi"}, requirements[2].Resources)
+	assert.Empty(t, requirements[0].Resources)
+}
+
+func TestCompare(t *testing.T) {
+	m, err := LoadMap("testdata/map.yaml")
+	require.NoError(t, err)
+	plan, err := tfplan.LoadPlan("testdata/plan.json")
+	require.NoError(t, err)
+
+	flavor := catalog.Flavor{
+		Name: "fully-configurable",
+		IAMPermissions: []catalog.IAMPermission{
+			permission("Resource group only", "Viewer"),
+			permission("is.vpc", "Administrator"),
+			permission("kms", "Manager"),
+			permission("hs-crypto", "Administrator", "Manager"),
+			permission("logs", "Writer"),
+		},
+	}
+	report := m.Compare(plan, flavor)
+	assert.Equal(t, []string{"secrets-manager Viewer"}, requirementStrings(report.Missing))
+	assert.Equal(t, []Excess{
+		{Service: "is.vpc", Role: "Administrator", Detail: "higher than needed, Editor is enough"},
+		{Service: "hs-crypto", Role: "Manager", Detail: "not needed by the planned resources"},
+		{Service: "logs", Role: "Writer", Detail: "not needed by the planned resources"},
+	}, report.Excessive)
+	assert.Equal(t, `fully-configurable: 6 role(s) inferred from the plan
+  unmapped resource type ibm_dns_zone, add it to the IAM permission map
+  missing secrets-manager Viewer (needed by ibm_iam_trusted_profile_policy.policy)
+  excessive is.vpc Administrator: higher than needed, Editor is enough
+  excessive hs-crypto Manager: not needed by the planned resources
+  excessive logs Writer: not needed by the planned resources
+`, report.String())
+}
+
+func TestCovers(t *testing.T) {
+	m := &Map{Aliases: map[string]string{"is.vpc": "is"}}
+	declared := func(service string, crn string) declaredRole {
+		kind, role := parseRoleCRN(crn)
+		return declaredRole{service: service, role: role, kind: kind}
+	}
+	assert.True(t, m.covers(declared("is.vpc", "crn:v1:bluemix:public:iam::::role:Editor"), Requirement{Service: "is", Role: "Viewer"}))
+	assert.False(t, m.covers(declared("is", "crn:v1:bluemix:public:iam::::role:Editor"), Requirement{Service: "is", Role: "Administrator"}))
+	assert.False(t, m.covers(declared("is", "crn:v1:bluemix:public:iam::::serviceRole:Manager"), Requirement{Service: "is", Role: "Editor"}))
+	assert.True(t, m.covers(declared("kms", "crn:v1:bluemix:public:iam::::serviceRole:Manager"), Requirement{Service: "kms", Role: "Writer"}))
+	assert.True(t, m.covers(declared("kms", "crn:v1:bluemix:public:kms::::serviceRole:KeyPurge"), Requirement{Service: "kms", Role: "KeyPurge"}))
+	assert.False(t, m.covers(declared("kms", "crn:v1:bluemix:public:iam::::serviceRole:Manager"), Requirement{Service: "kms", Role: "KeyPurge"}))
+	assert.True(t, m.covers(declared(AllIAMServices, "crn:v1:bluemix:public:iam::::role:Administrator"), Requirement{Service: "kms", Role: "Editor"}))
+	assert.False(t, m.covers(declared(AllIAMServices, "crn:v1:bluemix:public:iam::::role:Administrator"), Requirement{Service: "iam-identity", Role: "Editor"}))
+}
+
+func TestLoadMapErrors(t *testing.T) {
+	_, err := LoadMap("testdata/missing.yaml")
+	assert.Error(t, err)
+}
+
+// TestCatalogPermissions infers the roles of every flavor of ibm_catalog.json from the plan fixture of its working
+// directory and fails when a role is missing from its iam_permissions. Excessive roles are only logged: optional
+// dependencies of a flavor (monitoring, logs, activity tracker) need roles its own plan does not.
+func TestCatalogPermissions(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	m, err := LoadMap(filepath.Join(root, MapPath))
+	require.NoError(t, err)
+	manifest, err := catalog.LoadManifest(filepath.Join(root, "ibm_catalog.json"))
+	require.NoError(t, err)
+
+	for _, product := range manifest.Products {
+		for _, flavor := range product.Flavors {
+			t.Run(flavor.Name, func(t *testing.T) {
+				plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, PlanName(flavor)))
+				require.NoError(t, err)
+				report := m.Compare(plan, flavor)
+				assert.Empty(t, report.Unmapped, "resource types missing from %s", MapPath)
+				assert.Empty(t, requirementStrings(report.Missing))
+				for _, excess := range report.Excessive {
+					t.Logf("excessive %s %s: %s", excess.Service, excess.Role, excess.Detail)
+				}
+			})
+		}
+	}
+}
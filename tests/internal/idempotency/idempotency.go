@@ -0,0 +1,358 @@
+// Package idempotency classifies the changes of a second plan (the re-plan of the consistency tests after an apply,
+// which should be empty) by resource type, changed attribute and known-issue signature, using the checked-in
+// signature table (tests/idempotency-signatures.yaml). A change without a known signature fails the check, known
+// perma-diffs (provider issue 5527 on volumes) are only reported and exempted from the consistency check of the
+// test wrapper.
+package idempotency
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"gopkg.in/yaml.v3"
+)
+
+// SignaturesPath is the path of the signature table, relative to the root of the module
+const SignaturesPath = "tests/idempotency-signatures.yaml"
+
+// wholeResource is the attribute of the findings of a create, delete or replace
+const wholeResource = "*"
+
+var validActions = map[string]bool{"create": true, "update": true, "delete": true, "replace": true}
+
+// Signatures is the content of the signature table
+type Signatures struct {
+	Signatures []Signature `yaml:"signatures"`
+}
+
+// Signature is a known source of changes in a second plan
+type Signature struct {
+	ID         string   `yaml:"id"`
+	Type       string   `yaml:"type"`
+	Actions    []string `yaml:"actions"`
+	Attributes []string `yaml:"attributes"`
+	// EmptyAfter only matches changes to an empty list or null
+	EmptyAfter bool   `yaml:"empty_after"`
+	Reason     string `yaml:"reason"`
+}
+
+// Finding is a changed attribute of a resource of the second plan
+type Finding struct {
+	Address   string
+	Type      string
+	Action    string
+	Attribute string
+	// Signature is nil when the change is not known
+	Signature *Signature
+}
+
+// Report is the classification of a second plan
+type Report struct {
+	Findings []Finding
+}
+
+// LoadSignatures reads and validates the signature table
+func LoadSignatures(path string) (*Signatures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading idempotency signatures %s: %w", path, err)
+	}
+	signatures := &Signatures{}
+	if err := yaml.Unmarshal(data, signatures); err != nil {
+		return nil, fmt.Errorf("error parsing idempotency signatures %s: %w", path, err)
+	}
+	if err := signatures.Validate(); err != nil {
+		return nil, fmt.Errorf("idempotency signatures %s: %w", path, err)
+	}
+	return signatures, nil
+}
+
+// Validate checks every signature has an id, a type, actions, attributes and a reason
+func (s *Signatures) Validate() error {
+	var problems []string
+	for i, signature := range s.Signatures {
+		name := signature.ID
+		if name == "" {
+			name = fmt.Sprintf("signature %d", i+1)
+			problems = append(problems, fmt.Sprintf("%s: no id", name))
+		}
+		if signature.Type == "" {
+			problems = append(problems, fmt.Sprintf("%s: no type", name))
+		}
+		if len(signature.Actions) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no actions", name))
+		}
+		for _, action := range signature.Actions {
+			if !validActions[action] {
+				problems = append(problems, fmt.Sprintf("%s: unknown action %q", name, action))
+			}
+		}
+		if len(signature.Attributes) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: no attributes", name))
+		}
+		if strings.TrimSpace(signature.Reason) == "" {
+			problems = append(problems, fmt.Sprintf("%s: no reason", name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid signatures:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Classify returns a finding for every changed attribute of the managed resources of a second plan (one finding
+// with the attribute `*` for a create, delete or replace)
+func Classify(plan *tfjson.Plan, signatures *Signatures) *Report {
+	if signatures == nil {
+		signatures = &Signatures{}
+	}
+	report := &Report{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil {
+			continue
+		}
+		action := actionName(change.Change.Actions)
+		if action == "" {
+			continue
+		}
+		attributes := []string{wholeResource}
+		afters := map[string]interface{}{}
+		if action == "update" {
+			attributes, afters = changedAttributes(change.Change.Before, change.Change.After, change.Change.AfterUnknown)
+			// an update of sensitive values only has no visible attribute
+			if len(attributes) == 0 {
+				attributes = []string{wholeResource}
+			}
+		}
+		for _, attribute := range attributes {
+			finding := Finding{Address: change.Address, Type: change.Type, Action: action, Attribute: attribute}
+			for i := range signatures.Signatures {
+				if signatures.Signatures[i].matches(finding, afters[attribute]) {
+					finding.Signature = &signatures.Signatures[i]
+					break
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Address != report.Findings[j].Address {
+			return report.Findings[i].Address < report.Findings[j].Address
+		}
+		return report.Findings[i].Attribute < report.Findings[j].Attribute
+	})
+	return report
+}
+
+func actionName(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Create():
+		return "create"
+	case actions.Delete():
+		return "delete"
+	case actions.Update():
+		return "update"
+	default:
+		return ""
+	}
+}
+
+func (s Signature) matches(finding Finding, after interface{}) bool {
+	if s.Type != finding.Type || !contains(s.Actions, finding.Action) {
+		return false
+	}
+	if s.EmptyAfter && !isEmpty(after) {
+		return false
+	}
+	for _, attribute := range s.Attributes {
+		if attributePattern(attribute).MatchString(finding.Attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// attributePattern turns a signature attribute, where `*` matches anything, into an anchored regular expression
+func attributePattern(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	list, ok := value.([]interface{})
+	return ok && len(list) == 0
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownValue is the planned value of an attribute known only after the apply, which is not empty
+type unknownValue struct{}
+
+// changedAttributes returns the dotted paths of the attributes that differ between before and after, or that are
+// unknown after the apply, with their planned values. Objects and lists of objects are walked, other values (lists of
+// strings included) are compared as a whole.
+func changedAttributes(before interface{}, after interface{}, unknown interface{}) ([]string, map[string]interface{}) {
+	var paths []string
+	afters := map[string]interface{}{}
+	var walk func(path string, before interface{}, after interface{}, unknown interface{})
+	walk = func(path string, before interface{}, after interface{}, unknown interface{}) {
+		if isUnknown, _ := unknown.(bool); isUnknown {
+			paths = append(paths, path)
+			afters[path] = unknownValue{}
+			return
+		}
+		beforeMap, beforeIsMap := before.(map[string]interface{})
+		afterMap, afterIsMap := after.(map[string]interface{})
+		if beforeIsMap || afterIsMap {
+			unknownMap, _ := unknown.(map[string]interface{})
+			keys := map[string]bool{}
+			for key := range beforeMap {
+				keys[key] = true
+			}
+			for key := range afterMap {
+				keys[key] = true
+			}
+			for key := range unknownMap {
+				keys[key] = true
+			}
+			for key := range keys {
+				walk(join(path, key), beforeMap[key], afterMap[key], unknownMap[key])
+			}
+			return
+		}
+		beforeList, _ := before.([]interface{})
+		afterList, _ := after.([]interface{})
+		unknownList, _ := unknown.([]interface{})
+		if hasObjects(beforeList) || hasObjects(afterList) {
+			for i := 0; i < max(len(beforeList), len(afterList), len(unknownList)); i++ {
+				walk(join(path, strconv.Itoa(i)), index(beforeList, i), index(afterList, i), index(unknownList, i))
+			}
+			return
+		}
+		if !reflect.DeepEqual(before, after) {
+			paths = append(paths, path)
+			afters[path] = after
+		}
+	}
+	walk("", before, after, unknown)
+	sort.Strings(paths)
+	return paths, afters
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func hasObjects(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func index(list []interface{}, i int) interface{} {
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+// Unknown returns the findings without a known signature
+func (r *Report) Unknown() []Finding {
+	var unknown []Finding
+	for _, finding := range r.Findings {
+		if finding.Signature == nil {
+			unknown = append(unknown, finding)
+		}
+	}
+	return unknown
+}
+
+// Exemptions are the addresses of the resources of a second plan to exempt from the consistency check of the test
+// wrapper, by the action it checks
+type Exemptions struct {
+	Adds     []string
+	Updates  []string
+	Destroys []string
+}
+
+// Exemptions returns the resources whose changes all have a known signature. The wrapper exempts whole resources, so a
+// resource with a change without a known signature is not exempted. A replace is both an add and a destroy.
+func (r *Report) Exemptions() Exemptions {
+	unknown := map[string]bool{}
+	for _, finding := range r.Unknown() {
+		unknown[finding.Address] = true
+	}
+	exemptions := Exemptions{}
+	seen := map[string]bool{}
+	for _, finding := range r.Findings {
+		if unknown[finding.Address] || seen[finding.Address] {
+			continue
+		}
+		seen[finding.Address] = true
+		switch finding.Action {
+		case "create":
+			exemptions.Adds = append(exemptions.Adds, finding.Address)
+		case "update":
+			exemptions.Updates = append(exemptions.Updates, finding.Address)
+		case "delete":
+			exemptions.Destroys = append(exemptions.Destroys, finding.Address)
+		case "replace":
+			exemptions.Adds = append(exemptions.Adds, finding.Address)
+			exemptions.Destroys = append(exemptions.Destroys, finding.Address)
+		}
+	}
+	return exemptions
+}
+
+// Failed returns true if the second plan has a change without a known signature
+func (r *Report) Failed() bool {
+	return len(r.Unknown()) > 0
+}
+
+// String renders the findings as a table, one line per changed attribute
+func (r *Report) String() string {
+	if len(r.Findings) == 0 {
+		return "The second plan has no changes.\n"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The second plan has %d change(s), %d without a known signature:\n", len(r.Findings), len(r.Unknown()))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACTION\tADDRESS\tATTRIBUTE\tSIGNATURE")
+	for _, finding := range r.Findings {
+		signature := "UNKNOWN"
+		if finding.Signature != nil {
+			signature = finding.Signature.ID
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", finding.Action, finding.Address, finding.Attribute, signature)
+	}
+	_ = w.Flush()
+	if r.Failed() {
+		sb.WriteString("Fix the changes without a known signature, or add a signature with the issue to idempotency-signatures.yaml.\n")
+	}
+	return sb.String()
+}
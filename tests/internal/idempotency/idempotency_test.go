@@ -0,0 +1,163 @@
+package idempotency
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func classifications(findings []Finding) []string {
+	var list []string
+	for _, finding := range findings {
+		signature := "UNKNOWN"
+		if finding.Signature != nil {
+			signature = finding.Signature.ID
+		}
+		list = append(list, finding.Action+" "+finding.Address+" "+finding.Attribute+" "+signature)
+	}
+	return list
+}
+
+const (
+	volumeA    = `module.slz_vsi.ibm_is_volume.volume["slz-vsi-com-vpc-subnet-a-0-slz-vsi-com"]`
+	volumeB    = `module.slz_vsi.ibm_is_volume.volume["slz-vsi-com-vpc-subnet-b-0-slz-vsi-com"]`
+	volumeC    = `module.slz_vsi.ibm_is_volume.volume["slz-vsi-com-vpc-subnet-c-0-slz-vsi-com"]`
+	fip        = `module.slz_vsi.ibm_is_floating_ip.vsi_fip["slz-vsi-com-vpc-subnet-a-0"]`
+	primaryVNI = `module.slz_vsi.ibm_is_virtual_network_interface.primary_vni["slz-vsi-com-vpc-subnet-a-0"]`
+	secondVNI  = `module.slz_vsi.ibm_is_virtual_network_interface.secondary_vni["slz-vsi-com-second-subnet-a-0"]`
+)
+
+// loadDriftedPlan loads the second plan, where only the volumes change, and adds the changes the signatures do not
+// cover: a volume resized outside terraform, the security groups of the interfaces changed and a replaced floating IP
+func loadDriftedPlan(t *testing.T) *tfjson.Plan {
+	plan, err := tfplan.LoadPlan("testdata/second_plan.json")
+	require.NoError(t, err)
+	for _, change := range plan.ResourceChanges {
+		before, _ := change.Change.Before.(map[string]interface{})
+		after, _ := change.Change.After.(map[string]interface{})
+		switch change.Address {
+		case volumeB:
+			before["capacity"] = 250.0
+		case primaryVNI:
+			change.Change.Actions = tfjson.Actions{tfjson.ActionUpdate}
+			before["security_groups"] = []interface{}{"r006-0d5e1f2a-3b4c-4d5e-8f6a-7b8c9d0e1f2a"}
+			after["security_groups"] = []interface{}{}
+		case secondVNI:
+			change.Change.Actions = tfjson.Actions{tfjson.ActionUpdate}
+			before["security_groups"] = []interface{}{"r006-0d5e1f2a-3b4c-4d5e-8f6a-7b8c9d0e1f2a"}
+			after["security_groups"] = []interface{}{"r006-9a8b7c6d-5e4f-4a3b-9c2d-1e0f9a8b7c6d"}
+		case fip:
+			change.Change.Actions = tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate}
+		}
+	}
+	return plan
+}
+
+func TestClassify(t *testing.T) {
+	signatures, err := LoadSignatures("testdata/signatures.yaml")
+	require.NoError(t, err)
+
+	report := Classify(loadDriftedPlan(t), signatures)
+
+	// no-ops are not changes, and the tag signature does not cover the resize of a volume
+	assert.Equal(t, []string{
+		"replace " + fip + " * fip-replace",
+		"update " + primaryVNI + " security_groups UNKNOWN",
+		"update " + secondVNI + " security_groups UNKNOWN",
+		"update " + volumeA + " access_tags provider-5527",
+		"update " + volumeB + " access_tags provider-5527",
+		"update " + volumeB + " capacity UNKNOWN",
+		"update " + volumeC + " access_tags provider-5527",
+	}, classifications(report.Findings))
+	assert.True(t, report.Failed())
+	assert.Len(t, report.Unknown(), 3)
+
+	output := report.String()
+	assert.Contains(t, output, "The second plan has 7 change(s), 3 without a known signature:")
+	assert.Regexp(t, `update\s+module\.slz_vsi\.ibm_is_volume\.volume\["slz-vsi-com-vpc-subnet-b-0-slz-vsi-com"\]\s+capacity\s+UNKNOWN`, output)
+	assert.Contains(t, output, "add a signature")
+
+	// the resized volume is not exempted
+	assert.Equal(t, Exemptions{
+		Adds:     []string{fip},
+		Updates:  []string{volumeA, volumeC},
+		Destroys: []string{fip},
+	}, report.Exemptions())
+}
+
+func TestClassifyKnownOnly(t *testing.T) {
+	plan, err := tfplan.LoadPlan("testdata/second_plan.json")
+	require.NoError(t, err)
+	signatures, err := LoadSignatures("testdata/signatures.yaml")
+	require.NoError(t, err)
+
+	report := Classify(plan, signatures)
+	assert.False(t, report.Failed())
+	assert.Len(t, report.Findings, 3)
+	assert.NotContains(t, report.String(), "UNKNOWN")
+	assert.Equal(t, Exemptions{Updates: []string{volumeA, volumeB, volumeC}}, report.Exemptions())
+
+	assert.True(t, Classify(plan, nil).Failed(), "without signatures every change is unknown")
+	assert.Empty(t, Classify(plan, nil).Exemptions())
+	assert.Equal(t, "The second plan has no changes.\n", (&Report{}).String())
+}
+
+func TestClassifyEmptyAfter(t *testing.T) {
+	signatures := &Signatures{Signatures: []Signature{{
+		ID:         "emptied",
+		Type:       "ibm_is_virtual_network_interface",
+		Actions:    []string{"update"},
+		Attributes: []string{"security_groups"},
+		EmptyAfter: true,
+		Reason:     "list emptied",
+	}}}
+
+	// the primary interface loses its security groups, the secondary one gets another
+	report := Classify(loadDriftedPlan(t), signatures)
+	assert.Contains(t, classifications(report.Findings), "update "+primaryVNI+" security_groups emptied")
+	assert.Contains(t, classifications(report.Findings), "update "+secondVNI+" security_groups UNKNOWN")
+}
+
+func TestChangedAttributes(t *testing.T) {
+	paths, afters := changedAttributes(
+		map[string]interface{}{"a": "x", "tags": []interface{}{"t1"}, "nested": []interface{}{map[string]interface{}{"b": 1.0, "c": 2.0}}},
+		map[string]interface{}{"a": "x", "tags": []interface{}{"t2"}, "nested": []interface{}{map[string]interface{}{"b": 1.0, "c": 3.0}}, "d": nil},
+		map[string]interface{}{"d": true},
+	)
+	assert.Equal(t, []string{"d", "nested.0.c", "tags"}, paths)
+	assert.False(t, isEmpty(afters["d"]), "an unknown value is not empty")
+	assert.Equal(t, 3.0, afters["nested.0.c"])
+}
+
+func TestValidate(t *testing.T) {
+	signatures := &Signatures{Signatures: []Signature{
+		{ID: "ok", Type: "ibm_is_volume", Actions: []string{"update"}, Attributes: []string{"*"}, Reason: "issue"},
+		{Type: "ibm_is_instance", Actions: []string{"modify"}},
+	}}
+	err := signatures.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `signature 2: no id`)
+	assert.Contains(t, err.Error(), `signature 2: unknown action "modify"`)
+	assert.Contains(t, err.Error(), `signature 2: no attributes`)
+	assert.Contains(t, err.Error(), `signature 2: no reason`)
+
+	path := filepath.Join(t.TempDir(), "signatures.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("signatures:\n  - id: x\n"), 0o600))
+	_, err = LoadSignatures(path)
+	assert.ErrorContains(t, err, "x: no type")
+}
+
+// TestRepoSignatures checks the signature table of the module is valid
+func TestRepoSignatures(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	signatures, err := LoadSignatures(filepath.Join(root, SignaturesPath))
+	require.NoError(t, err)
+	assert.NotEmpty(t, signatures.Signatures)
+}
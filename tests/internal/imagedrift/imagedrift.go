@@ -0,0 +1,311 @@
+// Package imagedrift reports VSIs whose boot image no longer matches the configured image.
+//
+// ibm_is_instance.vsi ignores changes to `image` (and `user_data`), so bumping `image_id` or
+// picking up a newer image from the image selector never replaces existing instances. This
+// package compares the image recorded in state for every instance with the configured image ID
+// or catalog offering version, and builds a rolling replacement plan of `-replace` targets that
+// is batched per zone and never takes the last healthy member out of a load balancer pool.
+package imagedrift
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+const instanceResourceType = "ibm_is_instance"
+const poolMemberResourceType = "ibm_is_lb_pool_member"
+
+// healthy value reported by the provider for a load balancer pool member
+const memberHealthOK = "ok"
+
+// Expected is the image configuration the fleet should be running
+type Expected struct {
+	// ImageID is the value of the `image_id` input
+	ImageID string
+	// CatalogVersionCRN is the `catalog_offering.version_crn` input, used instead of ImageID for catalog images
+	CatalogVersionCRN string
+}
+
+// Instance is a VSI found in state along with its drift status
+type Instance struct {
+	Address      string
+	Name         string
+	ID           string
+	Zone         string
+	IPv4Address  string
+	Image        string
+	CatalogCRN   string
+	Stale        bool
+	StaleReasons []string
+}
+
+// PoolMember is a load balancer pool member found in state
+type PoolMember struct {
+	Address       string
+	Pool          string
+	TargetID      string
+	TargetAddress string
+	Health        string
+}
+
+// Report is the result of comparing the state with the expected image configuration
+type Report struct {
+	Expected    Expected
+	Instances   []Instance
+	PoolMembers []PoolMember
+}
+
+// Batch is a set of instances that can be replaced together
+type Batch struct {
+	Zone      string
+	Addresses []string
+	Warnings  []string
+}
+
+// Options controls how the rolling replacement plan is built
+type Options struct {
+	// BatchSize is the maximum number of instances replaced per batch, defaults to 1
+	BatchSize int
+	// MinHealthyPerPool is the number of healthy members each pool must keep during a batch, defaults to 1
+	MinHealthyPerPool int
+}
+
+// Analyze compares every ibm_is_instance in the state with the expected image configuration
+func Analyze(state *tfjson.State, expected Expected) (*Report, error) {
+	if expected.ImageID == "" && expected.CatalogVersionCRN == "" {
+		return nil, fmt.Errorf("either an image ID or a catalog offering version CRN must be provided")
+	}
+
+	resources := tfplan.StateResources(state)
+	report := &Report{Expected: expected}
+
+	for _, resource := range tfplan.ManagedResourcesOfType(resources, instanceResourceType) {
+		values := resource.AttributeValues
+		instance := Instance{
+			Address:     resource.Address,
+			Name:        tfplan.String(values, "name"),
+			ID:          tfplan.String(values, "id"),
+			Zone:        tfplan.String(values, "zone"),
+			IPv4Address: instanceIPv4Address(values),
+			Image:       tfplan.String(values, "image"),
+			CatalogCRN:  tfplan.String(values, "catalog_offering", 0, "version_crn"),
+		}
+		if expected.ImageID != "" && instance.Image != expected.ImageID {
+			instance.StaleReasons = append(instance.StaleReasons, fmt.Sprintf("image %s does not match image_id %s", displayValue(instance.Image), expected.ImageID))
+		}
+		if expected.CatalogVersionCRN != "" && instance.CatalogCRN != expected.CatalogVersionCRN {
+			instance.StaleReasons = append(instance.StaleReasons, fmt.Sprintf("catalog version %s does not match %s", displayValue(instance.CatalogCRN), expected.CatalogVersionCRN))
+		}
+		instance.Stale = len(instance.StaleReasons) > 0
+		report.Instances = append(report.Instances, instance)
+	}
+
+	for _, resource := range tfplan.ManagedResourcesOfType(resources, poolMemberResourceType) {
+		values := resource.AttributeValues
+		report.PoolMembers = append(report.PoolMembers, PoolMember{
+			Address:       resource.Address,
+			Pool:          tfplan.String(values, "pool"),
+			TargetID:      tfplan.String(values, "target_id"),
+			TargetAddress: tfplan.String(values, "target_address"),
+			Health:        tfplan.String(values, "health"),
+		})
+	}
+
+	return report, nil
+}
+
+// instanceIPv4Address returns the primary IP of an instance, in either legacy or VNI mode
+func instanceIPv4Address(values map[string]interface{}) string {
+	if address := tfplan.String(values, "primary_network_interface", 0, "primary_ip", 0, "address"); address != "" {
+		return address
+	}
+	return tfplan.String(values, "primary_network_attachment", 0, "primary_ip", 0, "address")
+}
+
+func displayValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// Stale returns the instances that are not running the expected image
+func (r *Report) Stale() []Instance {
+	var stale []Instance
+	for _, instance := range r.Instances {
+		if instance.Stale {
+			stale = append(stale, instance)
+		}
+	}
+	return stale
+}
+
+// poolsOf returns the pools the instance is a member of. ALB members are matched on IP and NLB members on instance ID.
+func (r *Report) poolsOf(instance Instance) []string {
+	pools := map[string]bool{}
+	for _, member := range r.PoolMembers {
+		if member.targets(instance) {
+			pools[member.Pool] = true
+		}
+	}
+	return sortedKeys(pools)
+}
+
+// targets returns true if the pool member points at the instance
+func (m PoolMember) targets(instance Instance) bool {
+	return (m.TargetID != "" && m.TargetID == instance.ID) ||
+		(m.TargetAddress != "" && m.TargetAddress == instance.IPv4Address)
+}
+
+// healthyMembers returns, per pool, the set of instance IDs of healthy members
+func (r *Report) healthyMembers() map[string]map[string]bool {
+	healthy := map[string]map[string]bool{}
+	for _, instance := range r.Instances {
+		for _, member := range r.PoolMembers {
+			if member.Health == memberHealthOK && member.targets(instance) {
+				if healthy[member.Pool] == nil {
+					healthy[member.Pool] = map[string]bool{}
+				}
+				healthy[member.Pool][instance.ID] = true
+			}
+		}
+	}
+	return healthy
+}
+
+// RolloutPlan groups the stale instances into replacement batches. Batches never mix zones and a batch never
+// holds so many members of a pool that fewer than MinHealthyPerPool healthy members would remain in it.
+// An instance that cannot be replaced without breaking that rule (e.g. it is the only healthy member of a pool)
+// is put in a batch of its own with a warning.
+func (r *Report) RolloutPlan(options Options) []Batch {
+	if options.BatchSize < 1 {
+		options.BatchSize = 1
+	}
+	if options.MinHealthyPerPool < 1 {
+		options.MinHealthyPerPool = 1
+	}
+
+	healthy := r.healthyMembers()
+
+	byZone := map[string][]Instance{}
+	for _, instance := range r.Stale() {
+		byZone[instance.Zone] = append(byZone[instance.Zone], instance)
+	}
+	zones := make([]string, 0, len(byZone))
+	for zone := range byZone {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	var batches []Batch
+	for _, zone := range zones {
+		var current *Batch
+		// number of healthy members of each pool taken out by the current batch
+		removed := map[string]int{}
+
+		for _, instance := range byZone[zone] {
+			pools := r.poolsOf(instance)
+
+			if current != nil && (len(current.Addresses) >= options.BatchSize || !fits(pools, instance.ID, healthy, removed, options.MinHealthyPerPool)) {
+				batches = append(batches, *current)
+				current = nil
+			}
+			if current == nil {
+				current = &Batch{Zone: zone}
+				removed = map[string]int{}
+			}
+
+			unsafe := false
+			for _, pool := range pools {
+				if healthy[pool][instance.ID] {
+					removed[pool]++
+				}
+				if len(healthy[pool])-removed[pool] < options.MinHealthyPerPool {
+					unsafe = true
+					current.Warnings = append(current.Warnings, fmt.Sprintf("replacing %s leaves pool %s with fewer than %d healthy member(s)", instance.Address, pool, options.MinHealthyPerPool))
+				}
+			}
+			current.Addresses = append(current.Addresses, instance.Address)
+
+			// an instance that breaks the pool rule on its own is always replaced alone
+			if unsafe {
+				batches = append(batches, *current)
+				current = nil
+			}
+		}
+		if current != nil {
+			batches = append(batches, *current)
+		}
+	}
+	return batches
+}
+
+// fits returns true if the instance can join a batch that already removed the given healthy members
+func fits(pools []string, instanceID string, healthy map[string]map[string]bool, removed map[string]int, minHealthy int) bool {
+	for _, pool := range pools {
+		taken := removed[pool]
+		if healthy[pool][instanceID] {
+			taken++
+		}
+		if len(healthy[pool])-taken < minHealthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ReplaceArgs returns the terraform CLI arguments to replace every instance of the batch
+func (b Batch) ReplaceArgs() []string {
+	args := make([]string, 0, len(b.Addresses))
+	for _, address := range b.Addresses {
+		args = append(args, fmt.Sprintf("-replace=%s", address))
+	}
+	return args
+}
+
+// WriteReport prints the drift status of every instance
+func (r *Report) WriteReport(w io.Writer) {
+	expected := r.Expected.ImageID
+	if r.Expected.CatalogVersionCRN != "" {
+		expected = r.Expected.CatalogVersionCRN
+	}
+	stale := r.Stale()
+	fmt.Fprintf(w, "Expected image: %s\n", expected)
+	fmt.Fprintf(w, "Instances: %d, stale: %d\n", len(r.Instances), len(stale))
+	for _, instance := range stale {
+		fmt.Fprintf(w, "  STALE %s (%s, %s): %s\n", instance.Address, instance.Name, instance.Zone, strings.Join(instance.StaleReasons, "; "))
+	}
+}
+
+// WritePlan prints a rolling replacement plan as terraform apply commands, one per batch
+func WritePlan(w io.Writer, batches []Batch) {
+	if len(batches) == 0 {
+		fmt.Fprintln(w, "No instance needs to be replaced.")
+		return
+	}
+	for i, batch := range batches {
+		fmt.Fprintf(w, "# batch %d/%d (zone %s)\n", i+1, len(batches), batch.Zone)
+		for _, warning := range batch.Warnings {
+			fmt.Fprintf(w, "# WARNING: %s\n", warning)
+		}
+		quoted := make([]string, 0, len(batch.Addresses))
+		for _, arg := range batch.ReplaceArgs() {
+			quoted = append(quoted, fmt.Sprintf("'%s'", arg))
+		}
+		fmt.Fprintf(w, "terraform apply %s\n", strings.Join(quoted, " "))
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
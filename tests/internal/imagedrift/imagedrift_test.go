@@ -0,0 +1,108 @@
+package imagedrift
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+const newImageID = "r006-4bd1d4b0-2b2c-4b5d-8c47-9a8fd0d3b7c2"
+
+func loadReport(t *testing.T, fixture string, expected Expected) *Report {
+	state, err := tfplan.LoadState(fixture)
+	require.NoError(t, err)
+	report, err := Analyze(state, expected)
+	require.NoError(t, err)
+	return report
+}
+
+func TestAnalyzeLegacyFleet(t *testing.T) {
+	report := loadReport(t, "testdata/state_legacy_mixed.json", Expected{ImageID: newImageID})
+
+	assert.Len(t, report.Instances, 5)
+	assert.Len(t, report.PoolMembers, 7)
+
+	var stale []string
+	for _, instance := range report.Stale() {
+		stale = append(stale, instance.Address)
+	}
+	assert.Equal(t, []string{
+		`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-1"]`,
+		`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-b-0"]`,
+		`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-b-1"]`,
+		`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-c-0"]`,
+	}, stale)
+
+	var buf bytes.Buffer
+	report.WriteReport(&buf)
+	assert.Contains(t, buf.String(), "Instances: 5, stale: 4")
+}
+
+func TestAnalyzeCatalogFleet(t *testing.T) {
+	report := loadReport(t, "testdata/state_vni_catalog.json", Expected{
+		CatalogVersionCRN: "crn:v1:bluemix:public:globalcatalog-collection:global::1082e7d2-5e2f-0a11-a3bc-f88a8e1931fc:version:00111601-0ec5-41ac-b142-96d1e64e6442/2f2a3d8e-9b0c-4f1e-8d7a-51ad2bb01c55",
+	})
+
+	stale := report.Stale()
+	if assert.Len(t, stale, 1) {
+		assert.Equal(t, `module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-b-0"]`, stale[0].Address)
+		// VNI mode instances take their address from the primary network attachment
+		assert.Equal(t, "10.20.10.4", stale[0].IPv4Address)
+	}
+}
+
+func TestAnalyzeRequiresExpectedImage(t *testing.T) {
+	state, err := tfplan.LoadState("testdata/state_legacy_mixed.json")
+	require.NoError(t, err)
+	_, err = Analyze(state, Expected{})
+	assert.Error(t, err)
+}
+
+func TestRolloutPlan(t *testing.T) {
+	report := loadReport(t, "testdata/state_legacy_mixed.json", Expected{ImageID: newImageID})
+
+	batches := report.RolloutPlan(Options{BatchSize: 2})
+
+	// b-0 and b-1 are the only two healthy members of the zone 2 NLB pool so they can not go together,
+	// and c-0 is the only member of its pool so it is replaced alone with a warning
+	if assert.Len(t, batches, 4) {
+		assert.Equal(t, Batch{Zone: "us-south-1", Addresses: []string{`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-1"]`}}, batches[0])
+		assert.Equal(t, []string{`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-b-0"]`}, batches[1].Addresses)
+		assert.Equal(t, []string{`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-b-1"]`}, batches[2].Addresses)
+		assert.Empty(t, batches[1].Warnings)
+		assert.Equal(t, "us-south-3", batches[3].Zone)
+		assert.Len(t, batches[3].Warnings, 1)
+	}
+
+	var buf bytes.Buffer
+	WritePlan(&buf, batches)
+	assert.Contains(t, buf.String(), `terraform apply '-replace=module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-1"]'`)
+	assert.Contains(t, buf.String(), "# batch 4/4 (zone us-south-3)")
+}
+
+func TestRolloutPlanBatchesWithinPoolLimits(t *testing.T) {
+	report := &Report{
+		Instances: []Instance{
+			{Address: "vsi[0]", ID: "i0", Zone: "z1", IPv4Address: "10.0.0.1", Stale: true},
+			{Address: "vsi[1]", ID: "i1", Zone: "z1", IPv4Address: "10.0.0.2", Stale: true},
+			{Address: "vsi[2]", ID: "i2", Zone: "z1", IPv4Address: "10.0.0.3", Stale: true},
+			{Address: "vsi[3]", ID: "i3", Zone: "z1", IPv4Address: "10.0.0.4"},
+		},
+		PoolMembers: []PoolMember{
+			{Pool: "p", TargetAddress: "10.0.0.1", Health: "ok"},
+			{Pool: "p", TargetAddress: "10.0.0.2", Health: "ok"},
+			{Pool: "p", TargetAddress: "10.0.0.3", Health: "faulted"},
+			{Pool: "p", TargetAddress: "10.0.0.4", Health: "ok"},
+		},
+	}
+
+	// three healthy members and two must stay up: one healthy member per batch, the faulted one can tag along
+	batches := report.RolloutPlan(Options{BatchSize: 3, MinHealthyPerPool: 2})
+	if assert.Len(t, batches, 2) {
+		assert.Equal(t, []string{"vsi[0]"}, batches[0].Addresses)
+		assert.Equal(t, []string{"vsi[1]", "vsi[2]"}, batches[1].Addresses)
+	}
+}
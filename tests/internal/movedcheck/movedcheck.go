@@ -0,0 +1,219 @@
+// Package movedcheck finds resource addresses of the VSI module that disappear between two plans (typically the
+// plan fixture of an example at the previous release tag and at HEAD) without a matching `moved` block, and
+// proposes the block to add to moved_config.tf.
+//
+// Renames and key format changes (the reason for the `resource_name` workaround of the secondary VNIs in main.tf)
+// otherwise destroy and re-create resources for every consumer that upgrades.
+package movedcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// Address is a resource instance address relative to a call of the VSI module
+type Address struct {
+	// Module is the address of the module call instance, e.g. `module.slz_vsi`
+	Module string
+	// Resource is `type.name`, e.g. `ibm_is_volume.volume`
+	Resource string
+	// Key is the instance key as written in an address, e.g. `["vpc-subnet-a-0"]` or `[0]`, empty if none
+	Key string
+	// Name is the planned `name` attribute, used to pair renamed instances
+	Name string
+}
+
+// Relative returns the address relative to the module, as written in a moved block
+func (a Address) Relative() string {
+	return a.Resource + a.Key
+}
+
+// String returns the absolute address
+func (a Address) String() string {
+	return a.Module + "." + a.Relative()
+}
+
+// Finding is an address that disappeared between the two plans
+type Finding struct {
+	Address Address
+	// Moved is the moved block that covers the address, nil if there is none
+	Moved *tfconfig.MovedBlock
+	// Proposal is the moved block that would cover the address, nil if none could be guessed
+	Proposal *tfconfig.MovedBlock
+}
+
+// Report is the result of comparing two plans
+type Report struct {
+	Findings []Finding
+}
+
+// Addresses returns the managed resource instances of a plan that belong to the given module calls.
+// modulePaths are module call paths without instance keys (see tfplan.ModuleCallsWithSource).
+func Addresses(plan *tfjson.Plan, modulePaths []string) []Address {
+	calls := map[string]bool{}
+	for _, path := range modulePaths {
+		calls[path] = true
+	}
+	var addresses []Address
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil {
+			continue
+		}
+		if change.Change.Actions.Delete() {
+			continue
+		}
+		if !calls[tfplan.ModuleCallPath(change.ModuleAddress)] {
+			continue
+		}
+		resource := change.Type + "." + change.Name
+		address := Address{
+			Module:   change.ModuleAddress,
+			Resource: resource,
+			Key:      strings.TrimPrefix(change.Address, change.ModuleAddress+"."+resource),
+			Name:     tfplan.String(change.Change.After, "name"),
+		}
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i].String() < addresses[j].String() })
+	return addresses
+}
+
+// Compare reports the addresses of previous that are not in head, whether a moved block covers them and,
+// if not, a proposed moved block
+func Compare(previous []Address, head []Address, moved []tfconfig.MovedBlock) *Report {
+	headSet := map[string]bool{}
+	headResources := map[string]bool{}
+	for _, address := range head {
+		headSet[address.String()] = true
+		headResources[address.Module+"."+address.Resource] = true
+	}
+	previousResources := map[string]bool{}
+	for _, address := range previous {
+		previousResources[address.Module+"."+address.Resource] = true
+	}
+
+	var gone []Address
+	for _, address := range previous {
+		if !headSet[address.String()] {
+			gone = append(gone, address)
+		}
+	}
+	previousSet := map[string]bool{}
+	for _, address := range previous {
+		previousSet[address.String()] = true
+	}
+	var appeared []Address
+	for _, address := range head {
+		if !previousSet[address.String()] {
+			appeared = append(appeared, address)
+		}
+	}
+
+	report := &Report{}
+	for _, address := range gone {
+		finding := Finding{Address: address}
+		if block := coveringBlock(address, moved); block != nil {
+			finding.Moved = block
+		} else {
+			finding.Proposal = propose(address, appeared, headResources, previousResources)
+		}
+		report.Findings = append(report.Findings, finding)
+	}
+	return report
+}
+
+// coveringBlock returns the moved block whose `from` is the resource or the resource instance
+func coveringBlock(address Address, moved []tfconfig.MovedBlock) *tfconfig.MovedBlock {
+	for i := range moved {
+		if moved[i].From == address.Resource || moved[i].From == address.Relative() {
+			return &moved[i]
+		}
+	}
+	return nil
+}
+
+// propose guesses the moved block of a disappeared address:
+//   - the whole resource is gone and exactly one new resource of the same type appeared: the resource was renamed
+//   - otherwise, a new instance of the same resource (or of the renamed resource) with the same planned name: the key changed
+func propose(address Address, appeared []Address, headResources map[string]bool, previousResources map[string]bool) *tfconfig.MovedBlock {
+	resourceType := strings.SplitN(address.Resource, ".", 2)[0]
+
+	if !headResources[address.Module+"."+address.Resource] {
+		renamed := map[string]bool{}
+		for _, candidate := range appeared {
+			if candidate.Module == address.Module && strings.HasPrefix(candidate.Resource, resourceType+".") && !previousResources[candidate.Module+"."+candidate.Resource] {
+				renamed[candidate.Resource] = true
+			}
+		}
+		if len(renamed) == 1 {
+			for to := range renamed {
+				return &tfconfig.MovedBlock{From: address.Resource, To: to}
+			}
+		}
+		return nil
+	}
+
+	if address.Name == "" {
+		return nil
+	}
+	for _, candidate := range appeared {
+		if candidate.Module == address.Module && candidate.Resource == address.Resource && candidate.Name == address.Name {
+			return &tfconfig.MovedBlock{From: address.Relative(), To: candidate.Relative()}
+		}
+	}
+	return nil
+}
+
+// Uncovered returns the findings without a moved block
+func (r *Report) Uncovered() []Finding {
+	var uncovered []Finding
+	for _, finding := range r.Findings {
+		if finding.Moved == nil {
+			uncovered = append(uncovered, finding)
+		}
+	}
+	return uncovered
+}
+
+// Proposals returns the distinct moved blocks proposed for the uncovered findings
+func (r *Report) Proposals() []tfconfig.MovedBlock {
+	seen := map[string]bool{}
+	var proposals []tfconfig.MovedBlock
+	for _, finding := range r.Uncovered() {
+		if finding.Proposal == nil {
+			continue
+		}
+		key := finding.Proposal.From + "->" + finding.Proposal.To
+		if !seen[key] {
+			seen[key] = true
+			proposals = append(proposals, *finding.Proposal)
+		}
+	}
+	return proposals
+}
+
+// String renders the uncovered addresses and the proposed moved blocks
+func (r *Report) String() string {
+	uncovered := r.Uncovered()
+	if len(uncovered) == 0 {
+		return "Every disappeared address is covered by a moved block.\n"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d address(es) disappeared without a moved block:\n", len(uncovered))
+	for _, finding := range uncovered {
+		fmt.Fprintf(&sb, "  - %s\n", finding.Address)
+	}
+	if proposals := r.Proposals(); len(proposals) > 0 {
+		sb.WriteString("Proposed additions to moved_config.tf:\n\n")
+		for _, block := range proposals {
+			sb.WriteString(block.String())
+			sb.WriteString("\n\n")
+		}
+	}
+	return sb.String()
+}
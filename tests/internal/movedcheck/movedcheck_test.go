@@ -0,0 +1,115 @@
+package movedcheck
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func loadAddresses(t *testing.T, path string) []Address {
+	plan, err := tfplan.LoadPlan(path)
+	require.NoError(t, err)
+	return Addresses(plan, []string{"module.slz_vsi"})
+}
+
+func TestAddresses(t *testing.T) {
+	addresses := loadAddresses(t, "testdata/plan_head.json")
+
+	// resources outside of the module and delete-only changes are ignored
+	var list []string
+	for _, address := range addresses {
+		list = append(list, address.String())
+	}
+	assert.Equal(t, []string{
+		`module.slz_vsi.ibm_is_floating_ip.instance_fip["vpc-subnet-a-0"]`,
+		`module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-0"]`,
+		`module.slz_vsi.ibm_is_lb_pool_member.alb_pool_members[0]`,
+		`module.slz_vsi.ibm_is_volume.volume["slz-a1b2-001-vsi-block-1"]`,
+	}, list)
+	assert.Equal(t, `ibm_is_volume.volume["slz-a1b2-001-vsi-block-1"]`, addresses[3].Relative())
+	assert.Equal(t, "slz-a1b2-001-vsi-block-1", addresses[3].Name)
+}
+
+func TestCompare(t *testing.T) {
+	previous := loadAddresses(t, "testdata/plan_previous.json")
+	head := loadAddresses(t, "testdata/plan_head.json")
+	moved := []tfconfig.MovedBlock{{From: "ibm_is_lb_pool_member.pool_members", To: "ibm_is_lb_pool_member.alb_pool_members"}}
+
+	report := Compare(previous, head, moved)
+	require.Len(t, report.Findings, 4)
+
+	// the pool member rename is covered by moved_config.tf
+	uncovered := report.Uncovered()
+	require.Len(t, uncovered, 3)
+	for _, finding := range report.Findings {
+		if finding.Address.Resource == "ibm_is_lb_pool_member.pool_members" {
+			assert.Equal(t, &moved[0], finding.Moved)
+		}
+	}
+
+	byResource := map[string]Finding{}
+	for _, finding := range uncovered {
+		byResource[finding.Address.Resource] = finding
+	}
+	// resource renamed
+	assert.Equal(t, &tfconfig.MovedBlock{From: "ibm_is_floating_ip.vsi_fip", To: "ibm_is_floating_ip.instance_fip"}, byResource["ibm_is_floating_ip.vsi_fip"].Proposal)
+	// key format changed, paired on the planned name
+	assert.Equal(t, &tfconfig.MovedBlock{
+		From: `ibm_is_volume.volume["vpc-subnet-a-0-vsi-block-1"]`,
+		To:   `ibm_is_volume.volume["slz-a1b2-001-vsi-block-1"]`,
+	}, byResource["ibm_is_volume.volume"].Proposal)
+	// removed without replacement, nothing to propose
+	assert.Nil(t, byResource["ibm_is_subnet_reserved_ip.secondary_vni_ip"].Proposal)
+
+	assert.Len(t, report.Proposals(), 2)
+	out := report.String()
+	assert.Contains(t, out, "3 address(es) disappeared without a moved block")
+	assert.Contains(t, out, "moved {\n  from = ibm_is_floating_ip.vsi_fip\n  to   = ibm_is_floating_ip.instance_fip\n}")
+
+	// adding the proposals leaves only the reserved IP, which needs a human decision
+	report = Compare(previous, head, append(moved, report.Proposals()...))
+	assert.Len(t, report.Uncovered(), 1)
+	assert.Empty(t, Compare(head, head, nil).Findings)
+}
+
+// TestMovedBlocksSincePreviousRelease compares the plan fixture of every example at the previous release tag
+// (or PREVIOUS_RELEASE_REF) with the one at HEAD
+func TestMovedBlocksSincePreviousRelease(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	ref := fixtures.PreviousReleaseRef(root)
+	if ref == "" {
+		t.Skip("no previous release tag, set PREVIOUS_RELEASE_REF to compare against a specific ref")
+	}
+	moved, err := tfconfig.MovedBlocks(root)
+	require.NoError(t, err)
+	names, err := fixtures.PlanNames(root)
+	require.NoError(t, err)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			configDir, err := fixtures.ConfigDir(root, name)
+			require.NoError(t, err)
+			headPlan, err := tfplan.LoadPlan(fixtures.PlanPath(root, name))
+			require.NoError(t, err)
+
+			data, found, err := fixtures.ReadAtRef(root, ref, filepath.Join(fixtures.PlansDir, name+".json"))
+			require.NoError(t, err)
+			if !found {
+				t.Skipf("no plan fixture for %s at %s", name, ref)
+			}
+			previousPlan, err := tfplan.ParsePlan(data)
+			require.NoError(t, err)
+
+			calls := tfplan.ModuleCallsWithSource(headPlan, configDir, root)
+			require.NotEmpty(t, calls, "the plan fixture does not call the module")
+			report := Compare(Addresses(previousPlan, calls), Addresses(headPlan, calls), moved)
+			assert.Empty(t, report.Uncovered(), report.String())
+		})
+	}
+}
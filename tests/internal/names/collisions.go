@@ -0,0 +1,151 @@
+package names
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// unknownScope is the scope of a resource whose VPC or subnet is only known after the apply
+const unknownScope = "(known after apply)"
+
+// Collision is a name used by more than one resource of a scope where the VPC API requires it to be unique
+type Collision struct {
+	Scope     string
+	Value     string
+	Addresses []string
+}
+
+// CollisionReport is the outcome of the search for collisions in a plan
+type CollisionReport struct {
+	Names      []Name
+	Collisions []Collision
+}
+
+// Collisions gathers the names of the instances (and their boot volumes), virtual network interfaces, reserved IPs,
+// volumes, security groups, load balancers and their pools, and floating IPs a plan creates or keeps, and returns
+// the ones used more than once in the same scope: the VPC for instances, interfaces and security groups, the subnet
+// for reserved IPs, the load balancer for pools, and the region (the whole plan) for the others. When the VPC of a
+// resource is only known after the apply, it is the one of the other resources of the plan if they all share one.
+func Collisions(plan *tfjson.Plan) *CollisionReport {
+	var changes []*tfjson.ResourceChange
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil {
+			continue
+		}
+		if change.Change.Actions.Delete() && !change.Change.Actions.Replace() {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	subnetVPCs := map[string]string{}
+	vpcs := map[string]bool{}
+	for _, change := range changes {
+		if vpc := tfplan.String(change.Change.After, "vpc"); vpc != "" {
+			vpcs[vpc] = true
+			if change.Type == "ibm_is_subnet" {
+				subnetVPCs[tfplan.String(change.Change.After, "id")] = vpc
+			}
+		}
+	}
+	for _, resource := range tfplan.PriorResources(plan) {
+		if resource.Type == "ibm_is_subnet" {
+			subnetVPCs[tfplan.String(resource.AttributeValues, "id")] = tfplan.String(resource.AttributeValues, "vpc")
+		}
+	}
+	defaultVPC := unknownScope
+	if len(vpcs) == 1 {
+		for vpc := range vpcs {
+			defaultVPC = vpc
+		}
+	}
+	vpcScope := func(vpc string) string {
+		if vpc == "" {
+			vpc = defaultVPC
+		}
+		return "vpc " + vpc
+	}
+
+	report := &CollisionReport{}
+	scopes := map[string]map[string][]string{}
+	add := func(resourceType string, address string, scope string, value string) {
+		if value == "" {
+			return
+		}
+		report.Names = append(report.Names, Name{Type: resourceType, Address: address, Value: value})
+		if scopes[scope] == nil {
+			scopes[scope] = map[string][]string{}
+		}
+		scopes[scope][value] = append(scopes[scope][value], address)
+	}
+	for _, change := range changes {
+		after := change.Change.After
+		name := tfplan.String(after, "name")
+		switch change.Type {
+		case "ibm_is_instance":
+			add(change.Type, change.Address, vpcScope(tfplan.String(after, "vpc")), name)
+			add("ibm_is_volume", change.Address+".boot_volume", "region", tfplan.String(after, "boot_volume", 0, "name"))
+		case "ibm_is_security_group":
+			add(change.Type, change.Address, vpcScope(tfplan.String(after, "vpc")), name)
+		case "ibm_is_virtual_network_interface":
+			add(change.Type, change.Address, vpcScope(subnetVPCs[tfplan.String(after, "subnet")]), name)
+		case "ibm_is_subnet_reserved_ip":
+			subnet := tfplan.String(after, "subnet")
+			if subnet == "" {
+				subnet = unknownScope
+			}
+			add(change.Type, change.Address, "subnet "+subnet, name)
+		case "ibm_is_lb_pool":
+			lb := tfplan.String(after, "lb")
+			if lb == "" {
+				// a pool of a new load balancer collides only through the load balancer
+				lb = change.Address
+			}
+			add(change.Type, change.Address, "load balancer "+lb, name)
+		case "ibm_is_volume", "ibm_is_floating_ip", "ibm_is_lb":
+			add(change.Type, change.Address, "region", name)
+		}
+	}
+
+	for scope, values := range scopes {
+		for value, addresses := range values {
+			if len(addresses) > 1 {
+				sort.Strings(addresses)
+				report.Collisions = append(report.Collisions, Collision{Scope: scope, Value: value, Addresses: addresses})
+			}
+		}
+	}
+	sort.Slice(report.Collisions, func(i, j int) bool {
+		if report.Collisions[i].Scope != report.Collisions[j].Scope {
+			return report.Collisions[i].Scope < report.Collisions[j].Scope
+		}
+		return report.Collisions[i].Value < report.Collisions[j].Value
+	})
+	return report
+}
+
+// Failed returns true if a name collides
+func (r *CollisionReport) Failed() bool {
+	return len(r.Collisions) > 0
+}
+
+// String renders the collisions as a table, one line per colliding name
+func (r *CollisionReport) String() string {
+	if !r.Failed() {
+		return fmt.Sprintf("No collision between the %d names of the plan.\n", len(r.Names))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d name(s) of the plan are used more than once in their scope:\n", len(r.Collisions))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCOPE\tNAME\tADDRESSES")
+	for _, collision := range r.Collisions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", collision.Scope, collision.Value, strings.Join(collision.Addresses, ", "))
+	}
+	_ = w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package names
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// TestRepoMultiProfile checks the module calls of the multi-profile example share the VPC and its subnets without
+// a name collision
+func TestRepoMultiProfile(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, "multi-profile-one-vpc"))
+	require.NoError(t, err)
+
+	calls := tfplan.ModuleCallsWithSource(plan, filepath.Join(root, "examples", "multi-profile-one-vpc"), root)
+	require.Equal(t, []string{"module.slz_vsi_bx", "module.slz_vsi_cx"}, calls)
+
+	report := Collisions(plan)
+	assert.False(t, report.Failed(), report.String())
+	assert.Len(t, report.Names, 75)
+}
+
+func TestCollisions(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	data, err := os.ReadFile(fixtures.PlanPath(root, "multi-profile-one-vpc"))
+	require.NoError(t, err)
+	// names of the second module call built with the prefix of the first one, like the resources named without the
+	// module prefix the example was added for
+	data = bytes.ReplaceAll(data, []byte(`"slz-vsi-com-cx-ad89-0"`), []byte(`"slz-vsi-com-bx-ad89-0"`))
+	data = bytes.ReplaceAll(data, []byte(`"slz-vsi-com-cx-f96c-001-ip"`), []byte(`"slz-vsi-com-bx-f96c-001-ip"`))
+	data = bytes.ReplaceAll(data, []byte(`"slz-vsi-com-cx-slz-vsi-com-cx-lb-lb"`), []byte(`"slz-vsi-com-bx-slz-vsi-com-bx-lb-lb"`))
+	plan, err := tfplan.ParsePlan(data)
+	require.NoError(t, err)
+
+	report := Collisions(plan)
+	require.True(t, report.Failed())
+	assert.Equal(t, []Collision{
+		{
+			Scope:     "region",
+			Value:     "slz-vsi-com-bx-slz-vsi-com-bx-lb-lb",
+			Addresses: []string{`module.slz_vsi_bx.ibm_is_lb.lb["slz-vsi-com-bx-lb"]`, `module.slz_vsi_cx.ibm_is_lb.lb["slz-vsi-com-cx-lb"]`},
+		},
+		{
+			Scope:     "subnet 0717-7ed5595a-6fc5-8c14-30a0-f96c",
+			Value:     "slz-vsi-com-bx-f96c-001-ip",
+			Addresses: []string{`module.slz_vsi_bx.ibm_is_subnet_reserved_ip.vsi_ip["slz-vsi-com-vpc-subnet-a-0"]`, `module.slz_vsi_cx.ibm_is_subnet_reserved_ip.vsi_ip["slz-vsi-com-vpc-subnet-a-0"]`},
+		},
+		{
+			// the VPC of the interfaces comes from the instances, their subnets are not in the plan
+			Scope:     "vpc r006-4c6a9d12-3b7e-4f5a-9e8d-1f2a3b4c5d6e",
+			Value:     "slz-vsi-com-bx-ad89-0",
+			Addresses: []string{`module.slz_vsi_bx.ibm_is_virtual_network_interface.secondary_vni["slz-vsi-com-second-subnet-a-0"]`, `module.slz_vsi_cx.ibm_is_virtual_network_interface.secondary_vni["slz-vsi-com-second-subnet-a-0"]`},
+		},
+	}, report.Collisions)
+	assert.Contains(t, report.String(), "3 name(s) of the plan are used more than once in their scope:\nSCOPE")
+}
+
+func TestCollisionScopes(t *testing.T) {
+	plan, err := tfplan.ParsePlan([]byte(`{
+		"format_version": "1.2",
+		"resource_changes": [
+			{"address": "ibm_is_subnet_reserved_ip.a", "mode": "managed", "type": "ibm_is_subnet_reserved_ip", "change": {"actions": ["create"], "after": {"name": "ip", "subnet": "subnet-1"}}},
+			{"address": "ibm_is_subnet_reserved_ip.b", "mode": "managed", "type": "ibm_is_subnet_reserved_ip", "change": {"actions": ["create"], "after": {"name": "ip", "subnet": "subnet-2"}}},
+			{"address": "ibm_is_security_group.a", "mode": "managed", "type": "ibm_is_security_group", "change": {"actions": ["create"], "after": {"name": "sg", "vpc": "vpc-1"}}},
+			{"address": "ibm_is_security_group.b", "mode": "managed", "type": "ibm_is_security_group", "change": {"actions": ["create"], "after": {"name": "sg", "vpc": "vpc-2"}}},
+			{"address": "ibm_is_volume.old", "mode": "managed", "type": "ibm_is_volume", "change": {"actions": ["delete"], "before": {"name": "data"}, "after": null}},
+			{"address": "ibm_is_volume.new", "mode": "managed", "type": "ibm_is_volume", "change": {"actions": ["create"], "after": {"name": "data"}}},
+			{"address": "ibm_is_instance.a", "mode": "managed", "type": "ibm_is_instance", "change": {"actions": ["no-op"], "after": {"name": "vsi", "vpc": "vpc-1", "boot_volume": [{"name": "data"}]}}}
+		]
+	}`))
+	require.NoError(t, err)
+
+	// reserved IPs in different subnets and security groups in different VPCs do not collide, a deleted volume
+	// frees its name but a boot volume does not
+	report := Collisions(plan)
+	assert.Equal(t, []Collision{{Scope: "region", Value: "data", Addresses: []string{"ibm_is_instance.a.boot_volume", "ibm_is_volume.new"}}}, report.Collisions)
+}
@@ -0,0 +1,355 @@
+// Package names enumerates the names the module generates for its resources, following the naming expressions of
+// the .tf files (Expressions), and checks them against the naming rule of their resource type. Names are built by
+// concatenation, so a long prefix (the fully-configurable solution passes `"${local.prefix}${var.vsi_name}"`) can
+// exceed the 63 characters of the VPC API, which only fails at apply. Collisions finds the names a plan uses twice
+// where they must be unique, for example between several calls of the module in one VPC.
+package names
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// UnknownID stands for the id of a subnet that does not exist yet. Only its last 4 characters are used in names,
+// and every VPC id ends with 4 lowercase hexadecimal characters.
+const UnknownID = "0000"
+
+// Rule is the length limit and charset of the names of a resource type
+type Rule struct {
+	MaxLength int
+	Pattern   *regexp.Regexp
+}
+
+// VPCRule is the rule of the names of the VPC API
+var VPCRule = Rule{MaxLength: 63, Pattern: regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)}
+
+// Rules is the rule of each resource type the module and the fully-configurable solution name. IAM allows more
+// characters in trusted profile names, they are held to the VPC rule so that one prefix fits every name.
+var Rules = map[string]Rule{
+	"ibm_is_instance":                    VPCRule,
+	"ibm_is_virtual_network_interface":   VPCRule,
+	"ibm_is_instance_network_attachment": VPCRule,
+	"ibm_is_subnet_reserved_ip":          VPCRule,
+	"ibm_is_volume":                      VPCRule,
+	"ibm_is_floating_ip":                 VPCRule,
+	"ibm_is_lb":                          VPCRule,
+	"ibm_is_lb_pool":                     VPCRule,
+	"ibm_is_security_group":              VPCRule,
+	"ibm_is_ssh_key":                     VPCRule,
+	"ibm_iam_trusted_profile":            VPCRule,
+	"ibm_iam_trusted_profile_link":       VPCRule,
+}
+
+// Expressions are the naming expressions this package follows, by the resource (or local) they name, as written in
+// the .tf files of the module and of the fully-configurable solution. TestRepoExpressions fails when one of them
+// changes, so that the enumeration is updated with it.
+var Expressions = map[string]string{
+	"local.vsi_list.vsi_name":                                 `try(keys(lookup(var.custom_vsi_volume_names, var.subnets[subnet].name, {}))[count], "${var.prefix}-${substr(var.subnets[subnet].id, -4, 4)}-${format("%03d", count + 1)}")`,
+	"local.secondary_vni_list.resource_name":                  `"${var.prefix}-${substr(var.secondary_subnets[subnet].id, -4, 4)}-${count}"`,
+	"local.secondary_reserved_ips_list.resource_name":         `"${vsi_value.vsi_name}-${count}-ip"`,
+	"local.legacy_secondary_fip_list.name":                    `"${instance.name}-${interface}-fip"`,
+	"local.volume_list.vol_name":                              `try(values(lookup(var.custom_vsi_volume_names, var.subnets[subnet].name, {}))[count][idx], "${var.prefix}-${substr(var.subnets[subnet].id, -4, 4)}-${format("%03d", count + 1)}-${volume.name}")`,
+	"ibm_is_virtual_network_interface.primary_vni":            `"${each.value.vsi_name}-vni"`,
+	"ibm_is_virtual_network_interface.secondary_vni":          `each.value.resource_name`,
+	"ibm_is_subnet_reserved_ip.vsi_ip":                        `"${each.value.vsi_name}-ip"`,
+	"ibm_is_subnet_reserved_ip.secondary_vsi_ip":              `each.value.resource_name`,
+	"ibm_is_subnet_reserved_ip.secondary_vni_ip":              `"${var.prefix}-${substr(md5(each.value.name), -4, 4)}-secondary-vni-ip"`,
+	"ibm_is_instance.vsi":                                     `each.value.vsi_name`,
+	"ibm_is_instance.vsi.primary_network_attachment":          `ibm_is_virtual_network_interface.primary_vni[each.key].name`,
+	"ibm_is_instance.vsi.network_attachments":                 `"${each.value.vsi_name}-secondary-vni-${network_attachments.key}"`,
+	"ibm_is_instance.vsi.boot_volume":                         `var.use_static_boot_volume_name ? "${each.value.vsi_name}-boot" : null`,
+	"ibm_is_floating_ip.vsi_fip":                              `"${each.value.name}-fip"`,
+	"ibm_is_floating_ip.secondary_fip":                        `each.key`,
+	"ibm_is_floating_ip.vni_secondary_fip":                    `"${each.value.vni_name}-fip"`,
+	"ibm_is_volume.volume":                                    `each.value.vol_name`,
+	"ibm_is_lb.lb":                                            `"${var.prefix}-${each.value.name}-lb"`,
+	"ibm_is_lb_pool.pool":                                     `"${var.prefix}-${each.value.name}-lb-pool"`,
+	"ibm_is_security_group.security_group":                    `each.value.name`,
+	"fully-configurable:local.prefix":                         `var.prefix != null ? trimspace(var.prefix) != "" ? "${var.prefix}-" : "" : ""`,
+	"fully-configurable:module.vsi.prefix":                    `"${local.prefix}${var.vsi_name}"`,
+	"fully-configurable:ibm_is_ssh_key.ssh_key":               `"${local.prefix}${var.vsi_name}-ssh-key-${each.key}"`,
+	"fully-configurable:ibm_is_ssh_key.auto_generate_ssh_key": `"${var.prefix}${var.vsi_name}-ssh-key"`,
+	"fully-configurable:module.trusted_profile":               `"${local.prefix}-vsi-logging-trusted-profile"`,
+	"fully-configurable:module.trusted_profile.links":         `"${vsi.name}-link"`,
+}
+
+// Subnet is an element of the subnets and secondary_subnets inputs
+type Subnet struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Zone string `json:"zone"`
+}
+
+// Volume is an element of the block_storage_volumes input
+type Volume struct {
+	Name string `json:"name"`
+}
+
+// SecurityGroup is the security_group input, or the one of a load balancer
+type SecurityGroup struct {
+	Name string `json:"name"`
+}
+
+// LoadBalancer is an element of the load_balancers input
+type LoadBalancer struct {
+	Name          string         `json:"name"`
+	SecurityGroup *SecurityGroup `json:"security_group"`
+}
+
+// Inputs are the inputs of the module that names depend on, with the names of its variables so they can be read
+// from a tfvars JSON file. The other variables are ignored.
+type Inputs struct {
+	Prefix                      string                         `json:"prefix"`
+	Subnets                     []Subnet                       `json:"subnets"`
+	VSIPerSubnet                int                            `json:"vsi_per_subnet"`
+	SecondarySubnets            []Subnet                       `json:"secondary_subnets"`
+	CustomVSIVolumeNames        map[string]map[string][]string `json:"custom_vsi_volume_names"`
+	BlockStorageVolumes         []Volume                       `json:"block_storage_volumes"`
+	ManageReservedIPs           bool                           `json:"manage_reserved_ips"`
+	PrimaryVNIAdditionalIPCount int                            `json:"primary_vni_additional_ip_count"`
+	UseLegacyNetworkInterface   bool                           `json:"use_legacy_network_interface"`
+	UseStaticBootVolumeName     bool                           `json:"use_static_boot_volume_name"`
+	EnableFloatingIP            bool                           `json:"enable_floating_ip"`
+	SecondaryFloatingIPs        []string                       `json:"secondary_floating_ips"`
+	LoadBalancers               []LoadBalancer                 `json:"load_balancers"`
+	CreateSecurityGroup         bool                           `json:"create_security_group"`
+	SecurityGroup               *SecurityGroup                 `json:"security_group"`
+}
+
+// Name is a name the module generates
+type Name struct {
+	Type string
+	// Address of the named resource, relative to the module, with the nested block of the name if any
+	// (`ibm_is_instance.vsi["subnet-a-0"].boot_volume`)
+	Address string
+	Value   string
+}
+
+// Problem is a name breaking the rule of its resource type
+type Problem struct {
+	Name   Name
+	Detail string
+}
+
+// Report is the outcome of the check of the names
+type Report struct {
+	Names    []Name
+	Problems []Problem
+}
+
+// LoadInputs reads module inputs from a tfvars JSON file
+func LoadInputs(path string) (*Inputs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading module inputs %s: %w", path, err)
+	}
+	inputs := &Inputs{}
+	if err := json.Unmarshal(data, inputs); err != nil {
+		return nil, fmt.Errorf("error parsing module inputs %s: %w", path, err)
+	}
+	return inputs, nil
+}
+
+// vsi is an element of local.vsi_map
+type vsi struct {
+	key    string
+	name   string
+	subnet Subnet
+	count  int
+}
+
+// secondaryVNI is an element of local.secondary_vni_map
+type secondaryVNI struct {
+	key   string
+	name  string
+	zone  string
+	count int
+}
+
+// Enumerate returns every name the module generates from its inputs, in address order
+func (in *Inputs) Enumerate() []Name {
+	var names []Name
+	add := func(resourceType string, address string, value string) {
+		names = append(names, Name{Type: resourceType, Address: address, Value: value})
+	}
+
+	var vsis []vsi
+	for count := 0; count < in.VSIPerSubnet; count++ {
+		for _, subnet := range in.Subnets {
+			name := fmt.Sprintf("%s-%s-%03d", in.Prefix, suffix(subnet.ID), count+1)
+			if custom := sortedKeys(in.CustomVSIVolumeNames[subnet.Name]); count < len(custom) {
+				name = custom[count]
+			}
+			vsis = append(vsis, vsi{key: fmt.Sprintf("%s-%d", subnet.Name, count), name: name, subnet: subnet, count: count})
+		}
+	}
+	var vnis []secondaryVNI
+	for count := 0; count < in.VSIPerSubnet; count++ {
+		for _, subnet := range in.SecondarySubnets {
+			vnis = append(vnis, secondaryVNI{
+				key:   fmt.Sprintf("%s-%d", subnet.Name, count),
+				name:  fmt.Sprintf("%s-%s-%d", in.Prefix, suffix(subnet.ID), count),
+				zone:  subnet.Zone,
+				count: count,
+			})
+		}
+	}
+	sort.Slice(vnis, func(i, j int) bool { return vnis[i].key < vnis[j].key })
+
+	for _, server := range vsis {
+		address := fmt.Sprintf("ibm_is_instance.vsi[%q]", server.key)
+		add("ibm_is_instance", address, server.name)
+		if in.UseStaticBootVolumeName {
+			add("ibm_is_volume", address+".boot_volume", server.name+"-boot")
+		}
+		if in.ManageReservedIPs {
+			add("ibm_is_subnet_reserved_ip", fmt.Sprintf("ibm_is_subnet_reserved_ip.vsi_ip[%q]", server.key), server.name+"-ip")
+		}
+		if in.EnableFloatingIP {
+			add("ibm_is_floating_ip", fmt.Sprintf("ibm_is_floating_ip.vsi_fip[%q]", server.key), server.name+"-fip")
+		}
+		if in.UseLegacyNetworkInterface {
+			for _, subnet := range in.SecondaryFloatingIPs {
+				name := fmt.Sprintf("%s-%s-fip", server.name, subnet)
+				add("ibm_is_floating_ip", fmt.Sprintf("ibm_is_floating_ip.secondary_fip[%q]", name), name)
+			}
+			continue
+		}
+		add("ibm_is_virtual_network_interface", fmt.Sprintf("ibm_is_virtual_network_interface.primary_vni[%q]", server.key), server.name+"-vni")
+		add("ibm_is_instance_network_attachment", address+".primary_network_attachment", server.name+"-vni")
+		attachment := 0
+		for _, vni := range vnis {
+			// main.tf matches the last character of the key with the count of the instance
+			if vni.zone == server.subnet.Zone && vni.key[len(vni.key)-1:] == fmt.Sprint(server.count) {
+				add("ibm_is_instance_network_attachment", fmt.Sprintf("%s.network_attachments[%d]", address, attachment), fmt.Sprintf("%s-secondary-vni-%d", server.name, attachment))
+				attachment++
+			}
+		}
+		for count := 0; count < in.PrimaryVNIAdditionalIPCount; count++ {
+			key := fmt.Sprintf("%s-%d", server.key, count)
+			add("ibm_is_subnet_reserved_ip", fmt.Sprintf("ibm_is_subnet_reserved_ip.secondary_vsi_ip[%q]", key), fmt.Sprintf("%s-%d-ip", server.name, count))
+		}
+	}
+
+	if !in.UseLegacyNetworkInterface {
+		fips := map[string]bool{}
+		for _, subnet := range in.SecondaryFloatingIPs {
+			for _, vni := range vnis {
+				if strings.Contains(vni.key, subnet) {
+					fips[vni.key] = true
+				}
+			}
+		}
+		for _, vni := range vnis {
+			add("ibm_is_virtual_network_interface", fmt.Sprintf("ibm_is_virtual_network_interface.secondary_vni[%q]", vni.key), vni.name)
+			if in.ManageReservedIPs {
+				sum := md5.Sum([]byte(vni.key))
+				add("ibm_is_subnet_reserved_ip", fmt.Sprintf("ibm_is_subnet_reserved_ip.secondary_vni_ip[%q]", vni.key), fmt.Sprintf("%s-%s-secondary-vni-ip", in.Prefix, suffix(hex.EncodeToString(sum[:]))))
+			}
+			if fips[vni.key] {
+				add("ibm_is_floating_ip", fmt.Sprintf("ibm_is_floating_ip.vni_secondary_fip[%q]", vni.key), vni.name+"-fip")
+			}
+		}
+	}
+
+	for _, subnet := range in.Subnets {
+		custom := in.CustomVSIVolumeNames[subnet.Name]
+		keys := sortedKeys(custom)
+		for count := 0; count < in.VSIPerSubnet; count++ {
+			for i, volume := range in.BlockStorageVolumes {
+				name := fmt.Sprintf("%s-%s-%03d-%s", in.Prefix, suffix(subnet.ID), count+1, volume.Name)
+				if count < len(keys) && i < len(custom[keys[count]]) {
+					name = custom[keys[count]][i]
+				}
+				key := fmt.Sprintf("%s-%d-%s", subnet.Name, count, volume.Name)
+				add("ibm_is_volume", fmt.Sprintf("ibm_is_volume.volume[%q]", key), name)
+			}
+		}
+	}
+
+	groups := map[string]bool{}
+	if in.CreateSecurityGroup && in.SecurityGroup != nil {
+		groups[in.SecurityGroup.Name] = true
+	}
+	for _, lb := range in.LoadBalancers {
+		add("ibm_is_lb", fmt.Sprintf("ibm_is_lb.lb[%q]", lb.Name), fmt.Sprintf("%s-%s-lb", in.Prefix, lb.Name))
+		add("ibm_is_lb_pool", fmt.Sprintf("ibm_is_lb_pool.pool[%q]", lb.Name), fmt.Sprintf("%s-%s-lb-pool", in.Prefix, lb.Name))
+		if lb.SecurityGroup != nil {
+			groups[lb.SecurityGroup.Name] = true
+		}
+	}
+	for _, group := range sortedKeys(groups) {
+		add("ibm_is_security_group", fmt.Sprintf("ibm_is_security_group.security_group[%q]", group), group)
+	}
+
+	sort.SliceStable(names, func(i, j int) bool { return names[i].Address < names[j].Address })
+	return names
+}
+
+// suffix is `substr(value, -4, 4)`
+func suffix(value string) string {
+	if value == "" {
+		value = UnknownID
+	}
+	if len(value) <= 4 {
+		return value
+	}
+	return value[len(value)-4:]
+}
+
+// sortedKeys returns the keys of a map in the order of the Terraform keys and values functions
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Check returns the names breaking the rule of their resource type. Names of a type without a rule are held to the
+// VPC rule.
+func Check(names []Name) *Report {
+	report := &Report{Names: names}
+	for _, name := range names {
+		rule, ok := Rules[name.Type]
+		if !ok {
+			rule = VPCRule
+		}
+		if len(name.Value) > rule.MaxLength {
+			report.Problems = append(report.Problems, Problem{Name: name, Detail: fmt.Sprintf("longer than %d characters", rule.MaxLength)})
+		}
+		if !rule.Pattern.MatchString(name.Value) {
+			report.Problems = append(report.Problems, Problem{Name: name, Detail: "does not match " + rule.Pattern.String()})
+		}
+	}
+	return report
+}
+
+// Failed returns true if a name breaks its rule
+func (r *Report) Failed() bool {
+	return len(r.Problems) > 0
+}
+
+// String renders the problems as a table, one line per problem
+func (r *Report) String() string {
+	if !r.Failed() {
+		return fmt.Sprintf("All %d generated names are valid.\n", len(r.Names))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of the %d generated names are invalid:\n", len(r.Problems), len(r.Names))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tLENGTH\tNAME\tPROBLEM")
+	for _, problem := range r.Problems {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", problem.Name.Address, len(problem.Name.Value), problem.Name.Value, problem.Detail)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
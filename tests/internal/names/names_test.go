@@ -0,0 +1,224 @@
+package names
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func values(names []Name) map[string]string {
+	m := map[string]string{}
+	for _, name := range names {
+		m[name.Address] = name.Value
+	}
+	return m
+}
+
+func TestEnumerate(t *testing.T) {
+	inputs := &Inputs{
+		Prefix:                      "slz-vsi",
+		Subnets:                     []Subnet{{Name: "vpc-subnet-a", ID: "0717-0e6e4b2a-0fa8", Zone: "us-south-1"}, {Name: "vpc-subnet-b", ID: "0727-5bc2c2f1-d824", Zone: "us-south-2"}},
+		VSIPerSubnet:                2,
+		SecondarySubnets:            []Subnet{{Name: "second-subnet-a", ID: "0717-61a6b6b7-2e7d", Zone: "us-south-1"}},
+		BlockStorageVolumes:         []Volume{{Name: "data"}},
+		ManageReservedIPs:           true,
+		PrimaryVNIAdditionalIPCount: 1,
+		UseStaticBootVolumeName:     true,
+		EnableFloatingIP:            true,
+		SecondaryFloatingIPs:        []string{"second-subnet-a"},
+		LoadBalancers:               []LoadBalancer{{Name: "alb", SecurityGroup: &SecurityGroup{Name: "alb-sg"}}},
+		CreateSecurityGroup:         true,
+		SecurityGroup:               &SecurityGroup{Name: "vsi-sg"},
+	}
+	names := values(inputs.Enumerate())
+
+	assert.Equal(t, "slz-vsi-0fa8-001", names[`ibm_is_instance.vsi["vpc-subnet-a-0"]`])
+	assert.Equal(t, "slz-vsi-d824-002", names[`ibm_is_instance.vsi["vpc-subnet-b-1"]`])
+	assert.Equal(t, "slz-vsi-0fa8-002-boot", names[`ibm_is_instance.vsi["vpc-subnet-a-1"].boot_volume`])
+	assert.Equal(t, "slz-vsi-0fa8-001-vni", names[`ibm_is_virtual_network_interface.primary_vni["vpc-subnet-a-0"]`])
+	assert.Equal(t, "slz-vsi-0fa8-001-vni", names[`ibm_is_instance.vsi["vpc-subnet-a-0"].primary_network_attachment`])
+	assert.Equal(t, "slz-vsi-d824-001-ip", names[`ibm_is_subnet_reserved_ip.vsi_ip["vpc-subnet-b-0"]`])
+	assert.Equal(t, "slz-vsi-d824-001-0-ip", names[`ibm_is_subnet_reserved_ip.secondary_vsi_ip["vpc-subnet-b-0-0"]`])
+	assert.Equal(t, "slz-vsi-d824-001-fip", names[`ibm_is_floating_ip.vsi_fip["vpc-subnet-b-0"]`])
+	assert.Equal(t, "slz-vsi-0fa8-002-data", names[`ibm_is_volume.volume["vpc-subnet-a-1-data"]`])
+	assert.Equal(t, "slz-vsi-2e7d-1", names[`ibm_is_virtual_network_interface.secondary_vni["second-subnet-a-1"]`])
+	assert.Equal(t, "slz-vsi-2e7d-1-fip", names[`ibm_is_floating_ip.vni_secondary_fip["second-subnet-a-1"]`])
+	// substr(md5("second-subnet-a-0"), -4, 4)
+	assert.Equal(t, "slz-vsi-4512-secondary-vni-ip", names[`ibm_is_subnet_reserved_ip.secondary_vni_ip["second-subnet-a-0"]`])
+	assert.Equal(t, "slz-vsi-alb-lb-pool", names[`ibm_is_lb_pool.pool["alb"]`])
+	assert.Equal(t, "alb-sg", names[`ibm_is_security_group.security_group["alb-sg"]`])
+	assert.Equal(t, "vsi-sg", names[`ibm_is_security_group.security_group["vsi-sg"]`])
+
+	// the secondary subnet is in the zone of subnet a only, each instance of the zone gets the interface of its count
+	assert.Equal(t, "slz-vsi-0fa8-002-secondary-vni-0", names[`ibm_is_instance.vsi["vpc-subnet-a-1"].network_attachments[0]`])
+	assert.NotContains(t, names, `ibm_is_instance.vsi["vpc-subnet-a-1"].network_attachments[1]`)
+	assert.NotContains(t, names, `ibm_is_instance.vsi["vpc-subnet-b-0"].network_attachments[0]`)
+	assert.Len(t, names, 44)
+
+	assert.False(t, Check(inputs.Enumerate()).Failed())
+}
+
+func TestEnumerateCustomAndLegacy(t *testing.T) {
+	inputs := &Inputs{
+		Prefix:                    "legacy",
+		Subnets:                   []Subnet{{Name: "subnet-a", Zone: "eu-de-1"}},
+		VSIPerSubnet:              2,
+		CustomVSIVolumeNames:      map[string]map[string][]string{"subnet-a": {"web-b": {"web-b-logs"}, "web-a": {"web-a-logs", "web-a-data"}}},
+		BlockStorageVolumes:       []Volume{{Name: "logs"}, {Name: "data"}},
+		UseLegacyNetworkInterface: true,
+		SecondaryFloatingIPs:      []string{"second-subnet-a"},
+	}
+	names := values(inputs.Enumerate())
+
+	// custom names are taken in key order, a missing volume name falls back to the generated one
+	assert.Equal(t, "web-a", names[`ibm_is_instance.vsi["subnet-a-0"]`])
+	assert.Equal(t, "web-b", names[`ibm_is_instance.vsi["subnet-a-1"]`])
+	assert.Equal(t, "web-a-data", names[`ibm_is_volume.volume["subnet-a-0-data"]`])
+	assert.Equal(t, "legacy-0000-002-data", names[`ibm_is_volume.volume["subnet-a-1-data"]`])
+	assert.Equal(t, "web-b-second-subnet-a-fip", names[`ibm_is_floating_ip.secondary_fip["web-b-second-subnet-a-fip"]`])
+	assert.NotContains(t, names, `ibm_is_virtual_network_interface.primary_vni["subnet-a-0"]`)
+}
+
+func TestCheck(t *testing.T) {
+	inputs := NewFullyConfigurable("landing-zone-production-workloads-eu")
+	inputs.VSIName = "app-server-primary"
+	inputs.BlockStorageVolumes = []Volume{{Name: "Data_1"}}
+	inputs.ManageReservedIPs = true
+	inputs.InstallLoggingAgent = true
+
+	report := Check(inputs.Enumerate())
+	require.True(t, report.Failed())
+	problems := map[string]string{}
+	for _, problem := range report.Problems {
+		problems[problem.Name.Address] = problem.Detail
+	}
+	// 55 characters for the instance, 59 for the interface, 58 for the reserved IP, 60 for the trusted profile link ...
+	assert.NotContains(t, problems, `module.vsi.ibm_is_instance.vsi["subnet-0"]`)
+	assert.NotContains(t, problems, `module.vsi.ibm_is_virtual_network_interface.primary_vni["subnet-0"]`)
+	assert.NotContains(t, problems, `module.trusted_profile[0].ibm_iam_trusted_profile_link.link["trusted-profile-link"]`)
+	// ... but 65 for the trusted profile, local.prefix already ends with a hyphen
+	assert.Equal(t, "longer than 63 characters", problems["module.trusted_profile[0].ibm_iam_trusted_profile.profile"])
+	assert.Equal(t, "does not match "+VPCRule.Pattern.String(), problems[`module.vsi.ibm_is_volume.volume["subnet-0-Data_1"]`])
+	assert.Len(t, report.Problems, 2)
+	assert.Contains(t, report.String(), "2 of the 8 generated names are invalid:\nADDRESS")
+
+	assert.Equal(t, "All 4 generated names are valid.\n", Check(NewFullyConfigurable("vsi").Enumerate()).String())
+}
+
+// TestFullyConfigurableFixture compares the names of the solution with the ones planned in its fixture
+func TestFullyConfigurableFixture(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, "fully-configurable"))
+	require.NoError(t, err)
+
+	inputs := NewFullyConfigurable(plan.Variables["prefix"].Value.(string))
+	inputs.VSIName = plan.Variables["vsi_name"].Value.(string)
+	inputs.InstallLoggingAgent = plan.Variables["install_logging_agent"].Value.(bool)
+	// the first subnet of the VPC of the fixture
+	inputs.Subnet.Name = "vpc-subnet-a"
+	names := inputs.Enumerate()
+
+	planned := map[string]string{}
+	for _, change := range plan.ResourceChanges {
+		if name := tfplan.String(change.Change.After, "name"); name != "" {
+			planned[change.Address] = name
+		}
+	}
+	compared := 0
+	for _, name := range names {
+		if value, ok := planned[name.Address]; ok {
+			assert.Equal(t, value, name.Value, name.Address)
+			compared++
+		}
+	}
+	assert.Equal(t, 4, compared, "the ssh key, trusted profile, instance and interface are planned with a known name")
+	assert.False(t, Check(names).Failed())
+}
+
+// TestFixtureNames checks the names planned in the fixtures for the resource types of the rules
+func TestFixtureNames(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	fixtureNames, err := fixtures.PlanNames(root)
+	require.NoError(t, err)
+	for _, fixture := range fixtureNames {
+		plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, fixture))
+		require.NoError(t, err)
+		var names []Name
+		for _, change := range plan.ResourceChanges {
+			if _, ok := Rules[change.Type]; !ok || change.Change == nil {
+				continue
+			}
+			if name := tfplan.String(change.Change.After, "name"); name != "" {
+				names = append(names, Name{Type: change.Type, Address: change.Address, Value: name})
+			}
+		}
+		report := Check(names)
+		assert.False(t, report.Failed(), "%s:\n%s", fixture, report)
+	}
+}
+
+// TestRepoExpressions checks the naming expressions followed by the package are the ones of the .tf files, and that
+// every name of a resource of the module is covered
+func TestRepoExpressions(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	files, err := tfconfig.ParseDir(root)
+	require.NoError(t, err)
+
+	found := map[string]string{}
+	for _, file := range files {
+		for _, block := range file.BlocksOfType("resource") {
+			collectNames(file, block.Body, block.Labels[0]+"."+block.Labels[1], found)
+		}
+	}
+	assert.Contains(t, found, "ibm_is_instance.vsi.network_attachments")
+	for key, text := range found {
+		assert.Equal(t, text, Expressions[key], "name of %s", key)
+	}
+
+	var sources []string
+	for _, dir := range []string{root, filepath.Join(root, "solutions", "fully-configurable")} {
+		paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+		require.NoError(t, err)
+		for _, path := range paths {
+			source, err := os.ReadFile(path)
+			require.NoError(t, err)
+			sources = append(sources, string(source))
+		}
+	}
+	all := strings.Join(sources, "\n")
+	for key, text := range Expressions {
+		assert.Contains(t, all, text, "expression of %s", key)
+	}
+}
+
+// collectNames records the expression of the non-literal name attributes of a block and of its nested blocks, the
+// content of a dynamic block is recorded under its label
+func collectNames(file *tfconfig.File, body *hclsyntax.Body, key string, found map[string]string) {
+	if attribute, ok := body.Attributes["name"]; ok {
+		// a literal evaluates without a context
+		if _, diags := attribute.Expr.Value(nil); diags.HasErrors() {
+			found[key] = file.ExprText(attribute.Expr)
+		}
+	}
+	for _, block := range body.Blocks {
+		switch {
+		case block.Type == "dynamic":
+			for _, content := range block.Body.Blocks {
+				collectNames(file, content.Body, key+"."+block.Labels[0], found)
+			}
+		case block.Type != "content" && block.Type != "lifecycle":
+			collectNames(file, block.Body, key+"."+block.Type, found)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package names
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// placeholderSubnet stands for the existing subnet the fully-configurable solution reads from the VPC. Its name and
+// id only change the addresses, not the names: the solution names the instance and volumes itself, and uses the name
+// of the secondary subnet only through md5.
+var placeholderSubnet = Subnet{Name: "subnet", ID: UnknownID, Zone: "zone-1"}
+
+// FullyConfigurable are the inputs of the fully-configurable solution that names depend on, with the names of its
+// variables and their defaults
+type FullyConfigurable struct {
+	Prefix                                          *string        `json:"prefix"`
+	VSIName                                         string         `json:"vsi_name"`
+	SSHPublicKeys                                   []string       `json:"ssh_public_keys"`
+	AutoGenerateSSHKey                              bool           `json:"auto_generate_ssh_key"`
+	ExistingSecondarySubnetID                       *string        `json:"existing_secondary_subnet_id"`
+	BlockStorageVolumes                             []Volume       `json:"block_storage_volumes"`
+	ManageReservedIPs                               bool           `json:"manage_reserved_ips"`
+	PrimaryVirtualNetworkInterfaceAdditionalIPCount int            `json:"primary_virtual_network_interface_additional_ip_count"`
+	UseStaticBootVolumeName                         bool           `json:"use_static_boot_volume_name"`
+	EnableFloatingIP                                bool           `json:"enable_floating_ip"`
+	SecondaryFloatingIPs                            []string       `json:"secondary_floating_ips"`
+	LoadBalancers                                   []LoadBalancer `json:"load_balancers"`
+	SecurityGroup                                   *SecurityGroup `json:"security_group"`
+	InstallLoggingAgent                             bool           `json:"install_logging_agent"`
+	LoggingAuthMode                                 string         `json:"logging_auth_mode"`
+	LoggingTrustedProfileID                         *string        `json:"logging_trusted_profile_id"`
+	// Subnet is the existing subnet of the instance, read from the VPC by the solution
+	Subnet Subnet `json:"-"`
+}
+
+// NewFullyConfigurable returns the inputs of the fully-configurable solution with their defaults
+func NewFullyConfigurable(prefix string) *FullyConfigurable {
+	return &FullyConfigurable{Prefix: &prefix, VSIName: "vsi", Subnet: placeholderSubnet, AutoGenerateSSHKey: true, LoggingAuthMode: "VSITrustedProfile"}
+}
+
+// LoadFullyConfigurable reads inputs of the fully-configurable solution from a tfvars JSON file
+func LoadFullyConfigurable(path string) (*FullyConfigurable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading solution inputs %s: %w", path, err)
+	}
+	inputs := NewFullyConfigurable("")
+	inputs.Prefix = nil
+	if err := json.Unmarshal(data, inputs); err != nil {
+		return nil, fmt.Errorf("error parsing solution inputs %s: %w", path, err)
+	}
+	return inputs, nil
+}
+
+// localPrefix is local.prefix of the solution
+func (s *FullyConfigurable) localPrefix() string {
+	if s.Prefix == nil || strings.TrimSpace(*s.Prefix) == "" {
+		return ""
+	}
+	return *s.Prefix + "-"
+}
+
+// Module returns the inputs the solution passes to the module
+func (s *FullyConfigurable) Module() *Inputs {
+	prefix := s.localPrefix() + s.VSIName
+	var volumes []string
+	for _, volume := range s.BlockStorageVolumes {
+		volumes = append(volumes, volume.Name)
+	}
+	inputs := &Inputs{
+		Prefix:                      prefix,
+		Subnets:                     []Subnet{s.Subnet},
+		VSIPerSubnet:                1,
+		CustomVSIVolumeNames:        map[string]map[string][]string{s.Subnet.Name: {prefix: volumes}},
+		BlockStorageVolumes:         s.BlockStorageVolumes,
+		ManageReservedIPs:           s.ManageReservedIPs,
+		PrimaryVNIAdditionalIPCount: s.PrimaryVirtualNetworkInterfaceAdditionalIPCount,
+		UseStaticBootVolumeName:     s.UseStaticBootVolumeName,
+		EnableFloatingIP:            s.EnableFloatingIP,
+		SecondaryFloatingIPs:        s.SecondaryFloatingIPs,
+		LoadBalancers:               s.LoadBalancers,
+		CreateSecurityGroup:         s.SecurityGroup != nil,
+		SecurityGroup:               s.SecurityGroup,
+	}
+	if s.ExistingSecondarySubnetID != nil {
+		inputs.SecondarySubnets = []Subnet{{Name: "secondary-subnet", ID: *s.ExistingSecondarySubnetID, Zone: s.Subnet.Zone}}
+	}
+	return inputs
+}
+
+// Enumerate returns every name the solution generates, the ones of the module call included (`module.vsi.`), in
+// address order
+func (s *FullyConfigurable) Enumerate() []Name {
+	var names []Name
+	for i := range s.SSHPublicKeys {
+		names = append(names, Name{Type: "ibm_is_ssh_key", Address: fmt.Sprintf("ibm_is_ssh_key.ssh_key[%q]", fmt.Sprint(i)), Value: fmt.Sprintf("%s%s-ssh-key-%d", s.localPrefix(), s.VSIName, i)})
+	}
+	if s.AutoGenerateSSHKey {
+		var prefix string
+		if s.Prefix != nil {
+			prefix = *s.Prefix
+		}
+		names = append(names, Name{Type: "ibm_is_ssh_key", Address: "ibm_is_ssh_key.auto_generate_ssh_key[0]", Value: prefix + s.VSIName + "-ssh-key"})
+	}
+	module := s.Module().Enumerate()
+	if s.InstallLoggingAgent && s.LoggingAuthMode == "VSITrustedProfile" && s.LoggingTrustedProfileID == nil {
+		names = append(names, Name{Type: "ibm_iam_trusted_profile", Address: "module.trusted_profile[0].ibm_iam_trusted_profile.profile", Value: s.localPrefix() + "-vsi-logging-trusted-profile"})
+		for _, name := range module {
+			if name.Type == "ibm_is_instance" {
+				names = append(names, Name{Type: "ibm_iam_trusted_profile_link", Address: `module.trusted_profile[0].ibm_iam_trusted_profile_link.link["trusted-profile-link"]`, Value: name.Value + "-link"})
+			}
+		}
+	}
+	for _, name := range module {
+		name.Address = "module.vsi." + name.Address
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool { return names[i].Address < names[j].Address })
+	return names
+}
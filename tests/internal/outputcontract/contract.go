@@ -0,0 +1,193 @@
+// Package outputcontract pins the shape of the `list` and `fip_list` outputs of the module, which downstream stacks
+// consume (`list[*].ipv4_address`, `floating_ip`, `secondary_network_interface_detail`, ...), with a versioned JSON
+// Schema (tests/contracts/outputs.schema.json).
+//
+// The contract is checked three ways: the output values of the state fixtures validate against the schema, the object
+// keys built in outputs.tf are exactly the properties of the schema, and a schema that differs from the previous
+// release has a higher version.
+package outputcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+)
+
+// SchemaPath is the path of the output contract, relative to the root of the module
+const SchemaPath = "tests/contracts/outputs.schema.json"
+
+// StatesDir is the directory of the state fixtures, relative to the root of the module
+const StatesDir = "tests/fixtures/states"
+
+// Outputs are the module outputs covered by the contract
+var Outputs = []string{"list", "fip_list"}
+
+// ModuleOutputs returns the value of a root output of a state that holds a whole module call
+// (`output "slz_vsi" { value = module.slz_vsi }` in the examples)
+func ModuleOutputs(state *tfjson.State, output string) (map[string]interface{}, error) {
+	if state == nil || state.Values == nil || state.Values.Outputs[output] == nil {
+		return nil, fmt.Errorf("state has no output %s", output)
+	}
+	value, ok := state.Values.Outputs[output].Value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("output %s is not a module object", output)
+	}
+	return value, nil
+}
+
+// ElementProperties returns the property names of the elements of an output, as declared in the schema
+func ElementProperties(schema *Schema, output string) ([]string, error) {
+	property, ok := schema.Properties[output]
+	if !ok {
+		return nil, fmt.Errorf("schema does not declare output %s", output)
+	}
+	property, err := property.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	if property.Items == nil {
+		return nil, fmt.Errorf("schema of output %s has no items", output)
+	}
+	items, err := property.Items.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(items.Properties))
+	for name := range items.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// OutputElementKeys returns the keys of the object built for each element of a `[for ... : { ... }]` output of
+// the module in moduleDir
+func OutputElementKeys(moduleDir string, output string) ([]string, error) {
+	files, err := tfconfig.ParseDir(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		for _, block := range file.BlocksOfType("output") {
+			if len(block.Labels) != 1 || block.Labels[0] != output {
+				continue
+			}
+			attr, ok := block.Body.Attributes["value"]
+			if !ok {
+				return nil, fmt.Errorf("output %s has no value", output)
+			}
+			forExpr, ok := attr.Expr.(*hclsyntax.ForExpr)
+			if !ok {
+				return nil, fmt.Errorf("output %s is not a for expression", output)
+			}
+			object, ok := forExpr.ValExpr.(*hclsyntax.ObjectConsExpr)
+			if !ok {
+				return nil, fmt.Errorf("output %s does not build an object for each element", output)
+			}
+			var keys []string
+			for _, item := range object.Items {
+				key := hcl.ExprAsKeyword(item.KeyExpr)
+				if key == "" {
+					return nil, fmt.Errorf("output %s has a computed key at %s", output, item.KeyExpr.Range().String())
+				}
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			return keys, nil
+		}
+	}
+	return nil, fmt.Errorf("output %s not found in %s", output, moduleDir)
+}
+
+// CheckKeys compares the keys built in outputs.tf with the properties of the schema for every output of the contract
+func CheckKeys(schema *Schema, moduleDir string) []string {
+	var problems []string
+	for _, output := range Outputs {
+		keys, err := OutputElementKeys(moduleDir, output)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		properties, err := ElementProperties(schema, output)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		added, removed := difference(keys, properties), difference(properties, keys)
+		if len(added) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: outputs.tf sets %s, which the schema does not declare", output, strings.Join(added, ", ")))
+		}
+		if len(removed) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: the schema declares %s, which outputs.tf no longer sets", output, strings.Join(removed, ", ")))
+		}
+	}
+	return problems
+}
+
+// difference returns the elements of a that are not in b
+func difference(a []string, b []string) []string {
+	in := map[string]bool{}
+	for _, s := range b {
+		in[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !in[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// CheckVersionBump returns an error if the schema changed since the previous revision without a higher version
+func CheckVersionBump(previous []byte, current []byte) error {
+	var previousDoc, currentDoc interface{}
+	if err := json.Unmarshal(previous, &previousDoc); err != nil {
+		return fmt.Errorf("error parsing the previous schema: %w", err)
+	}
+	if err := json.Unmarshal(current, &currentDoc); err != nil {
+		return fmt.Errorf("error parsing the schema: %w", err)
+	}
+	if reflect.DeepEqual(previousDoc, currentDoc) {
+		return nil
+	}
+	previousSchema, err := ParseSchema(previous)
+	if err != nil {
+		return fmt.Errorf("error parsing the previous schema: %w", err)
+	}
+	currentSchema, err := ParseSchema(current)
+	if err != nil {
+		return fmt.Errorf("error parsing the schema: %w", err)
+	}
+	previousVersion, err := version.NewVersion(previousSchema.Version)
+	if err != nil {
+		return fmt.Errorf("invalid version %q in the previous schema: %w", previousSchema.Version, err)
+	}
+	currentVersion, err := version.NewVersion(currentSchema.Version)
+	if err != nil {
+		return fmt.Errorf("invalid version %q in the schema: %w", currentSchema.Version, err)
+	}
+	if !currentVersion.GreaterThan(previousVersion) {
+		return fmt.Errorf("the output schema changed but its version did not: bump it above %s", previousVersion)
+	}
+	return nil
+}
+
+// StateFixtures returns the paths of the state fixtures under the module root
+func StateFixtures(root string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(root, StatesDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,82 @@
+package outputcontract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func loadContract(t *testing.T) (string, *Schema) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	schema, err := LoadSchema(filepath.Join(root, SchemaPath))
+	require.NoError(t, err)
+	return root, schema
+}
+
+// TestOutputContract validates the list and fip_list outputs recorded in the state fixtures against the schema
+func TestOutputContract(t *testing.T) {
+	root, schema := loadContract(t)
+	paths, err := StateFixtures(root)
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			state, err := tfplan.LoadState(path)
+			require.NoError(t, err)
+			outputs, err := ModuleOutputs(state, "slz_vsi")
+			require.NoError(t, err)
+			assert.Empty(t, schema.Validate(outputs))
+			assert.NotEmpty(t, tfplan.List(outputs, "list"))
+		})
+	}
+}
+
+// TestOutputKeysMatchSchema fails when outputs.tf adds or removes a key of list or fip_list without updating the schema
+func TestOutputKeysMatchSchema(t *testing.T) {
+	root, schema := loadContract(t)
+	assert.Empty(t, CheckKeys(schema, root))
+}
+
+// TestSchemaVersionBump fails when the schema changed since the previous release tag (or PREVIOUS_RELEASE_REF)
+// without a version bump
+func TestSchemaVersionBump(t *testing.T) {
+	root, _ := loadContract(t)
+	ref := fixtures.PreviousReleaseRef(root)
+	if ref == "" {
+		t.Skip("no previous release tag, set PREVIOUS_RELEASE_REF to compare against a specific ref")
+	}
+	previous, found, err := fixtures.ReadAtRef(root, ref, SchemaPath)
+	require.NoError(t, err)
+	if !found {
+		t.Skipf("no output schema at %s", ref)
+	}
+	current, err := os.ReadFile(filepath.Join(root, SchemaPath))
+	require.NoError(t, err)
+	assert.NoError(t, CheckVersionBump(previous, current))
+}
+
+func TestCheckVersionBump(t *testing.T) {
+	v1 := []byte(`{"version": "1.0.0", "type": "object"}`)
+	assert.NoError(t, CheckVersionBump(v1, []byte(`{"type": "object", "version": "1.0.0"}`)))
+	assert.Error(t, CheckVersionBump(v1, []byte(`{"version": "1.0.0", "type": "array"}`)))
+	assert.NoError(t, CheckVersionBump(v1, []byte(`{"version": "2.0.0", "type": "array"}`)))
+}
+
+func TestOutputElementKeys(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	keys, err := OutputElementKeys(root, "fip_list")
+	require.NoError(t, err)
+	assert.Contains(t, keys, "floating_ip")
+	assert.NotContains(t, keys, "crn")
+
+	_, err = OutputElementKeys(root, "ids")
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package outputcontract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema (draft 2020-12) used by the output contracts. Unknown keywords are rejected
+// when loading, so a schema never silently relies on a keyword this validator ignores.
+type Schema struct {
+	SchemaURI   string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Version of the contract, to bump with every change of the schema
+	Version string `json:"version,omitempty"`
+
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+
+	// root is the document the schema belongs to, to resolve $ref
+	root *Schema
+}
+
+// Types is the `type` keyword, a single type name or a list of them
+type Types []string
+
+// UnmarshalJSON accepts both `"string"` and `["string", "null"]`
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// LoadSchema reads a JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema %s: %w", path, err)
+	}
+	schema, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ParseSchema parses JSON Schema content
+func ParseSchema(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	schema := &Schema{}
+	if err := decoder.Decode(schema); err != nil {
+		return nil, err
+	}
+	schema.setRoot(schema)
+	return schema, nil
+}
+
+func (s *Schema) setRoot(root *Schema) {
+	if s == nil {
+		return
+	}
+	s.root = root
+	for _, child := range s.Properties {
+		child.setRoot(root)
+	}
+	for _, child := range s.Defs {
+		child.setRoot(root)
+	}
+	for _, child := range s.AnyOf {
+		child.setRoot(root)
+	}
+	s.Items.setRoot(root)
+}
+
+// Resolve follows $ref, which must point into the same document (`#/$defs/...`, `#/properties/...`)
+func (s *Schema) Resolve() (*Schema, error) {
+	current := s
+	for seen := 0; current.Ref != ""; seen++ {
+		if seen > 32 {
+			return nil, fmt.Errorf("$ref loop at %s", s.Ref)
+		}
+		if !strings.HasPrefix(current.Ref, "#/") {
+			return nil, fmt.Errorf("unsupported $ref %s, only local references are supported", current.Ref)
+		}
+		target := current.root
+		steps := strings.Split(strings.TrimPrefix(current.Ref, "#/"), "/")
+		for i := 0; i < len(steps); i++ {
+			var next *Schema
+			switch {
+			case steps[i] == "$defs" && i+1 < len(steps):
+				next = target.Defs[steps[i+1]]
+				i++
+			case steps[i] == "properties" && i+1 < len(steps):
+				next = target.Properties[steps[i+1]]
+				i++
+			case steps[i] == "items":
+				next = target.Items
+			}
+			if next == nil {
+				return nil, fmt.Errorf("unresolved $ref %s", current.Ref)
+			}
+			target = next
+		}
+		current = target
+	}
+	return current, nil
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json) against the schema and returns every
+// violation, prefixed with the path of the offending value
+func (s *Schema) Validate(value interface{}) []string {
+	var problems []string
+	s.validate(value, "", &problems)
+	return problems
+}
+
+func (s *Schema) validate(value interface{}, path string, problems *[]string) {
+	report := func(format string, args ...interface{}) {
+		location := path
+		if location == "" {
+			location = "(root)"
+		}
+		*problems = append(*problems, location+": "+fmt.Sprintf(format, args...))
+	}
+
+	schema, err := s.Resolve()
+	if err != nil {
+		report("%s", err)
+		return
+	}
+
+	if len(schema.AnyOf) > 0 {
+		matched := false
+		for _, option := range schema.AnyOf {
+			if len(option.Validate(value)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("does not match any of the allowed schemas")
+			return
+		}
+	}
+
+	if len(schema.Type) > 0 {
+		actual := jsonType(value)
+		allowed := false
+		for _, t := range schema.Type {
+			if t == actual || (t == "number" && actual == "integer") {
+				allowed = true
+			}
+		}
+		if !allowed {
+			report("expected %s, got %s", strings.Join(schema.Type, " or "), actual)
+			return
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if schema.Pattern != "" {
+			re, err := regexp.Compile(schema.Pattern)
+			if err != nil {
+				report("invalid pattern %s: %s", schema.Pattern, err)
+			} else if !re.MatchString(v) {
+				report("%q does not match %s", v, schema.Pattern)
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				schema.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				report("missing required property %s", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := joinPath(path, name)
+			if property, ok := schema.Properties[name]; ok {
+				property.validate(v[name], child, problems)
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				report("unexpected property %s", name)
+			}
+		}
+	}
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
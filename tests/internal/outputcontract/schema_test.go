@@ -0,0 +1,66 @@
+package outputcontract
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["list"],
+  "properties": {
+    "list": { "type": "array", "items": { "$ref": "#/$defs/element" } }
+  },
+  "$defs": {
+    "element": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["zone", "port_speed"],
+      "properties": {
+        "zone": { "type": "string", "pattern": "^[a-z]+-[a-z]+-[1-3]$" },
+        "port_speed": { "type": "integer" },
+        "floating_ip": { "type": ["string", "null"] },
+        "detail": { "anyOf": [{ "type": "null" }, { "$ref": "#/$defs/element/properties/zone" }] }
+      }
+    }
+  }
+}`
+
+func decode(t *testing.T, data string) interface{} {
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+func TestValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	valid := decode(t, `{"list": [{"zone": "us-south-1", "port_speed": 1000, "floating_ip": null, "detail": "eu-de-2"}], "other": 1}`)
+	assert.Empty(t, schema.Validate(valid))
+
+	invalid := decode(t, `{"list": [{"zone": "us-south-4", "port_speed": 1.5, "floating_ip": 1, "detail": 2, "extra": true}, {}]}`)
+	assert.Equal(t, []string{
+		"list[0].detail: does not match any of the allowed schemas",
+		"list[0]: unexpected property extra",
+		"list[0].floating_ip: expected string or null, got integer",
+		"list[0].port_speed: expected integer, got number",
+		`list[0].zone: "us-south-4" does not match ^[a-z]+-[a-z]+-[1-3]$`,
+		"list[1]: missing required property zone",
+		"list[1]: missing required property port_speed",
+	}, schema.Validate(invalid))
+
+	assert.Equal(t, []string{"(root): missing required property list"}, schema.Validate(decode(t, `{}`)))
+}
+
+func TestParseSchemaRejectsUnsupportedKeywords(t *testing.T) {
+	_, err := ParseSchema([]byte(`{"type": "string", "minLength": 1}`))
+	assert.Error(t, err)
+
+	schema, err := ParseSchema([]byte(`{"$ref": "#/$defs/missing"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"(root): unresolved $ref #/$defs/missing"}, schema.Validate("x"))
+}
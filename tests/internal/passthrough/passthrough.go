@@ -0,0 +1,193 @@
+// Package passthrough checks that the wrappers of the module (the FS Cloud profile and the solutions) forward
+// every input of the root module, or document why they do not. A root input that is neither set on the module
+// call nor excluded with a reason is a feature that users of the wrapper silently lose.
+package passthrough
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// metaArguments are the arguments of a module block that are not inputs of the module
+var metaArguments = map[string]bool{
+	"source":     true,
+	"version":    true,
+	"count":      true,
+	"for_each":   true,
+	"providers":  true,
+	"depends_on": true,
+}
+
+// Wrappers are the directories, relative to the module root, that call the root module
+var Wrappers = []string{"modules/fscloud", "solutions/fully-configurable", "solutions/quickstart"}
+
+// Exclusions is the content of the exclusion file: the root inputs each wrapper deliberately does not set
+type Exclusions struct {
+	Wrappers map[string][]Exclusion `yaml:"wrappers"`
+}
+
+// Exclusion is a root input a wrapper does not set, and why. An input that only configures another excluded input
+// (the settings of an agent the wrapper does not install) requires it instead, the reason of that exclusion applies.
+type Exclusion struct {
+	Input    string `yaml:"input"`
+	Reason   string `yaml:"reason"`
+	Requires string `yaml:"requires"`
+}
+
+// Finding is a root input that a wrapper neither sets nor excludes, or an exclusion that no longer applies
+type Finding struct {
+	Wrapper string
+	Input   string
+	Problem string
+}
+
+func (f Finding) String() string {
+	if f.Input == "" {
+		return fmt.Sprintf("%s: %s", f.Wrapper, f.Problem)
+	}
+	return fmt.Sprintf("%s: %s %s", f.Wrapper, f.Input, f.Problem)
+}
+
+// LoadExclusions reads the exclusion YAML file
+func LoadExclusions(path string) (*Exclusions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading exclusions %s: %w", path, err)
+	}
+	exclusions := &Exclusions{}
+	if err := yaml.Unmarshal(data, exclusions); err != nil {
+		return nil, fmt.Errorf("error parsing exclusions %s: %w", path, err)
+	}
+	return exclusions, nil
+}
+
+// WiredInputs returns the arguments set on the calls of the root module (moduleDir) in wrapperDir.
+// Every call counts: an input set on any of them is wired.
+func WiredInputs(moduleDir string, wrapperDir string) ([]string, error) {
+	target, err := filepath.Abs(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := tfconfig.ParseDir(wrapperDir)
+	if err != nil {
+		return nil, err
+	}
+	calls := 0
+	wired := map[string]bool{}
+	for _, file := range files {
+		for _, block := range file.BlocksOfType("module") {
+			source, ok := block.Body.Attributes["source"]
+			if !ok {
+				continue
+			}
+			template, ok := source.Expr.(*hclsyntax.TemplateExpr)
+			if !ok || !template.IsStringLiteral() {
+				continue
+			}
+			value, _ := template.Value(nil)
+			path := value.AsString()
+			if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+				continue
+			}
+			callDir, err := filepath.Abs(filepath.Join(wrapperDir, path))
+			if err != nil || callDir != target {
+				continue
+			}
+			calls++
+			for name := range block.Body.Attributes {
+				if !metaArguments[name] {
+					wired[name] = true
+				}
+			}
+		}
+	}
+	if calls == 0 {
+		return nil, fmt.Errorf("%s does not call the module in %s", wrapperDir, moduleDir)
+	}
+	inputs := make([]string, 0, len(wired))
+	for name := range wired {
+		inputs = append(inputs, name)
+	}
+	sort.Strings(inputs)
+	return inputs, nil
+}
+
+// Check reports, for every wrapper, the root inputs that are neither wired nor excluded, and the exclusions that
+// are stale (the input is wired, is not a root input anymore, has no reason or requires an input that is not excluded)
+func Check(moduleDir string, wrappers []string, exclusions *Exclusions) ([]Finding, error) {
+	files, err := tfconfig.ParseDir(moduleDir)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := tfconfig.Variables(files)
+	if err != nil {
+		return nil, err
+	}
+	rootInputs := map[string]bool{}
+	for _, variable := range variables {
+		rootInputs[variable.Name] = true
+	}
+	if exclusions == nil {
+		exclusions = &Exclusions{}
+	}
+
+	var findings []Finding
+	excludedWrappers := make([]string, 0, len(exclusions.Wrappers))
+	for wrapper := range exclusions.Wrappers {
+		excludedWrappers = append(excludedWrappers, wrapper)
+	}
+	sort.Strings(excludedWrappers)
+	for _, wrapper := range excludedWrappers {
+		if !contains(wrappers, wrapper) {
+			findings = append(findings, Finding{Wrapper: wrapper, Problem: "has exclusions but is not a checked wrapper"})
+		}
+	}
+	for _, wrapper := range wrappers {
+		wiredList, err := WiredInputs(moduleDir, filepath.Join(moduleDir, wrapper))
+		if err != nil {
+			return nil, err
+		}
+		wired := map[string]bool{}
+		for _, input := range wiredList {
+			wired[input] = true
+		}
+		excluded := map[string]bool{}
+		for _, exclusion := range exclusions.Wrappers[wrapper] {
+			excluded[exclusion.Input] = true
+		}
+		for _, exclusion := range exclusions.Wrappers[wrapper] {
+			switch {
+			case !rootInputs[exclusion.Input]:
+				findings = append(findings, Finding{Wrapper: wrapper, Input: exclusion.Input, Problem: "is excluded but is not an input of the module"})
+			case wired[exclusion.Input]:
+				findings = append(findings, Finding{Wrapper: wrapper, Input: exclusion.Input, Problem: "is excluded but is set on the module call, remove the exclusion"})
+			case exclusion.Requires != "" && !excluded[exclusion.Requires]:
+				findings = append(findings, Finding{Wrapper: wrapper, Input: exclusion.Input, Problem: fmt.Sprintf("requires %s, which is not excluded", exclusion.Requires)})
+			case exclusion.Requires == "" && strings.TrimSpace(exclusion.Reason) == "":
+				findings = append(findings, Finding{Wrapper: wrapper, Input: exclusion.Input, Problem: "is excluded without a reason"})
+			}
+		}
+		for _, variable := range variables {
+			if !wired[variable.Name] && !excluded[variable.Name] {
+				findings = append(findings, Finding{Wrapper: wrapper, Input: variable.Name, Problem: "is not passed to the module and has no exclusion"})
+			}
+		}
+	}
+	return findings, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package passthrough
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+func findingStrings(findings []Finding) []string {
+	var list []string
+	for _, finding := range findings {
+		list = append(list, finding.String())
+	}
+	return list
+}
+
+func TestWiredInputs(t *testing.T) {
+	inputs, err := WiredInputs("testdata/module", "testdata/module/wrapper")
+	require.NoError(t, err)
+	// the registry module call and the meta-arguments are ignored
+	assert.Equal(t, []string{"image_id", "prefix"}, inputs)
+
+	_, err = WiredInputs("testdata/module/wrapper", "testdata/module/wrapper")
+	assert.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	findings, err := Check("testdata/module", []string{"wrapper"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"wrapper: boot_volume_iops is not passed to the module and has no exclusion",
+		"wrapper: placement_group_id is not passed to the module and has no exclusion",
+		"wrapper: secondary_floating_ips is not passed to the module and has no exclusion",
+		"wrapper: secondary_subnets is not passed to the module and has no exclusion",
+	}, findingStrings(findings))
+
+	exclusions, err := LoadExclusions("testdata/exclusions.yaml")
+	require.NoError(t, err)
+	findings, err = Check("testdata/module", []string{"wrapper"}, exclusions)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"other: has exclusions but is not a checked wrapper",
+		"wrapper: boot_volume_iops requires boot_volume_profile, which is not excluded",
+		"wrapper: image_id is excluded but is set on the module call, remove the exclusion",
+		"wrapper: removed_input is excluded but is not an input of the module",
+		"wrapper: placement_group_id is not passed to the module and has no exclusion",
+	}, findingStrings(findings))
+}
+
+// TestWrappersPassThroughInputs fails when modules/fscloud or a solution does not pass a root input to the module
+// and passthrough-exclusions.yaml does not say why
+func TestWrappersPassThroughInputs(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	exclusions, err := LoadExclusions(filepath.Join(root, "tests", "passthrough-exclusions.yaml"))
+	require.NoError(t, err)
+
+	findings, err := Check(root, Wrappers, exclusions)
+	require.NoError(t, err)
+	assert.Empty(t, findingStrings(findings))
+}
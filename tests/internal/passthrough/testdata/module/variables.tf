@@ -0,0 +1,27 @@
+variable "prefix" {
+  type = string
+}
+
+variable "image_id" {
+  type = string
+}
+
+variable "placement_group_id" {
+  type    = string
+  default = null
+}
+
+variable "secondary_subnets" {
+  type    = list(string)
+  default = []
+}
+
+variable "secondary_floating_ips" {
+  type    = list(string)
+  default = []
+}
+
+variable "boot_volume_iops" {
+  type    = number
+  default = null
+}
@@ -0,0 +1,10 @@
+module "vpc" {
+  source = "terraform-ibm-modules/landing-zone-vpc/ibm"
+  prefix = var.prefix
+}
+
+module "vsi" {
+  source   = "../"
+  prefix   = var.prefix
+  image_id = var.image_id
+}
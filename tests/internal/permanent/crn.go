@@ -0,0 +1,45 @@
+package permanent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CRN is a parsed Cloud Resource Name:
+// crn:v1:<cname>:<ctype>:<service-name>:<location>:<scope>:<service-instance>:<resource-type>:<resource>
+type CRN struct {
+	CName           string
+	CType           string
+	ServiceName     string
+	Location        string
+	Scope           string
+	ServiceInstance string
+	ResourceType    string
+	Resource        string
+}
+
+// ParseCRN parses a CRN, the resource part may itself contain colons
+func ParseCRN(value string) (CRN, error) {
+	parts := strings.SplitN(value, ":", 10)
+	if len(parts) != 10 || parts[0] != "crn" || parts[1] != "v1" {
+		return CRN{}, fmt.Errorf("%q is not a CRN (crn:v1:<cname>:<ctype>:<service-name>:<location>:<scope>:<service-instance>:<resource-type>:<resource>)", value)
+	}
+	crn := CRN{
+		CName:           parts[2],
+		CType:           parts[3],
+		ServiceName:     parts[4],
+		Location:        parts[5],
+		Scope:           parts[6],
+		ServiceInstance: parts[7],
+		ResourceType:    parts[8],
+		Resource:        parts[9],
+	}
+	if crn.CName == "" || crn.CType == "" || crn.ServiceName == "" {
+		return CRN{}, fmt.Errorf("%q is not a CRN: cname, ctype and service name are required", value)
+	}
+	return crn, nil
+}
+
+func (c CRN) String() string {
+	return strings.Join([]string{"crn", "v1", c.CName, c.CType, c.ServiceName, c.Location, c.Scope, c.ServiceInstance, c.ResourceType, c.Resource}, ":")
+}
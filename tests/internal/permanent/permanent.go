@@ -0,0 +1,188 @@
+// Package permanent loads the permanent test resources (common-dev-assets/common-go-assets/common-permanent-resources.yaml)
+// the tests of this module use into a typed struct, and validates them before any test runs: a missing key, a
+// malformed CRN or a resource in the wrong region fails TestMain instead of a test 40 minutes into a run.
+//
+// Each field declares its checks in its `permanent` tag:
+//
+//	crn=<service>     the value is a CRN of the service
+//	region=<region>   the location of the CRN is the region (or a zone of it)
+//	type=<type>       the resource type of the CRN (`-` for a service instance)
+//	id=vpc            the value is a VPC resource ID (r<3 digits>-<uuid>)
+//	tags=access       every element is a `key:value` access tag
+package permanent
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resources are the permanent resources used by the tests of this module
+type Resources struct {
+	AccessTags                []string `yaml:"accessTags" permanent:"tags=access"`
+	HPCSSouthCRN              string   `yaml:"hpcs_south_crn" permanent:"crn=hs-crypto,region=us-south,type=-"`
+	HPCSSouthRootKeyCRN       string   `yaml:"hpcs_south_root_key_crn" permanent:"crn=hs-crypto,region=us-south,type=key"`
+	SecretsManagerCRN         string   `yaml:"secretsManagerCRN" permanent:"crn=secrets-manager,type=-"`
+	PrivateOnlySecretsMgrCRN  string   `yaml:"privateOnlySecMgrCRN" permanent:"crn=secrets-manager,type=-"`
+	SnapshotGroupAuSydGroupID string   `yaml:"snapshot_group_au_syd_group_id" permanent:"id=vpc"`
+	SnapshotGroupAuSydBootCRN string   `yaml:"snapshot_group_au_syd_boot_crn" permanent:"crn=is,region=au-syd,type=snapshot"`
+	SnapshotGroupAuSydVol1CRN string   `yaml:"snapshot_group_au_syd_vol1_crn" permanent:"crn=is,region=au-syd,type=snapshot"`
+	SnapshotGroupAuSydVol2CRN string   `yaml:"snapshot_group_au_syd_vol2_crn" permanent:"crn=is,region=au-syd,type=snapshot"`
+}
+
+// Reference is a permanent resource referenced by the tests
+type Reference struct {
+	Key   string
+	Value string
+	// CRN is nil when the value is not a CRN
+	CRN *CRN
+}
+
+var vpcID = regexp.MustCompile(`^r\d{3}-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+var zoneNumber = regexp.MustCompile(`^\d+$`)
+var accessTag = regexp.MustCompile(`^[A-Za-z0-9_.\- ]+:[A-Za-z0-9_.\- ]+$`)
+
+// Load reads and validates the permanent resources YAML file. The file holds the resources of every module, only the
+// keys of Resources are read.
+func Load(path string) (*Resources, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading permanent resources %s: %w", path, err)
+	}
+	resources, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("permanent resources %s: %w", path, err)
+	}
+	return resources, nil
+}
+
+// Parse parses and validates the content of the permanent resources YAML file
+func Parse(data []byte) (*Resources, error) {
+	keys := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %w", err)
+	}
+	resources := &Resources{}
+	if err := yaml.Unmarshal(data, resources); err != nil {
+		return nil, fmt.Errorf("error parsing YAML: %w", err)
+	}
+
+	var missing []string
+	for _, field := range fields() {
+		if value, ok := keys[field.key]; !ok || value == nil {
+			missing = append(missing, field.key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing keys %s", strings.Join(missing, ", "))
+	}
+	if problems := resources.Validate(); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid values:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return resources, nil
+}
+
+type field struct {
+	index  int
+	key    string
+	checks map[string]string
+}
+
+// fields returns the YAML key and the checks of every field of Resources
+func fields() []field {
+	t := reflect.TypeOf(Resources{})
+	list := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		checks := map[string]string{}
+		for _, check := range strings.Split(t.Field(i).Tag.Get("permanent"), ",") {
+			if name, value, ok := strings.Cut(check, "="); ok {
+				checks[name] = value
+			}
+		}
+		list = append(list, field{index: i, key: t.Field(i).Tag.Get("yaml"), checks: checks})
+	}
+	return list
+}
+
+// Validate checks every value against the checks of its field
+func (r *Resources) Validate() []string {
+	var problems []string
+	value := reflect.ValueOf(r).Elem()
+	for _, f := range fields() {
+		fieldValue := value.Field(f.index)
+		if tags, ok := fieldValue.Interface().([]string); ok {
+			if len(tags) == 0 {
+				problems = append(problems, fmt.Sprintf("%s: is empty", f.key))
+			}
+			for _, tag := range tags {
+				if f.checks["tags"] == "access" && !accessTag.MatchString(tag) {
+					problems = append(problems, fmt.Sprintf("%s: %q is not a key:value access tag", f.key, tag))
+				}
+			}
+			continue
+		}
+		s := fieldValue.String()
+		if s == "" {
+			problems = append(problems, fmt.Sprintf("%s: is empty", f.key))
+			continue
+		}
+		if f.checks["id"] == "vpc" && !vpcID.MatchString(s) {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a VPC resource ID", f.key, s))
+		}
+		service, isCRN := f.checks["crn"]
+		if !isCRN {
+			continue
+		}
+		crn, err := ParseCRN(s)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", f.key, err))
+			continue
+		}
+		if crn.ServiceName != service {
+			problems = append(problems, fmt.Sprintf("%s: CRN of service %s, expected %s", f.key, crn.ServiceName, service))
+		}
+		if region, ok := f.checks["region"]; ok && !inRegion(crn.Location, region) {
+			problems = append(problems, fmt.Sprintf("%s: CRN location %s, expected %s", f.key, crn.Location, region))
+		}
+		if resourceType, ok := f.checks["type"]; ok {
+			if resourceType == "-" {
+				resourceType = ""
+			}
+			if crn.ResourceType != resourceType {
+				problems = append(problems, fmt.Sprintf("%s: CRN resource type %q, expected %q", f.key, crn.ResourceType, resourceType))
+			}
+		}
+	}
+	return problems
+}
+
+// inRegion returns true if the location is the region or one of its zones (us-south-1)
+func inRegion(location string, region string) bool {
+	return location == region || strings.HasPrefix(location, region+"-") && zoneNumber.MatchString(strings.TrimPrefix(location, region+"-"))
+}
+
+// References returns the resources the tests reference, for the preflight. Access tags are not resources.
+func (r *Resources) References() []Reference {
+	var references []Reference
+	value := reflect.ValueOf(r).Elem()
+	for _, f := range fields() {
+		s, ok := value.Field(f.index).Interface().(string)
+		if !ok {
+			continue
+		}
+		reference := Reference{Key: f.key, Value: s}
+		if _, isCRN := f.checks["crn"]; isCRN {
+			if crn, err := ParseCRN(s); err == nil {
+				reference.CRN = &crn
+			}
+		}
+		references = append(references, reference)
+	}
+	return references
+}
@@ -0,0 +1,150 @@
+package permanent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/httpreplay"
+)
+
+func sample(t *testing.T) []byte {
+	data, err := os.ReadFile("testdata/common-permanent-resources.yaml")
+	require.NoError(t, err)
+	return data
+}
+
+func TestLoad(t *testing.T) {
+	resources, err := Load("testdata/common-permanent-resources.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"geretain-dev:permanent", "env:test"}, resources.AccessTags)
+	assert.Equal(t, "r026-c6a2c6b9-fdbc-4c5f-9e18-4a8e17a1c9e2", resources.SnapshotGroupAuSydGroupID)
+	assert.True(t, strings.HasSuffix(resources.HPCSSouthRootKeyCRN, ":key:76170fae-4e0c-48c3-8ebe-326059ebb533"))
+
+	_, err = Load("testdata/missing.yaml")
+	assert.Error(t, err)
+}
+
+func TestParseMissingKeys(t *testing.T) {
+	data := strings.ReplaceAll(string(sample(t)), "privateOnlySecMgrCRN:", "privateOnlySecMgrCrn:")
+	data = strings.ReplaceAll(data, "hpcs_south_crn:", "hpcs_south_crn_old:")
+	_, err := Parse([]byte(data))
+	assert.EqualError(t, err, "missing keys hpcs_south_crn, privateOnlySecMgrCRN")
+}
+
+func TestParseInvalidValues(t *testing.T) {
+	data := string(sample(t))
+	replace := func(old string, new string) {
+		require.Contains(t, data, old)
+		data = strings.Replace(data, old, new, 1)
+	}
+	replace(`"env:test"`, `"test"`)
+	replace("hs-crypto:us-south:a/abac0df06b644a9cabc6e44f55b3880e:e6dce284-e80f-46e1-a3c1-830f7adff7a9:key", "hs-crypto:eu-de:a/abac0df06b644a9cabc6e44f55b3880e:e6dce284-e80f-46e1-a3c1-830f7adff7a9:key")
+	replace(`secretsManagerCRN: "crn:v1:bluemix:public:secrets-manager`, `secretsManagerCRN: "crn:v1:bluemix:public:kms`)
+	replace(`"r026-c6a2c6b9-fdbc-4c5f-9e18-4a8e17a1c9e2"`, `"c6a2c6b9"`)
+	replace(`snapshot_group_au_syd_vol2_crn: "crn:v1:bluemix:public:is:au-syd:a/abac0df06b644a9cabc6e44f55b3880e::snapshot:`, `snapshot_group_au_syd_vol2_crn: "crn:v1:bluemix:public:is:au-syd:`)
+
+	_, err := Parse([]byte(data))
+	require.Error(t, err)
+	assert.Equal(t, `invalid values:
+  accessTags: "test" is not a key:value access tag
+  hpcs_south_root_key_crn: CRN location eu-de, expected us-south
+  secretsManagerCRN: CRN of service kms, expected secrets-manager
+  snapshot_group_au_syd_group_id: "c6a2c6b9" is not a VPC resource ID
+  snapshot_group_au_syd_vol2_crn: "crn:v1:bluemix:public:is:au-syd:r026-3f5d2c6e-6e3a-4da0-9d7e-2c8d3a4f5b62" is not a CRN (crn:v1:<cname>:<ctype>:<service-name>:<location>:<scope>:<service-instance>:<resource-type>:<resource>)`, err.Error())
+}
+
+func TestParseCRN(t *testing.T) {
+	crn, err := ParseCRN("crn:v1:bluemix:public:is:us-south-1:a/abc::instance:0717_1234")
+	require.NoError(t, err)
+	assert.Equal(t, CRN{CName: "bluemix", CType: "public", ServiceName: "is", Location: "us-south-1", Scope: "a/abc", ResourceType: "instance", Resource: "0717_1234"}, crn)
+	assert.Equal(t, "crn:v1:bluemix:public:is:us-south-1:a/abc::instance:0717_1234", crn.String())
+	assert.True(t, inRegion(crn.Location, "us-south"))
+	assert.False(t, inRegion(crn.Location, "us"))
+
+	_, err = ParseCRN("crn:v1:::is:us-south:a/abc:::")
+	assert.Error(t, err)
+}
+
+// fakeClient knows a fixed set of CRNs
+type fakeClient struct {
+	existing map[string]bool
+	err      error
+}
+
+func (c fakeClient) Exists(ctx context.Context, crn string) (bool, error) {
+	return c.existing[crn], c.err
+}
+
+func TestPreflight(t *testing.T) {
+	resources, err := Parse(sample(t))
+	require.NoError(t, err)
+
+	existing := map[string]bool{}
+	for _, reference := range resources.References() {
+		existing[reference.Value] = true
+	}
+	assert.Empty(t, Preflight(context.Background(), fakeClient{existing: existing}, resources))
+
+	delete(existing, resources.SnapshotGroupAuSydVol1CRN)
+	assert.Equal(t, []string{
+		"snapshot_group_au_syd_vol1_crn: " + resources.SnapshotGroupAuSydVol1CRN + " does not exist (rotated or deleted?)",
+	}, Preflight(context.Background(), fakeClient{existing: existing}, resources))
+
+	problems := Preflight(context.Background(), fakeClient{err: errors.New("unauthorized")}, resources)
+	assert.Len(t, problems, 7)
+	assert.Contains(t, problems[0], "error looking up")
+}
+
+func TestSearchClient(t *testing.T) {
+	const known = "crn:v1:bluemix:public:secrets-manager:us-south:a/abc:79c6d411::"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/resources/search", r.URL.Path)
+		var body struct {
+			Query string `json:"query"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		if body.Query == `crn:"`+known+`"` {
+			_, _ = w.Write([]byte(`{"items": [{"crn": "` + known + `"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"items": []}`))
+	}))
+	defer server.Close()
+
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL, Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	client := &SearchClient{service: service}
+
+	exists, err := client.Exists(context.Background(), known)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = client.Exists(context.Background(), known+"other")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestSearchClientReplay(t *testing.T) {
+	cassette, err := httpreplay.New(httpreplay.Replay, "testdata/search.cassette.json")
+	require.NoError(t, err)
+	client, err := NewSearchClient("not-a-real-key")
+	require.NoError(t, err)
+	cassette.Install(client.Service())
+
+	exists, err := client.Exists(context.Background(), "crn:v1:bluemix:public:secrets-manager:us-south:a/abac0df06b644a9cabc6e44f55b3880e:79c6d411-c18f-4670-b009-b0044a238667::")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = client.Exists(context.Background(), "crn:v1:bluemix:public:secrets-manager:eu-de:a/abac0df06b644a9cabc6e44f55b3880e:0e8d9a38-1d92-4da8-9e29-a21ec0a15e8b::")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, cassette.Unused())
+}
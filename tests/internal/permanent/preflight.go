@@ -0,0 +1,102 @@
+package permanent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// Client looks up a resource by CRN
+type Client interface {
+	Exists(ctx context.Context, crn string) (bool, error)
+}
+
+// Preflight checks that every CRN the tests reference still exists, and returns a problem for each one that does not
+// or can not be looked up. References that are not CRNs (the snapshot consistency group ID) are not checked.
+func Preflight(ctx context.Context, client Client, resources *Resources) []string {
+	var problems []string
+	for _, reference := range resources.References() {
+		if reference.CRN == nil {
+			continue
+		}
+		exists, err := client.Exists(ctx, reference.Value)
+		switch {
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s: error looking up %s: %s", reference.Key, reference.Value, err))
+		case !exists:
+			problems = append(problems, fmt.Sprintf("%s: %s does not exist (rotated or deleted?)", reference.Key, reference.Value))
+		}
+	}
+	return problems
+}
+
+// GlobalSearchURL is the endpoint of the IBM Cloud Global Search API
+const GlobalSearchURL = "https://api.global-search-tagging.cloud.ibm.com"
+
+// SearchClient looks up resources with the Global Search API of the account of the API key
+type SearchClient struct {
+	service *core.BaseService
+}
+
+// NewSearchClient returns a Global Search client authenticated with an API key
+func NewSearchClient(apiKey string) (*SearchClient, error) {
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           GlobalSearchURL,
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SearchClient{service: service}, nil
+}
+
+// SetServiceURL changes the endpoint, for tests or private endpoints
+func (c *SearchClient) SetServiceURL(url string) error {
+	return c.service.SetServiceURL(url)
+}
+
+// Service returns the underlying service, to install a recording or replaying transport
+func (c *SearchClient) Service() *core.BaseService {
+	return c.service
+}
+
+type searchResult struct {
+	Items []struct {
+		CRN string `json:"crn"`
+	} `json:"items"`
+}
+
+// Exists searches the resource by CRN
+func (c *SearchClient) Exists(ctx context.Context, crn string) (bool, error) {
+	builder := core.NewRequestBuilder(core.POST).WithContext(ctx)
+	if _, err := builder.ResolveRequestURL(c.service.GetServiceURL(), "/v3/resources/search", nil); err != nil {
+		return false, err
+	}
+	builder.AddHeader("Accept", "application/json")
+	builder.AddQuery("limit", "1")
+	query := fmt.Sprintf(`crn:"%s"`, strings.ReplaceAll(crn, `"`, `\"`))
+	if _, err := builder.SetBodyContentJSON(map[string]interface{}{"query": query, "fields": []string{"crn"}}); err != nil {
+		return false, err
+	}
+	request, err := builder.Build()
+	if err != nil {
+		return false, err
+	}
+	result := &searchResult{}
+	response, err := c.service.Request(request, &result)
+	if err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, item := range result.Items {
+		if item.CRN == crn {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,95 @@
+package prereq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// VarFile is the file CLI writes the variables to in the prerequisite directory, so the teardown of the catalog
+// pipeline (a separate process) destroys with the same prefix and region
+const VarFile = "prereq.auto.tfvars.json"
+
+// CLI runs the prerequisites with the terraform binary
+type CLI struct {
+	Dir    string
+	Config Config
+	// Binary defaults to terraform from the PATH
+	Binary string
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func (c *CLI) run(ctx context.Context, stdout io.Writer, args ...string) error {
+	binary := c.Binary
+	if binary == "" {
+		binary = "terraform"
+	}
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Dir = c.Dir
+	cmd.Stdout = stdout
+	cmd.Stderr = c.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("terraform %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// writeVars saves the config to VarFile, which terraform loads automatically
+func (c *CLI) writeVars() error {
+	data, err := json.MarshalIndent(c.Config.Vars(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.Dir, VarFile), append(data, '\n'), 0o600)
+}
+
+// Init runs terraform init
+func (c *CLI) Init(ctx context.Context) error {
+	return c.run(ctx, c.Stdout, "init", "-input=false")
+}
+
+// Apply saves the variables and runs terraform apply
+func (c *CLI) Apply(ctx context.Context) error {
+	if err := c.writeVars(); err != nil {
+		return err
+	}
+	return c.run(ctx, c.Stdout, "apply", "-input=false", "-auto-approve")
+}
+
+// Outputs runs terraform output -json
+func (c *CLI) Outputs(ctx context.Context) (map[string]interface{}, error) {
+	var out bytes.Buffer
+	if err := c.run(ctx, &out, "output", "-json"); err != nil {
+		return nil, err
+	}
+	return parseOutputs(out.Bytes())
+}
+
+// Destroy runs terraform destroy with the saved variables
+func (c *CLI) Destroy(ctx context.Context) error {
+	if err := c.writeVars(); err != nil {
+		return err
+	}
+	return c.run(ctx, c.Stdout, "destroy", "-input=false", "-auto-approve")
+}
+
+// parseOutputs reads the values of `terraform output -json`
+func parseOutputs(data []byte) (map[string]interface{}, error) {
+	var raw map[string]struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing terraform output: %w", err)
+	}
+	outputs := make(map[string]interface{}, len(raw))
+	for name, output := range raw {
+		outputs[name] = output.Value
+	}
+	return outputs, nil
+}
@@ -0,0 +1,199 @@
+// Package prereq provisions and destroys the prerequisites of the solutions (tests/existing-resources: a resource
+// group, a VPC with one subnet and the latest Ubuntu image) and writes their outputs into the catalog validation
+// values of a solution. It is used by cmd/prereq, which the catalog validation scripts of tests/scripts run, and by
+// provisionPreReq in pr_test.go, so both set up the same resources the same way.
+package prereq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Dir is the Terraform configuration of the prerequisites, relative to the root of the module
+const Dir = "tests/existing-resources"
+
+// ValuesFile is the catalog validation values of a solution, rendered from ValuesFile + TemplateSuffix
+const ValuesFile = "catalogValidationValues.json"
+
+// TemplateSuffix is the suffix of the checked-in template of ValuesFile
+const TemplateSuffix = ".template"
+
+// Input is a solution input set from an output of the prerequisites
+type Input struct {
+	Output string
+	Input  string
+}
+
+// Inputs are the solution inputs set from the prerequisites, in the order they are written
+var Inputs = []Input{
+	{Output: "resource_group_name", Input: "existing_resource_group_name"},
+	{Output: "vpc_crn", Input: "existing_vpc_crn"},
+	{Output: "subnet_id", Input: "existing_subnet_id"},
+	{Output: "image_id", Input: "image_id"},
+}
+
+// Config holds the variables of tests/existing-resources, ibmcloud_api_key excepted (it is read from
+// TF_VAR_ibmcloud_api_key so it is never written to disk)
+type Config struct {
+	Prefix        string   `json:"prefix"`
+	Region        string   `json:"region"`
+	ResourceTags  []string `json:"resource_tags"`
+	CreateVPC     bool     `json:"create_vpc"`
+	ResourceGroup string   `json:"resource_group,omitempty"`
+}
+
+// Vars returns the config as Terraform variables
+func (c Config) Vars() map[string]interface{} {
+	tags := c.ResourceTags
+	if tags == nil {
+		tags = []string{}
+	}
+	vars := map[string]interface{}{
+		"prefix":        c.Prefix,
+		"region":        c.Region,
+		"resource_tags": tags,
+		"create_vpc":    c.CreateVPC,
+	}
+	if c.ResourceGroup != "" {
+		vars["resource_group"] = c.ResourceGroup
+	}
+	return vars
+}
+
+// LoadConfig reads a config saved as a tfvars JSON file
+func LoadConfig(path string) (Config, error) {
+	config := Config{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading prerequisite variables %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("error parsing prerequisite variables %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// RandomPrefix returns base followed by 4 random hexadecimal characters, like `vsi-$(openssl rand -hex 2)`
+func RandomPrefix(base string) (string, error) {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base + "-" + hex.EncodeToString(b), nil
+}
+
+// Terraform runs the prerequisite configuration. The variables are the ones of the Config it was created with.
+type Terraform interface {
+	Init(ctx context.Context) error
+	Apply(ctx context.Context) error
+	// Outputs returns the value of every output
+	Outputs(ctx context.Context) (map[string]interface{}, error)
+	Destroy(ctx context.Context) error
+}
+
+// Outputs are the output values of the prerequisites
+type Outputs map[string]interface{}
+
+// String returns an output as a string, "" when it is null or not set
+func (o Outputs) String(name string) string {
+	switch value := o[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// SolutionInputs returns the solution inputs set from the outputs. Null outputs (no VPC when create_vpc is false)
+// are left out.
+func (o Outputs) SolutionInputs() map[string]interface{} {
+	inputs := map[string]interface{}{}
+	for _, input := range Inputs {
+		if value, ok := o[input.Output]; ok && value != nil {
+			inputs[input.Input] = value
+		}
+	}
+	return inputs
+}
+
+// Provision initializes and applies the prerequisites and returns their outputs
+func Provision(ctx context.Context, tf Terraform) (Outputs, error) {
+	if err := tf.Init(ctx); err != nil {
+		return nil, fmt.Errorf("error initializing the prerequisites: %w", err)
+	}
+	if err := tf.Apply(ctx); err != nil {
+		return nil, fmt.Errorf("error applying the prerequisites: %w", err)
+	}
+	outputs, err := tf.Outputs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the outputs of the prerequisites: %w", err)
+	}
+	return outputs, nil
+}
+
+// Teardown destroys the prerequisites
+func Teardown(ctx context.Context, tf Terraform) error {
+	if err := tf.Destroy(ctx); err != nil {
+		return fmt.Errorf("error destroying the prerequisites: %w", err)
+	}
+	return nil
+}
+
+var placeholder = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// RenderTemplate replaces the `$NAME` placeholders of a catalog validation values template with the JSON encoding of
+// vars[NAME], the way the catalog pipeline renders it. A placeholder without a value is an error.
+func RenderTemplate(template []byte, vars map[string]interface{}) ([]byte, error) {
+	var missing []string
+	var renderErr error
+	rendered := placeholder.ReplaceAllFunc(template, func(match []byte) []byte {
+		name := string(match[1:])
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		data, err := json.Marshal(value)
+		if err != nil && renderErr == nil {
+			renderErr = fmt.Errorf("error encoding %s: %w", name, err)
+		}
+		return data
+	})
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("no value for %s", strings.Join(missing, ", "))
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(rendered, &values); err != nil {
+		return nil, fmt.Errorf("rendered template is not a JSON object: %w", err)
+	}
+	return rendered, nil
+}
+
+// MergeValues adds inputs to catalog validation values, replacing the inputs already set (`jq '. + {...}'`)
+func MergeValues(values []byte, inputs map[string]interface{}) ([]byte, error) {
+	merged := map[string]interface{}{}
+	if err := json.Unmarshal(values, &merged); err != nil {
+		return nil, fmt.Errorf("catalog validation values are not a JSON object: %w", err)
+	}
+	for name, value := range inputs {
+		merged[name] = value
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,129 @@
+package prereq
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTerraform records the commands it runs
+type fakeTerraform struct {
+	calls   []string
+	outputs map[string]interface{}
+	failOn  string
+}
+
+func (f *fakeTerraform) call(name string) error {
+	f.calls = append(f.calls, name)
+	if name == f.failOn {
+		return errors.New("exit status 1")
+	}
+	return nil
+}
+
+func (f *fakeTerraform) Init(ctx context.Context) error    { return f.call("init") }
+func (f *fakeTerraform) Apply(ctx context.Context) error   { return f.call("apply") }
+func (f *fakeTerraform) Destroy(ctx context.Context) error { return f.call("destroy") }
+func (f *fakeTerraform) Outputs(ctx context.Context) (map[string]interface{}, error) {
+	return f.outputs, f.call("output")
+}
+
+func TestRenderTemplate(t *testing.T) {
+	template, err := os.ReadFile("testdata/catalogValidationValues.json.template")
+	require.NoError(t, err)
+
+	rendered, err := RenderTemplate(template, map[string]interface{}{
+		"VALIDATION_APIKEY": `key"with"quotes`,
+		"TAGS":              []string{"a", "b"},
+		"PREFIX":            "vsi-ab12",
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"ibmcloud_api_key": "key\"with\"quotes",
+		"vsi_resource_tags": ["a", "b"],
+		"existing_resource_group_name": "geretain-test-resources",
+		"prefix": "vsi-ab12"
+	}`, string(rendered))
+
+	_, err = RenderTemplate(template, map[string]interface{}{"PREFIX": "vsi"})
+	assert.EqualError(t, err, "no value for TAGS, VALIDATION_APIKEY")
+
+	_, err = RenderTemplate([]byte(`{"prefix": $PREFIX`), map[string]interface{}{"PREFIX": "vsi"})
+	assert.ErrorContains(t, err, "not a JSON object")
+}
+
+func TestMergeValues(t *testing.T) {
+	values := []byte(`{"prefix": "vsi-ab12", "existing_resource_group_name": "geretain-test-resources"}`)
+	outputs, err := parseOutputs(mustRead(t, "testdata/output.json"))
+	require.NoError(t, err)
+
+	merged, err := MergeValues(values, Outputs(outputs).SolutionInputs())
+	require.NoError(t, err)
+	assert.Equal(t, `{
+  "existing_resource_group_name": "vsi-ab12-resource-group",
+  "existing_subnet_id": "0717-subnet",
+  "existing_vpc_crn": "crn:v1:bluemix:public:is:us-south:a/abc::vpc:r006-vpc",
+  "image_id": "r006-1234",
+  "prefix": "vsi-ab12"
+}
+`, string(merged))
+
+	_, err = MergeValues([]byte(`[]`), nil)
+	assert.Error(t, err)
+}
+
+func TestSolutionInputsSkipsNullOutputs(t *testing.T) {
+	outputs := Outputs{"image_id": "r006-1234", "vpc_crn": nil, "subnet_id": nil, "resource_group_name": nil}
+	assert.Equal(t, map[string]interface{}{"image_id": "r006-1234"}, outputs.SolutionInputs())
+	assert.Equal(t, "", outputs.String("vpc_crn"))
+	assert.Equal(t, "r006-1234", outputs.String("image_id"))
+}
+
+func TestProvisionAndTeardown(t *testing.T) {
+	tf := &fakeTerraform{outputs: map[string]interface{}{"image_id": "r006-1234"}}
+	outputs, err := Provision(context.Background(), tf)
+	require.NoError(t, err)
+	assert.Equal(t, "r006-1234", outputs.String("image_id"))
+	require.NoError(t, Teardown(context.Background(), tf))
+	assert.Equal(t, []string{"init", "apply", "output", "destroy"}, tf.calls)
+
+	tf = &fakeTerraform{failOn: "apply"}
+	_, err = Provision(context.Background(), tf)
+	assert.EqualError(t, err, "error applying the prerequisites: exit status 1")
+	assert.Equal(t, []string{"init", "apply"}, tf.calls)
+}
+
+func TestConfig(t *testing.T) {
+	config := Config{Prefix: "vsi-ab12", Region: "us-south", CreateVPC: true}
+	assert.Equal(t, map[string]interface{}{
+		"prefix":        "vsi-ab12",
+		"region":        "us-south",
+		"resource_tags": []string{},
+		"create_vpc":    true,
+	}, config.Vars())
+
+	// the CLI saves the variables for the teardown
+	cli := &CLI{Dir: t.TempDir(), Config: config}
+	require.NoError(t, cli.writeVars())
+	loaded, err := LoadConfig(filepath.Join(cli.Dir, VarFile))
+	require.NoError(t, err)
+	assert.Equal(t, config.Prefix, loaded.Prefix)
+	assert.Equal(t, config.Region, loaded.Region)
+	assert.True(t, loaded.CreateVPC)
+
+	prefix, err := RandomPrefix("vsi")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^vsi-[0-9a-f]{4}$`), prefix)
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,101 @@
+package profiles
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// Instance is an instance of a plan with its planned volumes
+type Instance struct {
+	Address string
+	Inputs  Inputs
+}
+
+// FromPlan returns the instances a plan creates or keeps, with the profile and boot volume planned for them and the
+// data volumes of their module call whose key starts with theirs (`ibm_is_volume.volume["subnet-a-0-data"]` of
+// `ibm_is_instance.vsi["subnet-a-0"]`). Values only known after the apply are left to the API.
+func FromPlan(plan *tfjson.Plan) []Instance {
+	var instances []Instance
+	var instanceChanges, volumes []*tfjson.ResourceChange
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil || change.Change.Actions.Delete() && !change.Change.Actions.Replace() {
+			continue
+		}
+		switch change.Type {
+		case "ibm_is_instance":
+			after := change.Change.After
+			inputs := Inputs{
+				MachineType:         tfplan.String(after, "profile"),
+				BootVolumeSize:      number(after, "boot_volume", 0, "size"),
+				BootVolumeIOPS:      number(after, "boot_volume", 0, "iops"),
+				BootVolumeBandwidth: number(after, "boot_volume", 0, "bandwidth"),
+			}
+			if profile := tfplan.String(after, "boot_volume", 0, "profile"); profile != "" {
+				inputs.BootVolumeProfile = &profile
+			}
+			instances = append(instances, Instance{Address: change.Address, Inputs: inputs})
+			instanceChanges = append(instanceChanges, change)
+		case "ibm_is_volume":
+			volumes = append(volumes, change)
+		}
+	}
+
+	for i := range instances {
+		instance := &instances[i]
+		change := instanceChanges[i]
+		key, ok := change.Index.(string)
+		if !ok {
+			continue
+		}
+		for _, volume := range volumes {
+			volumeKey, ok := volume.Index.(string)
+			if !ok || volume.ModuleAddress != change.ModuleAddress || !strings.HasPrefix(volumeKey, key+"-") {
+				continue
+			}
+			after := volume.Change.After
+			instance.Inputs.BlockStorageVolumes = append(instance.Inputs.BlockStorageVolumes, Volume{
+				Name:      strings.TrimPrefix(volumeKey, key+"-"),
+				Profile:   tfplan.String(after, "profile"),
+				Capacity:  number(after, "capacity"),
+				IOPS:      number(after, "iops"),
+				Bandwidth: number(after, "bandwidth"),
+			})
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Address < instances[j].Address })
+	return instances
+}
+
+// CheckPlan checks every instance of a plan
+func (c *Catalog) CheckPlan(plan *tfjson.Plan) *Report {
+	report := &Report{}
+	for _, instance := range FromPlan(plan) {
+		inputs := instance.Inputs
+		c.check(report, instance.Address, &inputs)
+	}
+	return report
+}
+
+// CheckPlanFile checks every instance of a plan JSON file
+func (c *Catalog) CheckPlanFile(path string) (*Report, error) {
+	plan, err := tfplan.LoadPlan(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.CheckPlan(plan), nil
+}
+
+// number returns a planned number, nil if it is null or unknown
+func number(value interface{}, path ...interface{}) *int {
+	switch tfplan.Lookup(value, path...).(type) {
+	case float64, json.Number:
+		i := int(tfplan.Number(value, path...))
+		return &i
+	default:
+		return nil
+	}
+}
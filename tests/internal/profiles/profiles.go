@@ -0,0 +1,381 @@
+// Package profiles checks the combination of an instance profile (`machine_type`), its boot volume
+// (`boot_volume_profile`, `boot_volume_size`, `boot_volume_iops`, `boot_volume_bandwidth`) and its data volumes
+// (`block_storage_volumes`) against an offline catalog of the VPC profiles (tests/machine-profiles.yaml), and
+// suggests a fix for each problem: too many data volumes for the profile, sdp bandwidth above the volume allocation
+// of the instance, sdp volumes on a profile that does not support them, or capacity, IOPS and bandwidth outside the
+// range of a volume profile. These combinations pass `terraform plan` and only fail at apply.
+package profiles
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CatalogPath is the path of the profile catalog, relative to the root of the module
+const CatalogPath = "tests/machine-profiles.yaml"
+
+// Volume profiles the module and the checks refer to
+const (
+	GeneralPurpose = "general-purpose"
+	SDP            = "sdp"
+)
+
+// Catalog is the content of the profile catalog
+type Catalog struct {
+	Defaults         Defaults                   `yaml:"defaults"`
+	InstanceProfiles map[string]InstanceProfile `yaml:"instance_profiles"`
+	VolumeProfiles   map[string]VolumeProfile   `yaml:"volume_profiles"`
+}
+
+// Defaults are the values given to a profile new to the catalog for the fields the API does not return
+type Defaults struct {
+	MaxDataVolumes int `yaml:"max_data_volumes"`
+}
+
+// InstanceProfile is an entry of instance_profiles
+type InstanceProfile struct {
+	Architecture string `yaml:"architecture"`
+	VCPU         int    `yaml:"vcpu"`
+	Memory       int    `yaml:"memory"`
+	// Bandwidth is the total bandwidth of the instance, VolumeBandwidth its share for the volumes, in Mbps
+	Bandwidth       int  `yaml:"bandwidth"`
+	VolumeBandwidth int  `yaml:"volume_bandwidth"`
+	MaxDataVolumes  int  `yaml:"max_data_volumes"`
+	SDP             bool `yaml:"sdp"`
+}
+
+// Range is an inclusive range, a zero Max means the value can not be set
+type Range struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+// Contains returns true if the value is in the range
+func (r Range) Contains(value int) bool {
+	return value >= r.Min && value <= r.Max
+}
+
+// VolumeProfile is an entry of volume_profiles
+type VolumeProfile struct {
+	Family     string `yaml:"family"`
+	Generation int    `yaml:"generation,omitempty"`
+	Capacity   Range  `yaml:"capacity"`
+	// IOPS and Bandwidth are the ranges of the values that can be set, IOPSPerGB and MaxIOPS the IOPS of a tiered
+	// profile, which can not be set
+	IOPS      Range `yaml:"iops,omitempty"`
+	Bandwidth Range `yaml:"bandwidth,omitempty"`
+	IOPSPerGB int   `yaml:"iops_per_gb,omitempty"`
+	MaxIOPS   int   `yaml:"max_iops,omitempty"`
+}
+
+// LoadCatalog reads the profile catalog
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading profile catalog %s: %w", path, err)
+	}
+	catalog := &Catalog{}
+	if err := yaml.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf("error parsing profile catalog %s: %w", path, err)
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, fmt.Errorf("profile catalog %s: %w", path, err)
+	}
+	return catalog, nil
+}
+
+// Validate checks every profile has the values the checks need
+func (c *Catalog) Validate() error {
+	var problems []string
+	for _, name := range sortedKeys(c.InstanceProfiles) {
+		profile := c.InstanceProfiles[name]
+		if profile.VolumeBandwidth <= 0 || profile.VolumeBandwidth > profile.Bandwidth {
+			problems = append(problems, fmt.Sprintf("instance profile %s: volume_bandwidth must be positive and at most bandwidth", name))
+		}
+		if profile.MaxDataVolumes <= 0 {
+			problems = append(problems, fmt.Sprintf("instance profile %s: max_data_volumes must be positive", name))
+		}
+	}
+	for _, name := range sortedKeys(c.VolumeProfiles) {
+		profile := c.VolumeProfiles[name]
+		if profile.Capacity.Max == 0 || profile.Capacity.Min > profile.Capacity.Max {
+			problems = append(problems, fmt.Sprintf("volume profile %s: invalid capacity range", name))
+		}
+	}
+	for _, name := range []string{GeneralPurpose, SDP} {
+		if _, ok := c.VolumeProfiles[name]; !ok {
+			problems = append(problems, fmt.Sprintf("no volume profile %s", name))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid profile catalog:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Volume is a boot or data volume of an instance, nil values are the ones left to the API
+type Volume struct {
+	Name      string `json:"name"`
+	Profile   string `json:"profile"`
+	Capacity  *int   `json:"capacity"`
+	IOPS      *int   `json:"iops"`
+	Bandwidth *int   `json:"bandwidth"`
+}
+
+// Inputs are the inputs of the module the checks depend on, with the names of its variables so they can be read
+// from a tfvars JSON file or the TerraformVars of a test. The other variables are ignored.
+type Inputs struct {
+	MachineType         string   `json:"machine_type"`
+	BootVolumeProfile   *string  `json:"boot_volume_profile"`
+	BootVolumeSize      *int     `json:"boot_volume_size"`
+	BootVolumeIOPS      *int     `json:"boot_volume_iops"`
+	BootVolumeBandwidth *int     `json:"boot_volume_bandwidth"`
+	BlockStorageVolumes []Volume `json:"block_storage_volumes"`
+}
+
+// LoadInputs reads the inputs of a tfvars JSON file
+func LoadInputs(path string) (*Inputs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading inputs %s: %w", path, err)
+	}
+	inputs := &Inputs{}
+	if err := json.Unmarshal(data, inputs); err != nil {
+		return nil, fmt.Errorf("error parsing inputs %s: %w", path, err)
+	}
+	return inputs, nil
+}
+
+// FromVars returns the inputs of the TerraformVars of a test
+func FromVars(vars map[string]interface{}) (*Inputs, error) {
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+	inputs := &Inputs{}
+	if err := json.Unmarshal(data, inputs); err != nil {
+		return nil, fmt.Errorf("error reading the inputs of the test: %w", err)
+	}
+	return inputs, nil
+}
+
+// Boot returns the boot volume of the inputs, of the general-purpose profile when boot_volume_profile is null
+func (i *Inputs) Boot() Volume {
+	boot := Volume{Name: "boot", Profile: GeneralPurpose, Capacity: i.BootVolumeSize, IOPS: i.BootVolumeIOPS, Bandwidth: i.BootVolumeBandwidth}
+	if i.BootVolumeProfile != nil {
+		boot.Profile = *i.BootVolumeProfile
+	}
+	return boot
+}
+
+// Problem is a combination the API would reject, with the fix suggested
+type Problem struct {
+	// Subject is the instance, or the address of the instance of a plan, followed by the volume if any
+	Subject    string
+	Detail     string
+	Suggestion string
+}
+
+// Report is the outcome of the checks
+type Report struct {
+	Checked  int
+	Problems []Problem
+}
+
+// Check checks the inputs of a module call. Subject names the instance in the problems.
+func (c *Catalog) Check(subject string, inputs *Inputs) *Report {
+	report := &Report{}
+	c.check(report, subject, inputs)
+	return report
+}
+
+func (c *Catalog) check(report *Report, subject string, inputs *Inputs) {
+	report.Checked++
+	problem := func(volume string, suggestion string, format string, args ...interface{}) {
+		name := subject
+		if volume != "" {
+			name += " " + volume
+		}
+		report.Problems = append(report.Problems, Problem{Subject: name, Detail: fmt.Sprintf(format, args...), Suggestion: suggestion})
+	}
+
+	volumes := append([]Volume{inputs.Boot()}, inputs.BlockStorageVolumes...)
+	for i, volume := range volumes {
+		name := "volume " + volume.Name
+		if i == 0 {
+			name = "boot volume"
+		}
+		c.checkVolume(name, volume, problem)
+	}
+
+	profile, ok := c.InstanceProfiles[inputs.MachineType]
+	if !ok {
+		problem("", c.similar(inputs.MachineType), "unknown machine_type %q", inputs.MachineType)
+		return
+	}
+	if count := len(inputs.BlockStorageVolumes); count > profile.MaxDataVolumes {
+		suggestion := fmt.Sprintf("keep at most %d volumes in block_storage_volumes, or spread them over more instances", profile.MaxDataVolumes)
+		if larger := c.smallest(inputs.MachineType, func(p InstanceProfile) bool { return p.MaxDataVolumes >= count }); larger != "" {
+			suggestion += ", or use " + larger
+		}
+		problem("", suggestion, "%d data volumes, %s attaches at most %d", count, inputs.MachineType, profile.MaxDataVolumes)
+	}
+	if !profile.SDP {
+		for i, volume := range volumes {
+			if volume.Profile != SDP {
+				continue
+			}
+			suggestion := "use the " + GeneralPurpose + " profile (the custom one for data volumes that need their IOPS)"
+			if i == 0 {
+				suggestion = "set boot_volume_profile to " + GeneralPurpose + " and remove boot_volume_iops and boot_volume_bandwidth"
+			}
+			if same := c.sameSize(inputs.MachineType); same != "" {
+				suggestion += ", or use " + same
+			}
+			name := "volume " + volume.Name
+			if i == 0 {
+				name = "boot volume"
+			}
+			problem(name, suggestion, "%s does not support sdp volumes", inputs.MachineType)
+		}
+	}
+	total := 0
+	var set []string
+	for _, volume := range volumes {
+		if volume.Bandwidth != nil {
+			total += *volume.Bandwidth
+			set = append(set, fmt.Sprintf("%s %d", volume.Name, *volume.Bandwidth))
+		}
+	}
+	if total > profile.VolumeBandwidth {
+		suggestion := fmt.Sprintf("lower the bandwidths to a total of %d Mbps", profile.VolumeBandwidth)
+		if larger := c.smallest(inputs.MachineType, func(p InstanceProfile) bool { return p.VolumeBandwidth >= total }); larger != "" {
+			suggestion += fmt.Sprintf(", or use %s (%d Mbps for volumes)", larger, c.InstanceProfiles[larger].VolumeBandwidth)
+		}
+		problem("", suggestion, "volume bandwidth of %d Mbps (%s) above the %d Mbps %s allocates to volumes", total, strings.Join(set, ", "), profile.VolumeBandwidth, inputs.MachineType)
+	}
+}
+
+// checkVolume checks the values of a volume against the ranges of its profile
+func (c *Catalog) checkVolume(name string, volume Volume, problem func(volume string, suggestion string, format string, args ...interface{})) {
+	profile, ok := c.VolumeProfiles[volume.Profile]
+	if !ok {
+		problem(name, "use one of "+strings.Join(sortedKeys(c.VolumeProfiles), ", "), "unknown volume profile %q", volume.Profile)
+		return
+	}
+	if volume.Capacity != nil && !profile.Capacity.Contains(*volume.Capacity) {
+		problem(name, fmt.Sprintf("set a capacity between %d and %d GB", profile.Capacity.Min, profile.Capacity.Max), "capacity of %d GB outside the range of %s", *volume.Capacity, volume.Profile)
+	}
+	if volume.IOPS != nil {
+		switch {
+		case profile.IOPS.Max == 0:
+			problem(name, "remove the IOPS, or use the custom or sdp profile", "IOPS can not be set on %s", volume.Profile)
+		case !profile.IOPS.Contains(*volume.IOPS):
+			problem(name, fmt.Sprintf("set IOPS between %d and %d", profile.IOPS.Min, profile.IOPS.Max), "%d IOPS outside the range of %s", *volume.IOPS, volume.Profile)
+		}
+	}
+	if volume.Bandwidth != nil {
+		switch {
+		case profile.Bandwidth.Max == 0:
+			problem(name, "remove the bandwidth, or use the sdp profile", "bandwidth can not be set on %s", volume.Profile)
+		case !profile.Bandwidth.Contains(*volume.Bandwidth):
+			problem(name, fmt.Sprintf("set a bandwidth between %d and %d Mbps", profile.Bandwidth.Min, profile.Bandwidth.Max), "bandwidth of %d Mbps outside the range of %s", *volume.Bandwidth, volume.Profile)
+		}
+	}
+}
+
+// smallest returns the profile of the family of a profile (`bx2` of `bx2-2x8`), or else of any family of its
+// architecture, with the fewest vCPUs and memory meeting a condition, "" if there is none
+func (c *Catalog) smallest(name string, condition func(InstanceProfile) bool) string {
+	current := c.InstanceProfiles[name]
+	var best string
+	better := func(candidate string) bool {
+		if best == "" {
+			return true
+		}
+		a, b := c.InstanceProfiles[candidate], c.InstanceProfiles[best]
+		if sameFamily(candidate, name) != sameFamily(best, name) {
+			return sameFamily(candidate, name)
+		}
+		if a.VCPU != b.VCPU {
+			return a.VCPU < b.VCPU
+		}
+		return a.Memory < b.Memory
+	}
+	for _, candidate := range sortedKeys(c.InstanceProfiles) {
+		profile := c.InstanceProfiles[candidate]
+		if candidate == name || profile.Architecture != current.Architecture || !condition(profile) {
+			continue
+		}
+		if better(candidate) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// sameSize returns a profile supporting sdp volumes with the vCPUs and memory of a profile, "" if there is none
+func (c *Catalog) sameSize(name string) string {
+	current := c.InstanceProfiles[name]
+	for _, candidate := range sortedKeys(c.InstanceProfiles) {
+		profile := c.InstanceProfiles[candidate]
+		if profile.SDP && profile.VCPU == current.VCPU && profile.Memory == current.Memory {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// similar suggests the profiles of the family of an unknown profile
+func (c *Catalog) similar(name string) string {
+	var candidates []string
+	for _, candidate := range sortedKeys(c.InstanceProfiles) {
+		if sameFamily(candidate, name) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return "use a profile of the catalog, or refresh it if the profile is new"
+	}
+	return "use one of " + strings.Join(candidates, ", ") + ", or refresh the catalog if the profile is new"
+}
+
+func sameFamily(a string, b string) bool {
+	return strings.SplitN(a, "-", 2)[0] == strings.SplitN(b, "-", 2)[0]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Failed returns true if a combination would be rejected
+func (r *Report) Failed() bool {
+	return len(r.Problems) > 0
+}
+
+// String renders the problems as a table, one line per problem with its fix
+func (r *Report) String() string {
+	if !r.Failed() {
+		return fmt.Sprintf("The profiles of the %d instance(s) are compatible.\n", r.Checked)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d problem(s) in the profiles of the %d instance(s):\n", len(r.Problems), r.Checked)
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INSTANCE\tPROBLEM\tSUGGESTION")
+	for _, problem := range r.Problems {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", problem.Subject, problem.Detail, problem.Suggestion)
+	}
+	_ = w.Flush()
+	return sb.String()
+}
@@ -0,0 +1,228 @@
+package profiles
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func repoCatalog(t *testing.T) *Catalog {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	catalog, err := LoadCatalog(filepath.Join(root, CatalogPath))
+	require.NoError(t, err)
+	return catalog
+}
+
+func intp(i int) *int {
+	return &i
+}
+
+func strp(s string) *string {
+	return &s
+}
+
+// TestRepoCatalog checks the catalog is in the layout the refresh writes, so a refresh only shows the changes of
+// the API
+func TestRepoCatalog(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(root, CatalogPath))
+	require.NoError(t, err)
+	catalog := repoCatalog(t)
+	assert.Equal(t, string(data), string(catalog.Render(Header(data))))
+}
+
+// TestRepoFixtures checks the instances of the plan fixtures
+func TestRepoFixtures(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	catalog := repoCatalog(t)
+	names, err := fixtures.PlanNames(root)
+	require.NoError(t, err)
+	checked := 0
+	for _, name := range names {
+		report, err := catalog.CheckPlanFile(fixtures.PlanPath(root, name))
+		require.NoError(t, err)
+		assert.False(t, report.Failed(), "%s:\n%s", name, report)
+		checked += report.Checked
+	}
+	assert.Positive(t, checked)
+}
+
+func TestFromPlan(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	plan, err := tfplan.LoadPlan(fixtures.PlanPath(root, "multi-profile-one-vpc"))
+	require.NoError(t, err)
+
+	instances := FromPlan(plan)
+	require.Len(t, instances, 6)
+	instance := instances[0]
+	assert.Equal(t, `module.slz_vsi_bx.ibm_is_instance.vsi["slz-vsi-com-vpc-subnet-a-0"]`, instance.Address)
+	assert.Equal(t, "bx2-2x8", instance.Inputs.MachineType)
+	assert.Equal(t, intp(150), instance.Inputs.BootVolumeSize)
+	assert.Nil(t, instance.Inputs.BootVolumeProfile)
+	// the volume of the same key in the other module call is not attached to it
+	assert.Equal(t, []Volume{{Name: "slz-vsi-com", Profile: "10iops-tier", Capacity: intp(100)}}, instance.Inputs.BlockStorageVolumes)
+}
+
+func TestCheck(t *testing.T) {
+	catalog := repoCatalog(t)
+	data := func(count int, volume Volume) []Volume {
+		var volumes []Volume
+		for i := 0; i < count; i++ {
+			volumes = append(volumes, volume)
+		}
+		return volumes
+	}
+	tests := []struct {
+		name     string
+		inputs   Inputs
+		problems []Problem
+	}{
+		{
+			name:   "gen2-storage example",
+			inputs: Inputs{MachineType: "cx2-2x4", BootVolumeProfile: strp(SDP), BootVolumeSize: intp(200), BootVolumeIOPS: intp(5000), BootVolumeBandwidth: intp(1000)},
+		},
+		{
+			name:   "too many volumes",
+			inputs: Inputs{MachineType: "bx2-2x8", BlockStorageVolumes: data(13, Volume{Name: "data", Profile: "general-purpose"})},
+			problems: []Problem{{
+				Subject:    "vsi",
+				Detail:     "13 data volumes, bx2-2x8 attaches at most 12",
+				Suggestion: "keep at most 12 volumes in block_storage_volumes, or spread them over more instances",
+			}},
+		},
+		{
+			name: "bandwidth above the allocation",
+			inputs: Inputs{MachineType: "cx2-2x4", BootVolumeProfile: strp(SDP), BootVolumeBandwidth: intp(1000),
+				BlockStorageVolumes: []Volume{{Name: "logs", Profile: SDP, Bandwidth: intp(2000)}}},
+			problems: []Problem{{
+				Subject:    "vsi",
+				Detail:     "volume bandwidth of 3000 Mbps (boot 1000, logs 2000) above the 1000 Mbps cx2-2x4 allocates to volumes",
+				Suggestion: "lower the bandwidths to a total of 1000 Mbps, or use cx2-8x16 (4000 Mbps for volumes)",
+			}},
+		},
+		{
+			name:   "sdp on an unsupported profile",
+			inputs: Inputs{MachineType: "bz2-2x8", BootVolumeProfile: strp(SDP), BlockStorageVolumes: []Volume{{Name: "data", Profile: SDP}}},
+			problems: []Problem{
+				{
+					Subject:    "vsi boot volume",
+					Detail:     "bz2-2x8 does not support sdp volumes",
+					Suggestion: "set boot_volume_profile to general-purpose and remove boot_volume_iops and boot_volume_bandwidth, or use bx2-2x8",
+				},
+				{
+					Subject:    "vsi volume data",
+					Detail:     "bz2-2x8 does not support sdp volumes",
+					Suggestion: "use the general-purpose profile (the custom one for data volumes that need their IOPS), or use bx2-2x8",
+				},
+			},
+		},
+		{
+			name: "values outside the volume profiles",
+			inputs: Inputs{MachineType: "mx2-8x64", BootVolumeIOPS: intp(3000), BlockStorageVolumes: []Volume{
+				{Name: "a", Profile: "10iops-tier", Capacity: intp(8000)},
+				{Name: "b", Profile: "custom", IOPS: intp(50000), Bandwidth: intp(1000)},
+				{Name: "c", Profile: "gold"},
+			}},
+			problems: []Problem{
+				{Subject: "vsi boot volume", Detail: "IOPS can not be set on general-purpose", Suggestion: "remove the IOPS, or use the custom or sdp profile"},
+				{Subject: "vsi volume a", Detail: "capacity of 8000 GB outside the range of 10iops-tier", Suggestion: "set a capacity between 10 and 4800 GB"},
+				{Subject: "vsi volume b", Detail: "50000 IOPS outside the range of custom", Suggestion: "set IOPS between 100 and 48000"},
+				{Subject: "vsi volume b", Detail: "bandwidth can not be set on custom", Suggestion: "remove the bandwidth, or use the sdp profile"},
+				{Subject: "vsi volume c", Detail: `unknown volume profile "gold"`, Suggestion: "use one of 10iops-tier, 5iops-tier, custom, general-purpose, sdp"},
+			},
+		},
+		{
+			name:   "unknown machine type",
+			inputs: Inputs{MachineType: "cx2-64x128"},
+			problems: []Problem{{
+				Subject:    "vsi",
+				Detail:     `unknown machine_type "cx2-64x128"`,
+				Suggestion: "use one of cx2-2x4, cx2-4x8, cx2-8x16, or refresh the catalog if the profile is new",
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := catalog.Check("vsi", &test.inputs)
+			assert.Equal(t, test.problems, report.Problems)
+			assert.Equal(t, len(test.problems) > 0, report.Failed())
+		})
+	}
+}
+
+func TestFromVars(t *testing.T) {
+	inputs, err := FromVars(map[string]interface{}{
+		"prefix":                "slz-vsi-gen2",
+		"machine_type":          "cx2-2x4",
+		"boot_volume_profile":   "sdp",
+		"boot_volume_bandwidth": 1000,
+		"block_storage_volumes": []map[string]interface{}{{"name": "data", "profile": "sdp", "bandwidth": 500}},
+	})
+	require.NoError(t, err)
+	report := repoCatalog(t).Check("TestRunGen2BootExample", inputs)
+	require.True(t, report.Failed())
+	assert.Contains(t, report.String(), "2 problem(s) in the profiles of the 1 instance(s):\nINSTANCE")
+	assert.Contains(t, report.String(), "bandwidth of 500 Mbps outside the range of sdp")
+}
+
+func TestRefresh(t *testing.T) {
+	pages := map[string]string{
+		"/v1/instance/profiles":     "instance-profiles.json",
+		"/v1/volume/profiles":       "volume-profiles-1.json",
+		"/v1/volume/profiles?page2": "volume-profiles-2.json",
+	}
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		key := r.URL.Path
+		if r.URL.Query().Get("start") == "page-2" {
+			key += "?page2"
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", pages[key]))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	current := repoCatalog(t)
+	refreshed, err := Refresh(context.Background(), &Client{service: service}, current)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bx2-2x8", "bx3d-4x20", "bz2e-2x8"}, sortedKeys(refreshed.InstanceProfiles))
+	// the default of the range of a known profile, its curated values kept
+	assert.Equal(t, current.InstanceProfiles["bx2-2x8"], refreshed.InstanceProfiles["bx2-2x8"])
+	// a quarter of the bandwidth when it is dependent, the defaults of the file for a new profile
+	assert.Equal(t, InstanceProfile{Architecture: "amd64", VCPU: 4, Memory: 20, Bandwidth: 10000, VolumeBandwidth: 2500, MaxDataVolumes: 12, SDP: true}, refreshed.InstanceProfiles["bx3d-4x20"])
+	assert.False(t, refreshed.InstanceProfiles["bz2e-2x8"].SDP)
+
+	assert.Equal(t, []string{"custom", "general-purpose", "sdp"}, sortedKeys(refreshed.VolumeProfiles))
+	// a dependent range keeps the IOPS of the file
+	assert.Equal(t, current.VolumeProfiles["custom"], refreshed.VolumeProfiles["custom"])
+	assert.Equal(t, current.VolumeProfiles["sdp"], refreshed.VolumeProfiles["sdp"])
+	assert.Equal(t, []string{"generation=2&version=" + VPCVersion, "generation=2&version=" + VPCVersion, "generation=2&start=page-2&version=" + VPCVersion}, queries)
+
+	_, err = Refresh(context.Background(), &Client{service: service}, &Catalog{})
+	assert.ErrorContains(t, err, "instance profile bx3d-4x20: max_data_volumes must be positive")
+	require.NoError(t, service.SetServiceURL(server.URL+"/v2"))
+	_, err = Refresh(context.Background(), &Client{service: service}, current)
+	assert.ErrorContains(t, err, "GET /v2/instance/profiles")
+}
@@ -0,0 +1,220 @@
+package profiles
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// VPCVersion is the API version the client requests
+const VPCVersion = "2025-01-01"
+
+// Client lists the instance and volume profiles of a region with the VPC REST API
+type Client struct {
+	service *core.BaseService
+}
+
+// NewClient returns a client of a region authenticated with an API key
+func NewClient(apiKey string, region string) (*Client, error) {
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           fmt.Sprintf("https://%s.iaas.cloud.ibm.com/v1", region),
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{service: service}, nil
+}
+
+// SetServiceURL changes the endpoint, for tests or private endpoints
+func (c *Client) SetServiceURL(url string) error {
+	return c.service.SetServiceURL(url)
+}
+
+// Service returns the underlying service, to install a recording or replaying transport
+func (c *Client) Service() *core.BaseService {
+	return c.service
+}
+
+// apiProfile is an instance or volume profile as the API returns it. The values of a profile are objects whose type
+// tells how to read them: `fixed` has a value, `range` a min, max and default, `dependent` depends on other values.
+type apiProfile struct {
+	Name                 string                 `json:"name"`
+	Family               string                 `json:"family"`
+	VCPUArchitecture     map[string]interface{} `json:"vcpu_architecture"`
+	VCPUCount            map[string]interface{} `json:"vcpu_count"`
+	Memory               map[string]interface{} `json:"memory"`
+	Bandwidth            map[string]interface{} `json:"bandwidth"`
+	TotalVolumeBandwidth map[string]interface{} `json:"total_volume_bandwidth"`
+	Capacity             map[string]interface{} `json:"capacity"`
+	IOPS                 map[string]interface{} `json:"iops"`
+}
+
+type profileCollection struct {
+	Profiles []apiProfile `json:"profiles"`
+	Next     *struct {
+		Href string `json:"href"`
+	} `json:"next"`
+}
+
+// list returns every profile of a collection, following the pages
+func (c *Client) list(ctx context.Context, path string) ([]apiProfile, error) {
+	var profiles []apiProfile
+	start := ""
+	for {
+		builder := core.NewRequestBuilder(core.GET).WithContext(ctx)
+		if _, err := builder.ResolveRequestURL(c.service.GetServiceURL(), path, nil); err != nil {
+			return nil, err
+		}
+		builder.AddHeader("Accept", "application/json")
+		builder.AddQuery("version", VPCVersion)
+		builder.AddQuery("generation", "2")
+		if start != "" {
+			builder.AddQuery("start", start)
+		}
+		request, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		var page profileCollection
+		if _, err := c.service.Request(request, &page); err != nil {
+			return nil, fmt.Errorf("GET %s: %w", request.URL.Path, err)
+		}
+		profiles = append(profiles, page.Profiles...)
+		if page.Next == nil || page.Next.Href == "" {
+			return profiles, nil
+		}
+		next, err := url.Parse(page.Next.Href)
+		if err != nil {
+			return nil, fmt.Errorf("GET %s: invalid next page %s: %w", request.URL.Path, page.Next.Href, err)
+		}
+		if start = next.Query().Get("start"); start == "" {
+			return nil, fmt.Errorf("GET %s: next page %s without start", request.URL.Path, page.Next.Href)
+		}
+	}
+}
+
+// Refresh returns the catalog with the values of the profiles of a region. The values the API does not return are
+// kept from the current catalog, or defaulted for a new profile, and profiles the region no longer lists are removed.
+// Volume profiles whose capacity the API does not give as a range keep the current one.
+func Refresh(ctx context.Context, client *Client, current *Catalog) (*Catalog, error) {
+	instanceProfiles, err := client.list(ctx, "/instance/profiles")
+	if err != nil {
+		return nil, err
+	}
+	volumeProfiles, err := client.list(ctx, "/volume/profiles")
+	if err != nil {
+		return nil, err
+	}
+
+	refreshed := &Catalog{Defaults: current.Defaults, InstanceProfiles: map[string]InstanceProfile{}, VolumeProfiles: map[string]VolumeProfile{}}
+	for _, api := range instanceProfiles {
+		profile, known := current.InstanceProfiles[api.Name]
+		if !known {
+			profile.MaxDataVolumes = current.Defaults.MaxDataVolumes
+		}
+		profile.Architecture, _ = api.VCPUArchitecture["value"].(string)
+		profile.VCPU = fixed(api.VCPUCount)
+		profile.Memory = fixed(api.Memory)
+		profile.Bandwidth = fixed(api.Bandwidth)
+		profile.VolumeBandwidth = fixed(api.TotalVolumeBandwidth)
+		if profile.VolumeBandwidth == 0 {
+			// the default share of the volumes when it depends on the bandwidth of the network
+			profile.VolumeBandwidth = profile.Bandwidth / 4
+		}
+		if !known {
+			profile.SDP = profile.Architecture != "s390x"
+		}
+		refreshed.InstanceProfiles[api.Name] = profile
+	}
+	for _, api := range volumeProfiles {
+		profile := current.VolumeProfiles[api.Name]
+		profile.Family = api.Family
+		if capacity, ok := rangeOf(api.Capacity); ok {
+			profile.Capacity = capacity
+		}
+		if iops, ok := rangeOf(api.IOPS); ok {
+			profile.IOPS = iops
+		}
+		if bandwidth, ok := rangeOf(api.Bandwidth); ok {
+			profile.Bandwidth = bandwidth
+		}
+		refreshed.VolumeProfiles[api.Name] = profile
+	}
+	if err := refreshed.Validate(); err != nil {
+		return nil, err
+	}
+	return refreshed, nil
+}
+
+// fixed returns the value of a fixed value, or the default of a range, 0 for the other types
+func fixed(value map[string]interface{}) int {
+	switch value["type"] {
+	case "fixed":
+		n, _ := value["value"].(float64)
+		return int(n)
+	case "range":
+		n, _ := value["default"].(float64)
+		return int(n)
+	default:
+		return 0
+	}
+}
+
+// rangeOf returns the min and max of a range value
+func rangeOf(value map[string]interface{}) (Range, bool) {
+	if value["type"] != "range" {
+		return Range{}, false
+	}
+	minimum, _ := value["min"].(float64)
+	maximum, _ := value["max"].(float64)
+	return Range{Min: int(minimum), Max: int(maximum)}, true
+}
+
+// Header returns the comment lines at the top of a catalog file, kept when it is rewritten
+func Header(data []byte) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") && strings.TrimSpace(line) != "" {
+			break
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// Render writes the catalog in the layout of the checked-in file, one profile per line in name order, after a
+// header
+func (c *Catalog) Render(header string) []byte {
+	var sb strings.Builder
+	sb.WriteString(header)
+	fmt.Fprintf(&sb, "defaults:\n  max_data_volumes: %d\n\ninstance_profiles:\n", c.Defaults.MaxDataVolumes)
+	for _, name := range sortedKeys(c.InstanceProfiles) {
+		p := c.InstanceProfiles[name]
+		fmt.Fprintf(&sb, "  %s: {architecture: %s, vcpu: %d, memory: %d, bandwidth: %d, volume_bandwidth: %d, max_data_volumes: %d, sdp: %t}\n",
+			name, p.Architecture, p.VCPU, p.Memory, p.Bandwidth, p.VolumeBandwidth, p.MaxDataVolumes, p.SDP)
+	}
+	sb.WriteString("\nvolume_profiles:\n")
+	for _, name := range sortedKeys(c.VolumeProfiles) {
+		p := c.VolumeProfiles[name]
+		fields := []string{"family: " + p.Family}
+		if p.Generation != 0 {
+			fields = append(fields, fmt.Sprintf("generation: %d", p.Generation))
+		}
+		fields = append(fields, fmt.Sprintf("capacity: {min: %d, max: %d}", p.Capacity.Min, p.Capacity.Max))
+		if p.IOPS.Max != 0 {
+			fields = append(fields, fmt.Sprintf("iops: {min: %d, max: %d}", p.IOPS.Min, p.IOPS.Max))
+		}
+		if p.Bandwidth.Max != 0 {
+			fields = append(fields, fmt.Sprintf("bandwidth: {min: %d, max: %d}", p.Bandwidth.Min, p.Bandwidth.Max))
+		}
+		if p.IOPSPerGB != 0 {
+			fields = append(fields, fmt.Sprintf("iops_per_gb: %d, max_iops: %d", p.IOPSPerGB, p.MaxIOPS))
+		}
+		fmt.Fprintf(&sb, "  %s: {%s}\n", name, strings.Join(fields, ", "))
+	}
+	return []byte(sb.String())
+}
@@ -0,0 +1,203 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"gopkg.in/yaml.v3"
+)
+
+// LimitsPath is the limits table, relative to the root of the module
+const LimitsPath = "tests/quota-limits.yaml"
+
+// VPCVersion is the API version the provider requests
+const VPCVersion = "2025-01-01"
+
+// ResourceControllerURL is the endpoint of the resource controller API
+const ResourceControllerURL = "https://resource-controller.cloud.ibm.com"
+
+// SecretsManagerTrialPlanID is the resource plan of the Secrets Manager trial instances, one per account
+const SecretsManagerTrialPlanID = "869c191a-3c2a-4faf-98be-18d48f95ba1f"
+
+// vpcCollections are the VPC API collections counted for each metric of a region
+var vpcCollections = map[Metric]string{
+	VPCs:           "/vpcs",
+	FloatingIPs:    "/floating_ips",
+	LoadBalancers:  "/load_balancers",
+	Instances:      "/instances",
+	PublicGateways: "/public_gateways",
+}
+
+// Limits is the limit of each metric: per region for the VPC metrics, for the whole account for the Secrets Manager
+// trial instances. The VPC API does not return the quotas, the raised ones of the account are kept in
+// quota-limits.yaml.
+type Limits map[Metric]int
+
+// LoadLimits reads the limits table
+func LoadLimits(path string) (Limits, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading quota limits %s: %w", path, err)
+	}
+	limits := Limits{}
+	if err := yaml.Unmarshal(data, &limits); err != nil {
+		return nil, fmt.Errorf("error parsing quota limits %s: %w", path, err)
+	}
+	for metric := range limits {
+		if _, ok := vpcCollections[metric]; !ok && metric != SecretsManagerTrials {
+			return nil, fmt.Errorf("%s: unknown quota %s", path, metric)
+		}
+	}
+	return limits, nil
+}
+
+// APIProvider counts the resources of a region with the VPC API, and the Secrets Manager trial instances of the
+// account with the resource controller API
+type APIProvider struct {
+	limits             Limits
+	authenticator      core.Authenticator
+	vpcs               map[string]*core.BaseService
+	resourceController *core.BaseService
+	install            func(*core.BaseService)
+}
+
+// NewAPIProvider returns a provider of the limits authenticated with an API key
+func NewAPIProvider(apiKey string, limits Limits) (*APIProvider, error) {
+	authenticator := &core.IamAuthenticator{ApiKey: apiKey}
+	resourceController, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           ResourceControllerURL,
+		Authenticator: authenticator,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &APIProvider{
+		limits:             limits,
+		authenticator:      authenticator,
+		vpcs:               map[string]*core.BaseService{},
+		resourceController: resourceController,
+	}, nil
+}
+
+// VPCService returns the VPC service of a region, to change its endpoint or install a recording transport
+func (p *APIProvider) VPCService(region string) (*core.BaseService, error) {
+	if service, ok := p.vpcs[region]; ok {
+		return service, nil
+	}
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           fmt.Sprintf("https://%s.iaas.cloud.ibm.com/v1", region),
+		Authenticator: p.authenticator,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.install != nil {
+		p.install(service)
+	}
+	p.vpcs[region] = service
+	return service, nil
+}
+
+// InstallTransport calls install on the resource controller service and on the VPC service of each region, created
+// on the first read of the region, to install a recording or replaying transport
+func (p *APIProvider) InstallTransport(install func(*core.BaseService)) {
+	p.install = install
+	install(p.resourceController)
+	for _, service := range p.vpcs {
+		install(service)
+	}
+}
+
+// ResourceControllerService returns the resource controller service, to change its endpoint
+func (p *APIProvider) ResourceControllerService() *core.BaseService {
+	return p.resourceController
+}
+
+// Usage counts the resources of each limited metric in the region
+func (p *APIProvider) Usage(ctx context.Context, region string) (Usage, error) {
+	usage := Usage{}
+	for metric, limit := range p.limits {
+		var used int
+		var err error
+		if metric == SecretsManagerTrials {
+			used, err = p.secretsManagerTrials(ctx)
+		} else {
+			used, err = p.vpcCount(ctx, region, vpcCollections[metric])
+		}
+		if err != nil {
+			return nil, err
+		}
+		usage[metric] = Quota{Used: used, Limit: limit}
+	}
+	return usage, nil
+}
+
+// vpcCount returns the total count of a VPC API collection of the region
+func (p *APIProvider) vpcCount(ctx context.Context, region string, path string) (int, error) {
+	service, err := p.VPCService(region)
+	if err != nil {
+		return 0, err
+	}
+	builder := core.NewRequestBuilder(core.GET).WithContext(ctx)
+	if _, err := builder.ResolveRequestURL(service.GetServiceURL(), path, nil); err != nil {
+		return 0, err
+	}
+	builder.AddHeader("Accept", "application/json")
+	builder.AddQuery("version", VPCVersion)
+	builder.AddQuery("generation", "2")
+	builder.AddQuery("limit", "1")
+	request, err := builder.Build()
+	if err != nil {
+		return 0, err
+	}
+	var result struct {
+		TotalCount int `json:"total_count"`
+	}
+	if _, err := service.Request(request, &result); err != nil {
+		return 0, fmt.Errorf("GET %s in %s: %w", path, region, err)
+	}
+	return result.TotalCount, nil
+}
+
+// secretsManagerTrials counts the active Secrets Manager trial instances of the account. The resource controller
+// does not return a total, the pages are followed with their next_url.
+func (p *APIProvider) secretsManagerTrials(ctx context.Context) (int, error) {
+	query := map[string]string{"resource_plan_id": SecretsManagerTrialPlanID, "state": "active", "limit": "100"}
+	count := 0
+	for {
+		builder := core.NewRequestBuilder(core.GET).WithContext(ctx)
+		if _, err := builder.ResolveRequestURL(p.resourceController.GetServiceURL(), "/v2/resource_instances", nil); err != nil {
+			return 0, err
+		}
+		builder.AddHeader("Accept", "application/json")
+		for name, value := range query {
+			builder.AddQuery(name, value)
+		}
+		request, err := builder.Build()
+		if err != nil {
+			return 0, err
+		}
+		var result struct {
+			RowsCount int     `json:"rows_count"`
+			NextURL   *string `json:"next_url"`
+		}
+		if _, err := p.resourceController.Request(request, &result); err != nil {
+			return 0, fmt.Errorf("GET /v2/resource_instances: %w", err)
+		}
+		count += result.RowsCount
+		if result.NextURL == nil || *result.NextURL == "" {
+			return count, nil
+		}
+		next, err := url.Parse(*result.NextURL)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing next_url %s: %w", *result.NextURL, err)
+		}
+		query = map[string]string{}
+		for name, values := range next.Query() {
+			query[name] = values[0]
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package quota estimates the account quota each example or solution of a test run consumes, from its plan fixture,
+// and compares the totals with the current usage and limits of the account. Tests that can never fit are skipped,
+// and tests that do not fit together wait for each other instead of failing deep into an apply on a VPC, floating
+// IP, load balancer or Secrets Manager trial limit.
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+// Metric is an account quota
+type Metric string
+
+const (
+	VPCs                 Metric = "vpcs"
+	FloatingIPs          Metric = "floating_ips"
+	LoadBalancers        Metric = "load_balancers"
+	Instances            Metric = "instances"
+	PublicGateways       Metric = "public_gateways"
+	SecretsManagerTrials Metric = "secrets_manager_trial_instances"
+)
+
+// resourceMetrics are the resource types counted against a quota
+var resourceMetrics = map[string]Metric{
+	"ibm_is_vpc":            VPCs,
+	"ibm_is_floating_ip":    FloatingIPs,
+	"ibm_is_lb":             LoadBalancers,
+	"ibm_is_instance":       Instances,
+	"ibm_is_public_gateway": PublicGateways,
+}
+
+// Demand is the number of resources of each metric a test creates
+type Demand map[Metric]int
+
+// Add returns the sum of two demands
+func (d Demand) Add(other Demand) Demand {
+	sum := Demand{}
+	for metric, count := range d {
+		sum[metric] += count
+	}
+	for metric, count := range other {
+		sum[metric] += count
+	}
+	return sum
+}
+
+func (d Demand) String() string {
+	metrics := make([]string, 0, len(d))
+	for metric, count := range d {
+		if count > 0 {
+			metrics = append(metrics, fmt.Sprintf("%s=%d", metric, count))
+		}
+	}
+	sort.Strings(metrics)
+	return strings.Join(metrics, " ")
+}
+
+// Quota is the usage and limit of a metric in the account
+type Quota struct {
+	Used  int `yaml:"used"`
+	Limit int `yaml:"limit"`
+}
+
+// Usage is the quota of each metric. A metric without a quota is not limited.
+type Usage map[Metric]Quota
+
+// Provider returns the current usage and limits of a region, the account-wide quotas included
+type Provider interface {
+	Usage(ctx context.Context, region string) (Usage, error)
+}
+
+// PlanDemand counts the resources of a plan that consume a quota: the ones it creates, and the ones already in its
+// prior state (the fixtures are planned after applying the VPC of the example)
+func PlanDemand(plan *tfjson.Plan) Demand {
+	demand := Demand{}
+	count := func(resourceType string, values interface{}) {
+		if metric, ok := resourceMetrics[resourceType]; ok {
+			demand[metric]++
+		}
+		if resourceType == "ibm_resource_instance" && tfplan.String(values, "service") == "secrets-manager" && tfplan.String(values, "plan") == "trial" {
+			demand[SecretsManagerTrials]++
+		}
+	}
+	for _, change := range plan.ResourceChanges {
+		// a replaced resource counts too, the new one may be created before the old one is destroyed
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil || !change.Change.Actions.Create() && !change.Change.Actions.Replace() {
+			continue
+		}
+		count(change.Type, change.Change.After)
+	}
+	for _, resource := range tfplan.PriorResources(plan) {
+		if resource.Mode == tfjson.ManagedResourceMode {
+			count(resource.Type, resource.AttributeValues)
+		}
+	}
+	return demand
+}
+
+// LoadDemand reads a plan fixture and returns its demand
+func LoadDemand(path string) (Demand, error) {
+	plan, err := tfplan.LoadPlan(path)
+	if err != nil {
+		return nil, err
+	}
+	return PlanDemand(plan), nil
+}
+
+// exceeded returns the metrics for which the demand does not fit in the headroom (limit minus used minus reserved)
+func exceeded(usage Usage, reserved Demand, demand Demand) []string {
+	var problems []string
+	for metric, count := range demand {
+		quota, limited := usage[metric]
+		if !limited || count == 0 {
+			continue
+		}
+		if headroom := quota.Limit - quota.Used - reserved[metric]; count > headroom {
+			problems = append(problems, fmt.Sprintf("%s needs %d, %d available", metric, count, max(headroom, 0)))
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// Test is a test of the run and the resources it needs
+type Test struct {
+	Name   string
+	Demand Demand
+}
+
+// Skip is a test that does not fit in the quotas even alone
+type Skip struct {
+	Name   string
+	Reason string
+}
+
+// Schedule groups the tests of a run in batches that fit in the quotas: the tests of a batch can run in parallel,
+// the batches run one after the other
+type Schedule struct {
+	Batches [][]string
+	Skipped []Skip
+}
+
+// Plan schedules the tests, in order, in the first batch they fit in
+func Plan(tests []Test, usage Usage) *Schedule {
+	schedule := &Schedule{}
+	var reserved []Demand
+	for _, test := range tests {
+		if problems := exceeded(usage, nil, test.Demand); len(problems) > 0 {
+			schedule.Skipped = append(schedule.Skipped, Skip{Name: test.Name, Reason: strings.Join(problems, ", ")})
+			continue
+		}
+		placed := false
+		for i := range schedule.Batches {
+			if len(exceeded(usage, reserved[i], test.Demand)) == 0 {
+				schedule.Batches[i] = append(schedule.Batches[i], test.Name)
+				reserved[i] = reserved[i].Add(test.Demand)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			schedule.Batches = append(schedule.Batches, []string{test.Name})
+			reserved = append(reserved, Demand{}.Add(test.Demand))
+		}
+	}
+	return schedule
+}
+
+func (s *Schedule) String() string {
+	var sb strings.Builder
+	for i, batch := range s.Batches {
+		fmt.Fprintf(&sb, "batch %d: %s\n", i+1, strings.Join(batch, ", "))
+	}
+	for _, skip := range s.Skipped {
+		fmt.Fprintf(&sb, "skipped %s: %s\n", skip.Name, skip.Reason)
+	}
+	return sb.String()
+}
+
+// accountMetrics are limited over the whole account, the other metrics in each region
+var accountMetrics = map[Metric]bool{SecretsManagerTrials: true}
+
+// Gate admits the tests of a run while their combined demand fits in the quotas of their region. A test that does
+// not fit waits for running tests to release their resources. The usage of a region is read from the provider when
+// the first test of the region arrives.
+type Gate struct {
+	provider Provider
+	mu       sync.Mutex
+	cond     *sync.Cond
+	usage    map[string]Usage
+	reserved map[string]Demand
+}
+
+// NewGate returns a gate over the usage of the provider
+func NewGate(provider Provider) *Gate {
+	gate := &Gate{provider: provider, usage: map[string]Usage{}, reserved: map[string]Demand{}}
+	gate.cond = sync.NewCond(&gate.mu)
+	return gate
+}
+
+// reservedIn returns the demand reserved against the quotas of a region: its own tests for the regional metrics,
+// the tests of every region for the account metrics. The caller holds the lock.
+func (g *Gate) reservedIn(region string) Demand {
+	reserved := Demand{}
+	for reservedRegion, demand := range g.reserved {
+		for metric, count := range demand {
+			if reservedRegion == region || accountMetrics[metric] {
+				reserved[metric] += count
+			}
+		}
+	}
+	return reserved
+}
+
+// ErrDoesNotFit is returned by Acquire for a demand over the limits of the region even with no other test running
+var ErrDoesNotFit = errors.New("does not fit in the account quotas")
+
+// Acquire waits until the demand fits in the region and reserves it. It returns an error without waiting if the
+// demand can never fit (ErrDoesNotFit), the test should then be skipped, or if the usage of the region cannot be read.
+func (g *Gate) Acquire(ctx context.Context, name string, region string, demand Demand) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	usage, ok := g.usage[region]
+	if !ok {
+		var err error
+		if usage, err = g.provider.Usage(ctx, region); err != nil {
+			return fmt.Errorf("error reading the quota usage of %s: %w", region, err)
+		}
+		g.usage[region] = usage
+	}
+	if problems := exceeded(usage, nil, demand); len(problems) > 0 {
+		return fmt.Errorf("%s %w of %s: %s", name, ErrDoesNotFit, region, strings.Join(problems, ", "))
+	}
+	for len(exceeded(usage, g.reservedIn(region), demand)) > 0 {
+		g.cond.Wait()
+	}
+	g.reserved[region] = g.reserved[region].Add(demand)
+	return nil
+}
+
+// Release returns the demand of a finished test
+func (g *Gate) Release(region string, demand Demand) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for metric, count := range demand {
+		g.reserved[region][metric] -= count
+	}
+	g.cond.Broadcast()
+}
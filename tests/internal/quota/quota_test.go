@@ -0,0 +1,212 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/httpreplay"
+)
+
+// fakeProvider returns a fixed usage for each region
+type fakeProvider struct {
+	usage map[string]Usage
+	err   error
+}
+
+func (p fakeProvider) Usage(ctx context.Context, region string) (Usage, error) {
+	return p.usage[region], p.err
+}
+
+func fixtureDemand(t *testing.T, name string) Demand {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	demand, err := LoadDemand(fixtures.PlanPath(root, name))
+	require.NoError(t, err)
+	return demand
+}
+
+func TestPlanDemand(t *testing.T) {
+	assert.Equal(t, "floating_ips=6 instances=3 load_balancers=2 vpcs=1", fixtureDemand(t, "complete").String())
+	assert.Equal(t, "floating_ips=1 instances=1 public_gateways=1 vpcs=1", fixtureDemand(t, "quickstart").String())
+}
+
+func TestPlan(t *testing.T) {
+	provider := fakeProvider{usage: map[string]Usage{"us-south": {
+		VPCs:          {Used: 8, Limit: 10},
+		FloatingIPs:   {Used: 10, Limit: 20},
+		LoadBalancers: {Used: 45, Limit: 50},
+	}}}
+	usage, err := provider.Usage(context.Background(), "us-south")
+	require.NoError(t, err)
+
+	tests := []Test{
+		{Name: "TestRunCompleteExample", Demand: fixtureDemand(t, "complete")},
+		{Name: "TestRunMultiProfileExample", Demand: fixtureDemand(t, "multi-profile-one-vpc")},
+		{Name: "TestRunBasicExample", Demand: fixtureDemand(t, "basic")},
+		{Name: "TestQuickstart", Demand: fixtureDemand(t, "quickstart")},
+		{Name: "TestAnotherComplete", Demand: fixtureDemand(t, "complete")},
+	}
+	schedule := Plan(tests, usage)
+	assert.Equal(t, [][]string{
+		{"TestRunCompleteExample", "TestRunBasicExample"},
+		{"TestQuickstart", "TestAnotherComplete"},
+	}, schedule.Batches)
+	assert.Equal(t, `batch 1: TestRunCompleteExample, TestRunBasicExample
+batch 2: TestQuickstart, TestAnotherComplete
+skipped TestRunMultiProfileExample: floating_ips needs 12, 10 available
+`, schedule.String())
+}
+
+func TestGate(t *testing.T) {
+	ctx := context.Background()
+	gate := NewGate(fakeProvider{usage: map[string]Usage{
+		"us-south": {VPCs: {Used: 1, Limit: 3}, SecretsManagerTrials: {Used: 0, Limit: 1}},
+		"eu-de":    {VPCs: {Used: 0, Limit: 3}, SecretsManagerTrials: {Used: 0, Limit: 1}},
+	}})
+	two := Demand{VPCs: 2}
+
+	err := gate.Acquire(ctx, "too-big", "us-south", Demand{VPCs: 3})
+	assert.EqualError(t, err, "too-big does not fit in the account quotas of us-south: vpcs needs 3, 2 available")
+	assert.ErrorIs(t, err, ErrDoesNotFit)
+	require.NoError(t, gate.Acquire(ctx, "first", "us-south", two))
+	// the VPCs of another region are not held back by us-south
+	require.NoError(t, gate.Acquire(ctx, "other-region", "eu-de", Demand{VPCs: 3}))
+
+	acquired := make(chan struct{})
+	go func() {
+		assert.NoError(t, gate.Acquire(ctx, "second", "us-south", two))
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second test started while the first one holds the quota")
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate.Release("us-south", two)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second test did not start after the first one released the quota")
+	}
+
+	// metrics without a quota are not limited
+	assert.NoError(t, gate.Acquire(ctx, "unlimited", "us-south", Demand{FloatingIPs: 100}))
+
+	// the Secrets Manager trial is limited over the account: a trial reserved in eu-de holds back one in us-south
+	trial := Demand{SecretsManagerTrials: 1}
+	require.NoError(t, gate.Acquire(ctx, "trial", "eu-de", trial))
+	acquired = make(chan struct{})
+	go func() {
+		assert.NoError(t, gate.Acquire(ctx, "another-trial", "us-south", trial))
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second trial started while the first one holds the account quota")
+	case <-time.After(50 * time.Millisecond):
+	}
+	gate.Release("eu-de", trial)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second trial did not start after the first one released the account quota")
+	}
+
+	gate = NewGate(fakeProvider{err: errors.New("unauthorized")})
+	err = gate.Acquire(ctx, "first", "us-south", two)
+	assert.EqualError(t, err, "error reading the quota usage of us-south: unauthorized")
+	assert.NotErrorIs(t, err, ErrDoesNotFit)
+}
+
+func TestAPIProvider(t *testing.T) {
+	counts := map[string]int{"/vpcs": 7, "/floating_ips": 30, "/load_balancers": 2}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if path, ok := strings.CutPrefix(r.URL.Path, "/us-south/v1"); ok {
+			count, ok := counts[path]
+			if !ok || r.URL.Query().Get("version") == "" || r.URL.Query().Get("limit") != "1" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"total_count": count}))
+			return
+		}
+		// two pages of trial instances
+		assert.Equal(t, "/v2/resource_instances", r.URL.Path)
+		assert.Equal(t, SecretsManagerTrialPlanID, r.URL.Query().Get("resource_plan_id"))
+		if r.URL.Query().Get("start") == "" {
+			assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"rows_count": 1, "next_url": "/v2/resource_instances?resource_plan_id=" + SecretsManagerTrialPlanID + "&start=page2"}))
+			return
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"rows_count": 1, "next_url": nil}))
+	}))
+	defer server.Close()
+
+	newProvider := func(transport *httpreplay.Transport) *APIProvider {
+		limits, err := LoadLimits("testdata/limits.yaml")
+		require.NoError(t, err)
+		provider, err := NewAPIProvider("key", limits)
+		require.NoError(t, err)
+		provider.authenticator = &core.NoAuthAuthenticator{}
+		service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/us-south/v1", Authenticator: provider.authenticator})
+		require.NoError(t, err)
+		provider.vpcs["us-south"] = service
+		provider.resourceController, err = core.NewBaseService(&core.ServiceOptions{URL: server.URL, Authenticator: provider.authenticator})
+		require.NoError(t, err)
+		provider.InstallTransport(transport.Install)
+		return provider
+	}
+	expected := Usage{
+		VPCs:                 {Used: 7, Limit: 10},
+		FloatingIPs:          {Used: 30, Limit: 120},
+		LoadBalancers:        {Used: 2, Limit: 50},
+		SecretsManagerTrials: {Used: 2, Limit: 1},
+	}
+
+	cassette := filepath.Join(t.TempDir(), "quota-usage.json")
+	recorder, err := httpreplay.New(httpreplay.Record, cassette)
+	require.NoError(t, err)
+	usage, err := newProvider(recorder).Usage(context.Background(), "us-south")
+	require.NoError(t, err)
+	assert.Equal(t, expected, usage)
+	require.NoError(t, recorder.Save())
+
+	delete(counts, "/load_balancers")
+	_, err = newProvider(recorder).Usage(context.Background(), "us-south")
+	assert.ErrorContains(t, err, "GET /load_balancers in us-south")
+
+	// the usage read before the server lost the load balancers is replayed from the cassette
+	server.Close()
+	player, err := httpreplay.New(httpreplay.Replay, cassette)
+	require.NoError(t, err)
+	usage, err = newProvider(player).Usage(context.Background(), "us-south")
+	require.NoError(t, err)
+	assert.Equal(t, expected, usage)
+	assert.Empty(t, player.Unused())
+}
+
+func TestLimits(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	_, err = LoadLimits(filepath.Join(root, LimitsPath))
+	assert.NoError(t, err)
+
+	_, err = LoadLimits("testdata/unknown-limits.yaml")
+	assert.ErrorContains(t, err, "unknown quota instance_count")
+}
+
+func TestSecretsManagerTrial(t *testing.T) {
+	demand, err := LoadDemand(filepath.Join("testdata", "plan_sm_trial.json"))
+	require.NoError(t, err)
+	assert.Equal(t, Demand{SecretsManagerTrials: 1}, demand)
+}
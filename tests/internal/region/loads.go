@@ -0,0 +1,72 @@
+package region
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+)
+
+// VPCVersion is the API version the load provider requests
+const VPCVersion = "2025-01-01"
+
+// VPCLoads counts the VPCs of the account in each region with the VPC REST API, the load testhelper.GetBestVpcRegion
+// ranks the regions by
+type VPCLoads struct {
+	services map[string]*core.BaseService
+}
+
+// NewVPCLoads returns a load provider of the regions authenticated with an API key
+func NewVPCLoads(apiKey string, regions []string) (*VPCLoads, error) {
+	loads := &VPCLoads{services: map[string]*core.BaseService{}}
+	for _, region := range regions {
+		service, err := core.NewBaseService(&core.ServiceOptions{
+			URL:           fmt.Sprintf("https://%s.iaas.cloud.ibm.com/v1", region),
+			Authenticator: &core.IamAuthenticator{ApiKey: apiKey},
+		})
+		if err != nil {
+			return nil, err
+		}
+		loads.services[region] = service
+	}
+	return loads, nil
+}
+
+// Service returns the underlying service of a region, to change its endpoint or install a recording transport
+func (p *VPCLoads) Service(region string) *core.BaseService {
+	return p.services[region]
+}
+
+// Loads returns the number of VPCs of each region
+func (p *VPCLoads) Loads(ctx context.Context) (map[string]int, error) {
+	regions := make([]string, 0, len(p.services))
+	for region := range p.services {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	loads := map[string]int{}
+	for _, region := range regions {
+		service := p.services[region]
+		builder := core.NewRequestBuilder(core.GET).WithContext(ctx)
+		if _, err := builder.ResolveRequestURL(service.GetServiceURL(), "/vpcs", nil); err != nil {
+			return nil, err
+		}
+		builder.AddHeader("Accept", "application/json")
+		builder.AddQuery("version", VPCVersion)
+		builder.AddQuery("generation", "2")
+		builder.AddQuery("limit", "1")
+		request, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			TotalCount int `json:"total_count"`
+		}
+		if _, err := service.Request(request, &result); err != nil {
+			return nil, fmt.Errorf("GET %s in %s: %w", request.URL.Path, region, err)
+		}
+		loads[region] = result.TotalCount
+	}
+	return loads, nil
+}
@@ -0,0 +1,248 @@
+// Package region places tests in a VPC region that has the capabilities they need (second generation boot
+// volumes, `network-fixed` network load balancers, dedicated hosts, catalog images) according to the checked-in
+// capability table (tests/region-capabilities.yaml), picking the least loaded compliant region and logging why. The
+// regions and their priority are the ones of the region preferences shared with testhelper.GetBestVpcRegion.
+package region
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TablePath is the path of the capability table, relative to the root of the module
+const TablePath = "tests/region-capabilities.yaml"
+
+// Capabilities of the capability table
+const (
+	SDPBoot         = "sdp-boot"
+	NLBNetworkFixed = "nlb-network-fixed"
+	DedicatedHosts  = "dedicated-hosts"
+	CatalogImages   = "catalog-images"
+)
+
+// PrefsPath is the path of the region preferences of common-dev-assets, relative to the root of the module
+const PrefsPath = "common-dev-assets/common-go-assets/cloudinfo-region-vpc-gen2-prefs.yaml"
+
+// Table is the region preferences with the capabilities of each region
+type Table struct {
+	Regions map[string]Region
+}
+
+// Region is a region of the preferences
+type Region struct {
+	Priority     int
+	UseForTest   bool
+	Capabilities []string
+}
+
+// preference is an entry of the region preferences
+type preference struct {
+	Name         string `yaml:"name"`
+	UseForTest   bool   `yaml:"useForTest"`
+	TestPriority int    `yaml:"testPriority"`
+}
+
+// Requirements are what a test needs from its region
+type Requirements struct {
+	Test         string
+	Capabilities []string
+	// Regions restricts the selection, for tests pinned to a region (an image or permanent resource only there)
+	Regions []string
+}
+
+// LoadProvider returns the current load of each region, for example the number of VPCs in the account
+type LoadProvider interface {
+	Loads(ctx context.Context) (map[string]int, error)
+}
+
+// Logger is the part of testing.T the selector logs to
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// LoadTable reads the region preferences and the capability table. A region of the capability table must be in the
+// preferences, the table does not add regions.
+func LoadTable(path string, prefsPath string) (*Table, error) {
+	data, err := os.ReadFile(prefsPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading region preferences %s: %w", prefsPath, err)
+	}
+	var prefs []preference
+	if err := yaml.Unmarshal(data, &prefs); err != nil {
+		return nil, fmt.Errorf("error parsing region preferences %s: %w", prefsPath, err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading region capabilities %s: %w", path, err)
+	}
+	var capabilities struct {
+		Regions map[string][]string `yaml:"regions"`
+	}
+	if err := yaml.Unmarshal(data, &capabilities); err != nil {
+		return nil, fmt.Errorf("error parsing region capabilities %s: %w", path, err)
+	}
+
+	table := &Table{Regions: map[string]Region{}}
+	for _, pref := range prefs {
+		table.Regions[pref.Name] = Region{Priority: pref.TestPriority, UseForTest: pref.UseForTest, Capabilities: capabilities.Regions[pref.Name]}
+	}
+	for name := range capabilities.Regions {
+		if _, ok := table.Regions[name]; !ok {
+			return nil, fmt.Errorf("%s: region %s is not in the region preferences %s", path, name, prefsPath)
+		}
+	}
+	return table, nil
+}
+
+// Has returns true if the region has the capability
+func (r Region) Has(capability string) bool {
+	for _, c := range r.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidates returns the regions usable for tests that meet the requirements, sorted by name
+func (t *Table) Candidates(requirements Requirements) []string {
+	var candidates []string
+	for name, region := range t.Regions {
+		if !region.UseForTest {
+			continue
+		}
+		if len(requirements.Regions) > 0 && !contains(requirements.Regions, name) {
+			continue
+		}
+		compliant := true
+		for _, capability := range requirements.Capabilities {
+			if !region.Has(capability) {
+				compliant = false
+				break
+			}
+		}
+		if compliant {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// Selector picks a region for each test of a run. The regions it picked count as load, so parallel tests with the
+// same requirements spread over the compliant regions.
+type Selector struct {
+	table    *Table
+	provider LoadProvider
+	mu       sync.Mutex
+	loads    map[string]int
+	assigned map[string]int
+}
+
+// NewSelector returns a selector over the table. provider may be nil, only the regions picked during the run count
+// as load then.
+func NewSelector(table *Table, provider LoadProvider) *Selector {
+	return &Selector{table: table, provider: provider, assigned: map[string]int{}}
+}
+
+// Select picks the least loaded region meeting the requirements (ties go to the lowest priority, then the name) and
+// logs the choice. When the loads cannot be read, the regions are picked in priority order, the way
+// testhelper.GetBestVpcRegion falls back to its default region.
+func (s *Selector) Select(ctx context.Context, logger Logger, requirements Requirements) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loads == nil && s.provider != nil {
+		loads, err := s.provider.Loads(ctx)
+		if err != nil {
+			if logger != nil {
+				logger.Logf("error reading the region loads, the regions are picked in priority order: %s", err)
+			}
+			loads = map[string]int{}
+		}
+		s.loads = loads
+	}
+	candidates := s.table.Candidates(requirements)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no region usable for tests has %s%s", describe(requirements), s.unknown(requirements))
+	}
+	load := func(name string) int {
+		return s.loads[name] + s.assigned[name]
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if load(candidates[i]) != load(candidates[j]) {
+			return load(candidates[i]) < load(candidates[j])
+		}
+		return s.table.Regions[candidates[i]].Priority < s.table.Regions[candidates[j]].Priority
+	})
+	selected := candidates[0]
+	if logger != nil {
+		var loads []string
+		for _, candidate := range candidates {
+			loads = append(loads, fmt.Sprintf("%s=%d", candidate, load(candidate)))
+		}
+		logger.Logf("region %s selected for %s (needs %s; load %s)", selected, requirements.Test, describe(requirements), strings.Join(loads, " "))
+	}
+	s.assigned[selected]++
+	return selected, nil
+}
+
+// Release returns a region picked for a test that finished, so it no longer counts as load
+func (s *Selector) Release(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.assigned[name] > 0 {
+		s.assigned[name]--
+	}
+}
+
+// describe renders the requirements for logs and errors
+func describe(requirements Requirements) string {
+	var parts []string
+	if len(requirements.Capabilities) > 0 {
+		parts = append(parts, strings.Join(requirements.Capabilities, ", "))
+	}
+	if len(requirements.Regions) > 0 {
+		parts = append(parts, "region in "+strings.Join(requirements.Regions, ", "))
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// unknown points out required capabilities no region of the table declares, usually a typo
+func (s *Selector) unknown(requirements Requirements) string {
+	var unknown []string
+	for _, capability := range requirements.Capabilities {
+		declared := false
+		for _, region := range s.table.Regions {
+			if region.Has(capability) {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			unknown = append(unknown, capability)
+		}
+	}
+	if len(unknown) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (no region declares %s)", strings.Join(unknown, ", "))
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package region
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+// fakeLoads returns fixed region loads
+type fakeLoads struct {
+	loads map[string]int
+	err   error
+	calls int
+}
+
+func (f *fakeLoads) Loads(ctx context.Context) (map[string]int, error) {
+	f.calls++
+	return f.loads, f.err
+}
+
+// logs collects the log lines of the selector
+type logs []string
+
+func (l *logs) Logf(format string, args ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, args...))
+}
+
+func loadTable(t *testing.T) *Table {
+	table, err := LoadTable("testdata/capabilities.yaml", "testdata/prefs.yaml")
+	require.NoError(t, err)
+	return table
+}
+
+func TestCandidates(t *testing.T) {
+	table := loadTable(t)
+	assert.Equal(t, []string{"au-syd", "eu-de", "us-south"}, table.Candidates(Requirements{}))
+	assert.Equal(t, []string{"eu-de", "us-south"}, table.Candidates(Requirements{Capabilities: []string{SDPBoot}}))
+	assert.Equal(t, []string{"eu-de"}, table.Candidates(Requirements{Capabilities: []string{SDPBoot, DedicatedHosts}}))
+	assert.Equal(t, []string{"au-syd"}, table.Candidates(Requirements{Regions: []string{"au-syd", "br-sao"}}))
+	assert.Empty(t, table.Candidates(Requirements{Capabilities: []string{CatalogImages, DedicatedHosts}}))
+}
+
+func TestSelectLeastLoaded(t *testing.T) {
+	provider := &fakeLoads{loads: map[string]int{"us-south": 5, "eu-de": 2, "au-syd": 0}}
+	selector := NewSelector(loadTable(t), provider)
+	var log logs
+
+	region, err := selector.Select(context.Background(), &log, Requirements{Test: "TestRunGen2BootExample", Capabilities: []string{SDPBoot}})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-de", region)
+	assert.Equal(t, logs{"region eu-de selected for TestRunGen2BootExample (needs sdp-boot; load eu-de=2 us-south=5)"}, log)
+
+	// picked regions count as load: eu-de is at 3, then 4, 5 and ties with us-south, which has the lower priority
+	for _, expected := range []string{"eu-de", "eu-de", "us-south", "eu-de"} {
+		region, err = selector.Select(context.Background(), nil, Requirements{Capabilities: []string{SDPBoot}})
+		require.NoError(t, err)
+		assert.Equal(t, expected, region)
+	}
+	assert.Equal(t, 1, provider.calls, "the loads are read once per run")
+
+	selector.Release("us-south")
+	selector.Release("us-south")
+	region, err = selector.Select(context.Background(), nil, Requirements{Regions: []string{"us-south", "au-syd"}})
+	require.NoError(t, err)
+	assert.Equal(t, "au-syd", region)
+}
+
+func TestSelectWithoutProvider(t *testing.T) {
+	selector := NewSelector(loadTable(t), nil)
+	var picked []string
+	for i := 0; i < 4; i++ {
+		region, err := selector.Select(context.Background(), nil, Requirements{Capabilities: []string{NLBNetworkFixed}})
+		require.NoError(t, err)
+		picked = append(picked, region)
+	}
+	assert.Equal(t, []string{"us-south", "eu-de", "au-syd", "us-south"}, picked)
+}
+
+func TestSelectErrors(t *testing.T) {
+	selector := NewSelector(loadTable(t), nil)
+	_, err := selector.Select(context.Background(), nil, Requirements{Capabilities: []string{CatalogImages, DedicatedHosts}})
+	assert.EqualError(t, err, "no region usable for tests has catalog-images, dedicated-hosts")
+	_, err = selector.Select(context.Background(), nil, Requirements{Capabilities: []string{"sdp_boot"}})
+	assert.EqualError(t, err, "no region usable for tests has sdp_boot (no region declares sdp_boot)")
+
+}
+
+// TestSelectWithoutLoads checks the regions are picked in priority order when the loads cannot be read, a pinned test
+// still getting its region
+func TestSelectWithoutLoads(t *testing.T) {
+	loads := &fakeLoads{err: errors.New("unauthorized")}
+	selector := NewSelector(loadTable(t), loads)
+	var log logs
+	region, err := selector.Select(context.Background(), &log, Requirements{Test: "TestRunBasicExample"})
+	require.NoError(t, err)
+	assert.Equal(t, "us-south", region)
+	assert.Equal(t, "error reading the region loads, the regions are picked in priority order: unauthorized", log[0])
+	region, err = selector.Select(context.Background(), nil, Requirements{Test: "TestRunExistingSnapshotGroupExample", Regions: []string{"au-syd"}})
+	require.NoError(t, err)
+	assert.Equal(t, "au-syd", region)
+	assert.Equal(t, 1, loads.calls)
+}
+
+func TestLoadTable(t *testing.T) {
+	table := loadTable(t)
+	assert.Equal(t, Region{Priority: 2, UseForTest: true, Capabilities: []string{SDPBoot, NLBNetworkFixed, DedicatedHosts}}, table.Regions["eu-de"])
+
+	_, err := LoadTable("testdata/unknown-region.yaml", "testdata/prefs.yaml")
+	assert.EqualError(t, err, "testdata/unknown-region.yaml: region jp-osa is not in the region preferences testdata/prefs.yaml")
+}
+
+func TestVPCLoads(t *testing.T) {
+	counts := map[string]int{"us-south": 7, "eu-de": 2, "au-syd": 4}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := r.URL.Path[1 : len(r.URL.Path)-len("/v1/vpcs")]
+		count, ok := counts[region]
+		if !ok || r.URL.Query().Get("version") == "" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"vpcs": []interface{}{}, "total_count": count}))
+	}))
+	defer server.Close()
+	provider := &VPCLoads{services: map[string]*core.BaseService{}}
+	for _, region := range loadTable(t).Candidates(Requirements{}) {
+		service, err := core.NewBaseService(&core.ServiceOptions{URL: server.URL + "/" + region + "/v1", Authenticator: &core.NoAuthAuthenticator{}})
+		require.NoError(t, err)
+		provider.services[region] = service
+	}
+
+	loads, err := provider.Loads(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, counts, loads)
+	region, err := NewSelector(loadTable(t), provider).Select(context.Background(), nil, Requirements{})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-de", region)
+
+	delete(counts, "au-syd")
+	_, err = provider.Loads(context.Background())
+	assert.ErrorContains(t, err, "GET /au-syd/v1/vpcs in au-syd")
+}
+
+// TestCapabilityTable checks the checked-in table covers the capabilities the tests need
+func TestCapabilityTable(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	if _, err := os.Stat(filepath.Join(root, PrefsPath)); errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("%s is missing, run git submodule update --init", PrefsPath)
+	}
+	table, err := LoadTable(filepath.Join(root, TablePath), filepath.Join(root, PrefsPath))
+	require.NoError(t, err)
+	for _, capability := range []string{SDPBoot, NLBNetworkFixed, DedicatedHosts, CatalogImages} {
+		assert.NotEmpty(t, table.Candidates(Requirements{Capabilities: []string{capability}}), capability)
+	}
+	assert.NotEmpty(t, table.Candidates(Requirements{Regions: []string{"au-syd"}}), "the snapshot test is pinned to au-syd")
+}
@@ -0,0 +1,216 @@
+// Package retry retries the Terraform runs of the harness whose error matches a curated table of IBM Cloud VPC
+// transient errors (tests/retry-policy.yaml), with an exponential backoff, logging each retry with the rule that
+// allowed it. Unlike the default retryable errors of terratest, the rules know the conflicts of the VPC API; they are
+// also given to terratest for the runs of the test wrapper, and match the errors of the logs of Schematics jobs.
+package retry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyPath is the path of the policy, relative to the root of the module
+const PolicyPath = "tests/retry-policy.yaml"
+
+// Rule retries the errors matching its pattern
+type Rule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Pattern     string `yaml:"pattern"`
+	// MaxRetries and InitialBackoff override the ones of the policy when set
+	MaxRetries     int           `yaml:"max_retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	pattern        *regexp.Regexp
+}
+
+// Policy is the content of the policy file
+type Policy struct {
+	MaxRetries     int           `yaml:"max_retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Rules          []*Rule       `yaml:"rules"`
+}
+
+// LoadPolicy reads the policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading retry policy %s: %w", path, err)
+	}
+	policy := &Policy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("error parsing retry policy %s: %w", path, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, fmt.Errorf("retry policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// Validate checks the backoff of the policy and compiles the patterns of its rules
+func (p *Policy) Validate() error {
+	var problems []string
+	if p.MaxRetries < 0 {
+		problems = append(problems, "max_retries can not be negative")
+	}
+	if p.InitialBackoff <= 0 || p.MaxBackoff < p.InitialBackoff {
+		problems = append(problems, "initial_backoff must be positive and at most max_backoff")
+	}
+	if p.Multiplier < 1 {
+		problems = append(problems, "multiplier must be at least 1")
+	}
+	names := map[string]bool{}
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			problems = append(problems, fmt.Sprintf("rule %d: no name", i))
+		} else if names[rule.Name] {
+			problems = append(problems, "rule "+rule.Name+": duplicate name")
+		}
+		names[rule.Name] = true
+		if rule.MaxRetries < 0 || rule.InitialBackoff < 0 {
+			problems = append(problems, "rule "+rule.Name+": max_retries and initial_backoff can not be negative")
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		switch {
+		case rule.Pattern == "":
+			problems = append(problems, "rule "+rule.Name+": no pattern")
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("rule %s: %v", rule.Name, err))
+		default:
+			rule.pattern = pattern
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid retry policy:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Match returns the first rule matching the output or the error of a failed run, nil if the error is not retryable
+func (p *Policy) Match(output string, err error) *Rule {
+	text := output
+	if err != nil {
+		text += "\n" + err.Error()
+	}
+	for _, rule := range p.Rules {
+		if rule.pattern != nil && rule.pattern.MatchString(text) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// MaxRetriesOf returns how many times the errors of a rule are retried
+func (p *Policy) MaxRetriesOf(rule *Rule) int {
+	if rule.MaxRetries > 0 {
+		return rule.MaxRetries
+	}
+	return p.MaxRetries
+}
+
+// Backoff returns how long to wait before the retry-th retry (from 1) of an error of a rule
+func (p *Policy) Backoff(rule *Rule, retry int) time.Duration {
+	initial := p.InitialBackoff
+	if rule.InitialBackoff > 0 {
+		initial = rule.InitialBackoff
+	}
+	backoff := float64(initial) * math.Pow(p.Multiplier, float64(retry-1))
+	if backoff > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// TerraformErrors returns the rules as the retryable errors of terratest (terraform.Options.RetryableTerraformErrors):
+// the pattern of each rule, with its name and description for the log of the retries
+func (p *Policy) TerraformErrors() map[string]string {
+	errors := map[string]string{}
+	for _, rule := range p.Rules {
+		errors[rule.Pattern] = rule.Name + ": " + rule.Description
+	}
+	return errors
+}
+
+// TerraformRetries returns the retries and the wait between them for terratest (terraform.Options.MaxRetries and
+// TimeBetweenRetries), which has one of each for all its retryable errors: the most retries of a rule and the longest
+// initial backoff, so no rule is retried fewer times or sooner than the policy allows
+func (p *Policy) TerraformRetries() (int, time.Duration) {
+	retries, backoff := p.MaxRetries, p.InitialBackoff
+	for _, rule := range p.Rules {
+		retries = max(retries, p.MaxRetriesOf(rule))
+		backoff = max(backoff, rule.InitialBackoff)
+	}
+	return retries, backoff
+}
+
+// Runner runs a function under a policy
+type Runner struct {
+	Policy *Policy
+	// Logf logs the retries, t.Logf in the tests
+	Logf func(format string, args ...interface{})
+	// Sleep waits between the attempts, replaced in the tests of the package
+	Sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRunner returns a runner logging with logf and sleeping for real
+func NewRunner(policy *Policy, logf func(format string, args ...interface{})) *Runner {
+	return &Runner{Policy: policy, Logf: logf, Sleep: sleep}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Run runs fn until it succeeds, fails with an error no rule matches, or exhausts the retries of the rule of its
+// error. name identifies the run in the log (`terraform apply (prereq)`). It returns the output and the error of the
+// last attempt.
+func (r *Runner) Run(ctx context.Context, name string, fn func() (string, error)) (string, error) {
+	output, err := fn()
+	return r.Retry(ctx, name, output, err, fn)
+}
+
+// Retry retries fn as Run does, from a first attempt that already returned output and err, such as a Schematics job
+// the test wrapper ran
+func (r *Runner) Retry(ctx context.Context, name string, output string, err error, fn func() (string, error)) (string, error) {
+	retries := map[string]int{}
+	for err != nil {
+		rule := r.Policy.Match(output, err)
+		if rule == nil {
+			return output, err
+		}
+		retries[rule.Name]++
+		retry, limit := retries[rule.Name], r.Policy.MaxRetriesOf(rule)
+		if retry > limit {
+			r.Logf("%s failed on rule %s (%s) after %d retries, giving up", name, rule.Name, rule.Description, limit)
+			return output, err
+		}
+		backoff := r.Policy.Backoff(rule, retry)
+		r.Logf("%s failed on rule %s (%s), retry %d/%d in %s", name, rule.Name, rule.Description, retry, limit, backoff)
+		if err := r.Sleep(ctx, backoff); err != nil {
+			return output, fmt.Errorf("%s: retry %d/%d on rule %s interrupted: %w", name, retry, limit, rule.Name, err)
+		}
+		output, err = fn()
+	}
+	return output, nil
+}
+
+// RunErr runs a function returning only an error
+func (r *Runner) RunErr(ctx context.Context, name string, fn func() error) error {
+	_, err := r.Run(ctx, name, func() (string, error) { return "", fn() })
+	return err
+}
@@ -0,0 +1,219 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+func repoPolicy(t *testing.T) *Policy {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	policy, err := LoadPolicy(filepath.Join(root, PolicyPath))
+	require.NoError(t, err)
+	return policy
+}
+
+// TestErrors matches the captured errors of testdata/errors, named <rule>--<case>.txt, with the rules of the policy of
+// the repo; the errors of none--<case>.txt must not be retried
+func TestErrors(t *testing.T) {
+	policy := repoPolicy(t)
+	paths, err := filepath.Glob(filepath.Join("testdata", "errors", "*.txt"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	matched := map[string]bool{}
+	for _, path := range paths {
+		expected, _, _ := strings.Cut(filepath.Base(path), "--")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		actual := "none"
+		if rule := policy.Match(string(data), errors.New("exit status 1")); rule != nil {
+			actual = rule.Name
+		}
+		assert.Equal(t, expected, actual, path)
+		matched[actual] = true
+	}
+	for _, rule := range policy.Rules {
+		assert.True(t, matched[rule.Name], "no captured error in testdata/errors for rule %s", rule.Name)
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	// the test wrapper returns the Schematics errors without their log
+	rule := repoPolicy(t).Match("", fmt.Errorf("error running apply job: %w", errors.New(`"StatusCode": 429, "code": "too_many_requests"`)))
+	require.NotNil(t, rule)
+	assert.Equal(t, "server-error", rule.Name)
+	assert.Nil(t, repoPolicy(t).Match("", nil))
+}
+
+func TestTerraformErrors(t *testing.T) {
+	policy := repoPolicy(t)
+	errs := policy.TerraformErrors()
+	require.Len(t, errs, len(policy.Rules))
+	assert.Equal(t, "provider-consistency: the provider read the resource before the API propagated the apply", errs["Provider produced inconsistent result after apply"])
+	retries, backoff := policy.TerraformRetries()
+	assert.Equal(t, 5, retries)
+	assert.Equal(t, time.Minute, backoff)
+}
+
+func TestBackoff(t *testing.T) {
+	policy := &Policy{InitialBackoff: 30 * time.Second, MaxBackoff: 5 * time.Minute, Multiplier: 2}
+	rule := &Rule{Name: "default"}
+	var backoffs []time.Duration
+	for retry := 1; retry <= 6; retry++ {
+		backoffs = append(backoffs, policy.Backoff(rule, retry))
+	}
+	assert.Equal(t, []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}, backoffs)
+	assert.Equal(t, 2*time.Minute, policy.Backoff(&Rule{InitialBackoff: time.Minute}, 2))
+}
+
+// recorder is a runner of the test policy recording its log and its sleeps instead of sleeping
+type recorder struct {
+	*Runner
+	log    []string
+	sleeps []time.Duration
+}
+
+func newRecorder(t *testing.T) *recorder {
+	policy := &Policy{
+		MaxRetries:     2,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Multiplier:     3,
+		Rules: []*Rule{
+			{Name: "busy", Description: "the thing is busy", Pattern: "busy", MaxRetries: 3, InitialBackoff: 10 * time.Second},
+			{Name: "reset", Description: "the connection was reset", Pattern: "connection reset"},
+		},
+	}
+	require.NoError(t, policy.Validate())
+	r := &recorder{}
+	r.Runner = &Runner{
+		Policy: policy,
+		Logf:   func(format string, args ...interface{}) { r.log = append(r.log, fmt.Sprintf(format, args...)) },
+		Sleep: func(ctx context.Context, d time.Duration) error {
+			r.sleeps = append(r.sleeps, d)
+			return ctx.Err()
+		},
+	}
+	return r
+}
+
+// attempts returns a function failing with the errors in turn, then succeeding
+func attempts(errs ...string) (fn func() (string, error), calls *int) {
+	calls = new(int)
+	return func() (string, error) {
+		*calls++
+		if *calls > len(errs) {
+			return "applied", nil
+		}
+		return "output of attempt " + fmt.Sprint(*calls), errors.New(errs[*calls-1])
+	}, calls
+}
+
+func TestRun(t *testing.T) {
+	t.Run("succeeds after retries", func(t *testing.T) {
+		r := newRecorder(t)
+		fn, calls := attempts("thing is busy", "connection reset by peer", "thing is busy")
+		output, err := r.Run(context.Background(), "terraform apply", fn)
+		require.NoError(t, err)
+		assert.Equal(t, "applied", output)
+		assert.Equal(t, 4, *calls)
+		// the retries are counted per rule
+		assert.Equal(t, []time.Duration{10 * time.Second, time.Second, 30 * time.Second}, r.sleeps)
+		assert.Equal(t, []string{
+			"terraform apply failed on rule busy (the thing is busy), retry 1/3 in 10s",
+			"terraform apply failed on rule reset (the connection was reset), retry 1/2 in 1s",
+			"terraform apply failed on rule busy (the thing is busy), retry 2/3 in 30s",
+		}, r.log)
+	})
+
+	t.Run("gives up after the retries of the rule", func(t *testing.T) {
+		r := newRecorder(t)
+		fn, calls := attempts("connection reset", "connection reset", "connection reset", "connection reset")
+		output, err := r.Run(context.Background(), "terraform apply", fn)
+		assert.EqualError(t, err, "connection reset")
+		assert.Equal(t, "output of attempt 3", output)
+		assert.Equal(t, 3, *calls)
+		assert.Equal(t, []time.Duration{time.Second, 3 * time.Second}, r.sleeps)
+		assert.Equal(t, "terraform apply failed on rule reset (the connection was reset) after 2 retries, giving up", r.log[len(r.log)-1])
+	})
+
+	t.Run("does not retry the other errors", func(t *testing.T) {
+		r := newRecorder(t)
+		fn, calls := attempts("Invalid index")
+		_, err := r.Run(context.Background(), "terraform apply", fn)
+		assert.EqualError(t, err, "Invalid index")
+		assert.Equal(t, 1, *calls)
+		assert.Empty(t, r.log)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		r := newRecorder(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		fn, calls := attempts("busy")
+		_, err := r.Run(ctx, "terraform destroy", fn)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.EqualError(t, err, "terraform destroy: retry 1/3 on rule busy interrupted: context canceled")
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("retries from a failed attempt", func(t *testing.T) {
+		r := newRecorder(t)
+		fn, calls := attempts("connection reset")
+		output, err := r.Retry(context.Background(), "Schematics apply job", "log of the job", errors.New("thing is busy"), fn)
+		require.NoError(t, err)
+		assert.Equal(t, "applied", output)
+		assert.Equal(t, 2, *calls)
+		assert.Equal(t, []time.Duration{10 * time.Second, time.Second}, r.sleeps)
+
+		r = newRecorder(t)
+		fn, calls = attempts()
+		_, err = r.Retry(context.Background(), "Schematics apply job", "Invalid index", errors.New("job failed"), fn)
+		assert.EqualError(t, err, "job failed")
+		assert.Equal(t, 0, *calls)
+	})
+
+	t.Run("retries the functions returning an error", func(t *testing.T) {
+		r := newRecorder(t)
+		fn, calls := attempts("busy")
+		require.NoError(t, r.RunErr(context.Background(), "schematics test", func() error {
+			_, err := fn()
+			return err
+		}))
+		assert.Equal(t, 2, *calls)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	policy := &Policy{
+		MaxRetries:     -1,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     time.Second,
+		Multiplier:     0.5,
+		Rules: []*Rule{
+			{Name: "a", Pattern: "("},
+			{Name: "a", MaxRetries: -1},
+			{},
+		},
+	}
+	assert.EqualError(t, policy.Validate(), "invalid retry policy:\n"+
+		"  max_retries can not be negative\n"+
+		"  initial_backoff must be positive and at most max_backoff\n"+
+		"  multiplier must be at least 1\n"+
+		"  rule a: error parsing regexp: missing closing ): `(`\n"+
+		"  rule a: duplicate name\n"+
+		"  rule a: max_retries and initial_backoff can not be negative\n"+
+		"  rule a: no pattern\n"+
+		"  rule 2: no name\n"+
+		"  rule : no pattern")
+}
@@ -0,0 +1,283 @@
+// Package schematics reads the jobs of the Schematics workspace of a test run by the test wrapper: the plan of its last
+// plan job, for internal/upgradegate to gate an upgrade, and the log of its last failed job, for internal/failures to
+// classify, since the wrapper only returns a short error. It also runs the plan and apply jobs of a workspace again,
+// for internal/retry to retry a job that failed on a transient error.
+package schematics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Locations are the geographies of the Schematics endpoints the test wrapper creates its workspaces in
+var Locations = []string{"us", "eu"}
+
+// Job names and statuses of the workspace jobs
+const (
+	Plan      = "PLAN"
+	Apply     = "APPLY"
+	Destroy   = "DESTROY"
+	Failed    = "FAILED"
+	Completed = "COMPLETED"
+	Pending   = "PENDING"
+	Running   = "INPROGRESS"
+)
+
+// ErrNotFound is wrapped by the errors of the requests the endpoint answered with a 404
+var ErrNotFound = errors.New("not found")
+
+// Workspace is a Schematics workspace
+type Workspace struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	TemplateData []struct {
+		ID string `json:"id"`
+	} `json:"template_data"`
+}
+
+// TemplateID returns the ID of the Terraform template of the workspace, "" if it has none
+func (w *Workspace) TemplateID() string {
+	if len(w.TemplateData) == 0 {
+		return ""
+	}
+	return w.TemplateData[0].ID
+}
+
+// Job is a job (action) of a workspace
+type Job struct {
+	ID          string    `json:"action_id"`
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	PerformedAt time.Time `json:"performed_at"`
+}
+
+// Client reads the workspaces and runs the jobs of the Schematics endpoint of a location
+type Client struct {
+	service *core.BaseService
+}
+
+// NewClient returns a client of the Schematics endpoint of a location ("us" or "eu") authenticated with an API key
+func NewClient(apiKey string, location string) (*Client, error) {
+	service, err := core.NewBaseService(&core.ServiceOptions{
+		URL:           fmt.Sprintf("https://%s.schematics.cloud.ibm.com", location),
+		Authenticator: &core.IamAuthenticator{ApiKey: apiKey},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{service: service}, nil
+}
+
+// SetServiceURL changes the endpoint, for tests or private endpoints
+func (c *Client) SetServiceURL(url string) error {
+	return c.service.SetServiceURL(url)
+}
+
+// Service returns the underlying service, to install a recording or replaying transport
+func (c *Client) Service() *core.BaseService {
+	return c.service
+}
+
+func (c *Client) request(ctx context.Context, path string, pathParams map[string]string, query map[string]string, result interface{}) error {
+	return c.send(ctx, core.GET, path, pathParams, nil, query, result)
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, pathParams map[string]string, header map[string]string, query map[string]string, result interface{}) error {
+	builder := core.NewRequestBuilder(method).WithContext(ctx)
+	if _, err := builder.ResolveRequestURL(c.service.GetServiceURL(), path, pathParams); err != nil {
+		return err
+	}
+	if _, stream := result.(*io.ReadCloser); !stream {
+		builder.AddHeader("Accept", "application/json")
+	}
+	for name, value := range header {
+		builder.AddHeader(name, value)
+	}
+	for name, value := range query {
+		builder.AddQuery(name, value)
+	}
+	if method != core.GET {
+		if _, err := builder.SetBodyContentJSON(map[string]interface{}{}); err != nil {
+			return err
+		}
+	}
+	request, err := builder.Build()
+	if err != nil {
+		return err
+	}
+	if response, err := c.service.Request(request, result); err != nil {
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("%s %s: %w: %w", method, request.URL.Path, ErrNotFound, err)
+		}
+		return fmt.Errorf("%s %s: %w", method, request.URL.Path, err)
+	}
+	return nil
+}
+
+// Workspace returns a workspace by the ID the test wrapper created it with, nil if the endpoint has no such workspace
+func (c *Client) Workspace(ctx context.Context, id string) (*Workspace, error) {
+	workspace := &Workspace{}
+	if err := c.request(ctx, "/v1/workspaces/{w_id}", map[string]string{"w_id": id}, nil, workspace); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// Jobs returns the jobs of a workspace, the most recent first
+func (c *Client) Jobs(ctx context.Context, workspaceID string) ([]Job, error) {
+	var result struct {
+		Actions []Job `json:"actions"`
+	}
+	if err := c.request(ctx, "/v1/workspaces/{w_id}/actions", map[string]string{"w_id": workspaceID}, nil, &result); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(result.Actions, func(i, j int) bool {
+		return result.Actions[i].PerformedAt.After(result.Actions[j].PerformedAt)
+	})
+	return result.Actions, nil
+}
+
+// LastJob returns the most recent job of a workspace with a name and a status, "" matching any, nil if there is none
+func (c *Client) LastJob(ctx context.Context, workspaceID string, name string, status string) (*Job, error) {
+	jobs, err := c.Jobs(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	for i, job := range jobs {
+		if (name == "" || job.Name == name) && (status == "" || job.Status == status) {
+			return &jobs[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// PlanJSON returns the plan of a plan job, as `terraform show -json` renders it
+func (c *Client) PlanJSON(ctx context.Context, jobID string) (*tfjson.Plan, error) {
+	var file struct {
+		FileContent string `json:"file_content"`
+	}
+	if err := c.request(ctx, "/v2/jobs/{job_id}/files/{file_type}", map[string]string{"job_id": jobID, "file_type": "plan_json"}, nil, &file); err != nil {
+		return nil, err
+	}
+	plan := &tfjson.Plan{}
+	if err := json.Unmarshal([]byte(file.FileContent), plan); err != nil {
+		return nil, fmt.Errorf("error parsing plan of job %s: %w", jobID, err)
+	}
+	return plan, nil
+}
+
+// Log returns the Terraform log of a job of a workspace
+func (c *Client) Log(ctx context.Context, workspace *Workspace, jobID string) (string, error) {
+	if workspace.TemplateID() == "" {
+		return "", fmt.Errorf("workspace %s has no template to read the log of job %s from", workspace.Name, jobID)
+	}
+	var body io.ReadCloser
+	path := "/v1/workspaces/{w_id}/runtime_data/{t_id}/log_store/actions/{activity_id}"
+	if err := c.request(ctx, path, map[string]string{"w_id": workspace.ID, "t_id": workspace.TemplateID(), "activity_id": jobID}, nil, &body); err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("error reading log of job %s: %w", jobID, err)
+	}
+	return string(data), nil
+}
+
+// FailedLog returns the log of the last job of a workspace when it failed, "" when the last job did not fail
+func (c *Client) FailedLog(ctx context.Context, workspace *Workspace) (string, error) {
+	jobs, err := c.Jobs(ctx, workspace.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(jobs) == 0 || jobs[0].Status != Failed {
+		return "", nil
+	}
+	return c.Log(ctx, workspace, jobs[0].ID)
+}
+
+// Job returns a job of a workspace
+func (c *Client) Job(ctx context.Context, workspaceID string, jobID string) (*Job, error) {
+	job := &Job{}
+	if err := c.request(ctx, "/v1/workspaces/{w_id}/actions/{action_id}", map[string]string{"w_id": workspaceID, "action_id": jobID}, nil, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// jobEndpoints are the method and path that start each job RunJob runs
+var jobEndpoints = map[string][2]string{
+	Plan:  {core.POST, "/v1/workspaces/{w_id}/plan"},
+	Apply: {core.PUT, "/v1/workspaces/{w_id}/apply"},
+}
+
+// RunJob starts a plan or apply job of a workspace and waits for its end, reading its status every poll. When the job
+// does not complete, it returns its log with the error, for internal/retry to match.
+func (c *Client) RunJob(ctx context.Context, workspace *Workspace, name string, poll time.Duration) (*Job, string, error) {
+	endpoint, ok := jobEndpoints[name]
+	if !ok {
+		return nil, "", fmt.Errorf("can not run a %s job", name)
+	}
+	token, err := c.refreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	var started struct {
+		ActivityID string `json:"activityid"`
+	}
+	if err := c.send(ctx, endpoint[0], endpoint[1], map[string]string{"w_id": workspace.ID}, map[string]string{"refresh_token": token}, nil, &started); err != nil {
+		return nil, "", err
+	}
+	for {
+		job, err := c.Job(ctx, workspace.ID, started.ActivityID)
+		if err != nil {
+			return nil, "", err
+		}
+		switch job.Status {
+		case Completed:
+			return job, "", nil
+		case Pending, Running:
+		default:
+			err := fmt.Errorf("%s job %s of workspace %s failed with status %s", name, job.ID, workspace.Name, job.Status)
+			log, logErr := c.Log(ctx, workspace, job.ID)
+			if logErr != nil {
+				return job, "", errors.Join(err, logErr)
+			}
+			return job, log, err
+		}
+		timer := time.NewTimer(poll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return job, "", fmt.Errorf("waiting for %s job %s of workspace %s: %w", name, job.ID, workspace.Name, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshToken returns the IAM refresh token the jobs run Terraform with, "" when the client does not authenticate
+// with an API key
+func (c *Client) refreshToken() (string, error) {
+	authenticator, ok := c.service.Options.Authenticator.(*core.IamAuthenticator)
+	if !ok {
+		return "", nil
+	}
+	token, err := authenticator.RequestToken()
+	if err != nil {
+		return "", fmt.Errorf("error requesting an IAM refresh token: %w", err)
+	}
+	return token.RefreshToken, nil
+}
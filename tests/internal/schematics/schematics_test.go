@@ -0,0 +1,189 @@
+package schematics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IBM/go-sdk-core/v5/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/failures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+// server fakes the workspace and job endpoints of Schematics for a workspace with two plans, the last one failed, and a
+// workspace the endpoint fails to read
+func server(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/workspaces/ws-new/runtime_data/tpl-1/log_store/actions/job-plan-2" {
+			log, err := os.ReadFile("testdata/job-plan-2.log")
+			assert.NoError(t, err)
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write(log)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		var body interface{}
+		switch r.URL.Path {
+		case "/v1/workspaces/ws-new":
+			body = map[string]interface{}{"id": "ws-new", "name": "vsi-qs-upg-g7h8i9", "created_at": "2026-10-19T08:00:00Z", "template_data": []map[string]string{{"id": "tpl-1"}}}
+		case "/v1/workspaces/ws-broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			body = map[string]interface{}{"errors": []map[string]string{{"message": "internal error"}}}
+		case "/v1/workspaces/ws-new/actions":
+			body = map[string]interface{}{"actions": []map[string]string{
+				{"action_id": "job-apply", "name": Apply, "status": Completed, "performed_at": "2026-10-19T08:10:00Z"},
+				{"action_id": "job-plan-2", "name": Plan, "status": Failed, "performed_at": "2026-10-19T08:30:00Z"},
+				{"action_id": "job-plan-1", "name": Plan, "status": Completed, "performed_at": "2026-10-19T08:05:00Z"},
+			}}
+		case "/v2/jobs/job-plan-1/files/plan_json":
+			plan, _ := json.Marshal(map[string]interface{}{
+				"format_version": "1.2",
+				"resource_changes": []map[string]interface{}{
+					{"address": "module.slz_vsi.ibm_is_volume.volume[\"a\"]", "mode": "managed", "type": "ibm_is_volume", "change": map[string]interface{}{"actions": []string{"delete", "create"}}},
+				},
+			})
+			body = map[string]string{"job_id": "job-plan-1", "file_type": "plan_json", "file_content": string(plan)}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			body = map[string]interface{}{"errors": []map[string]string{{"message": "not found"}}}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(body))
+	}))
+}
+
+func client(t *testing.T, url string) *Client {
+	service, err := core.NewBaseService(&core.ServiceOptions{URL: url, Authenticator: &core.NoAuthAuthenticator{}})
+	require.NoError(t, err)
+	return &Client{service: service}
+}
+
+func TestClient(t *testing.T) {
+	server := server(t)
+	defer server.Close()
+	c := client(t, server.URL)
+	ctx := context.Background()
+
+	workspace, err := c.Workspace(ctx, "ws-new")
+	require.NoError(t, err)
+	require.NotNil(t, workspace)
+	assert.Equal(t, "vsi-qs-upg-g7h8i9", workspace.Name)
+	assert.Equal(t, "tpl-1", workspace.TemplateID())
+	missing, err := c.Workspace(ctx, "ws-old")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+	_, err = c.Workspace(ctx, "ws-broken")
+	assert.ErrorContains(t, err, "GET /v1/workspaces/ws-broken")
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	jobs, err := c.Jobs(ctx, workspace.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job-plan-2", "job-apply", "job-plan-1"}, []string{jobs[0].ID, jobs[1].ID, jobs[2].ID})
+	job, err := c.LastJob(ctx, workspace.ID, Plan, Completed)
+	require.NoError(t, err)
+	assert.Equal(t, "job-plan-1", job.ID)
+	job, err = c.LastJob(ctx, workspace.ID, "", Failed)
+	require.NoError(t, err)
+	assert.Equal(t, "job-plan-2", job.ID)
+	job, err = c.LastJob(ctx, workspace.ID, Destroy, "")
+	require.NoError(t, err)
+	assert.Nil(t, job)
+
+	plan, err := c.PlanJSON(ctx, "job-plan-1")
+	require.NoError(t, err)
+	require.Len(t, plan.ResourceChanges, 1)
+	assert.True(t, plan.ResourceChanges[0].Change.Actions.Replace())
+
+	_, err = c.PlanJSON(ctx, "job-plan-2")
+	assert.ErrorContains(t, err, "GET /v2/jobs/job-plan-2/files/plan_json")
+}
+
+func TestFailedLog(t *testing.T) {
+	server := server(t)
+	defer server.Close()
+	c := client(t, server.URL)
+	ctx := context.Background()
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+	rules, err := failures.LoadRules(filepath.Join(root, failures.ClassesPath))
+	require.NoError(t, err)
+
+	// the error testschematic returns for a failed job has none of its Terraform errors
+	wrapperErr := "error running the Schematics test: plan job job-plan-2 of workspace vsi-qs-upg-g7h8i9 failed with status FAILED"
+	assert.Empty(t, rules.Classify(wrapperErr).Failures)
+
+	workspace, err := c.Workspace(ctx, "ws-new")
+	require.NoError(t, err)
+	log, err := c.FailedLog(ctx, workspace)
+	require.NoError(t, err)
+	report := rules.Classify(log)
+	require.Len(t, report.Failures, 2)
+	assert.Equal(t, "Invalid index", report.Failures[0].Summary)
+	assert.Equal(t, map[string]int{failures.ModuleBug: 2}, report.Counts())
+
+	_, err = c.Log(ctx, &Workspace{ID: "ws-old", Name: "vsi-qs-upg-a1b2c3"}, "job-apply")
+	assert.EqualError(t, err, "workspace vsi-qs-upg-a1b2c3 has no template to read the log of job job-apply from")
+	workspace.TemplateData[0].ID = "tpl-2"
+	_, err = c.Log(ctx, workspace, "job-plan-2")
+	assert.ErrorContains(t, err, "GET /v1/workspaces/ws-new/runtime_data/tpl-2/log_store/actions/job-plan-2")
+}
+
+func TestRunJob(t *testing.T) {
+	// the apply job is in progress on the first read of its status, then fails; the plan job completes
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body interface{}
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /v1/workspaces/ws-new/apply":
+			body = map[string]string{"activityid": "job-apply-2"}
+		case "POST /v1/workspaces/ws-new/plan":
+			body = map[string]string{"activityid": "job-plan-3"}
+		case "GET /v1/workspaces/ws-new/actions/job-apply-2":
+			reads++
+			status := Running
+			if reads > 1 {
+				status = Failed
+			}
+			body = map[string]string{"action_id": "job-apply-2", "name": Apply, "status": status}
+		case "GET /v1/workspaces/ws-new/actions/job-plan-3":
+			body = map[string]string{"action_id": "job-plan-3", "name": Plan, "status": Completed}
+		case "GET /v1/workspaces/ws-new/runtime_data/tpl-1/log_store/actions/job-apply-2":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("Error: load_balancer_update_pending"))
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			body = map[string]interface{}{"errors": []map[string]string{{"message": "not found"}}}
+		}
+		assert.NoError(t, json.NewEncoder(w).Encode(body))
+	}))
+	defer server.Close()
+	c := client(t, server.URL)
+	ctx := context.Background()
+	workspace := &Workspace{ID: "ws-new", Name: "vsi-qs-upg-g7h8i9", TemplateData: []struct {
+		ID string `json:"id"`
+	}{{ID: "tpl-1"}}}
+
+	job, log, err := c.RunJob(ctx, workspace, Apply, time.Millisecond)
+	assert.EqualError(t, err, "APPLY job job-apply-2 of workspace vsi-qs-upg-g7h8i9 failed with status FAILED")
+	assert.Equal(t, "Error: load_balancer_update_pending", log)
+	require.NotNil(t, job)
+	assert.Equal(t, 2, reads)
+
+	job, log, err = c.RunJob(ctx, workspace, Plan, time.Millisecond)
+	require.NoError(t, err)
+	assert.Empty(t, log)
+	assert.Equal(t, "job-plan-3", job.ID)
+
+	_, _, err = c.RunJob(ctx, workspace, Destroy, time.Millisecond)
+	assert.EqualError(t, err, "can not run a DESTROY job")
+	_, _, err = c.RunJob(ctx, &Workspace{ID: "ws-old", Name: "vsi-qs-upg-a1b2c3"}, Apply, time.Millisecond)
+	assert.ErrorContains(t, err, "PUT /v1/workspaces/ws-old/apply")
+}
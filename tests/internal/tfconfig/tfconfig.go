@@ -0,0 +1,221 @@
+// Package tfconfig parses the Terraform configuration of this module (and its wrappers) with the HCL parser,
+// so offline checks can reason about `moved` blocks, variables and module calls without running terraform.
+package tfconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// File is a parsed .tf file with its source, used to recover the text of expressions
+type File struct {
+	Path   string
+	Source []byte
+	Body   *hclsyntax.Body
+}
+
+// ParseFile parses a single .tf file
+func ParseFile(path string) (*File, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return ParseSource(path, source)
+}
+
+// ParseSource parses the content of a .tf file, path is only used in error messages and positions
+func ParseSource(path string, source []byte) (*File, error) {
+	file, diags := hclsyntax.ParseConfig(source, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error parsing %s: %s", path, diags.Error())
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected body type in %s", path)
+	}
+	return &File{Path: path, Source: source, Body: body}, nil
+}
+
+// ParseDir parses every .tf file of a directory (not recursive), sorted by file name
+func ParseDir(dir string) ([]*File, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .tf files found in %s", dir)
+	}
+	sort.Strings(paths)
+	files := make([]*File, 0, len(paths))
+	for _, path := range paths {
+		file, err := ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// ExprText returns the source text of an expression, as written in the file
+func (f *File) ExprText(expr hclsyntax.Expression) string {
+	r := expr.Range()
+	if r.Start.Byte < 0 || r.End.Byte > len(f.Source) || r.Start.Byte > r.End.Byte {
+		return ""
+	}
+	return strings.TrimSpace(string(f.Source[r.Start.Byte:r.End.Byte]))
+}
+
+// BlocksOfType returns the top level blocks of the given type (e.g. "variable", "module", "moved")
+func (f *File) BlocksOfType(blockType string) []*hclsyntax.Block {
+	var blocks []*hclsyntax.Block
+	for _, block := range f.Body.Blocks {
+		if block.Type == blockType {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// MovedBlock is a `moved` block, with addresses relative to the module it is declared in
+type MovedBlock struct {
+	From string
+	To   string
+	// Pos is the file:line of the block, for reports
+	Pos string
+}
+
+// String renders the block as it would be written in a .tf file
+func (m MovedBlock) String() string {
+	return fmt.Sprintf("moved {\n  from = %s\n  to   = %s\n}", m.From, m.To)
+}
+
+// MovedBlocks returns every `moved` block declared in the .tf files of a directory
+func MovedBlocks(dir string) ([]MovedBlock, error) {
+	files, err := ParseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var moved []MovedBlock
+	for _, file := range files {
+		for _, block := range file.BlocksOfType("moved") {
+			from, fromOK := block.Body.Attributes["from"]
+			to, toOK := block.Body.Attributes["to"]
+			if !fromOK || !toOK {
+				return nil, fmt.Errorf("%s: moved block must have both from and to", block.DefRange().String())
+			}
+			moved = append(moved, MovedBlock{
+				From: file.ExprText(from.Expr),
+				To:   file.ExprText(to.Expr),
+				Pos:  fmt.Sprintf("%s:%d", filepath.Base(file.Path), block.DefRange().Start.Line),
+			})
+		}
+	}
+	return moved, nil
+}
+
+// Variable is a `variable` block
+type Variable struct {
+	Name        string
+	Description string
+	// Type is the type constraint, cty.DynamicPseudoType if the variable has no type
+	Type cty.Type
+	// TypeDefaults holds the defaults of the optional object attributes of Type, nil if there are none
+	TypeDefaults *typeexpr.Defaults
+	HasDefault   bool
+	// DefaultText is the default as written in the file
+	DefaultText string
+	// Default is the evaluated default, cty.NilVal if it can not be evaluated without context
+	Default     cty.Value
+	Nullable    bool
+	Sensitive   bool
+	Validations []Validation
+	// Pos is the file:line of the block, for reports
+	Pos string
+}
+
+// Validation is a `validation` block of a variable
+type Validation struct {
+	Condition    string
+	ErrorMessage string
+}
+
+// Variables returns the `variable` blocks of the given files, sorted by name
+func Variables(files []*File) ([]Variable, error) {
+	var variables []Variable
+	for _, file := range files {
+		for _, block := range file.BlocksOfType("variable") {
+			if len(block.Labels) != 1 {
+				return nil, fmt.Errorf("%s: variable block must have a name", block.DefRange().String())
+			}
+			variable := Variable{
+				Name:     block.Labels[0],
+				Type:     cty.DynamicPseudoType,
+				Nullable: true,
+				Pos:      fmt.Sprintf("%s:%d", filepath.Base(file.Path), block.DefRange().Start.Line),
+			}
+			attributes := block.Body.Attributes
+			if attr, ok := attributes["description"]; ok {
+				variable.Description = stringValue(attr.Expr)
+			}
+			if attr, ok := attributes["type"]; ok {
+				ty, defaults, diags := typeexpr.TypeConstraintWithDefaults(attr.Expr)
+				if diags.HasErrors() {
+					return nil, fmt.Errorf("%s: invalid type of variable %s: %s", variable.Pos, variable.Name, diags.Error())
+				}
+				variable.Type = ty
+				variable.TypeDefaults = defaults
+			}
+			if attr, ok := attributes["default"]; ok {
+				variable.HasDefault = true
+				variable.DefaultText = file.ExprText(attr.Expr)
+				if value, diags := attr.Expr.Value(nil); !diags.HasErrors() {
+					variable.Default = value
+				}
+			}
+			if attr, ok := attributes["nullable"]; ok {
+				if value, diags := attr.Expr.Value(nil); !diags.HasErrors() && value.Type() == cty.Bool && value.IsKnown() && !value.IsNull() {
+					variable.Nullable = value.True()
+				}
+			}
+			if attr, ok := attributes["sensitive"]; ok {
+				if value, diags := attr.Expr.Value(nil); !diags.HasErrors() && value.Type() == cty.Bool && value.IsKnown() && !value.IsNull() {
+					variable.Sensitive = value.True()
+				}
+			}
+			for _, child := range block.Body.Blocks {
+				if child.Type != "validation" {
+					continue
+				}
+				validation := Validation{}
+				if attr, ok := child.Body.Attributes["condition"]; ok {
+					validation.Condition = file.ExprText(attr.Expr)
+				}
+				if attr, ok := child.Body.Attributes["error_message"]; ok {
+					validation.ErrorMessage = stringValue(attr.Expr)
+				}
+				variable.Validations = append(variable.Validations, validation)
+			}
+			variables = append(variables, variable)
+		}
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return variables, nil
+}
+
+// stringValue evaluates a literal string expression, returning "" if it is not one
+func stringValue(expr hclsyntax.Expression) string {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || value.Type() != cty.String || !value.IsKnown() || value.IsNull() {
+		return ""
+	}
+	return value.AsString()
+}
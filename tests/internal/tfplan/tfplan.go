@@ -0,0 +1,215 @@
+// Package tfplan loads the JSON documents produced by `terraform show -json` (plans and states)
+// and offers a few helpers to walk them. It is shared by the offline checks and reports in this
+// tests module so they all read plan and state fixtures the same way.
+package tfplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// LoadPlan reads and validates a plan JSON file (output of `terraform show -json <planfile>`)
+func LoadPlan(path string) (*tfjson.Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %s: %w", path, err)
+	}
+	plan, err := ParsePlan(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing plan file %s: %w", path, err)
+	}
+	return plan, nil
+}
+
+// ParsePlan parses and validates plan JSON content
+func ParsePlan(data []byte) (*tfjson.Plan, error) {
+	plan := &tfjson.Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// LoadState reads and validates a state JSON file (output of `terraform show -json` after an apply)
+func LoadState(path string) (*tfjson.State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file %s: %w", path, err)
+	}
+	state := &tfjson.State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// StateResources returns every resource of a state, walking all child modules, sorted by address
+func StateResources(state *tfjson.State) []*tfjson.StateResource {
+	if state == nil || state.Values == nil {
+		return nil
+	}
+	return moduleResources(state.Values.RootModule)
+}
+
+// PlannedResources returns every resource of the planned values of a plan, walking all child modules, sorted by address
+func PlannedResources(plan *tfjson.Plan) []*tfjson.StateResource {
+	if plan == nil || plan.PlannedValues == nil {
+		return nil
+	}
+	return moduleResources(plan.PlannedValues.RootModule)
+}
+
+// PriorResources returns every resource of the prior state embedded in a plan, sorted by address
+func PriorResources(plan *tfjson.Plan) []*tfjson.StateResource {
+	if plan == nil {
+		return nil
+	}
+	return StateResources(plan.PriorState)
+}
+
+func moduleResources(module *tfjson.StateModule) []*tfjson.StateResource {
+	var resources []*tfjson.StateResource
+	var walk func(m *tfjson.StateModule)
+	walk = func(m *tfjson.StateModule) {
+		if m == nil {
+			return
+		}
+		resources = append(resources, m.Resources...)
+		for _, child := range m.ChildModules {
+			walk(child)
+		}
+	}
+	walk(module)
+	sort.SliceStable(resources, func(i, j int) bool {
+		return resources[i].Address < resources[j].Address
+	})
+	return resources
+}
+
+// ManagedResourcesOfType filters a resource list down to managed resources of the given type
+func ManagedResourcesOfType(resources []*tfjson.StateResource, resourceType string) []*tfjson.StateResource {
+	var filtered []*tfjson.StateResource
+	for _, resource := range resources {
+		if resource.Mode == tfjson.ManagedResourceMode && resource.Type == resourceType {
+			filtered = append(filtered, resource)
+		}
+	}
+	return filtered
+}
+
+// Lookup walks a decoded JSON value using map keys (string) and list indexes (int).
+// It returns nil as soon as an element of the path does not exist.
+func Lookup(value interface{}, path ...interface{}) interface{} {
+	current := value
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = m[key]
+		case int:
+			l, ok := current.([]interface{})
+			if !ok || key < 0 || key >= len(l) {
+				return nil
+			}
+			current = l[key]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// String is Lookup for values expected to be strings, returning "" if the value is missing or not a string
+func String(value interface{}, path ...interface{}) string {
+	switch v := Lookup(value, path...).(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	default:
+		return ""
+	}
+}
+
+// Number is Lookup for numeric values, returning 0 if the value is missing or not a number
+func Number(value interface{}, path ...interface{}) float64 {
+	switch v := Lookup(value, path...).(type) {
+	case float64:
+		return v
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	default:
+		return 0
+	}
+}
+
+// List is Lookup for list values, returning nil if the value is missing or not a list
+func List(value interface{}, path ...interface{}) []interface{} {
+	l, _ := Lookup(value, path...).([]interface{})
+	return l
+}
+
+// Map is Lookup for object values, returning nil if the value is missing or not an object
+func Map(value interface{}, path ...interface{}) map[string]interface{} {
+	m, _ := Lookup(value, path...).(map[string]interface{})
+	return m
+}
+
+// moduleInstanceKey matches the instance key of a module call in a module address, e.g. `["a"]` in `module.x["a"]`
+var moduleInstanceKey = regexp.MustCompile(`\[[^\]]*\]`)
+
+// ModuleCallPath returns a module address without the instance keys of count/for_each module calls,
+// e.g. `module.slz_vsi_dh[0]` becomes `module.slz_vsi_dh`
+func ModuleCallPath(moduleAddress string) string {
+	return moduleInstanceKey.ReplaceAllString(moduleAddress, "")
+}
+
+// ModuleCallsWithSource returns the paths (e.g. `module.slz_vsi.module.fscloud_vsi`) of every module call of the
+// plan configuration whose local source resolves to targetDir. configDir is the directory the plan was produced
+// from, used to resolve relative sources. Registry sources never match.
+func ModuleCallsWithSource(plan *tfjson.Plan, configDir string, targetDir string) []string {
+	if plan == nil || plan.Config == nil || plan.Config.RootModule == nil {
+		return nil
+	}
+	target, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	var walk func(module *tfjson.ConfigModule, dir string, prefix string)
+	walk = func(module *tfjson.ConfigModule, dir string, prefix string) {
+		for name, call := range module.ModuleCalls {
+			path := prefix + "module." + name
+			if !strings.HasPrefix(call.Source, "./") && !strings.HasPrefix(call.Source, "../") {
+				continue
+			}
+			callDir, err := filepath.Abs(filepath.Join(dir, call.Source))
+			if err != nil {
+				continue
+			}
+			if callDir == target {
+				paths = append(paths, path)
+			}
+			if call.Module != nil {
+				walk(call.Module, callDir, path+".")
+			}
+		}
+	}
+	walk(plan.Config.RootModule, configDir, "")
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,217 @@
+package timing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Names of the files WriteFiles writes
+const (
+	JUnitFile   = "junit.xml"
+	SummaryFile = "summary.json"
+)
+
+// Summary is the JSON summary of a run
+type Summary struct {
+	Started  time.Time    `json:"started"`
+	Duration float64      `json:"duration_seconds"`
+	Tests    []TestResult `json:"tests"`
+	// Phases totals the runs of each phase over the tests, the series the dashboards follow
+	Phases map[Phase]PhaseTotal `json:"phases"`
+}
+
+// TestResult is the summary of a test
+type TestResult struct {
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`
+	Started       time.Time      `json:"started"`
+	Duration      float64        `json:"duration_seconds"`
+	Resources     map[string]int `json:"resources,omitempty"`
+	ResourceCount int            `json:"resource_count"`
+	Phases        []PhaseResult  `json:"phases"`
+}
+
+// PhaseResult is the summary of a phase of a test
+type PhaseResult struct {
+	Phase    Phase     `json:"phase"`
+	Status   string    `json:"status"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration_seconds"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// PhaseTotal totals the runs of a phase
+type PhaseTotal struct {
+	Runs     int     `json:"runs"`
+	Failures int     `json:"failures"`
+	Total    float64 `json:"total_seconds"`
+	Max      float64 `json:"max_seconds"`
+}
+
+// seconds returns a duration in seconds, to the millisecond
+func seconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// Summary summarizes the finished tests of the run
+func (r *Recorder) Summary() *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := &Summary{Started: r.started, Duration: seconds(r.clock.Now().Sub(r.started)), Tests: []TestResult{}, Phases: map[Phase]PhaseTotal{}}
+	for _, test := range r.finished() {
+		result := TestResult{Name: test.Name, Status: test.Status, Started: test.Started, Duration: seconds(test.Duration()), Resources: test.Resources, Phases: []PhaseResult{}}
+		for _, count := range test.Resources {
+			result.ResourceCount += count
+		}
+		for _, phase := range test.Phases {
+			duration := seconds(phase.Duration())
+			result.Phases = append(result.Phases, PhaseResult{Phase: phase.Phase, Status: phase.Status, Started: phase.Started, Duration: duration, Message: phase.Message})
+			total := summary.Phases[phase.Phase]
+			total.Runs++
+			if phase.Status == Failed {
+				total.Failures++
+			}
+			total.Total = math.Round((total.Total+duration)*1000) / 1000
+			total.Max = math.Max(total.Max, duration)
+			summary.Phases[phase.Phase] = total
+		}
+		summary.Tests = append(summary.Tests, result)
+	}
+	return summary
+}
+
+// JSON returns the indented JSON of the summary
+func (s *Summary) JSON() ([]byte, error) {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// junitSuites is the root element of a JUnit XML report, one suite per test and one case per phase
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr"`
+	Properties *junitProperties `xml:"properties"`
+	Cases      []junitCase      `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// JUnit returns the JUnit XML report of the finished tests of the run: a suite per test, with a case per phase and a
+// property per resource type. A test that failed or was skipped outside of its phases gets a case named after it.
+func (r *Recorder) JUnit() ([]byte, error) {
+	summary := r.Summary()
+	suites := junitSuites{Time: fmt.Sprintf("%.3f", summary.Duration)}
+	for _, test := range summary.Tests {
+		suite := junitSuite{Name: test.Name, Time: fmt.Sprintf("%.3f", test.Duration), Timestamp: test.Started.UTC().Format(time.RFC3339)}
+		if len(test.Resources) > 0 {
+			suite.Properties = &junitProperties{}
+			for _, resourceType := range sortedKeys(test.Resources) {
+				suite.Properties.Properties = append(suite.Properties.Properties, junitProperty{Name: "resources." + resourceType, Value: fmt.Sprint(test.Resources[resourceType])})
+			}
+		}
+		failed := false
+		for _, phase := range test.Phases {
+			testCase := junitCase{Name: string(phase.Phase), Classname: test.Name, Time: fmt.Sprintf("%.3f", phase.Duration)}
+			if phase.Status == Failed {
+				testCase.Failure = &junitFailure{Message: phase.Message}
+				failed = true
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		switch {
+		case test.Status == Skipped:
+			suite.Cases = append(suite.Cases, junitCase{Name: test.Name, Classname: test.Name, Time: "0.000", Skipped: &struct{}{}})
+		case test.Status == Failed && !failed:
+			suite.Cases = append(suite.Cases, junitCase{Name: test.Name, Classname: test.Name, Time: fmt.Sprintf("%.3f", test.Duration), Failure: &junitFailure{Message: "the test failed outside of its phases"}})
+		}
+		for _, testCase := range suite.Cases {
+			suite.Tests++
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+			if testCase.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// WriteFiles writes the JUnit XML report and the JSON summary of the run to a directory
+func (r *Recorder) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating report directory %s: %w", dir, err)
+	}
+	junit, err := r.JUnit()
+	if err != nil {
+		return fmt.Errorf("error rendering JUnit report: %w", err)
+	}
+	summary, err := r.Summary().JSON()
+	if err != nil {
+		return fmt.Errorf("error rendering summary: %w", err)
+	}
+	for name, data := range map[string][]byte{JUnitFile: junit, SummaryFile: summary} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return fmt.Errorf("error writing report %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
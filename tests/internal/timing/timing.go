@@ -0,0 +1,222 @@
+// Package timing records when each phase of the tests (prerequisite apply, Schematics upload, plan, apply, consistency
+// check, upgrade, destroy) starts and ends, and reports the phases as JUnit XML and as a JSON summary with the
+// resource counts of the tests, so the phases getting slower can be tracked across runs.
+package timing
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Phase is a step of a test
+type Phase string
+
+// Phases of the tests, in the order they run
+const (
+	// PrereqApply provisions the existing resources a solution is deployed into
+	PrereqApply Phase = "prereq_apply"
+	// SchematicsUpload creates the Schematics workspace, uploads the configuration and runs its plan job, which the
+	// test wrapper does not separate
+	SchematicsUpload Phase = "schematics_upload"
+	// Plan initializes and plans the configuration, up to the apply
+	Plan             Phase = "plan"
+	Apply            Phase = "apply"
+	ConsistencyCheck Phase = "consistency_check"
+	// Upgrade plans the configuration of the branch over the resources applied from the base branch
+	Upgrade       Phase = "upgrade"
+	Destroy       Phase = "destroy"
+	PrereqDestroy Phase = "prereq_destroy"
+)
+
+// Statuses of a test or a phase
+const (
+	Passed  = "passed"
+	Failed  = "failed"
+	Skipped = "skipped"
+)
+
+// Clock returns the current time, a fake one in the tests of the package
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the clock of the system
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Recorder records the tests of a run. It is safe for concurrent use by parallel tests.
+type Recorder struct {
+	clock   Clock
+	started time.Time
+	mu      sync.Mutex
+	tests   []*Test
+}
+
+// NewRecorder returns a recorder whose run starts now
+func NewRecorder(clock Clock) *Recorder {
+	return &Recorder{clock: clock, started: clock.Now()}
+}
+
+// Test starts recording a test
+func (r *Recorder) Test(name string) *Test {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	test := &Test{recorder: r, Name: name, Started: r.clock.Now()}
+	r.tests = append(r.tests, test)
+	return test
+}
+
+// Test is the record of a test
+type Test struct {
+	recorder *Recorder
+	Name     string
+	Started  time.Time
+	Ended    time.Time
+	Status   string
+	Phases   []*PhaseRecord
+	// Resources counts the resources the test applies by type
+	Resources map[string]int
+	running   *PhaseRecord
+}
+
+// PhaseRecord is the record of a phase of a test
+type PhaseRecord struct {
+	Phase   Phase
+	Started time.Time
+	Ended   time.Time
+	Status  string
+	// Message is the error of a failed phase
+	Message string
+}
+
+// Duration returns how long the phase ran
+func (p *PhaseRecord) Duration() time.Duration {
+	return p.Ended.Sub(p.Started)
+}
+
+// Duration returns how long the test ran
+func (t *Test) Duration() time.Duration {
+	return t.Ended.Sub(t.Started)
+}
+
+// Phase returns the first record of a phase of the test, nil if the test did not run it
+func (t *Test) Phase(phase Phase) *PhaseRecord {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	for _, record := range t.Phases {
+		if record.Phase == phase {
+			return record
+		}
+	}
+	return nil
+}
+
+// Begin starts a phase, ending the running one as passed. The hooks of the test wrapper only mark where a phase
+// starts.
+func (t *Test) Begin(phase Phase) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	now := t.recorder.clock.Now()
+	t.end(now, nil)
+	t.running = &PhaseRecord{Phase: phase, Started: now}
+	t.Phases = append(t.Phases, t.running)
+}
+
+// End ends the running phase, failed when err is not nil
+func (t *Test) End(err error) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	t.end(t.recorder.clock.Now(), err)
+}
+
+// Run runs fn as a phase
+func (t *Test) Run(phase Phase, fn func() error) error {
+	t.Begin(phase)
+	err := fn()
+	t.End(err)
+	return err
+}
+
+func (t *Test) end(now time.Time, err error) {
+	if t.running == nil {
+		return
+	}
+	t.running.Ended = now
+	t.running.Status = Passed
+	if err != nil {
+		t.running.Status = Failed
+		t.running.Message = err.Error()
+	}
+	t.running = nil
+}
+
+// SetResources sets the resource counts of the test
+func (t *Test) SetResources(counts map[string]int) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	t.Resources = counts
+}
+
+// Finish ends the test. When the test failed and none of its phases did, the phase still running is the one it
+// failed in.
+func (t *Test) Finish(failed bool, skipped bool) {
+	t.recorder.mu.Lock()
+	defer t.recorder.mu.Unlock()
+	now := t.recorder.clock.Now()
+	if t.running != nil && failed && !t.phaseFailed() {
+		t.running.Ended = now
+		t.running.Status = Failed
+		t.running.Message = "the test failed in this phase"
+		t.running = nil
+	}
+	t.end(now, nil)
+	t.Ended = now
+	switch {
+	case skipped:
+		t.Status = Skipped
+	case failed:
+		t.Status = Failed
+	default:
+		t.Status = Passed
+	}
+}
+
+func (t *Test) phaseFailed() bool {
+	for _, phase := range t.Phases {
+		if phase.Status == Failed {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceCounts counts the managed resources a plan creates or keeps, by type
+func ResourceCounts(plan *tfjson.Plan) map[string]int {
+	counts := map[string]int{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil || change.Change.Actions.Delete() && !change.Change.Actions.Replace() {
+			continue
+		}
+		counts[change.Type]++
+	}
+	return counts
+}
+
+// finished returns the finished tests, sorted by name
+func (r *Recorder) finished() []*Test {
+	var tests []*Test
+	for _, test := range r.tests {
+		if !test.Ended.IsZero() {
+			tests = append(tests, test)
+		}
+	}
+	sort.SliceStable(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
+	return tests
+}
@@ -0,0 +1,146 @@
+package timing
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+// fakeClock only moves when the test advances it
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// run records a run of a solution, an example failing in its apply, a skipped test and a test failing before its
+// first phase
+func run() *Recorder {
+	clock := &fakeClock{now: time.Date(2025, 3, 1, 6, 0, 0, 0, time.UTC)}
+	recorder := NewRecorder(clock)
+
+	solution := recorder.Test("TestFullyConfigurable")
+	solution.SetResources(map[string]int{"ibm_is_instance": 1, "ibm_is_virtual_network_interface": 1})
+	_ = solution.Run(PrereqApply, func() error { clock.Advance(4*time.Minute + 30*time.Second); return nil })
+	solution.Begin(SchematicsUpload)
+	clock.Advance(90 * time.Second)
+	solution.Begin(Apply)
+	clock.Advance(21*time.Minute + 250*time.Millisecond)
+	solution.Begin(ConsistencyCheck)
+	clock.Advance(2 * time.Minute)
+	solution.Begin(Destroy)
+	clock.Advance(9 * time.Minute)
+	solution.End(nil)
+	_ = solution.Run(PrereqDestroy, func() error { clock.Advance(3 * time.Minute); return nil })
+	solution.Finish(false, false)
+
+	example := recorder.Test("TestRunCompleteExample")
+	example.SetResources(map[string]int{"ibm_is_instance": 3, "ibm_is_volume": 3})
+	example.Begin(Plan)
+	clock.Advance(45 * time.Second)
+	example.Begin(Apply)
+	clock.Advance(12 * time.Minute)
+	example.End(errors.New("timeout while waiting for state to become 'available'"))
+	example.Begin(Destroy)
+	clock.Advance(6 * time.Minute)
+	example.Finish(true, false)
+
+	upgrade := recorder.Test("TestRunCompleteUpgradeExample")
+	upgrade.Begin(Plan)
+	clock.Advance(30 * time.Second)
+	upgrade.Begin(Apply)
+	clock.Advance(11 * time.Minute)
+	upgrade.Begin(Upgrade)
+	clock.Advance(time.Minute)
+	// fails in the upgrade, before its destroy hook
+	upgrade.Finish(true, false)
+
+	skipped := recorder.Test("TestRunGen2BootExample")
+	skipped.Finish(false, true)
+
+	outside := recorder.Test("TestAddonDefaultConfiguration")
+	clock.Advance(time.Second)
+	outside.Finish(true, false)
+
+	// still running, left out of the reports
+	recorder.Test("TestRunMultiProfileExample").Begin(Plan)
+	clock.Advance(time.Minute)
+	return recorder
+}
+
+func golden(t *testing.T, name string, data []byte) {
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, data, 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data), "%s differs, run the test with -update if the change is expected", path)
+}
+
+func TestSummary(t *testing.T) {
+	summary := run().Summary()
+	require.Len(t, summary.Tests, 5)
+	assert.Equal(t, PhaseTotal{Runs: 3, Failures: 1, Total: 1260.25 + 720 + 660, Max: 1260.25}, summary.Phases[Apply])
+	assert.Equal(t, PhaseTotal{Runs: 1, Failures: 1, Total: 60, Max: 60}, summary.Phases[Upgrade])
+	assert.Equal(t, 2, summary.Tests[1].ResourceCount)
+
+	data, err := summary.JSON()
+	require.NoError(t, err)
+	golden(t, SummaryFile, data)
+}
+
+func TestJUnit(t *testing.T) {
+	data, err := run().JUnit()
+	require.NoError(t, err)
+	golden(t, JUnitFile, data)
+}
+
+func TestWriteFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	require.NoError(t, run().WriteFiles(dir))
+	for _, name := range []string{JUnitFile, SummaryFile} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+}
+
+func TestRun(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	test := NewRecorder(clock).Test("TestRun")
+	err := test.Run(Apply, func() error { clock.Advance(time.Second); return errors.New("apply failed") })
+	assert.EqualError(t, err, "apply failed")
+	// nothing is running anymore
+	test.End(nil)
+	require.Len(t, test.Phases, 1)
+	assert.Equal(t, &PhaseRecord{Phase: Apply, Started: time.Unix(0, 0), Ended: time.Unix(1, 0), Status: Failed, Message: "apply failed"}, test.Phases[0])
+	assert.Same(t, test.Phases[0], test.Phase(Apply))
+	assert.Nil(t, test.Phase(Destroy))
+}
+
+func TestResourceCounts(t *testing.T) {
+	plan, err := tfplan.ParsePlan([]byte(`{"format_version": "1.2", "resource_changes": [
+		{"address": "ibm_is_instance.a", "mode": "managed", "type": "ibm_is_instance", "change": {"actions": ["create"]}},
+		{"address": "ibm_is_instance.b", "mode": "managed", "type": "ibm_is_instance", "change": {"actions": ["delete", "create"]}},
+		{"address": "ibm_is_volume.old", "mode": "managed", "type": "ibm_is_volume", "change": {"actions": ["delete"]}},
+		{"address": "ibm_is_vpc.vpc", "mode": "managed", "type": "ibm_is_vpc", "change": {"actions": ["no-op"]}},
+		{"address": "data.ibm_is_image.image", "mode": "data", "type": "ibm_is_image", "change": {"actions": ["read"]}}
+	]}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"ibm_is_instance": 2, "ibm_is_vpc": 1}, ResourceCounts(plan))
+}
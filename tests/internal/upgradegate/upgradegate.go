@@ -0,0 +1,303 @@
+// Package upgradegate fails an upgrade when the plan destroys or replaces data-bearing resources
+// (volumes, reserved IPs, floating IPs, instances and the KMS authorization policy) that the release
+// has not explicitly documented in the upgrade allow-list.
+//
+// The allow-list is a YAML file with one section per release. Each entry names the resource addresses a
+// release is allowed to destroy or replace, why, and optionally the `moved` block of moved_config.tf that
+// goes with it, so a release documents (and tests) exactly which data-bearing resources it touches.
+package upgradegate
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// UnreleasedVersion is the allow-list version for changes on the main branch that are not released yet
+const UnreleasedVersion = "unreleased"
+
+// statefulResourceTypes are always gated; ibm_iam_authorization_policy is only gated when it grants access to a KMS key
+var statefulResourceTypes = map[string]bool{
+	"ibm_is_volume":                true,
+	"ibm_is_subnet_reserved_ip":    true,
+	"ibm_is_floating_ip":           true,
+	"ibm_is_instance":              true,
+	"ibm_iam_authorization_policy": true,
+}
+
+var kmsServiceNames = map[string]bool{
+	"kms":       true,
+	"hs-crypto": true,
+}
+
+// AllowList is the content of the upgrade allow-list file
+type AllowList struct {
+	Releases []Release `yaml:"releases"`
+}
+
+// Release lists the data-bearing resources a release is allowed to destroy or replace
+type Release struct {
+	Version string  `yaml:"version"`
+	Changes []Entry `yaml:"changes"`
+}
+
+// Entry allows the destruction or replacement of the resources matching Address
+type Entry struct {
+	// Address of the resource, `*` matches any sequence of characters (e.g. `module.*.ibm_is_volume.volume[*]`)
+	Address string `yaml:"address"`
+	// Actions allowed: "delete" and/or "replace"
+	Actions []string `yaml:"actions"`
+	Reason  string   `yaml:"reason"`
+	// Moved references the moved block of moved_config.tf that goes with the change, if any
+	Moved *MovedRef `yaml:"moved,omitempty"`
+}
+
+// MovedRef references a moved block by its from and to addresses, as written in moved_config.tf
+type MovedRef struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// Finding is a destroyed or replaced data-bearing resource
+type Finding struct {
+	Address string
+	Type    string
+	Action  string
+	// Release and Entry are set when the finding is covered by the allow-list
+	Release string
+	Entry   *Entry
+}
+
+// Report is the result of gating a plan
+type Report struct {
+	Violations []Finding
+	Allowed    []Finding
+}
+
+// LoadAllowList reads the allow-list YAML file
+func LoadAllowList(path string) (*AllowList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading allow-list %s: %w", path, err)
+	}
+	list := &AllowList{}
+	if err := yaml.Unmarshal(data, list); err != nil {
+		return nil, fmt.Errorf("error parsing allow-list %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Validate checks the allow-list is well formed and that every referenced moved block exists
+func (a *AllowList) Validate(moved []tfconfig.MovedBlock) error {
+	var problems []string
+	for _, release := range a.Releases {
+		if release.Version != UnreleasedVersion {
+			if _, err := version.NewVersion(release.Version); err != nil {
+				problems = append(problems, fmt.Sprintf("release %q: invalid version", release.Version))
+			}
+		}
+		for _, entry := range release.Changes {
+			if entry.Address == "" {
+				problems = append(problems, fmt.Sprintf("release %s: entry without address", release.Version))
+			}
+			if strings.TrimSpace(entry.Reason) == "" {
+				problems = append(problems, fmt.Sprintf("release %s: %s has no reason", release.Version, entry.Address))
+			}
+			if len(entry.Actions) == 0 {
+				problems = append(problems, fmt.Sprintf("release %s: %s has no actions", release.Version, entry.Address))
+			}
+			for _, action := range entry.Actions {
+				if action != "delete" && action != "replace" {
+					problems = append(problems, fmt.Sprintf("release %s: %s has unknown action %q", release.Version, entry.Address, action))
+				}
+			}
+			if entry.Moved != nil && !hasMovedBlock(moved, *entry.Moved) {
+				problems = append(problems, fmt.Sprintf("release %s: %s references moved block %s -> %s which is not in moved_config.tf", release.Version, entry.Address, entry.Moved.From, entry.Moved.To))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid upgrade allow-list:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func hasMovedBlock(moved []tfconfig.MovedBlock, ref MovedRef) bool {
+	for _, block := range moved {
+		if block.From == ref.From && block.To == ref.To {
+			return true
+		}
+	}
+	return false
+}
+
+// entriesSince returns the allow-list entries of every release newer than fromVersion, plus the unreleased ones.
+// An empty fromVersion returns every entry.
+func (a *AllowList) entriesSince(fromVersion string) (map[string][]Entry, error) {
+	var from *version.Version
+	if fromVersion != "" {
+		var err error
+		if from, err = version.NewVersion(fromVersion); err != nil {
+			return nil, fmt.Errorf("invalid upgrade base version %q: %w", fromVersion, err)
+		}
+	}
+	entries := map[string][]Entry{}
+	for _, release := range a.Releases {
+		if release.Version != UnreleasedVersion {
+			v, err := version.NewVersion(release.Version)
+			if err != nil {
+				return nil, fmt.Errorf("invalid release version %q in allow-list: %w", release.Version, err)
+			}
+			if from != nil && !v.GreaterThan(from) {
+				continue
+			}
+		}
+		entries[release.Version] = append(entries[release.Version], release.Changes...)
+	}
+	return entries, nil
+}
+
+// Check gates the resource changes of an upgrade plan. fromVersion is the release the upgrade starts from,
+// only allow-list entries of later releases (and unreleased ones) are taken into account.
+func Check(plan *tfjson.Plan, allowList *AllowList, fromVersion string) (*Report, error) {
+	if allowList == nil {
+		allowList = &AllowList{}
+	}
+	entries, err := allowList.entriesSince(fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	releases := make([]string, 0, len(entries))
+	for release := range entries {
+		releases = append(releases, release)
+	}
+	sortReleases(releases)
+
+	report := &Report{}
+	for _, change := range plan.ResourceChanges {
+		if change.Mode != tfjson.ManagedResourceMode || change.Change == nil || !statefulResourceTypes[change.Type] {
+			continue
+		}
+		action := destructiveAction(change.Change.Actions)
+		if action == "" {
+			continue
+		}
+		if change.Type == "ibm_iam_authorization_policy" && !isKMSPolicy(change.Change.Before) {
+			continue
+		}
+
+		finding := Finding{Address: change.Address, Type: change.Type, Action: action}
+		for _, release := range releases {
+			for i := range entries[release] {
+				entry := entries[release][i]
+				if entry.allows(change.Address, action) {
+					finding.Release = release
+					finding.Entry = &entry
+					break
+				}
+			}
+			if finding.Entry != nil {
+				break
+			}
+		}
+		if finding.Entry != nil {
+			report.Allowed = append(report.Allowed, finding)
+		} else {
+			report.Violations = append(report.Violations, finding)
+		}
+	}
+	return report, nil
+}
+
+// sortReleases sorts release versions oldest first, by semantic version, with the unreleased changes last. The
+// versions were parsed by entriesSince.
+func sortReleases(releases []string) {
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i] == UnreleasedVersion || releases[j] == UnreleasedVersion {
+			return releases[j] == UnreleasedVersion && releases[i] != UnreleasedVersion
+		}
+		return version.Must(version.NewVersion(releases[i])).LessThan(version.Must(version.NewVersion(releases[j])))
+	})
+}
+
+func destructiveAction(actions tfjson.Actions) string {
+	switch {
+	case actions.Replace():
+		return "replace"
+	case actions.Delete():
+		return "delete"
+	default:
+		return ""
+	}
+}
+
+// isKMSPolicy returns true for authorization policies that target a Key Protect or HPCS instance or key
+func isKMSPolicy(before interface{}) bool {
+	values, ok := before.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if service, _ := values["target_service_name"].(string); kmsServiceNames[service] {
+		return true
+	}
+	attributes, _ := values["resource_attributes"].([]interface{})
+	for _, attribute := range attributes {
+		attr, _ := attribute.(map[string]interface{})
+		if attr["name"] == "serviceName" {
+			if service, _ := attr["value"].(string); kmsServiceNames[service] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e Entry) allows(address string, action string) bool {
+	allowed := false
+	for _, a := range e.Actions {
+		if a == action {
+			allowed = true
+		}
+	}
+	return allowed && addressPattern(e.Address).MatchString(address)
+}
+
+// addressPattern turns an allow-list address, where `*` matches anything, into an anchored regular expression
+func addressPattern(pattern string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(pattern)
+	return regexp.MustCompile("^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$")
+}
+
+// Failed returns true if at least one data-bearing resource is destroyed or replaced without being allowed
+func (r *Report) Failed() bool {
+	return len(r.Violations) > 0
+}
+
+// String renders an itemized report of the violations and of the allowed changes
+func (r *Report) String() string {
+	var sb strings.Builder
+	if len(r.Violations) > 0 {
+		fmt.Fprintf(&sb, "%d data-bearing resource(s) would be destroyed or replaced by the upgrade:\n", len(r.Violations))
+		for _, finding := range r.Violations {
+			fmt.Fprintf(&sb, "  - %s %s (%s)\n", strings.ToUpper(finding.Action), finding.Address, finding.Type)
+		}
+		sb.WriteString("If this is intended, document it for the release in the upgrade allow-list (and add a moved block to moved_config.tf if the address changed).\n")
+	}
+	if len(r.Allowed) > 0 {
+		fmt.Fprintf(&sb, "%d data-bearing resource change(s) allowed by the upgrade allow-list:\n", len(r.Allowed))
+		for _, finding := range r.Allowed {
+			fmt.Fprintf(&sb, "  - %s %s (release %s: %s)\n", strings.ToUpper(finding.Action), finding.Address, finding.Release, finding.Entry.Reason)
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("No data-bearing resource is destroyed or replaced by the upgrade.\n")
+	}
+	return sb.String()
+}
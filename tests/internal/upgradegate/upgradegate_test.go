@@ -0,0 +1,122 @@
+package upgradegate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+)
+
+func addresses(findings []Finding) []string {
+	var list []string
+	for _, finding := range findings {
+		list = append(list, finding.Action+" "+finding.Address)
+	}
+	return list
+}
+
+func TestCheckWithoutAllowList(t *testing.T) {
+	plan, err := tfplan.LoadPlan("testdata/plan_upgrade.json")
+	require.NoError(t, err)
+
+	report, err := Check(plan, nil, "")
+	require.NoError(t, err)
+
+	// volume updates, no-ops, moved resources and non KMS policies are not gated
+	assert.True(t, report.Failed())
+	assert.Equal(t, []string{
+		`replace module.slz_vsi.ibm_is_subnet_reserved_ip.secondary_vni_ip["vpc-secondary-subnet-a-0"]`,
+		`replace module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-0"]`,
+		`delete module.slz_vsi.ibm_is_floating_ip.vsi_fip["vpc-subnet-a-0"]`,
+		`replace module.slz_vsi.ibm_iam_authorization_policy.block_storage_policy[0]`,
+	}, addresses(report.Violations))
+	assert.Contains(t, report.String(), `REPLACE module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-0"] (ibm_is_instance)`)
+}
+
+func TestCheckWithAllowList(t *testing.T) {
+	plan, err := tfplan.LoadPlan("testdata/plan_upgrade.json")
+	require.NoError(t, err)
+	allowList, err := LoadAllowList("testdata/allowlist.yaml")
+	require.NoError(t, err)
+
+	// upgrading from 5.0.0 picks up the entries of 5.1.0 and 5.4.0
+	report, err := Check(plan, allowList, "5.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`replace module.slz_vsi.ibm_is_subnet_reserved_ip.secondary_vni_ip["vpc-secondary-subnet-a-0"]`,
+		`delete module.slz_vsi.ibm_is_floating_ip.vsi_fip["vpc-subnet-a-0"]`,
+	}, addresses(report.Allowed))
+	assert.Equal(t, []string{
+		`replace module.slz_vsi.ibm_is_instance.vsi["vpc-subnet-a-0"]`,
+		`replace module.slz_vsi.ibm_iam_authorization_policy.block_storage_policy[0]`,
+	}, addresses(report.Violations))
+	assert.Equal(t, "5.4.0", report.Allowed[0].Release)
+
+	// upgrading from 5.1.0 no longer allows the floating IP deletion introduced by 5.1.0
+	report, err = Check(plan, allowList, "v5.1.0")
+	require.NoError(t, err)
+	assert.Len(t, report.Allowed, 1)
+	assert.Len(t, report.Violations, 3)
+
+	_, err = Check(plan, allowList, "not-a-version")
+	assert.Error(t, err)
+}
+
+// A change allowed by several releases is reported against the oldest of them, by semantic version
+func TestCheckReleaseOrder(t *testing.T) {
+	plan, err := tfplan.LoadPlan("testdata/plan_upgrade.json")
+	require.NoError(t, err)
+	instance := Entry{Address: `module.slz_vsi.ibm_is_instance.vsi[*]`, Actions: []string{"replace"}, Reason: "boot volume profile change"}
+	allowList := &AllowList{Releases: []Release{
+		{Version: UnreleasedVersion, Changes: []Entry{instance}},
+		{Version: "5.10.0", Changes: []Entry{instance}},
+		{Version: "5.9.0", Changes: []Entry{instance}},
+	}}
+
+	report, err := Check(plan, allowList, "")
+	require.NoError(t, err)
+	require.Len(t, report.Allowed, 1)
+	assert.Equal(t, "5.9.0", report.Allowed[0].Release)
+
+	report, err = Check(plan, allowList, "5.9.0")
+	require.NoError(t, err)
+	require.Len(t, report.Allowed, 1)
+	assert.Equal(t, "5.10.0", report.Allowed[0].Release)
+
+	report, err = Check(plan, allowList, "5.10.0")
+	require.NoError(t, err)
+	require.Len(t, report.Allowed, 1)
+	assert.Equal(t, UnreleasedVersion, report.Allowed[0].Release)
+}
+
+func TestValidateAllowList(t *testing.T) {
+	allowList, err := LoadAllowList("testdata/allowlist.yaml")
+	require.NoError(t, err)
+
+	moved := []tfconfig.MovedBlock{{From: "ibm_is_lb_pool_member.pool_members", To: "ibm_is_lb_pool_member.alb_pool_members"}}
+	assert.NoError(t, allowList.Validate(moved))
+
+	err = allowList.Validate(nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "references moved block ibm_is_lb_pool_member.pool_members -> ibm_is_lb_pool_member.alb_pool_members")
+	}
+
+	invalid := &AllowList{Releases: []Release{{Version: "next", Changes: []Entry{{Address: "ibm_is_volume.volume[*]", Actions: []string{"destroy"}}}}}}
+	err = invalid.Validate(nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `release "next": invalid version`)
+		assert.Contains(t, err.Error(), "has no reason")
+		assert.Contains(t, err.Error(), `unknown action "destroy"`)
+	}
+}
+
+// The checked in allow-list must stay valid against the moved blocks of the root module
+func TestRepoAllowList(t *testing.T) {
+	allowList, err := LoadAllowList("../../upgrade-safety-allowlist.yaml")
+	require.NoError(t, err)
+	moved, err := tfconfig.MovedBlocks("../../..")
+	require.NoError(t, err)
+	assert.NoError(t, allowList.Validate(moved))
+}
@@ -0,0 +1,86 @@
+variable "prefix" {
+  description = "The value that you would like to prefix to the name of the resources provisioned by this module."
+  type        = string
+
+  validation {
+    error_message = "Prefix must begin with a lowercase letter and be at most 16 characters."
+    condition     = can(regex("^[a-z]", var.prefix)) && length(var.prefix) <= 16
+  }
+}
+
+variable "image_id" {
+  description = "Image ID used for the VSI. Ignored if catalog_offering is set."
+  type        = string
+  default     = null
+}
+
+variable "vsi_per_subnet" {
+  description = "Number of VSI instances for each subnet."
+  type        = number
+  default     = 2
+}
+
+variable "enable_floating_ip" {
+  description = "Create a floating IP for each virtual server."
+  type        = bool
+  default     = false
+  nullable    = false
+}
+
+variable "block_storage_volumes" {
+  description = "List describing the block storage volumes."
+  type = list(
+    object({
+      name     = string
+      profile  = string
+      capacity = optional(number)
+      iops     = optional(number)
+      tags     = optional(list(string), ["vsi"])
+      snapshot = optional(string)
+    })
+  )
+  default = []
+}
+
+variable "load_balancers" {
+  description = "Load balancers to add to VSI."
+  type = list(
+    object({
+      name             = string
+      type             = string
+      pool_member_port = string
+      listener_port    = number
+      dns = optional(object({
+        instance_crn = string
+        zone_id      = optional(string)
+      }))
+    })
+  )
+  default = []
+}
+
+variable "user_data" {
+  description = "User data to initialize VSI deployment."
+  type        = string
+  default     = null
+}
+
+variable "kms_encryption_enabled" {
+  description = "Set this to true to control the encryption keys used to encrypt the data."
+  type        = bool
+  default     = false
+}
+
+variable "catalog_offering" {
+  description = "Catalog offering to provision the virtual server from."
+  type = object({
+    version_crn = string
+    plan_crn    = optional(string)
+  })
+  default = null
+}
+
+variable "resource_group_id" {
+  description = "ID of resource group to create VSI and block storage volumes."
+  type        = string
+}
@@ -0,0 +1,75 @@
+variable "prefix" {
+  description = "The value that you would like to prefix to the name of the resources provisioned by this module."
+  type        = string
+
+  validation {
+    error_message = "Prefix must begin with a lowercase letter."
+    condition     = can(regex("^[a-z]", var.prefix))
+  }
+}
+
+variable "image_id" {
+  description = "Image ID used for the VSI."
+  type        = string
+}
+
+variable "vsi_per_subnet" {
+  description = "Number of VSI instances for each subnet."
+  type        = number
+  default     = 1
+}
+
+variable "enable_floating_ip" {
+  description = "Create a floating IP for each virtual server."
+  type        = bool
+  default     = false
+}
+
+variable "boot_volume_encryption_key" {
+  description = "CRN of boot volume encryption key."
+  type        = string
+  default     = null
+}
+
+variable "block_storage_volumes" {
+  description = "List describing the block storage volumes."
+  type = list(
+    object({
+      name     = string
+      profile  = string
+      capacity = optional(number)
+      iops     = optional(number)
+      tags     = optional(list(string), [])
+    })
+  )
+  default = []
+}
+
+variable "load_balancers" {
+  description = "Load balancers to add to VSI."
+  type = list(
+    object({
+      name             = string
+      type             = string
+      pool_member_port = number
+      idle_timeout     = optional(number)
+      dns = optional(object({
+        instance_crn = string
+        zone_id      = string
+      }))
+    })
+  )
+  default = []
+}
+
+variable "user_data" {
+  description = "User data to initialize VSI deployment."
+  type        = string
+  default     = null
+}
+
+variable "kms_encryption_enabled" {
+  description = "Set this to true to control the encryption keys used to encrypt the data."
+  type        = bool
+  default     = false
+}
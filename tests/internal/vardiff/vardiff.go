@@ -0,0 +1,378 @@
+// Package vardiff compares the input variables of two revisions of the module (or of its wrappers and solutions)
+// and classifies every difference as a major, minor or patch change, so a breaking change to the variable
+// schema (a removed variable, a new required field, a tightened validation, a narrowed object type) is not
+// released by accident.
+package vardiff
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Dirs are the directories, relative to the module root, whose variables are part of the public interface
+var Dirs = []string{".", "modules/fscloud", "solutions/fully-configurable", "solutions/quickstart"}
+
+// Level is the semantic version bump a change requires
+type Level int
+
+const (
+	Patch Level = iota
+	Minor
+	Major
+)
+
+func (l Level) String() string {
+	switch l {
+	case Major:
+		return "major"
+	case Minor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// ParseLevel parses "major", "minor" or "patch"
+func ParseLevel(s string) (Level, error) {
+	for _, level := range []Level{Patch, Minor, Major} {
+		if level.String() == s {
+			return level, nil
+		}
+	}
+	return Patch, fmt.Errorf("unknown level %q, expected major, minor or patch", s)
+}
+
+// Change is a difference between the old and the new revision of a variable
+type Change struct {
+	Variable string
+	// Path is the attribute path inside the type of the variable, e.g. `[*].pool_member_port`, empty for the variable itself
+	Path   string
+	Level  Level
+	Detail string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("[%s] %s%s: %s", c.Level, c.Variable, c.Path, c.Detail)
+}
+
+// Report lists the changes between two revisions of a set of variables
+type Report struct {
+	Changes []Change
+}
+
+// Bump returns the highest level of the changes, Patch if there are none
+func (r *Report) Bump() Level {
+	bump := Patch
+	for _, change := range r.Changes {
+		if change.Level > bump {
+			bump = change.Level
+		}
+	}
+	return bump
+}
+
+// AtLeast returns the changes of the given level or above
+func (r *Report) AtLeast(level Level) []Change {
+	var changes []Change
+	for _, change := range r.Changes {
+		if change.Level >= level {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// String renders the changes, most severe first
+func (r *Report) String() string {
+	if len(r.Changes) == 0 {
+		return "No change to the input variables.\n"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d change(s) to the input variables, requires a %s release:\n", len(r.Changes), r.Bump())
+	for _, change := range r.Changes {
+		fmt.Fprintf(&sb, "  - %s\n", change)
+	}
+	return sb.String()
+}
+
+// LoadDir returns the variables declared in the .tf files of a directory
+func LoadDir(dir string) ([]tfconfig.Variable, error) {
+	files, err := tfconfig.ParseDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return tfconfig.Variables(files)
+}
+
+// LoadDirAtRef returns the variables declared in the .tf files of a directory (relative to the module root) at a
+// git ref. The returned bool is false if the directory does not exist at that ref.
+func LoadDirAtRef(root string, ref string, dir string) ([]tfconfig.Variable, bool, error) {
+	paths, found, err := fixtures.ListAtRef(root, ref, dir)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	var files []*tfconfig.File
+	for _, path := range paths {
+		if filepath.Ext(path) != ".tf" {
+			continue
+		}
+		source, _, err := fixtures.ReadAtRef(root, ref, path)
+		if err != nil {
+			return nil, true, err
+		}
+		file, err := tfconfig.ParseSource(ref+":"+path, source)
+		if err != nil {
+			return nil, true, err
+		}
+		files = append(files, file)
+	}
+	variables, err := tfconfig.Variables(files)
+	return variables, true, err
+}
+
+// Compare returns the changes between the old and the new variables
+func Compare(old []tfconfig.Variable, new []tfconfig.Variable) *Report {
+	oldByName := map[string]tfconfig.Variable{}
+	for _, variable := range old {
+		oldByName[variable.Name] = variable
+	}
+	newByName := map[string]tfconfig.Variable{}
+	for _, variable := range new {
+		newByName[variable.Name] = variable
+	}
+
+	report := &Report{}
+	for _, o := range old {
+		if _, ok := newByName[o.Name]; !ok {
+			report.Changes = append(report.Changes, Change{Variable: o.Name, Level: Major, Detail: "variable removed"})
+		}
+	}
+	for _, n := range new {
+		o, ok := oldByName[n.Name]
+		if !ok {
+			if n.HasDefault {
+				report.Changes = append(report.Changes, Change{Variable: n.Name, Level: Minor, Detail: "optional variable added"})
+			} else {
+				report.Changes = append(report.Changes, Change{Variable: n.Name, Level: Major, Detail: "required variable added"})
+			}
+			continue
+		}
+		report.Changes = append(report.Changes, compareVariable(o, n)...)
+	}
+	sort.SliceStable(report.Changes, func(i, j int) bool {
+		if report.Changes[i].Level != report.Changes[j].Level {
+			return report.Changes[i].Level > report.Changes[j].Level
+		}
+		if report.Changes[i].Variable != report.Changes[j].Variable {
+			return report.Changes[i].Variable < report.Changes[j].Variable
+		}
+		return report.Changes[i].Path < report.Changes[j].Path
+	})
+	return report
+}
+
+func compareVariable(o tfconfig.Variable, n tfconfig.Variable) []Change {
+	var changes []Change
+	add := func(level Level, format string, args ...interface{}) {
+		changes = append(changes, Change{Variable: n.Name, Level: level, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case o.HasDefault && !n.HasDefault:
+		add(Major, "default removed, the variable is now required")
+	case !o.HasDefault && n.HasDefault:
+		add(Minor, "default added, the variable is now optional")
+	case o.HasDefault && !sameDefault(o, n):
+		// existing consumers relying on the default get a different value, but their configuration stays valid
+		add(Minor, "default changed from %s to %s", o.DefaultText, n.DefaultText)
+	}
+
+	if o.Nullable && !n.Nullable {
+		add(Major, "no longer nullable")
+	} else if !o.Nullable && n.Nullable {
+		add(Minor, "now nullable")
+	}
+
+	changes = append(changes, compareValidations(n.Name, o.Validations, n.Validations)...)
+
+	for _, change := range compareTypes(o.Type, n.Type, o.TypeDefaults, n.TypeDefaults, "") {
+		change.Variable = n.Name
+		changes = append(changes, change)
+	}
+
+	if o.Description != n.Description {
+		add(Patch, "description changed")
+	}
+	return changes
+}
+
+// sameDefault compares the evaluated defaults, or their source text when they can not be evaluated
+func sameDefault(o tfconfig.Variable, n tfconfig.Variable) bool {
+	if o.Default != cty.NilVal && n.Default != cty.NilVal {
+		return sameValue(o.Default, n.Default)
+	}
+	return normalize(o.DefaultText) == normalize(n.DefaultText)
+}
+
+func sameValue(a cty.Value, b cty.Value) bool {
+	if a.IsNull() || b.IsNull() {
+		return a.IsNull() == b.IsNull()
+	}
+	if !a.IsWhollyKnown() || !b.IsWhollyKnown() {
+		return false
+	}
+	return a.Equals(b).True()
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// compareValidations matches validations on their condition. A new or modified condition may reject values that
+// were accepted before, so it is a major change. Removed conditions only accept more values.
+func compareValidations(name string, old []tfconfig.Validation, new []tfconfig.Validation) []Change {
+	oldConditions := map[string]tfconfig.Validation{}
+	for _, validation := range old {
+		oldConditions[normalize(validation.Condition)] = validation
+	}
+	newConditions := map[string]bool{}
+	var changes []Change
+	for _, validation := range new {
+		condition := normalize(validation.Condition)
+		newConditions[condition] = true
+		previous, ok := oldConditions[condition]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Variable: name, Level: Major, Detail: fmt.Sprintf("validation added or tightened: %s", condition)})
+		case previous.ErrorMessage != validation.ErrorMessage:
+			changes = append(changes, Change{Variable: name, Level: Patch, Detail: fmt.Sprintf("validation error message changed: %s", condition)})
+		}
+	}
+	for _, validation := range old {
+		condition := normalize(validation.Condition)
+		if !newConditions[condition] {
+			changes = append(changes, Change{Variable: name, Level: Patch, Detail: fmt.Sprintf("validation removed: %s", condition)})
+		}
+	}
+	return changes
+}
+
+// compareTypes walks two type constraints side by side
+func compareTypes(o cty.Type, n cty.Type, oDefaults *typeexpr.Defaults, nDefaults *typeexpr.Defaults, path string) []Change {
+	changeType := func(level Level) []Change {
+		return []Change{{Path: path, Level: level, Detail: fmt.Sprintf("type changed from %s to %s", typeexpr.TypeString(o), typeexpr.TypeString(n))}}
+	}
+	switch {
+	case o.Equals(cty.DynamicPseudoType) && n.Equals(cty.DynamicPseudoType):
+		return nil
+	case n.Equals(cty.DynamicPseudoType):
+		return changeType(Minor)
+	case o.Equals(cty.DynamicPseudoType):
+		return changeType(Major)
+	case o.IsPrimitiveType() && n.IsPrimitiveType():
+		if o.Equals(n) {
+			return nil
+		}
+		// numbers and bools still convert to strings
+		if n.Equals(cty.String) {
+			return changeType(Minor)
+		}
+		return changeType(Major)
+	case o.IsObjectType() && n.IsObjectType():
+		return compareObjects(o, n, oDefaults, nDefaults, path)
+	case o.IsListType() && n.IsListType(), o.IsSetType() && n.IsSetType():
+		return compareTypes(o.ElementType(), n.ElementType(), child(oDefaults, ""), child(nDefaults, ""), path+"[*]")
+	case o.IsMapType() && n.IsMapType():
+		return compareTypes(o.ElementType(), n.ElementType(), child(oDefaults, ""), child(nDefaults, ""), path+"[*]")
+	case o.Equals(n):
+		return nil
+	default:
+		return changeType(Major)
+	}
+}
+
+func compareObjects(o cty.Type, n cty.Type, oDefaults *typeexpr.Defaults, nDefaults *typeexpr.Defaults, path string) []Change {
+	var changes []Change
+	add := func(attrPath string, level Level, format string, args ...interface{}) {
+		changes = append(changes, Change{Path: attrPath, Level: level, Detail: fmt.Sprintf(format, args...)})
+	}
+	oAttrs := o.AttributeTypes()
+	nAttrs := n.AttributeTypes()
+	names := make([]string, 0, len(oAttrs)+len(nAttrs))
+	for name := range oAttrs {
+		names = append(names, name)
+	}
+	for name := range nAttrs {
+		if _, ok := oAttrs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		attrPath := path + "." + name
+		oType, inOld := oAttrs[name]
+		nType, inNew := nAttrs[name]
+		switch {
+		case !inNew:
+			// terraform drops unknown attributes silently, consumers setting it would lose the setting
+			add(attrPath, Major, "object attribute removed")
+		case !inOld:
+			if n.AttributeOptional(name) {
+				add(attrPath, Minor, "optional object attribute added")
+			} else {
+				add(attrPath, Major, "required object attribute added")
+			}
+		default:
+			if o.AttributeOptional(name) && !n.AttributeOptional(name) {
+				add(attrPath, Major, "object attribute is now required")
+			} else if !o.AttributeOptional(name) && n.AttributeOptional(name) {
+				add(attrPath, Minor, "object attribute is now optional")
+			}
+			oDefault, oHas := defaultValue(oDefaults, name)
+			nDefault, nHas := defaultValue(nDefaults, name)
+			if n.AttributeOptional(name) && o.AttributeOptional(name) && (oHas != nHas || (oHas && !sameValue(oDefault, nDefault))) {
+				add(attrPath, Minor, "optional attribute default changed from %s to %s", valueString(oDefault, oHas), valueString(nDefault, nHas))
+			}
+			changes = append(changes, compareTypes(oType, nType, child(oDefaults, name), child(nDefaults, name), attrPath)...)
+		}
+	}
+	return changes
+}
+
+func child(defaults *typeexpr.Defaults, key string) *typeexpr.Defaults {
+	if defaults == nil {
+		return nil
+	}
+	return defaults.Children[key]
+}
+
+func defaultValue(defaults *typeexpr.Defaults, name string) (cty.Value, bool) {
+	if defaults == nil {
+		return cty.NilVal, false
+	}
+	value, ok := defaults.DefaultValues[name]
+	return value, ok
+}
+
+func valueString(value cty.Value, ok bool) string {
+	switch {
+	case !ok || value.IsNull():
+		return "null"
+	case !value.IsWhollyKnown():
+		return "(unknown)"
+	}
+	out, err := ctyjson.Marshal(value, value.Type())
+	if err != nil {
+		return typeexpr.TypeString(value.Type()) + " value"
+	}
+	return string(out)
+}
@@ -0,0 +1,90 @@
+package vardiff
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+)
+
+func TestCompare(t *testing.T) {
+	old, err := LoadDir("testdata/old")
+	require.NoError(t, err)
+	new, err := LoadDir("testdata/new")
+	require.NoError(t, err)
+
+	report := Compare(old, new)
+	var changes []string
+	for _, change := range report.Changes {
+		changes = append(changes, change.String())
+	}
+	assert.Equal(t, []string{
+		`[major] boot_volume_encryption_key: variable removed`,
+		`[major] enable_floating_ip: no longer nullable`,
+		`[major] load_balancers[*].idle_timeout: object attribute removed`,
+		`[major] load_balancers[*].listener_port: required object attribute added`,
+		`[major] prefix: validation added or tightened: can(regex("^[a-z]", var.prefix)) && length(var.prefix) <= 16`,
+		`[major] resource_group_id: required variable added`,
+		`[minor] block_storage_volumes[*].snapshot: optional object attribute added`,
+		`[minor] block_storage_volumes[*].tags: optional attribute default changed from [] to ["vsi"]`,
+		`[minor] catalog_offering: optional variable added`,
+		`[minor] image_id: default added, the variable is now optional`,
+		`[minor] load_balancers[*].dns.zone_id: object attribute is now optional`,
+		`[minor] load_balancers[*].pool_member_port: type changed from number to string`,
+		`[minor] vsi_per_subnet: default changed from 1 to 2`,
+		`[patch] image_id: description changed`,
+		`[patch] prefix: validation removed: can(regex("^[a-z]", var.prefix))`,
+	}, changes)
+	assert.Equal(t, Major, report.Bump())
+	assert.Len(t, report.AtLeast(Minor), 13)
+	assert.Contains(t, report.String(), "15 change(s) to the input variables, requires a major release")
+
+	// no change at all
+	report = Compare(new, new)
+	assert.Empty(t, report.Changes)
+	assert.Equal(t, Patch, report.Bump())
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("minor")
+	require.NoError(t, err)
+	assert.Equal(t, Minor, level)
+	_, err = ParseLevel("breaking")
+	assert.Error(t, err)
+}
+
+// TestVariablesSincePreviousRelease fails on breaking changes to the variables of the module, the fscloud profile and
+// the solutions since the previous release tag (or PREVIOUS_RELEASE_REF). Set ALLOW_BREAKING_CHANGES=true for a
+// change that is released as a new major version.
+func TestVariablesSincePreviousRelease(t *testing.T) {
+	root, err := fixtures.RepoRoot()
+	require.NoError(t, err)
+
+	for _, dir := range Dirs {
+		t.Run(dir, func(t *testing.T) {
+			// the variables at HEAD must always parse
+			new, err := LoadDir(filepath.Join(root, dir))
+			require.NoError(t, err)
+
+			ref := fixtures.PreviousReleaseRef(root)
+			if ref == "" {
+				t.Skip("no previous release tag, set PREVIOUS_RELEASE_REF to compare against a specific ref")
+			}
+			old, found, err := LoadDirAtRef(root, ref, dir)
+			require.NoError(t, err)
+			if !found {
+				t.Skipf("%s does not exist at %s", dir, ref)
+			}
+
+			report := Compare(old, new)
+			t.Log(report.String())
+			if os.Getenv("ALLOW_BREAKING_CHANGES") == "true" {
+				return
+			}
+			assert.Empty(t, report.AtLeast(Major), "breaking changes to the variables of %s since %s", dir, ref)
+		})
+	}
+}
@@ -2,20 +2,36 @@
 package test
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/terraform-ibm-modules/ibmcloud-terratest-wrapper/testhelper"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/faultproxy"
+	testregion "github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/region"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/timing"
 )
 
 func TestRunBasicExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "basic")
+	checkCost(t, "basic")
+	acquireQuota(t, region, "basic")
 
 	options := setupOptions(t, basicExampleTerraformDir, "slz-vsi-basic")
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "basic"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
 
@@ -28,11 +44,17 @@ func TestRunCatalogImageExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "catalog-image")
+	checkCost(t, "catalog-image")
+	catalogRegion := selectRegion(t, testregion.Requirements{Capabilities: []string{testregion.CatalogImages}})
+	acquireQuota(t, catalogRegion, "catalog-image")
 
-	options := setupOptions(t, catalogImageExampleTerraformDir, "slz-vsi-cat")
+	options := setupOptionsInRegion(t, catalogImageExampleTerraformDir, "slz-vsi-cat", catalogRegion)
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "catalog-image"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
 
@@ -40,10 +62,82 @@ func TestRunGen2BootExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "gen2-storage")
+	checkCost(t, "gen2-storage")
+	gen2Region := selectRegion(t, testregion.Requirements{Capabilities: []string{testregion.SDPBoot}})
+	acquireQuota(t, gen2Region, "gen2-storage")
 
-	options := setupOptions(t, gen2bootExampleTerraformDir, "slz-vsi-gen2")
+	options := setupOptionsInRegion(t, gen2bootExampleTerraformDir, "slz-vsi-gen2", gen2Region)
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "gen2-storage"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
+
+// TestFaultInjection runs the consistency test of the example of the scenario FAULT_INJECTION_SCENARIO of
+// fault-scenarios.yaml with the VPC API behind internal/faultproxy, the faults staying injected while the harness
+// tears it down, and checks the provider retried the injected failures and the teardown left no resource behind
+func TestFaultInjection(t *testing.T) {
+	name := os.Getenv("FAULT_INJECTION_SCENARIO")
+	if name == "" {
+		t.Skip("FAULT_INJECTION_SCENARIO is not set")
+	}
+	scenario, ok := faultScenarios.Scenarios[name]
+	require.True(t, ok, "unknown fault injection scenario %q, one of %s", name, strings.Join(faultScenarios.Names(), ", "))
+	t.Parallel()
+	acquireTestSlot()
+	defer releaseTestSlot()
+
+	region := selectRegion(t, testregion.Requirements{})
+	server, err := faultproxy.NewServer(fmt.Sprintf("https://%s.iaas.cloud.ibm.com", region), scenario.Faults, false)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		t.Logf("faults injected by %s:\n%s", name, server.Proxy)
+		assert.Empty(t, server.Proxy.Verify(), "the faults of %s did not exercise the retries", name)
+		server.Close()
+	})
+	t.Logf("injecting %s into the VPC API of %s: %s", name, region, scenario.Description)
+
+	options := setupOptionsInRegion(t, scenario.Example, "slz-vsi-flt", region)
+	// the provider calls the VPC API through the proxy, and the other services directly. The faults exercise the
+	// retries of the provider, so the runs are not retried on the rules of retryPolicy, only on the errors of the wrapper.
+	hook := options.PreApplyHook
+	options.PreApplyHook = func(options *testhelper.TestOptions) error {
+		if options.TerraformOptions.EnvVars == nil {
+			options.TerraformOptions.EnvVars = map[string]string{}
+		}
+		options.TerraformOptions.EnvVars["IBMCLOUD_IS_NG_API_ENDPOINT"] = server.URL + "/v1"
+		retryable, retries, between := options.TerraformOptions.RetryableTerraformErrors, options.TerraformOptions.MaxRetries, options.TerraformOptions.TimeBetweenRetries
+		if hook != nil {
+			if err := hook(options); err != nil {
+				return err
+			}
+		}
+		options.TerraformOptions.RetryableTerraformErrors, options.TerraformOptions.MaxRetries, options.TerraformOptions.TimeBetweenRetries = retryable, retries, between
+		return nil
+	}
+	options.PostDestroyHook = func(options *testhelper.TestOptions) error {
+		leftovers, err := terraform.RunTerraformCommandContextE(t, context.Background(), options.TerraformOptions, "state", "list")
+		if assert.NoError(t, err) {
+			assert.Empty(t, strings.TrimSpace(leftovers), "resources left behind by the teardown")
+		}
+		return nil
+	}
+	checkIdempotency(t, options)
+	test := recordTest(t, "")
+	recordPhases(test, options, timing.ConsistencyCheck)
+
+	_, err = options.RunTestConsistency()
+	if scenario.ApplyFails {
+		assert.Error(t, err, "the faults of %s were expected to fail the apply", name)
+	} else {
+		assertNoFailure(t, err)
+	}
+	// the apply phase ends at the PostApplyHook, where the consistency check begins
+	if apply := test.Phase(timing.Apply); scenario.MaxApply > 0 && assert.NotNil(t, apply, "no apply was recorded") {
+		t.Logf("the apply of %s took %s", name, apply.Duration().Round(time.Second))
+		assert.LessOrEqual(t, apply.Duration(), scenario.MaxApply, "the apply of %s took longer than its max_apply", name)
+	}
+}
@@ -2,17 +2,23 @@ package test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/IBM/go-sdk-core/v5/core"
 	"github.com/gruntwork-io/terratest/modules/files"
 	"github.com/gruntwork-io/terratest/modules/logger"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/terraform-ibm-modules/ibmcloud-terratest-wrapper/cloudinfo"
@@ -20,6 +26,24 @@ import (
 	"github.com/terraform-ibm-modules/ibmcloud-terratest-wrapper/testaddons"
 	"github.com/terraform-ibm-modules/ibmcloud-terratest-wrapper/testhelper"
 	"github.com/terraform-ibm-modules/ibmcloud-terratest-wrapper/testschematic"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/cost"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/drift"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/failures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/faultproxy"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/fixtures"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/httpreplay"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/idempotency"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/permanent"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/prereq"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/profiles"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/quota"
+	testregion "github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/region"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/retry"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/schematics"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfconfig"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/tfplan"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/timing"
+	"github.com/terraform-ibm-modules/terraform-ibm-landing-zone-vsi/internal/upgradegate"
 )
 
 const basicExampleTerraformDir = "examples/basic"
@@ -41,21 +65,182 @@ const region = "us-south"
 // Define a struct with fields that match the structure of the YAML data
 const yamlLocation = "../common-dev-assets/common-go-assets/common-permanent-resources.yaml"
 
-var permanentResources map[string]interface{}
+var permanentResources *permanent.Resources
+
+// regionSelector places the tests that need a region capability, or that provision their own prerequisites, in the
+// compliant region with the fewest VPCs
+var regionSelector *testregion.Selector
+
+// quotaGate holds back tests whose resources do not fit in the account quotas, set when TF_VAR_ibmcloud_api_key is set
+var quotaGate *quota.Gate
+
+// profileCatalog rejects the examples whose instance and volume profiles the API would reject at apply
+var profileCatalog *profiles.Catalog
+
+// costPrices and costBudgets fail the tests whose plan fixture costs more than their budget
+var costPrices *cost.Prices
+var costBudgets *cost.Budgets
+
+// failureRules classify the Terraform errors of a failed run at the top of the failure message
+var failureRules *failures.Rules
+
+// idempotencySignatures are the known changes of the second plan of the consistency tests, exempted from their check
+var idempotencySignatures *idempotency.Signatures
+
+// retryPolicy retries the Terraform runs of the prerequisites, of the test wrapper and of the drift simulation, and the
+// Schematics apply jobs, that failed on a transient error of the VPC API
+var retryPolicy *retry.Policy
+
+// schematicsPoll is how often the status of a Schematics job run again by retrySchematicApply is read
+const schematicsPoll = 30 * time.Second
+
+// faultScenarios are the faults TestFaultInjection injects into the VPC API, the one of FAULT_INJECTION_SCENARIO
+var faultScenarios *faultproxy.Scenarios
+
+// upgradeAllowList fails the upgrade tests whose plan destroys or replaces a data-bearing resource that no release
+// since upgradeRelease allows, the release of upgradeBase, the branch the wrapper upgrades from
+var upgradeAllowList *upgradegate.AllowList
+var upgradeBase string
+var upgradeRelease string
+
+// cassettes record or replay the requests the harness sends to IBM Cloud APIs (HTTP_CASSETTE_MODE), saved after the run
+var cassettes []*httpreplay.Transport
+
+// schematicsCassette holds the Schematics lookups of the upgrade and failure checks
+var schematicsCassette *httpreplay.Transport
+
+// testReport records the phases of the tests, written as junit.xml and summary.json to TEST_REPORT_DIR after the run
+var testReport = timing.NewRecorder(timing.SystemClock{})
 
 // Channel to limit parallel test execution to 6 at a time
 var testSemaphore = make(chan struct{}, 6)
 
 // TestMain will be run before any parallel tests, used to read data from yaml for use with tests
 func TestMain(m *testing.M) {
-	// Read the YAML file contents
+	// Read the YAML file contents, failing on missing keys or malformed values
 	var err error
-	permanentResources, err = common.LoadMapFromYaml(yamlLocation)
+	permanentResources, err = permanent.Load(yamlLocation)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// Optionally check that the permanent resources still exist before starting long running tests
+	if strings.ToLower(os.Getenv("PERMANENT_RESOURCES_PREFLIGHT")) == "true" {
+		client, err := permanent.NewSearchClient(os.Getenv("TF_VAR_ibmcloud_api_key"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		cassette := openCassette("permanent-preflight")
+		cassette.Install(client.Service())
+		problems := permanent.Preflight(context.Background(), client, permanentResources)
+		if err := cassette.Save(); err != nil {
+			log.Fatal(err)
+		}
+		if len(problems) > 0 {
+			log.Fatalf("permanent resources preflight failed:\n%s", strings.Join(problems, "\n"))
+		}
+	}
 
-	os.Exit(m.Run())
+	regionTable, err := testregion.LoadTable("region-capabilities.yaml", filepath.Join("..", testregion.PrefsPath))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the table only filters the regions, they are ranked by their number of VPCs like testhelper.GetBestVpcRegion
+	var regionLoads testregion.LoadProvider
+	if apiKey := os.Getenv("TF_VAR_ibmcloud_api_key"); apiKey != "" {
+		loads, err := testregion.NewVPCLoads(apiKey, regionTable.Candidates(testregion.Requirements{}))
+		if err != nil {
+			log.Fatal(err)
+		}
+		cassette := openCassette("region-loads")
+		for _, name := range regionTable.Candidates(testregion.Requirements{}) {
+			cassette.Install(loads.Service(name))
+		}
+		regionLoads = loads
+	}
+	regionSelector = testregion.NewSelector(regionTable, regionLoads)
+
+	profileCatalog, err = profiles.LoadCatalog("machine-profiles.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	costPrices, err = cost.LoadPrices("cost-prices.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	costBudgets, err = cost.LoadBudgets("cost-budgets.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	failureRules, err = failures.LoadRules("failure-classes.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	idempotencySignatures, err = idempotency.LoadSignatures("idempotency-signatures.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	retryPolicy, err = retry.LoadPolicy("retry-policy.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	faultScenarios, err = faultproxy.LoadScenarios("fault-scenarios.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	upgradeAllowList, err = upgradegate.LoadAllowList("upgrade-safety-allowlist.yaml")
+	if err != nil {
+		log.Fatal(err)
+	}
+	moved, err := tfconfig.MovedBlocks("..")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := upgradeAllowList.Validate(moved); err != nil {
+		log.Fatal(err)
+	}
+	upgradeBase = fixtures.UpgradeBaseRef("..")
+	upgradeRelease = fixtures.ReleaseVersion("..", upgradeBase)
+
+	// wait for quota, or skip, before applying examples that would hit an account limit: the usage of the region of
+	// each test is read from the VPC and resource controller APIs, the limits from quota-limits.yaml
+	if apiKey := os.Getenv("TF_VAR_ibmcloud_api_key"); apiKey != "" {
+		limits, err := quota.LoadLimits("quota-limits.yaml")
+		if err != nil {
+			log.Fatal(err)
+		}
+		quotaProvider, err := quota.NewAPIProvider(apiKey, limits)
+		if err != nil {
+			log.Fatal(err)
+		}
+		quotaProvider.InstallTransport(openCassette("quota-usage").Install)
+		quotaGate = quota.NewGate(quotaProvider)
+	}
+	schematicsCassette = openCassette("schematics")
+
+	code := m.Run()
+	for _, cassette := range cassettes {
+		if err := cassette.Save(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if dir := os.Getenv("TEST_REPORT_DIR"); dir != "" {
+		if err := testReport.WriteFiles(dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	os.Exit(code)
+}
+
+// openCassette returns the transport of a cassette of fixtures/cassettes in the mode of HTTP_CASSETTE_MODE: record
+// sends the requests and saves them after the run, replay answers from the cassette (recorded first, none is checked
+// in), anything else passes the requests through
+func openCassette(name string) *httpreplay.Transport {
+	cassette, err := httpreplay.FromEnv(filepath.Join("fixtures", "cassettes", name+".json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cassettes = append(cassettes, cassette)
+	return cassette
 }
 
 // acquireTestSlot acquires a slot to limit parallel execution to 7 tests at a time
@@ -68,7 +253,325 @@ func releaseTestSlot() {
 	<-testSemaphore
 }
 
+// acquireQuota waits until the resources of the plan fixture of an example, plus the extra resources the fixture does
+// not plan, fit in the account quotas of the region, and skips the test if they never will. The quota is released when
+// the test ends.
+func acquireQuota(t *testing.T, region string, fixture string, extra ...quota.Demand) {
+	if quotaGate == nil {
+		return
+	}
+	demand, err := quota.LoadDemand(filepath.Join("fixtures", "plans", fixture+".json"))
+	require.NoError(t, err)
+	for _, resources := range extra {
+		demand = demand.Add(resources)
+	}
+	err = quotaGate.Acquire(context.Background(), t.Name(), region, demand)
+	if errors.Is(err, quota.ErrDoesNotFit) {
+		t.Skip(err)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { quotaGate.Release(region, demand) })
+}
+
+// checkProfiles fails the test before the apply when the instance and volume profiles of the plan fixture of an
+// example do not fit together, printing the fix of each problem
+func checkProfiles(t *testing.T, fixture string) {
+	report, err := profileCatalog.CheckPlanFile(filepath.Join("fixtures", "plans", fixture+".json"))
+	require.NoError(t, err)
+	require.False(t, report.Failed(), report.String())
+}
+
+// checkCost logs the estimated cost of the plan fixture of an example, and fails the test before the apply when it is
+// over the budget of the test in cost-budgets.yaml
+func checkCost(t *testing.T, fixture string) {
+	budget, ok := costBudgets.Budgets[t.Name()]
+	require.True(t, ok, "no budget for %s in cost-budgets.yaml", t.Name())
+	require.Equal(t, fixture, budget.Fixture, "fixture of the budget of %s in cost-budgets.yaml", t.Name())
+	plan, err := tfplan.LoadPlan(filepath.Join("fixtures", "plans", fixture+".json"))
+	require.NoError(t, err)
+	estimate := costPrices.EstimatePlan(plan)
+	t.Logf("cost of %s:\n%s", fixture, estimate)
+	over := budget.Over(estimate)
+	require.Empty(t, over, "%s over budget: %s", t.Name(), strings.Join(over, ", "))
+}
+
+// assertNoFailure fails the test when a run of the test wrapper failed, with the failing resources, error codes,
+// request IDs and classes of the Terraform errors of the run before its error
+func assertNoFailure(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		assert.Fail(t, failureRules.Classify(err.Error()).String(), "This should not have errored: %v", err)
+	}
+}
+
+// checkUpgrade fails the test when the upgrade plan destroys or replaces data-bearing resources that are not in
+// upgrade-safety-allowlist.yaml
+func checkUpgrade(t *testing.T, plan *tfjson.Plan) {
+	report, err := upgradegate.Check(plan, upgradeAllowList, upgradeRelease)
+	if !assert.NoError(t, err) {
+		return
+	}
+	t.Logf("upgrade gate from %s (release %s):\n%s", upgradeBase, upgradeRelease, report)
+	assert.False(t, report.Failed(), report.String())
+}
+
+// schematicsWorkspace returns the workspace the test wrapper created for a Schematics test, by its ID, and a client of
+// its location
+func schematicsWorkspace(ctx context.Context, id string) (*schematics.Client, *schematics.Workspace, error) {
+	if id == "" {
+		return nil, nil, fmt.Errorf("the test wrapper created no Schematics workspace")
+	}
+	for _, location := range schematics.Locations {
+		client, err := schematics.NewClient(os.Getenv("TF_VAR_ibmcloud_api_key"), location)
+		if err != nil {
+			return nil, nil, err
+		}
+		schematicsCassette.Install(client.Service())
+		workspace, err := client.Workspace(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if workspace != nil {
+			return client, workspace, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no Schematics workspace %s in %s", id, strings.Join(schematics.Locations, ", "))
+}
+
+// schematicsUpgradePlan returns the plan of the upgrade of a Schematics upgrade test, the last plan job of its workspace
+func schematicsUpgradePlan(ctx context.Context, workspaceID string) (*tfjson.Plan, error) {
+	client, workspace, err := schematicsWorkspace(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := client.LastJob(ctx, workspace.ID, schematics.Plan, schematics.Completed)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("no completed plan job in workspace %s", workspace.Name)
+	}
+	return client.PlanJSON(ctx, job.ID)
+}
+
+// schematicsFailedLog returns the log of the last job of the workspace of a Schematics test when it failed
+func schematicsFailedLog(ctx context.Context, workspaceID string) (string, error) {
+	client, workspace, err := schematicsWorkspace(ctx, workspaceID)
+	if err != nil {
+		return "", err
+	}
+	return client.FailedLog(ctx, workspace)
+}
+
+// retrySchematicApply runs the failed apply job of a Schematics test again while the error of its log matches a rule
+// of retryPolicy, then plans the workspace again and classifies the changes with idempotencySignatures, the
+// consistency check the wrapper skipped. It returns whether the apply job completed when retried.
+func retrySchematicApply(t *testing.T, workspaceID string, failedLog string) bool {
+	ctx := context.Background()
+	client, workspace, err := schematicsWorkspace(ctx, workspaceID)
+	if err != nil {
+		t.Logf("error reading the Schematics workspace to retry its apply job: %v", err)
+		return false
+	}
+	job, err := client.LastJob(ctx, workspace.ID, "", "")
+	if err != nil || job == nil || job.Name != schematics.Apply || job.Status != schematics.Failed {
+		return false
+	}
+	name := fmt.Sprintf("Schematics apply job of %s", workspace.Name)
+	failed := fmt.Errorf("apply job %s of workspace %s failed", job.ID, workspace.Name)
+	_, err = retry.NewRunner(retryPolicy, t.Logf).Retry(ctx, name, failedLog, failed, func() (string, error) {
+		_, log, err := client.RunJob(ctx, workspace, schematics.Apply, schematicsPoll)
+		return log, err
+	})
+	if err != nil {
+		return false
+	}
+	planJob, _, err := client.RunJob(ctx, workspace, schematics.Plan, schematicsPoll)
+	if !assert.NoError(t, err, "error planning again after the retried apply") {
+		return true
+	}
+	plan, err := client.PlanJSON(ctx, planJob.ID)
+	if !assert.NoError(t, err, "error reading the plan after the retried apply") {
+		return true
+	}
+	report := idempotency.Classify(plan, idempotencySignatures)
+	t.Log(report.String())
+	assert.False(t, report.Failed(), report.String())
+	return true
+}
+
+// checkSchematicFailure reads the log of the failed job of a Schematics test before the wrapper destroys and deletes
+// its workspace, retrying the job with retrySchematicApply when it is an apply that failed on a transient error, and
+// returns the assertNoFailure of the run: the wrapper only returns a short error, so the Terraform errors of the log are
+// classified instead, or the error when there is no log. A run whose apply job completed when retried does not fail.
+// Call it before checkSchematicUpgrade, which reads the upgrade plan before the retry plans again.
+func checkSchematicFailure(t *testing.T, options *testschematic.TestSchematicOptions) func(err error) {
+	var failedLog string
+	var recovered bool
+	read := func() {
+		if failedLog != "" {
+			return
+		}
+		log, err := schematicsFailedLog(context.Background(), options.WorkspaceID)
+		if err != nil {
+			t.Logf("error reading the log of the failed Schematics job: %v", err)
+		}
+		failedLog = log
+	}
+	hook := options.PreDestroyHook
+	options.PreDestroyHook = func(options *testschematic.TestSchematicOptions) error {
+		read()
+		if failedLog != "" {
+			recovered = retrySchematicApply(t, options.WorkspaceID, failedLog)
+		}
+		if hook != nil {
+			return hook(options)
+		}
+		return nil
+	}
+	return func(err error) {
+		t.Helper()
+		if err == nil {
+			return
+		}
+		if recovered {
+			t.Logf("the Schematics apply job completed when retried after the run failed: %v", err)
+			return
+		}
+		read()
+		log := failedLog
+		if log == "" {
+			log = err.Error()
+		}
+		assert.Fail(t, failureRules.Classify(log).String(), "This should not have errored: %v", err)
+	}
+}
+
+// checkSchematicUpgrade runs checkUpgrade on the plan of the upgrade of a Schematics upgrade test before its workspace
+// is destroyed
+func checkSchematicUpgrade(t *testing.T, options *testschematic.TestSchematicOptions) {
+	hook := options.PreDestroyHook
+	options.PreDestroyHook = func(options *testschematic.TestSchematicOptions) error {
+		if !options.UpgradeTestSkipped {
+			plan, err := schematicsUpgradePlan(context.Background(), options.WorkspaceID)
+			if assert.NoError(t, err, "error reading the upgrade plan") {
+				checkUpgrade(t, plan)
+			}
+		}
+		if hook != nil {
+			return hook(options)
+		}
+		return nil
+	}
+}
+
+// checkIdempotency plans a consistency test again after its apply and classifies the changes with
+// idempotencySignatures: the resources whose changes all have a known signature are exempted from the consistency check
+// of the wrapper, and the test fails only when a change has no known signature
+func checkIdempotency(t *testing.T, options *testhelper.TestOptions) {
+	hook := options.PostApplyHook
+	options.PostApplyHook = func(options *testhelper.TestOptions) error {
+		if hook != nil {
+			if err := hook(options); err != nil {
+				return err
+			}
+		}
+		planOptions := *options.TerraformOptions
+		planOptions.PlanFilePath = filepath.Join(t.TempDir(), "second.tfplan")
+		plan, err := terraform.InitAndPlanAndShowWithStructContextE(t, context.Background(), &planOptions)
+		if !assert.NoError(t, err, "error planning again after the apply") {
+			return nil
+		}
+		report := idempotency.Classify(&plan.RawPlan, idempotencySignatures)
+		t.Log(report.String())
+		assert.False(t, report.Failed(), report.String())
+		exemptions := report.Exemptions()
+		options.IgnoreAdds.List = append(options.IgnoreAdds.List, exemptions.Adds...)
+		options.IgnoreUpdates.List = append(options.IgnoreUpdates.List, exemptions.Updates...)
+		options.IgnoreDestroys.List = append(options.IgnoreDestroys.List, exemptions.Destroys...)
+		return nil
+	}
+}
+
+// recordTest records the phases of a test in testReport, with the resource counts of its plan fixture when it has one
+func recordTest(t *testing.T, fixture string) *timing.Test {
+	test := testReport.Test(t.Name())
+	if fixture != "" {
+		plan, err := tfplan.LoadPlan(filepath.Join("fixtures", "plans", fixture+".json"))
+		require.NoError(t, err)
+		test.SetResources(timing.ResourceCounts(plan))
+	}
+	t.Cleanup(func() { test.Finish(t.Failed(), t.Skipped()) })
+	return test
+}
+
+// marking returns a hook that marks the start of a phase before calling the hook it replaces
+func marking[O any](mark func(), hook func(O) error) func(O) error {
+	return func(options O) error {
+		mark()
+		if hook != nil {
+			return hook(options)
+		}
+		return nil
+	}
+}
+
+// recordPhases marks the phases of a run of testhelper at its hooks. The run is in the plan phase until its apply;
+// afterApply is the phase between the apply and the destroy, the consistency check or the upgrade.
+func recordPhases(test *timing.Test, options *testhelper.TestOptions, afterApply timing.Phase) {
+	test.Begin(timing.Plan)
+	options.PreApplyHook = marking(func() { test.Begin(timing.Apply) }, options.PreApplyHook)
+	options.PostApplyHook = marking(func() { test.Begin(afterApply) }, options.PostApplyHook)
+	options.PreDestroyHook = marking(func() { test.Begin(timing.Destroy) }, options.PreDestroyHook)
+	options.PostDestroyHook = marking(func() { test.End(nil) }, options.PostDestroyHook)
+}
+
+// recordSchematicPhases marks the phases of a run of testschematic at its hooks, the run being in the Schematics upload
+// phase until its apply
+func recordSchematicPhases(test *timing.Test, options *testschematic.TestSchematicOptions, afterApply timing.Phase) {
+	test.Begin(timing.SchematicsUpload)
+	options.PreApplyHook = marking(func() { test.Begin(timing.Apply) }, options.PreApplyHook)
+	options.PostApplyHook = marking(func() { test.Begin(afterApply) }, options.PostApplyHook)
+	options.PreDestroyHook = marking(func() { test.Begin(timing.Destroy) }, options.PreDestroyHook)
+	options.PostDestroyHook = marking(func() { test.End(nil) }, options.PostDestroyHook)
+}
+
+// recordAddonPhases marks the phases of a run of testaddons at its hooks, the run being in the plan phase while the
+// project of the addon and its dependencies is created and validated, until the deploy
+func recordAddonPhases(test *timing.Test, options *testaddons.TestAddonOptions) {
+	test.Begin(timing.Plan)
+	options.PreDeployHook = marking(func() { test.Begin(timing.Apply) }, options.PreDeployHook)
+	options.PreUndeployHook = marking(func() { test.Begin(timing.Destroy) }, options.PreUndeployHook)
+	options.PostUndeployHook = marking(func() { test.End(nil) }, options.PostUndeployHook)
+}
+
+// selectRegion picks the least loaded region meeting the requirements of the test and logs the choice. The region
+// no longer counts as load when the test ends.
+func selectRegion(t *testing.T, requirements testregion.Requirements) string {
+	requirements.Test = t.Name()
+	selected, err := regionSelector.Select(context.Background(), t, requirements)
+	require.NoError(t, err)
+	t.Cleanup(func() { regionSelector.Release(selected) })
+	return selected
+}
+
+// retryTerraformErrors gives the rules of retryPolicy to terratest as its retryable errors once the wrapper created the
+// Terraform options of a test, so its apply, consistency plan and destroy are retried on the transient errors of the
+// VPC API as well
+func retryTerraformErrors(options *testhelper.TestOptions) {
+	options.PreApplyHook = marking(func() {
+		retryable := retryPolicy.TerraformErrors()
+		maps.Copy(retryable, options.TerraformOptions.RetryableTerraformErrors)
+		options.TerraformOptions.RetryableTerraformErrors = retryable
+		options.TerraformOptions.MaxRetries, options.TerraformOptions.TimeBetweenRetries = retryPolicy.TerraformRetries()
+	}, options.PreApplyHook)
+}
+
 func setupOptions(t *testing.T, dir string, prefix string) *testhelper.TestOptions {
+	return setupOptionsInRegion(t, dir, prefix, region)
+}
+
+func setupOptionsInRegion(t *testing.T, dir string, prefix string, region string) *testhelper.TestOptions {
 	options := testhelper.TestOptionsDefaultWithVars(&testhelper.TestOptions{
 		Testing:       t,
 		TerraformDir:  dir,
@@ -76,7 +579,7 @@ func setupOptions(t *testing.T, dir string, prefix string) *testhelper.TestOptio
 		ResourceGroup: resourceGroup,
 		Region:        region,
 		TerraformVars: map[string]interface{}{
-			"access_tags": permanentResources["accessTags"],
+			"access_tags": permanentResources.AccessTags,
 		},
 	})
 	// need to ignore because of a provider issue: https://github.com/IBM-Cloud/terraform-provider-ibm/issues/5527
@@ -87,6 +590,7 @@ func setupOptions(t *testing.T, dir string, prefix string) *testhelper.TestOptio
 			fmt.Sprintf("module.slz_vsi.ibm_is_volume.volume[\"%s-vpc-subnet-c-0-%s\"]", options.Prefix, options.Prefix),
 		},
 	}
+	retryTerraformErrors(options)
 
 	return options
 }
@@ -95,23 +599,37 @@ func TestRunCompleteExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "complete")
+	checkCost(t, "complete")
+	acquireQuota(t, region, "complete")
 
 	options := setupOptions(t, completeExampleTerraformDir, "slz-vsi-com")
+	// DRIFT_SIMULATION=true changes the applied resources out of band and checks the module repairs or ignores each change
+	if strings.ToLower(os.Getenv("DRIFT_SIMULATION")) == "true" {
+		// the security group rules the simulation edits are only created by the dedicated host call of the example
+		options.TerraformVars["enable_dedicated_host"] = true
+		options.PostApplyHook = simulateDrift
+	}
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "complete"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
 
 func TestRunCompleteUpgradeExample(t *testing.T) {
 
 	options := setupOptions(t, completeExampleTerraformDir, "slz-vsi-com-upg")
+	recordPhases(recordTest(t, "complete"), options, timing.Upgrade)
 
 	output, err := options.RunTestUpgrade()
 
 	if !options.UpgradeTestSkipped {
-		assert.Nil(t, err, "This should not have errored")
-		assert.NotNil(t, output, "Expected some output")
+		assertNoFailure(t, err)
+		if assert.NotNil(t, output, "Expected some output") {
+			checkUpgrade(t, &output.RawPlan)
+		}
 	}
 }
 
@@ -124,8 +642,8 @@ func setupFSCloudOptions(t *testing.T, prefix string) *testhelper.TestOptions {
 		Region:        region,
 		TerraformVars: map[string]interface{}{
 			"skip_iam_authorization_policy": true, // The test account already has got a s2s policy setup that would clash
-			"boot_volume_encryption_key":    permanentResources["hpcs_south_root_key_crn"],
-			"access_tags":                   permanentResources["accessTags"],
+			"boot_volume_encryption_key":    permanentResources.HPCSSouthRootKeyCRN,
+			"access_tags":                   permanentResources.AccessTags,
 		},
 	})
 	// need to ignore because of a provider issue: https://github.com/IBM-Cloud/terraform-provider-ibm/issues/5527
@@ -136,6 +654,7 @@ func setupFSCloudOptions(t *testing.T, prefix string) *testhelper.TestOptions {
 			fmt.Sprintf("module.slz_vsi.module.fscloud_vsi.ibm_is_volume.volume[\"%s-vpc-subnet-c-0-%s\"]", options.Prefix, options.Prefix),
 		},
 	}
+	retryTerraformErrors(options)
 	return options
 }
 
@@ -143,11 +662,16 @@ func TestRunFSCloudExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "fscloud")
+	checkCost(t, "fscloud")
+	acquireQuota(t, region, "fscloud")
 
 	options := setupFSCloudOptions(t, "slz-vsi-fscloud")
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "fscloud"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored.")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
 
@@ -155,26 +679,32 @@ func TestRunExistingSnapshotGroupExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "snapshot")
+	snapshotRegion := selectRegion(t, testregion.Requirements{Regions: []string{"au-syd"}}) // pinned due to image requirement
+	acquireQuota(t, snapshotRegion, "snapshot")
 
-	snapGroupId := permanentResources["snapshot_group_au_syd_group_id"]
+	snapGroupId := permanentResources.SnapshotGroupAuSydGroupID
 
 	options := testhelper.TestOptionsDefaultWithVars(&testhelper.TestOptions{
 		Testing:       t,
 		TerraformDir:  snapshotExampleTerraformDir,
 		Prefix:        "slz-vsi-snap",
 		ResourceGroup: resourceGroup,
-		Region:        "au-syd", // hardcode due to image requirement
+		Region:        snapshotRegion,
 		TerraformVars: map[string]interface{}{
-			"access_tags":                   permanentResources["accessTags"],
+			"access_tags":                   permanentResources.AccessTags,
 			"snapshot_consistency_group_id": snapGroupId,
 		},
 	})
 
 	// Add a post-apply verification
 	options.PostApplyHook = verifyVolumeSnapshots
+	retryTerraformErrors(options)
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "snapshot"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored.")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 
 }
@@ -185,9 +715,9 @@ func verifyVolumeSnapshots(options *testhelper.TestOptions) error {
 		options.Testing.Logf("DEBUG: value of global pr_test variable is: %s", snapshotExampleTerraformDir)
 	}
 
-	snapBootId := permanentResources["snapshot_group_au_syd_boot_crn"]
-	snapVol1Id := permanentResources["snapshot_group_au_syd_vol1_crn"]
-	snapVol2Id := permanentResources["snapshot_group_au_syd_vol2_crn"]
+	snapBootId := permanentResources.SnapshotGroupAuSydBootCRN
+	snapVol1Id := permanentResources.SnapshotGroupAuSydVol1CRN
+	snapVol2Id := permanentResources.SnapshotGroupAuSydVol2CRN
 
 	options.Testing.Log("====== START VERIFY OF SNAPSHOTS ========")
 
@@ -209,6 +739,66 @@ func verifyVolumeSnapshots(options *testhelper.TestOptions) error {
 	return nil
 }
 
+// simulateDrift performs the mutations of drift-mutations.yaml on the applied example and logs the result of each one
+func simulateDrift(options *testhelper.TestOptions) error {
+	mutations, err := drift.LoadMutations("drift-mutations.yaml")
+	if err != nil {
+		return err
+	}
+	client, err := drift.NewVPCClient(os.Getenv("TF_VAR_ibmcloud_api_key"), options.Region)
+	if err != nil {
+		return err
+	}
+	report, err := drift.Run(context.Background(), terratestDrift{t: options.Testing, options: options.TerraformOptions}, client, mutations.Mutations)
+	if report != nil {
+		options.Testing.Logf("drift simulation:\n%s", report)
+	}
+	if err != nil {
+		return err
+	}
+	assert.False(options.Testing, report.Failed(), "out-of-band changes were not repaired or ignored as expected")
+	return nil
+}
+
+// terratestDrift plans and applies the example of the drift simulation with terratest
+type terratestDrift struct {
+	t       *testing.T
+	options *terraform.Options
+}
+
+func (d terratestDrift) State(ctx context.Context) (*tfjson.State, error) {
+	options := *d.options
+	options.PlanFilePath = ""
+	out, err := terraform.ShowContextE(d.t, ctx, &options)
+	if err != nil {
+		return nil, err
+	}
+	state := &tfjson.State{}
+	if err := json.Unmarshal([]byte(out), state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (d terratestDrift) Plan(ctx context.Context) (*tfjson.Plan, error) {
+	options := *d.options
+	options.PlanFilePath = filepath.Join(d.t.TempDir(), "drift.tfplan")
+	plan, err := terraform.InitAndPlanAndShowWithStructContextE(d.t, ctx, &options)
+	if err != nil {
+		return nil, err
+	}
+	return &plan.RawPlan, nil
+}
+
+func (d terratestDrift) Apply(ctx context.Context) error {
+	options := *d.options
+	options.PlanFilePath = ""
+	_, err := retry.NewRunner(retryPolicy, d.t.Logf).Run(ctx, "terraform apply (drift)", func() (string, error) {
+		return terraform.ApplyContextE(d.t, ctx, &options)
+	})
+	return err
+}
+
 func sshPublicKey(t *testing.T) string {
 	pubKey, keyErr := common.GenerateSshRsaPublicKey()
 
@@ -218,7 +808,40 @@ func sshPublicKey(t *testing.T) string {
 	return pubKey
 }
 
-func provisionPreReq(t *testing.T, create_vpc bool) (string, *terraform.Options, error) {
+// terratestPrereq runs the prerequisites of internal/prereq with terratest, in a workspace of the temp directory
+type terratestPrereq struct {
+	t       *testing.T
+	options *terraform.Options
+}
+
+func (p terratestPrereq) Init(ctx context.Context) error {
+	_, err := retry.NewRunner(retryPolicy, p.t.Logf).Run(ctx, "terraform init (prereq)", func() (string, error) {
+		return terraform.InitContextE(p.t, ctx, p.options)
+	})
+	return err
+}
+
+func (p terratestPrereq) Apply(ctx context.Context) error {
+	_, err := retry.NewRunner(retryPolicy, p.t.Logf).Run(ctx, "terraform apply (prereq)", func() (string, error) {
+		return terraform.ApplyContextE(p.t, ctx, p.options)
+	})
+	return err
+}
+
+func (p terratestPrereq) Outputs(ctx context.Context) (map[string]interface{}, error) {
+	return terraform.OutputAllContextE(p.t, ctx, p.options)
+}
+
+func (p terratestPrereq) Destroy(ctx context.Context) error {
+	_, err := retry.NewRunner(retryPolicy, p.t.Logf).Run(ctx, "terraform destroy (prereq)", func() (string, error) {
+		return terraform.DestroyContextE(p.t, ctx, p.options)
+	})
+	return err
+}
+
+// provisionPreReq applies existing-resources in the region, a test selects it with selectRegion and acquires the
+// quota of the prerequisites and of its solution there first
+func provisionPreReq(t *testing.T, region string, create_vpc bool) (string, *terraform.Options, error) {
 	// ------------------------------------------------------------------------------------
 	// Provision existing resources first
 	// ------------------------------------------------------------------------------------
@@ -232,24 +855,20 @@ func provisionPreReq(t *testing.T, create_vpc bool) (string, *terraform.Options,
 	val, present := os.LookupEnv(checkVariable)
 	require.True(t, present, checkVariable+" environment variable not set")
 	require.NotEqual(t, "", val, checkVariable+" environment variable is empty")
-	region, _ := testhelper.GetBestVpcRegion(val, "../common-dev-assets/common-go-assets/cloudinfo-region-vpc-gen2-prefs.yaml", "eu-de")
 
 	logger.Log(t, "Tempdir: ", tempTerraformDir)
-	existingTerraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+	config := prereq.Config{Prefix: prefix, Region: region, ResourceTags: tags, CreateVPC: create_vpc}
+	// the transient errors are retried with retryPolicy by terratestPrereq, instead of the retryable errors of terratest
+	existingTerraformOptions := &terraform.Options{
 		TerraformDir: tempTerraformDir,
-		Vars: map[string]interface{}{
-			"prefix":        prefix,
-			"region":        region,
-			"resource_tags": tags,
-			"create_vpc":    create_vpc,
-		},
+		Vars:         config.Vars(),
 		// Set Upgrade to true to ensure latest version of providers and modules are used by terratest.
 		// This is the same as setting the -upgrade=true flag with terraform.
 		Upgrade: true,
-	})
+	}
 
 	terraform.WorkspaceSelectOrNewContext(t, context.Background(), existingTerraformOptions, prefix)
-	_, existErr := terraform.InitAndApplyContextE(t, context.Background(), existingTerraformOptions)
+	_, existErr := prereq.Provision(context.Background(), terratestPrereq{t: t, options: existingTerraformOptions})
 	if existErr != nil {
 		// assert.True(t, existErr == nil, "Init and Apply of temp existing resource failed")
 		return "", nil, existErr
@@ -257,13 +876,29 @@ func provisionPreReq(t *testing.T, create_vpc bool) (string, *terraform.Options,
 	return prefix, existingTerraformOptions, nil
 }
 
+// destroyPreReq destroys the resources of provisionPreReq and deletes its workspace
+func destroyPreReq(t *testing.T, existingTerraformOptions *terraform.Options, prefix string) {
+	logger.Log(t, "START: Destroy (prereq resources)")
+	err := prereq.Teardown(context.Background(), terratestPrereq{t: t, options: existingTerraformOptions})
+	assert.NoError(t, err)
+	terraform.WorkspaceDeleteContext(t, context.Background(), existingTerraformOptions, prefix)
+	logger.Log(t, "END: Destroy (prereq resources)")
+}
+
 // Test the fully-configurable DA with defaults
 func TestFullyConfigurable(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkCost(t, "fully-configurable")
+	prereqRegion := selectRegion(t, testregion.Requirements{})
+	// the VPC of the prerequisites is not in the plan fixture of the solution
+	acquireQuota(t, prereqRegion, "fully-configurable", quota.Demand{quota.VPCs: 1})
 
-	prefix, existingTerraformOptions, existErr := provisionPreReq(t, true)
+	test := recordTest(t, "fully-configurable")
+	test.Begin(timing.PrereqApply)
+	prefix, existingTerraformOptions, existErr := provisionPreReq(t, prereqRegion, true)
+	test.End(existErr)
 
 	if existErr != nil {
 		assert.True(t, existErr == nil, "Init and Apply of temp existing resource failed")
@@ -290,15 +925,17 @@ func TestFullyConfigurable(t *testing.T) {
 			{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 			{Name: "existing_resource_group_name", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "resource_group_name"), DataType: "string"},
 			{Name: "vsi_resource_tags", Value: options.Tags, DataType: "list(string)"},
-			{Name: "vsi_access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+			{Name: "vsi_access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 			{Name: "prefix", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "prefix"), DataType: "string"},
 			{Name: "existing_vpc_crn", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "vpc_crn"), DataType: "string"},
 			{Name: "existing_subnet_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "subnet_id"), DataType: "string"},
 			{Name: "image_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "image_id"), DataType: "string"},
-			{Name: "existing_secrets_manager_instance_crn", Value: permanentResources["secretsManagerCRN"], DataType: "string"},
+			{Name: "existing_secrets_manager_instance_crn", Value: permanentResources.SecretsManagerCRN, DataType: "string"},
 		}
+		recordSchematicPhases(test, options, timing.ConsistencyCheck)
+		assertNoSchematicFailure := checkSchematicFailure(t, options)
 		err := options.RunSchematicTest()
-		assert.Nil(t, err, "This should not have errored")
+		assertNoSchematicFailure(err)
 	}
 
 	// Check if "DO_NOT_DESTROY_ON_FAILURE" is set
@@ -307,10 +944,9 @@ func TestFullyConfigurable(t *testing.T) {
 	if t.Failed() && strings.ToLower(envVal) == "true" {
 		fmt.Println("Terratest failed. Debug the test and delete resources manually.")
 	} else {
-		logger.Log(t, "START: Destroy (prereq resources)")
-		terraform.DestroyContext(t, context.Background(), existingTerraformOptions)
-		terraform.WorkspaceDeleteContext(t, context.Background(), existingTerraformOptions, prefix)
-		logger.Log(t, "END: Destroy (prereq resources)")
+		test.Begin(timing.PrereqDestroy)
+		destroyPreReq(t, existingTerraformOptions, prefix)
+		test.End(nil)
 	}
 }
 
@@ -321,8 +957,14 @@ func TestExistingKeyFullyConfigurable(t *testing.T) {
 	defer releaseTestSlot()
 
 	sshPublicKey := sshPublicKey(t)
+	prereqRegion := selectRegion(t, testregion.Requirements{})
+	// the VPC of the prerequisites is not in the plan fixture of the solution
+	acquireQuota(t, prereqRegion, "fully-configurable", quota.Demand{quota.VPCs: 1})
 
-	prefix, existingTerraformOptions, existErr := provisionPreReq(t, true)
+	test := recordTest(t, "fully-configurable")
+	test.Begin(timing.PrereqApply)
+	prefix, existingTerraformOptions, existErr := provisionPreReq(t, prereqRegion, true)
+	test.End(existErr)
 
 	if existErr != nil {
 		assert.True(t, existErr == nil, "Init and Apply of temp existing resource failed")
@@ -349,19 +991,21 @@ func TestExistingKeyFullyConfigurable(t *testing.T) {
 			{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 			{Name: "existing_resource_group_name", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "resource_group_name"), DataType: "string"},
 			{Name: "vsi_resource_tags", Value: options.Tags, DataType: "list(string)"},
-			{Name: "vsi_access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+			{Name: "vsi_access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 			{Name: "prefix", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "prefix"), DataType: "string"},
 			{Name: "existing_vpc_crn", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "vpc_crn"), DataType: "string"},
 			{Name: "existing_subnet_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "subnet_id"), DataType: "string"},
 			{Name: "image_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "image_id"), DataType: "string"},
-			{Name: "existing_boot_volume_kms_key_crn", Value: permanentResources["hpcs_south_root_key_crn"], DataType: "string"},
+			{Name: "existing_boot_volume_kms_key_crn", Value: permanentResources.HPCSSouthRootKeyCRN, DataType: "string"},
 			{Name: "skip_block_storage_kms_iam_auth_policy", Value: true, DataType: "bool"}, // The test account already has got a s2s policy setup that would clash
 			{Name: "kms_encryption_enabled_boot_volume", Value: true, DataType: "bool"},
 			{Name: "auto_generate_ssh_key", Value: false, DataType: "bool"},
 			{Name: "ssh_public_keys", Value: []string{sshPublicKey}, DataType: "list(string)"},
 		}
+		recordSchematicPhases(test, options, timing.ConsistencyCheck)
+		assertNoSchematicFailure := checkSchematicFailure(t, options)
 		err := options.RunSchematicTest()
-		assert.Nil(t, err, "This should not have errored")
+		assertNoSchematicFailure(err)
 	}
 
 	// Check if "DO_NOT_DESTROY_ON_FAILURE" is set
@@ -370,10 +1014,9 @@ func TestExistingKeyFullyConfigurable(t *testing.T) {
 	if t.Failed() && strings.ToLower(envVal) == "true" {
 		fmt.Println("Terratest failed. Debug the test and delete resources manually.")
 	} else {
-		logger.Log(t, "START: Destroy (prereq resources)")
-		terraform.DestroyContext(t, context.Background(), existingTerraformOptions)
-		terraform.WorkspaceDeleteContext(t, context.Background(), existingTerraformOptions, prefix)
-		logger.Log(t, "END: Destroy (prereq resources)")
+		test.Begin(timing.PrereqDestroy)
+		destroyPreReq(t, existingTerraformOptions, prefix)
+		test.End(nil)
 	}
 }
 
@@ -382,8 +1025,14 @@ func TestUpgradeFullyConfigurable(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	prereqRegion := selectRegion(t, testregion.Requirements{})
+	// the VPC of the prerequisites is not in the plan fixture of the solution
+	acquireQuota(t, prereqRegion, "fully-configurable", quota.Demand{quota.VPCs: 1})
 
-	prefix, existingTerraformOptions, existErr := provisionPreReq(t, true)
+	test := recordTest(t, "fully-configurable")
+	test.Begin(timing.PrereqApply)
+	prefix, existingTerraformOptions, existErr := provisionPreReq(t, prereqRegion, true)
+	test.End(existErr)
 
 	if existErr != nil {
 		assert.True(t, existErr == nil, "Init and Apply of temp existing resource failed")
@@ -411,17 +1060,20 @@ func TestUpgradeFullyConfigurable(t *testing.T) {
 			{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 			{Name: "existing_resource_group_name", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "resource_group_name"), DataType: "string"},
 			{Name: "vsi_resource_tags", Value: options.Tags, DataType: "list(string)"},
-			{Name: "vsi_access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+			{Name: "vsi_access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 			{Name: "prefix", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "prefix"), DataType: "string"},
 			{Name: "existing_vpc_crn", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "vpc_crn"), DataType: "string"},
 			{Name: "existing_subnet_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "subnet_id"), DataType: "string"},
 			{Name: "image_id", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "image_id"), DataType: "string"},
-			{Name: "existing_secrets_manager_instance_crn", Value: permanentResources["secretsManagerCRN"], DataType: "string"},
+			{Name: "existing_secrets_manager_instance_crn", Value: permanentResources.SecretsManagerCRN, DataType: "string"},
 			{Name: "kms_encryption_enabled_boot_volume", Value: true, DataType: "bool"},
-			{Name: "existing_kms_instance_crn", Value: permanentResources["hpcs_south_crn"], DataType: "string"},
+			{Name: "existing_kms_instance_crn", Value: permanentResources.HPCSSouthCRN, DataType: "string"},
 		}
+		recordSchematicPhases(test, options, timing.Upgrade)
+		assertNoSchematicFailure := checkSchematicFailure(t, options)
+		checkSchematicUpgrade(t, options)
 		err := options.RunSchematicUpgradeTest()
-		assert.Nil(t, err, "This should not have errored")
+		assertNoSchematicFailure(err)
 	}
 
 	// Check if "DO_NOT_DESTROY_ON_FAILURE" is set
@@ -430,10 +1082,9 @@ func TestUpgradeFullyConfigurable(t *testing.T) {
 	if t.Failed() && strings.ToLower(envVal) == "true" {
 		fmt.Println("Terratest failed. Debug the test and delete resources manually.")
 	} else {
-		logger.Log(t, "START: Destroy (prereq resources)")
-		terraform.DestroyContext(t, context.Background(), existingTerraformOptions)
-		terraform.WorkspaceDeleteContext(t, context.Background(), existingTerraformOptions, prefix)
-		logger.Log(t, "END: Destroy (prereq resources)")
+		test.Begin(timing.PrereqDestroy)
+		destroyPreReq(t, existingTerraformOptions, prefix)
+		test.End(nil)
 	}
 }
 
@@ -444,6 +1095,9 @@ func TestRunMultiProfileExample(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkProfiles(t, "multi-profile-one-vpc")
+	checkCost(t, "multi-profile-one-vpc")
+	acquireQuota(t, region, "multi-profile-one-vpc")
 
 	options := testhelper.TestOptionsDefaultWithVars(&testhelper.TestOptions{
 		Testing:       t,
@@ -452,12 +1106,15 @@ func TestRunMultiProfileExample(t *testing.T) {
 		ResourceGroup: resourceGroup,
 		Region:        region,
 		TerraformVars: map[string]interface{}{
-			"access_tags": permanentResources["accessTags"],
+			"access_tags": permanentResources.AccessTags,
 		},
 	})
+	retryTerraformErrors(options)
+	checkIdempotency(t, options)
+	recordPhases(recordTest(t, "multi-profile-one-vpc"), options, timing.ConsistencyCheck)
 
 	output, err := options.RunTestConsistency()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoFailure(t, err)
 	assert.NotNil(t, output, "Expected some output")
 }
 
@@ -466,8 +1123,16 @@ func TestAddonDefaultConfiguration(t *testing.T) {
 	acquireTestSlot()
 	defer releaseTestSlot()
 
+	// the VPC of the slz-vpc dependency is not in the plan fixture of the solution, the Secrets Manager trial is avoided
+	// by using the existing instance
+	prereqRegion := selectRegion(t, testregion.Requirements{})
+	acquireQuota(t, prereqRegion, "fully-configurable", quota.Demand{quota.VPCs: 1})
+
 	// run this terraform code to return the latest ubuntu image ID
-	prefix, existingTerraformOptions, existErr := provisionPreReq(t, false)
+	test := recordTest(t, "fully-configurable")
+	test.Begin(timing.PrereqApply)
+	prefix, existingTerraformOptions, existErr := provisionPreReq(t, prereqRegion, false)
+	test.End(existErr)
 
 	if existErr != nil {
 		assert.True(t, existErr == nil, "Init and Apply of prereq script failed.")
@@ -497,7 +1162,7 @@ func TestAddonDefaultConfiguration(t *testing.T) {
 				OfferingName:   "deploy-arch-ibm-secrets-manager",
 				OfferingFlavor: "fully-configurable",
 				Inputs: map[string]interface{}{
-					"existing_secrets_manager_crn":         permanentResources["privateOnlySecMgrCRN"],
+					"existing_secrets_manager_crn":         permanentResources.PrivateOnlySecretsMgrCRN,
 					"service_plan":                         "__NULL__", // no plan value needed when using existing SM
 					"skip_secrets_manager_iam_auth_policy": true,       // since using an existing Secrets Manager instance, attempting to re-create auth policy can cause conflicts if the policy already exists
 					"secret_groups":                        []string{}, // passing empty array for secret groups as default value is creating general group and it will cause conflicts as we are using an existing SM
@@ -518,8 +1183,26 @@ func TestAddonDefaultConfiguration(t *testing.T) {
 					"enable_activity_tracker_event_routing_to_cloud_logs": false,
 				},
 			},
+			// the other dependencies of the flavor are deployed with their defaults
+			{
+				OfferingName:   "deploy-arch-ibm-slz-vpc",
+				OfferingFlavor: "fully-configurable",
+			},
+			{
+				OfferingName:   "deploy-arch-ibm-kms",
+				OfferingFlavor: "fully-configurable",
+			},
+			{
+				OfferingName:   "deploy-arch-ibm-cloud-logs",
+				OfferingFlavor: "fully-configurable",
+			},
+			{
+				OfferingName:   "deploy-arch-ibm-scc-workload-protection",
+				OfferingFlavor: "fully-configurable",
+			},
 		}
 
+		recordAddonPhases(test, options)
 		err := options.RunAddonTest()
 		require.NoError(t, err)
 	}
@@ -529,6 +1212,9 @@ func TestQuickstartDefaultConfigSchematics(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	checkCost(t, "quickstart")
+	// the quickstart creates its VPC in its default vpc_region, us-south
+	acquireQuota(t, region, "quickstart")
 
 	options := testschematic.TestSchematicOptionsDefault(&testschematic.TestSchematicOptions{
 		Testing: t,
@@ -546,17 +1232,20 @@ func TestQuickstartDefaultConfigSchematics(t *testing.T) {
 	options.TerraformVars = []testschematic.TestSchematicTerraformVar{
 		{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 		{Name: "resource_tags", Value: options.Tags, DataType: "list(string)"},
-		{Name: "access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+		{Name: "access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 		{Name: "prefix", Value: options.Prefix, DataType: "string"},
 	}
+	recordSchematicPhases(recordTest(t, "quickstart"), options, timing.ConsistencyCheck)
+	assertNoSchematicFailure := checkSchematicFailure(t, options)
 	err := options.RunSchematicTest()
-	assert.Nil(t, err, "This should not have errored")
+	assertNoSchematicFailure(err)
 }
 
 func TestQuickstartDefaultConfigUpgradeSchematics(t *testing.T) {
 	t.Parallel()
 	acquireTestSlot()
 	defer releaseTestSlot()
+	acquireQuota(t, region, "quickstart")
 
 	options := testschematic.TestSchematicOptionsDefault(&testschematic.TestSchematicOptions{
 		Testing: t,
@@ -574,12 +1263,15 @@ func TestQuickstartDefaultConfigUpgradeSchematics(t *testing.T) {
 	options.TerraformVars = []testschematic.TestSchematicTerraformVar{
 		{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 		{Name: "resource_tags", Value: options.Tags, DataType: "list(string)"},
-		{Name: "access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+		{Name: "access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 		{Name: "prefix", Value: options.Prefix, DataType: "string"},
 	}
+	recordSchematicPhases(recordTest(t, "quickstart"), options, timing.Upgrade)
+	assertNoSchematicFailure := checkSchematicFailure(t, options)
+	checkSchematicUpgrade(t, options)
 	err := options.RunSchematicUpgradeTest()
 	if !options.UpgradeTestSkipped {
-		assert.Nil(t, err, "This should not have errored")
+		assertNoSchematicFailure(err)
 	}
 }
 
@@ -588,7 +1280,14 @@ func TestQuickstartExistingConfigSchematics(t *testing.T) {
 	acquireTestSlot()
 	defer releaseTestSlot()
 
-	prefix, existingTerraformOptions, existErr := provisionPreReq(t, true)
+	// the quickstart uses the VPC of the prerequisites instead of the one of its plan fixture
+	prereqRegion := selectRegion(t, testregion.Requirements{})
+	acquireQuota(t, prereqRegion, "quickstart")
+
+	test := recordTest(t, "quickstart")
+	test.Begin(timing.PrereqApply)
+	prefix, existingTerraformOptions, existErr := provisionPreReq(t, prereqRegion, true)
+	test.End(existErr)
 
 	if existErr != nil {
 		assert.True(t, existErr == nil, "Init and Apply of temp existing resource failed")
@@ -612,12 +1311,14 @@ func TestQuickstartExistingConfigSchematics(t *testing.T) {
 		options.TerraformVars = []testschematic.TestSchematicTerraformVar{
 			{Name: "ibmcloud_api_key", Value: options.RequiredEnvironmentVars["TF_VAR_ibmcloud_api_key"], DataType: "string", Secure: true},
 			{Name: "resource_tags", Value: options.Tags, DataType: "list(string)"},
-			{Name: "access_tags", Value: permanentResources["accessTags"], DataType: "list(string)"},
+			{Name: "access_tags", Value: permanentResources.AccessTags, DataType: "list(string)"},
 			{Name: "prefix", Value: options.Prefix, DataType: "string"},
 			{Name: "existing_vpc_crn", Value: terraform.OutputContext(t, context.Background(), existingTerraformOptions, "vpc_crn"), DataType: "string"},
 		}
+		recordSchematicPhases(test, options, timing.ConsistencyCheck)
+		assertNoSchematicFailure := checkSchematicFailure(t, options)
 		err := options.RunSchematicTest()
-		assert.Nil(t, err, "This should not have errored")
+		assertNoSchematicFailure(err)
 	}
 
 	// Check if "DO_NOT_DESTROY_ON_FAILURE" is set
@@ -626,9 +1327,8 @@ func TestQuickstartExistingConfigSchematics(t *testing.T) {
 	if t.Failed() && strings.ToLower(envVal) == "true" {
 		fmt.Println("Terratest failed. Debug the test and delete resources manually.")
 	} else {
-		logger.Log(t, "START: Destroy (prereq resources)")
-		terraform.DestroyContext(t, context.Background(), existingTerraformOptions)
-		terraform.WorkspaceDeleteContext(t, context.Background(), existingTerraformOptions, prefix)
-		logger.Log(t, "END: Destroy (prereq resources)")
+		test.Begin(timing.PrereqDestroy)
+		destroyPreReq(t, existingTerraformOptions, prefix)
+		test.End(nil)
 	}
 }
@@ -7,13 +7,11 @@
 
 set -e
 
-TERRAFORM_SOURCE_DIR="tests/existing-resources"
-TF_VARS_FILE="terraform.tfvars"
+# tests/cmd/prereq destroys tests/existing-resources with the variables pre-validation-deploy-vpc.sh saved
+if [ -n "${PREREQ_BIN}" ]; then
+  "${PREREQ_BIN}" teardown -module-dir "$(pwd)"
+else
+  (cd tests && go run ./cmd/prereq teardown -module-dir ..)
+fi
 
-(
-  cd ${TERRAFORM_SOURCE_DIR}
-  echo "Destroying prerequisite VPC .."
-  terraform destroy -input=false -auto-approve -var-file=${TF_VARS_FILE} || exit 1
-
-  echo "Post-validation completed successfully"
-)
+echo "Post-validation completed successfully"
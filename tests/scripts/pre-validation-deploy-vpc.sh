@@ -8,45 +8,15 @@
 set -e
 
 DA_DIR="solutions/fully-configurable"
-TERRAFORM_SOURCE_DIR="tests/existing-resources"
-JSON_FILE="${DA_DIR}/catalogValidationValues.json"
 REGION="us-south"
-TF_VARS_FILE="terraform.tfvars"
 
-(
-  cwd=$(pwd)
-  cd ${TERRAFORM_SOURCE_DIR}
-  echo "Provisioning prerequisite secret manager instance and VPC .."
-  terraform init || exit 1
-  # $VALIDATION_APIKEY is available in the catalog runtime
-  {
-    echo "ibmcloud_api_key=\"${VALIDATION_APIKEY}\""
-    echo "region=\"${REGION}\""
-    echo "prefix=\"vsi-$(openssl rand -hex 2)\""
-  } >>${TF_VARS_FILE}
-  terraform apply -input=false -auto-approve -var-file=${TF_VARS_FILE} || exit 1
+# tests/cmd/prereq provisions tests/existing-resources and appends its outputs to ${DA_DIR}/catalogValidationValues.json,
+# the same way provisionPreReq in tests/pr_test.go does. $VALIDATION_APIKEY is available in the catalog runtime.
+# Set PREREQ_BIN to a binary built with "go build ./cmd/prereq" when the runtime has no Go toolchain.
+if [ -n "${PREREQ_BIN}" ]; then
+  "${PREREQ_BIN}" provision -module-dir "$(pwd)" -solution "${DA_DIR}" -region "${REGION}"
+else
+  (cd tests && go run ./cmd/prereq provision -module-dir .. -solution "${DA_DIR}" -region "${REGION}")
+fi
 
-  existing_resource_group_name="existing_resource_group_name"
-  existing_resource_group_value=$(terraform output -state=terraform.tfstate -raw resource_group_name)
-  existing_vpc_name="existing_vpc_crn"
-  existing_vpc_value=$(terraform output -state=terraform.tfstate -raw vpc_crn)
-  existing_subnet_name="existing_subnet_id"
-  existing_subnet_value=$(terraform output -state=terraform.tfstate -raw subnet_id)
-  existing_image_name="image_id"
-  existing_image_value=$(terraform output -state=terraform.tfstate -raw image_id)
-
-  echo "Appending '${existing_resource_group_name}' and '${existing_vpc_name}' input variable values to ${JSON_FILE}.."
-
-  cd "${cwd}"
-  jq -r --arg existing_resource_group_name "${existing_resource_group_name}" \
-    --arg existing_resource_group_value "${existing_resource_group_value}" \
-    --arg existing_vpc_name "${existing_vpc_name}" \
-    --arg existing_vpc_value "${existing_vpc_value}" \
-    --arg existing_subnet_name "${existing_subnet_name}" \
-    --arg existing_subnet_value "${existing_subnet_value}" \
-    --arg existing_image_name "${existing_image_name}" \
-    --arg existing_image_value "${existing_image_value}" \
-    '. + {($existing_resource_group_name): $existing_resource_group_value, ($existing_vpc_name): $existing_vpc_value, ($existing_subnet_name): $existing_subnet_value, ($existing_image_name): $existing_image_value}' "${JSON_FILE}" >tmpfile && mv tmpfile "${JSON_FILE}" || exit 1
-
-  echo "Pre-validation complete successfully"
-)
+echo "Pre-validation complete successfully"